@@ -19,6 +19,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -37,6 +38,10 @@ type NvidiaManager struct {
 	// true if the NVML library (libnvidia-ml.so.1) was loaded successfully
 	nvmlInitialized bool
 
+	// true if GetGPUs failed to load NVML, it is not tried again so that the
+	// library isn't loaded on every update of the machine information
+	nvmlLoadFailed bool
+
 	// nvidiaDevices is a map from device minor number to a handle that can be used to get metrics about the device
 	nvidiaDevices map[int]nvml.Device
 }
@@ -54,7 +59,12 @@ func (nm *NvidiaManager) Setup() {
 
 	nm.devicesPresent = true
 
-	initializeNVML(nm)
+	nm.Lock()
+	defer nm.Unlock()
+	// GetGPUs may have initialized NVML already.
+	if !nm.nvmlInitialized {
+		initializeNVML(nm)
+	}
 }
 
 // detectDevices returns true if a device with given pci id is present on the node.
@@ -107,6 +117,46 @@ var initializeNVML = func(nm *NvidiaManager) {
 	}
 }
 
+// GetGPUs returns the NVIDIA GPUs of the machine, ordered by minor number.
+// NVML is loaded at runtime, an error is returned if it is not available.
+// A failed load is remembered and not retried.
+func (nm *NvidiaManager) GetGPUs() ([]info.AcceleratorInfo, error) {
+	nm.Lock()
+	defer nm.Unlock()
+	if !nm.nvmlInitialized && !nm.nvmlLoadFailed {
+		if !detectDevices(nvidiaVendorId) {
+			return []info.AcceleratorInfo{}, nil
+		}
+		initializeNVML(nm)
+		nm.nvmlLoadFailed = !nm.nvmlInitialized
+	}
+	if !nm.nvmlInitialized {
+		return nil, fmt.Errorf("NVML is not available")
+	}
+
+	minors := make([]int, 0, len(nm.nvidiaDevices))
+	for minor := range nm.nvidiaDevices {
+		minors = append(minors, minor)
+	}
+	sort.Ints(minors)
+	gpus := make([]info.AcceleratorInfo, 0, len(minors))
+	for _, minor := range minors {
+		device := nm.nvidiaDevices[minor]
+		gpu := info.AcceleratorInfo{
+			Make: "nvidia",
+			ID:   device.UUID,
+		}
+		if device.Model != nil {
+			gpu.Model = *device.Model
+		}
+		if device.Memory != nil {
+			gpu.MemoryTotal = *device.Memory
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus, nil
+}
+
 // Destroy shuts down NVML.
 func (nm *NvidiaManager) Destroy() {
 	if nm.nvmlInitialized {
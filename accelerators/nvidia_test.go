@@ -178,3 +178,34 @@ func TestParseDevicesCgroup(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, []int{}, nvidiaMinorNumbers)
 }
+
+func TestGetGPUsNVMLLoadFailure(t *testing.T) {
+	var err error
+	originalPath := sysFsPCIDevicesPath
+	if sysFsPCIDevicesPath, err = ioutil.TempDir("", "sys-bus-pci-devices"); err != nil {
+		t.Fatalf("Error creating temporary directory for testing: %v", err)
+	}
+	defer os.RemoveAll(sysFsPCIDevicesPath)
+	device := filepath.Join(sysFsPCIDevicesPath, "device0")
+	if err = os.Mkdir(device, 0777); err != nil {
+		t.Fatalf("Error creating temporary directory for testing: %v", err)
+	}
+	updateFile(t, filepath.Join(device, "vendor"), []byte("0x10de\n"))
+
+	initializations := 0
+	originalInitializeNVML := initializeNVML
+	initializeNVML = func(_ *NvidiaManager) { initializations++ }
+	defer func() {
+		sysFsPCIDevicesPath = originalPath
+		initializeNVML = originalInitializeNVML
+	}()
+
+	nm := &NvidiaManager{}
+	for i := 0; i < 3; i++ {
+		gpus, err := nm.GetGPUs()
+		assert.NotNil(t, err)
+		assert.Nil(t, gpus)
+	}
+	// Loading NVML is only attempted once.
+	assert.Equal(t, 1, initializations)
+}
@@ -25,6 +25,8 @@ type AcceleratorManager interface {
 	Setup()
 	Destroy()
 	GetCollector(deviceCgroup string) (AcceleratorCollector, error)
+	// GetGPUs lists the accelerators of the machine.
+	GetGPUs() ([]info.AcceleratorInfo, error)
 }
 
 type AcceleratorCollector interface {
@@ -38,9 +38,14 @@ import (
 	_ "github.com/matthewygf/cadvisor/container/install"
 
 	// Register CloudProviders
+	_ "github.com/matthewygf/cadvisor/utils/cloudinfo/alibaba"
 	_ "github.com/matthewygf/cadvisor/utils/cloudinfo/aws"
 	_ "github.com/matthewygf/cadvisor/utils/cloudinfo/azure"
+	_ "github.com/matthewygf/cadvisor/utils/cloudinfo/digitalocean"
 	_ "github.com/matthewygf/cadvisor/utils/cloudinfo/gce"
+	_ "github.com/matthewygf/cadvisor/utils/cloudinfo/ibmcloud"
+	_ "github.com/matthewygf/cadvisor/utils/cloudinfo/oci"
+	_ "github.com/matthewygf/cadvisor/utils/cloudinfo/tencent"
 
 	"k8s.io/klog"
 )
@@ -71,7 +76,9 @@ var whitelistedContainerLabels = flag.String("whitelisted_container_labels", "",
 
 var urlBasePrefix = flag.String("url_base_prefix", "", "prefix path that will be prepended to all paths to support some reverse proxies")
 
-var rawCgroupPrefixWhiteList = flag.String("raw_cgroup_prefix_whitelist", "", "A comma-separated list of cgroup path prefix that needs to be collected even when -docker_only is specified")
+var rawCgroupPrefixWhiteList = flag.String("raw_cgroup_prefix_whitelist", "", "A comma-separated list of cgroup path prefix that needs to be collected even when -docker_only is specified. Entries prefixed with \"re:\" are regular expressions that must match the whole cgroup path")
+
+var rawCgroupPrefixBlackList = flag.String("raw_cgroup_prefix_blacklist", "", "A comma-separated list of cgroup path prefix that must not be collected, even if whitelisted by -raw_cgroup_prefix_whitelist. Entries prefixed with \"re:\" are regular expressions that must match the whole cgroup path")
 
 var (
 	// Metrics to be ignored.
@@ -81,18 +88,22 @@ var (
 		container.NetworkUdpUsageMetrics:  struct{}{},
 		container.ProcessSchedulerMetrics: struct{}{},
 		container.ProcessMetrics:          struct{}{},
+		container.DiskImageUsageMetrics:   struct{}{},
+		container.SummedNetworkMetrics:    struct{}{},
 	}}
 
 	// List of metrics that can be ignored.
 	ignoreWhitelist = container.MetricSet{
 		container.DiskUsageMetrics:        struct{}{},
 		container.DiskIOMetrics:           struct{}{},
+		container.DiskImageUsageMetrics:   struct{}{},
 		container.NetworkUsageMetrics:     struct{}{},
 		container.NetworkTcpUsageMetrics:  struct{}{},
 		container.NetworkUdpUsageMetrics:  struct{}{},
 		container.PerCpuUsageMetrics:      struct{}{},
 		container.ProcessSchedulerMetrics: struct{}{},
 		container.ProcessMetrics:          struct{}{},
+		container.SummedNetworkMetrics:    struct{}{},
 	}
 )
 
@@ -124,7 +135,7 @@ func (ml *metricSetValue) Set(value string) error {
 }
 
 func init() {
-	flag.Var(&ignoreMetrics, "disable_metrics", "comma-separated list of `metrics` to be disabled. Options are 'disk', 'diskIO', 'network', 'tcp', 'udp', 'percpu', 'sched', 'process'.")
+	flag.Var(&ignoreMetrics, "disable_metrics", "comma-separated list of `metrics` to be disabled. Options are 'disk', 'diskImage', 'diskIO', 'network', 'summedNetwork', 'tcp', 'udp', 'percpu', 'sched', 'process'.")
 
 	// Default logging verbosity to V(2)
 	flag.Set("v", "2")
@@ -153,7 +164,7 @@ func main() {
 
 	collectorHttpClient := createCollectorHttpClient(*collectorCert, *collectorKey)
 
-	containerManager, err := manager.New(memoryStorage, sysFs, *maxHousekeepingInterval, *allowDynamicHousekeeping, includedMetrics, &collectorHttpClient, strings.Split(*rawCgroupPrefixWhiteList, ","))
+	containerManager, err := manager.New(memoryStorage, sysFs, *maxHousekeepingInterval, *allowDynamicHousekeeping, includedMetrics, &collectorHttpClient, strings.Split(*rawCgroupPrefixWhiteList, ","), strings.Split(*rawCgroupPrefixBlackList, ","))
 	if err != nil {
 		klog.Fatalf("Failed to create a Container Manager: %s", err)
 	}
@@ -267,9 +278,11 @@ func toIncludedMetrics(ignoreMetrics container.MetricSet) container.MetricSet {
 		container.CpuLoadMetrics,
 		container.DiskIOMetrics,
 		container.DiskUsageMetrics,
+		container.DiskImageUsageMetrics,
 		container.NetworkUsageMetrics,
 		container.NetworkTcpUsageMetrics,
 		container.NetworkUdpUsageMetrics,
+		container.SummedNetworkMetrics,
 		container.AcceleratorUsageMetrics,
 		container.AppMetrics,
 		container.ProcessMetrics,
@@ -34,6 +34,18 @@ func TestUdpMetricsAreDisabledByDefault(t *testing.T) {
 	assert.True(t, ignoreMetrics.Has(container.NetworkUdpUsageMetrics))
 }
 
+func TestSummedNetworkMetricsAreDisabledByDefault(t *testing.T) {
+	assert.True(t, ignoreMetrics.Has(container.SummedNetworkMetrics))
+	flag.Parse()
+	assert.True(t, ignoreMetrics.Has(container.SummedNetworkMetrics))
+}
+
+func TestDiskImageMetricsAreDisabledByDefault(t *testing.T) {
+	assert.True(t, ignoreMetrics.Has(container.DiskImageUsageMetrics))
+	flag.Parse()
+	assert.True(t, ignoreMetrics.Has(container.DiskImageUsageMetrics))
+}
+
 func TestIgnoreMetrics(t *testing.T) {
 	tests := []struct {
 		value    string
@@ -0,0 +1,65 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"sync"
+	"time"
+)
+
+// CollectionError is an error that occurred while collecting stats.
+type CollectionError struct {
+	Timestamp time.Time
+	Err       error
+}
+
+// ErrorHistory keeps the most recent collection errors of a container.
+type ErrorHistory struct {
+	lock   sync.Mutex
+	errors []CollectionError
+	// Index of the oldest error once the buffer is full.
+	next int
+	size int
+}
+
+// NewErrorHistory returns an ErrorHistory keeping the last size errors.
+func NewErrorHistory(size int) *ErrorHistory {
+	return &ErrorHistory{size: size}
+}
+
+// Record adds an error that occurred at the given time, dropping the oldest
+// error if the history is full.
+func (h *ErrorHistory) Record(timestamp time.Time, err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.size <= 0 {
+		return
+	}
+	if len(h.errors) < h.size {
+		h.errors = append(h.errors, CollectionError{Timestamp: timestamp, Err: err})
+		return
+	}
+	h.errors[h.next] = CollectionError{Timestamp: timestamp, Err: err}
+	h.next = (h.next + 1) % h.size
+}
+
+// Errors returns the recorded errors, oldest first.
+func (h *ErrorHistory) Errors() []CollectionError {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	errors := make([]CollectionError, 0, len(h.errors))
+	errors = append(errors, h.errors[h.next:]...)
+	return append(errors, h.errors[:h.next]...)
+}
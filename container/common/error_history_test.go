@@ -0,0 +1,46 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestErrorHistory(t *testing.T) {
+	history := NewErrorHistory(3)
+	if errors := history.Errors(); len(errors) != 0 {
+		t.Errorf("expected no errors, got %v", errors)
+	}
+
+	start := time.Unix(1000, 0)
+	var recorded []CollectionError
+	for i := 0; i < 5; i++ {
+		collectionError := CollectionError{Timestamp: start.Add(time.Duration(i) * time.Second), Err: fmt.Errorf("error %d", i)}
+		recorded = append(recorded, collectionError)
+		history.Record(collectionError.Timestamp, collectionError.Err)
+		if i == 1 {
+			if errors := history.Errors(); !reflect.DeepEqual(recorded, errors) {
+				t.Errorf("expected errors %v, got %v", recorded, errors)
+			}
+		}
+	}
+	// Only the last three errors are kept.
+	if errors := history.Errors(); !reflect.DeepEqual(recorded[2:], errors) {
+		t.Errorf("expected errors %v, got %v", recorded[2:], errors)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/matthewygf/cadvisor/fs"
 
 	"k8s.io/klog"
+	"k8s.io/utils/clock"
 )
 
 type FsHandler interface {
@@ -35,6 +36,13 @@ type FsUsage struct {
 	BaseUsageBytes  uint64
 	TotalUsageBytes uint64
 	InodeUsage      uint64
+	// Bytes used by the container's log files. Included in TotalUsageBytes
+	// when the logs live in the directories walked by the handler.
+	LogUsageBytes uint64
+	// Time of the update the usage was computed in. Zero if unknown.
+	LastUpdate time.Time
+	// Set when BaseUsageBytes could not be determined and is unreliable.
+	Stale bool
 }
 
 type realFsHandler struct {
@@ -46,6 +54,7 @@ type realFsHandler struct {
 	rootfs     string
 	extraDir   string
 	fsInfo     fs.FsInfo
+	clock      clock.Clock
 	// Tells the container to stop.
 	stopChan chan struct{}
 }
@@ -59,6 +68,12 @@ const DefaultPeriod = time.Minute
 var _ FsHandler = &realFsHandler{}
 
 func NewFsHandler(period time.Duration, rootfs, extraDir string, fsInfo fs.FsInfo) FsHandler {
+	return NewFsHandlerWithClock(period, rootfs, extraDir, fsInfo, clock.RealClock{})
+}
+
+// NewFsHandlerWithClock returns a FsHandler that uses the given clock to
+// schedule and timestamp usage updates.
+func NewFsHandlerWithClock(period time.Duration, rootfs, extraDir string, fsInfo fs.FsInfo, clock clock.Clock) FsHandler {
 	return &realFsHandler{
 		lastUpdate: time.Time{},
 		usage:      FsUsage{},
@@ -67,6 +82,7 @@ func NewFsHandler(period time.Duration, rootfs, extraDir string, fsInfo fs.FsInf
 		rootfs:     rootfs,
 		extraDir:   extraDir,
 		fsInfo:     fsInfo,
+		clock:      clock,
 		stopChan:   make(chan struct{}, 1),
 	}
 }
@@ -89,7 +105,8 @@ func (fh *realFsHandler) update() error {
 	// An error in one will not cause an early return, skipping others
 	fh.Lock()
 	defer fh.Unlock()
-	fh.lastUpdate = time.Now()
+	fh.lastUpdate = fh.clock.Now()
+	fh.usage.LastUpdate = fh.lastUpdate
 	if fh.rootfs != "" && rootErr == nil {
 		fh.usage.InodeUsage = rootUsage.Inodes
 		fh.usage.TotalUsageBytes = rootUsage.Bytes + extraUsage.Bytes
@@ -112,8 +129,8 @@ func (fh *realFsHandler) trackUsage() {
 		select {
 		case <-fh.stopChan:
 			return
-		case <-time.After(fh.period):
-			start := time.Now()
+		case <-fh.clock.After(fh.period):
+			start := fh.clock.Now()
 			if err := fh.update(); err != nil {
 				klog.Errorf("failed to collect filesystem stats - %v", err)
 				fh.period = fh.period * 2
@@ -123,7 +140,7 @@ func (fh *realFsHandler) trackUsage() {
 			} else {
 				fh.period = fh.minPeriod
 			}
-			duration := time.Since(start)
+			duration := fh.clock.Since(start)
 			if duration > longOp {
 				// adapt longOp time so that message doesn't continue to print
 				// if the long duration is persistent either because of slow
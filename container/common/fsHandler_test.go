@@ -0,0 +1,94 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matthewygf/cadvisor/fs"
+
+	"github.com/stretchr/testify/assert"
+	clock "k8s.io/utils/clock/testing"
+)
+
+// fakeDirUsageFsInfo serves directory usage from a map.
+type fakeDirUsageFsInfo struct {
+	fs.FsInfo
+
+	sync.Mutex
+	usage map[string]fs.UsageInfo
+}
+
+func (f *fakeDirUsageFsInfo) GetDirUsage(dir string) (fs.UsageInfo, error) {
+	f.Lock()
+	defer f.Unlock()
+	return f.usage[dir], nil
+}
+
+func (f *fakeDirUsageFsInfo) setUsage(dir string, usage fs.UsageInfo) {
+	f.Lock()
+	defer f.Unlock()
+	f.usage[dir] = usage
+}
+
+func TestFsHandlerUpdateUsesClock(t *testing.T) {
+	as := assert.New(t)
+	fakeClock := clock.NewFakeClock(time.Unix(1000, 0))
+	fsInfo := &fakeDirUsageFsInfo{usage: map[string]fs.UsageInfo{
+		"/rootfs": {Bytes: 10, Inodes: 2},
+		"/extra":  {Bytes: 5},
+	}}
+	fh := NewFsHandlerWithClock(time.Minute, "/rootfs", "/extra", fsInfo, fakeClock).(*realFsHandler)
+
+	as.Nil(fh.update())
+	as.Equal(fakeClock.Now(), fh.lastUpdate)
+	as.Equal(FsUsage{BaseUsageBytes: 10, TotalUsageBytes: 15, InodeUsage: 2, LastUpdate: fakeClock.Now()}, fh.Usage())
+}
+
+func TestFsHandlerTrackUsage(t *testing.T) {
+	as := assert.New(t)
+	fakeClock := clock.NewFakeClock(time.Unix(1000, 0))
+	fsInfo := &fakeDirUsageFsInfo{usage: map[string]fs.UsageInfo{
+		"/rootfs": {Bytes: 10, Inodes: 2},
+	}}
+	fh := NewFsHandlerWithClock(time.Minute, "/rootfs", "", fsInfo, fakeClock)
+	fh.Start()
+	defer fh.Stop()
+
+	waitFor := func(cond func() bool) bool {
+		for i := 0; i < 100; i++ {
+			if cond() {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	// The initial update happens as soon as the handler is started.
+	as.True(waitFor(fakeClock.HasWaiters))
+	as.Equal(uint64(10), fh.Usage().TotalUsageBytes)
+
+	// No further update happens until the period has elapsed.
+	fsInfo.setUsage("/rootfs", fs.UsageInfo{Bytes: 20, Inodes: 3})
+	fakeClock.Step(30 * time.Second)
+	as.Equal(uint64(10), fh.Usage().TotalUsageBytes)
+
+	fakeClock.Step(30 * time.Second)
+	as.True(waitFor(func() bool { return fh.Usage().TotalUsageBytes == 20 }))
+	as.Equal(uint64(3), fh.Usage().InodeUsage)
+}
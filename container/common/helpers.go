@@ -17,9 +17,11 @@ package common
 import (
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -27,6 +29,7 @@ import (
 	"github.com/matthewygf/cadvisor/container"
 	info "github.com/matthewygf/cadvisor/info/v1"
 	"github.com/matthewygf/cadvisor/utils"
+	units "github.com/docker/go-units"
 	"github.com/karrick/godirwalk"
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/pkg/errors"
@@ -49,6 +52,34 @@ func DebugInfo(watches map[string][]string) map[string][]string {
 	return out
 }
 
+// CgroupPathCollisions returns the cgroup paths for the given resource that
+// are shared by more than one of the handlers, mapped to the sorted names of
+// the containers sharing them. Handlers without a path for the resource are
+// ignored. Colliding handlers report the same usage, which is then counted
+// more than once.
+func CgroupPathCollisions(handlers []container.ContainerHandler, resource string) (map[string][]string, error) {
+	containers := make(map[string][]string)
+	for _, handler := range handlers {
+		ref, err := handler.ContainerReference()
+		if err != nil {
+			return nil, err
+		}
+		cgroupPath, err := handler.GetCgroupPath(resource)
+		if err != nil {
+			continue
+		}
+		containers[cgroupPath] = append(containers[cgroupPath], ref.Name)
+	}
+	collisions := make(map[string][]string)
+	for cgroupPath, names := range containers {
+		if len(names) > 1 {
+			sort.Strings(names)
+			collisions[cgroupPath] = names
+		}
+	}
+	return collisions, nil
+}
+
 // findFileInAncestorDir returns the path to the parent directory that contains the specified file.
 // "" is returned if the lookup reaches the limit.
 func findFileInAncestorDir(current, file, limit string) (string, error) {
@@ -112,6 +143,13 @@ func GetSpec(cgroupPaths map[string]string, machineInfoFactory info.MachineInfoF
 				}
 				spec.Cpu.Quota = val
 			}
+			// CFS burst is only supported by newer kernels, the file is absent
+			// on older ones. cgroup v2 exposes it as cpu.max.burst.
+			burstFile := "cpu.cfs_burst_us"
+			if utils.FileExists(path.Join(cpuRoot, "cpu.max.burst")) {
+				burstFile = "cpu.max.burst"
+			}
+			spec.Cpu.Burst = readUInt64(cpuRoot, burstFile)
 		}
 	}
 
@@ -164,6 +202,39 @@ func GetSpec(cgroupPaths map[string]string, machineInfoFactory info.MachineInfoF
 		}
 	}
 
+	// Huge pages
+	hugetlbRoot, ok := cgroupPaths["hugetlb"]
+	if ok {
+		if utils.FileExists(hugetlbRoot) {
+			limits, err := readHugetlbLimits(hugetlbRoot)
+			if err != nil {
+				return spec, err
+			}
+			if len(limits) > 0 {
+				spec.HasHugetlb = true
+				spec.Hugetlb = limits
+			}
+		}
+	}
+
+	// Network traffic classification
+	netClsRoot, ok := cgroupPaths["net_cls"]
+	if ok {
+		if utils.FileExists(netClsRoot) {
+			spec.Network.ClassID = uint32(readUInt64(netClsRoot, "net_cls.classid"))
+		}
+	}
+	netPrioRoot, ok := cgroupPaths["net_prio"]
+	if ok {
+		if utils.FileExists(netPrioRoot) {
+			priorities, err := readIfPriorities(netPrioRoot)
+			if err != nil {
+				return spec, err
+			}
+			spec.Network.IfPriorities = priorities
+		}
+	}
+
 	spec.HasNetwork = hasNetwork
 	spec.HasFilesystem = hasFilesystem
 
@@ -178,6 +249,164 @@ func GetSpec(cgroupPaths map[string]string, machineInfoFactory info.MachineInfoF
 	return spec, nil
 }
 
+// readIfPriorities reads the per interface priorities from the
+// net_prio.ifpriomap file in dirpath, e.g. "eth0 5".
+func readIfPriorities(dirpath string) (map[string]uint32, error) {
+	out := readString(dirpath, "net_prio.ifpriomap")
+	if out == "" {
+		return nil, nil
+	}
+	priorities := make(map[string]uint32)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid line %q in %q", line, path.Join(dirpath, "net_prio.ifpriomap"))
+		}
+		priority, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority in line %q in %q: %v", line, path.Join(dirpath, "net_prio.ifpriomap"), err)
+		}
+		priorities[fields[0]] = uint32(priority)
+	}
+	return priorities, nil
+}
+
+// readHugetlbLimits reads the limit for each huge page size from the
+// hugetlb.<size>.limit_in_bytes (cgroup v1) or hugetlb.<size>.max (cgroup v2)
+// files in dirpath. Unlimited sizes are reported as math.MaxUint64.
+func readHugetlbLimits(dirpath string) (map[string]info.HugetlbSpec, error) {
+	files, err := ioutil.ReadDir(dirpath)
+	if err != nil {
+		return nil, err
+	}
+	limits := make(map[string]info.HugetlbSpec)
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasPrefix(name, "hugetlb.") {
+			continue
+		}
+		var pageSize string
+		switch {
+		case strings.HasSuffix(name, ".limit_in_bytes"):
+			pageSize = strings.TrimSuffix(strings.TrimPrefix(name, "hugetlb."), ".limit_in_bytes")
+		case strings.HasSuffix(name, ".max"):
+			pageSize = strings.TrimSuffix(strings.TrimPrefix(name, "hugetlb."), ".max")
+		default:
+			continue
+		}
+		// Skip files such as hugetlb.2MB.rsvd.max.
+		if strings.Contains(pageSize, ".") {
+			continue
+		}
+		out := readString(dirpath, name)
+		if out == "" {
+			continue
+		}
+		limit := uint64(math.MaxUint64)
+		if out != "max" {
+			limit, err = strconv.ParseUint(out, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse hugetlb limit %q from file %q: %v", out, path.Join(dirpath, name), err)
+			}
+			// cgroup v1 reports no limit as the largest page counter value,
+			// rounded down to the page size.
+			if pageBytes, err := units.RAMInBytes(pageSize); err == nil && limit > math.MaxInt64-uint64(pageBytes) {
+				limit = math.MaxUint64
+			}
+		}
+		limits[pageSize] = info.HugetlbSpec{Limit: limit}
+	}
+	return limits, nil
+}
+
+// unlimitedMemory is the memory.limit_in_bytes of cgroup v1 cgroups without a
+// limit: the largest int64 rounded down to the page size.
+var unlimitedMemory = uint64(math.MaxInt64) / uint64(os.Getpagesize()) * uint64(os.Getpagesize())
+
+// GetResourceLimits reads the CPU quota and period and the memory limit of
+// the container from its cgroup v1 or v2 files. Unlimited values, "max" or -1
+// in the files, are reported as zero.
+func GetResourceLimits(cgroupPaths map[string]string) (info.ContainerResourceLimits, error) {
+	var limits info.ContainerResourceLimits
+
+	if cpuRoot, ok := cgroupPaths["cpu"]; ok {
+		if !utils.FileExists(cpuRoot) {
+			return limits, fmt.Errorf("cgroup %q does not exist", cpuRoot)
+		}
+		if cpuMax, err := readLimitFile(cpuRoot, "cpu.max"); err != nil {
+			return limits, err
+		} else if cpuMax != "" {
+			// "$MAX $PERIOD", e.g. "max 100000".
+			fields := strings.Fields(cpuMax)
+			if len(fields) != 2 {
+				return limits, fmt.Errorf("malformed %q: %q", path.Join(cpuRoot, "cpu.max"), cpuMax)
+			}
+			if limits.CpuQuota, err = parseLimit(fields[0]); err != nil {
+				return limits, fmt.Errorf("malformed %q: %v", path.Join(cpuRoot, "cpu.max"), err)
+			}
+			if limits.CpuPeriod, err = parseLimit(fields[1]); err != nil {
+				return limits, fmt.Errorf("malformed %q: %v", path.Join(cpuRoot, "cpu.max"), err)
+			}
+		} else {
+			for file, limit := range map[string]*uint64{
+				"cpu.cfs_quota_us":  &limits.CpuQuota,
+				"cpu.cfs_period_us": &limits.CpuPeriod,
+			} {
+				value, err := readLimitFile(cpuRoot, file)
+				if err != nil {
+					return limits, err
+				}
+				if *limit, err = parseLimit(value); err != nil {
+					return limits, fmt.Errorf("malformed %q: %v", path.Join(cpuRoot, file), err)
+				}
+			}
+		}
+	}
+
+	if memoryRoot, ok := cgroupPaths["memory"]; ok {
+		if !utils.FileExists(memoryRoot) {
+			return limits, fmt.Errorf("cgroup %q does not exist", memoryRoot)
+		}
+		file := "memory.max"
+		if !utils.FileExists(path.Join(memoryRoot, file)) {
+			file = "memory.limit_in_bytes"
+		}
+		value, err := readLimitFile(memoryRoot, file)
+		if err != nil {
+			return limits, err
+		}
+		if limits.MemoryLimit, err = parseLimit(value); err != nil {
+			return limits, fmt.Errorf("malformed %q: %v", path.Join(memoryRoot, file), err)
+		}
+		if limits.MemoryLimit == unlimitedMemory {
+			limits.MemoryLimit = 0
+		}
+	}
+	return limits, nil
+}
+
+// readLimitFile returns the trimmed contents of the cgroup file, empty if it
+// doesn't exist.
+func readLimitFile(dirpath string, file string) (string, error) {
+	out, err := ioutil.ReadFile(path.Join(dirpath, file))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// parseLimit parses a cgroup limit, reporting the unlimited "max" and -1, as
+// well as absent values, as zero.
+func parseLimit(value string) (uint64, error) {
+	if value == "" || value == "max" || value == "-1" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
 func readString(dirpath string, file string) string {
 	cgroupFile := path.Join(dirpath, file)
 
@@ -253,6 +482,17 @@ func MakeCgroupPaths(mountPoints map[string]string, name string) map[string]stri
 	return cgroupPaths
 }
 
+// GetRelativeCgroupPath returns the path of the named container's cgroup for
+// the resource relative to the root of the resource's hierarchy, e.g.
+// "/kubepods/pod123/container456". cgroupPaths must have been made by
+// MakeCgroupPaths for the container.
+func GetRelativeCgroupPath(cgroupPaths map[string]string, name string, resource string) (string, error) {
+	if _, ok := cgroupPaths[resource]; !ok {
+		return "", fmt.Errorf("could not find path for resource %q for container %q", resource, name)
+	}
+	return path.Join("/", name), nil
+}
+
 func CgroupExists(cgroupPaths map[string]string) bool {
 	// If any cgroup exists, the container is still alive.
 	for _, cgroupPath := range cgroupPaths {
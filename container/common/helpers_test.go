@@ -15,9 +15,29 @@
 package common
 
 import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"reflect"
 	"testing"
+
+	"github.com/matthewygf/cadvisor/container"
+	containertest "github.com/matthewygf/cadvisor/container/testing"
+	info "github.com/matthewygf/cadvisor/info/v1"
 )
 
+type fakeMachineInfoFactory struct{}
+
+func (f fakeMachineInfoFactory) GetMachineInfo() (*info.MachineInfo, error) {
+	return &info.MachineInfo{NumCores: 4}, nil
+}
+
+func (f fakeMachineInfoFactory) GetVersionInfo() (*info.VersionInfo, error) {
+	return &info.VersionInfo{}, nil
+}
+
 func BenchmarkListDirectories(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		output := make(map[string]struct{})
@@ -26,3 +46,289 @@ func BenchmarkListDirectories(b *testing.B) {
 		}
 	}
 }
+
+func TestGetSpecCpuBurst(t *testing.T) {
+	for _, test := range []struct {
+		files    map[string]string
+		expected uint64
+	}{
+		{
+			// cgroup v2 cpu controller.
+			files:    map[string]string{"cpu.max": "100000 100000\n", "cpu.max.burst": "50000\n"},
+			expected: 50000,
+		},
+		{
+			// cgroup v1 on a kernel supporting CFS burst.
+			files:    map[string]string{"cpu.cfs_period_us": "100000\n", "cpu.cfs_burst_us": "20000\n"},
+			expected: 20000,
+		},
+		{
+			// Kernel without CFS burst support.
+			files:    map[string]string{"cpu.max": "max 100000\n"},
+			expected: 0,
+		},
+	} {
+		cpuRoot, err := ioutil.TempDir("", "cpu")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(cpuRoot)
+		for name, contents := range test.files {
+			if err := ioutil.WriteFile(path.Join(cpuRoot, name), []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		spec, err := GetSpec(map[string]string{"cpu": cpuRoot}, fakeMachineInfoFactory{}, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !spec.HasCpu {
+			t.Errorf("%v: expected cpu spec", test.files)
+		}
+		if spec.Cpu.Burst != test.expected {
+			t.Errorf("%v: expected burst %d, got %d", test.files, test.expected, spec.Cpu.Burst)
+		}
+	}
+}
+
+func TestGetSpecHugetlb(t *testing.T) {
+	for _, test := range []struct {
+		files    map[string]string
+		expected map[string]info.HugetlbSpec
+	}{
+		{
+			// cgroup v1, with 1GB pages unlimited.
+			files: map[string]string{
+				"hugetlb.2MB.limit_in_bytes":     "4194304\n",
+				"hugetlb.2MB.usage_in_bytes":     "2097152\n",
+				"hugetlb.1GB.limit_in_bytes":     "9223372036854771712\n",
+				"hugetlb.1GB.max_usage_in_bytes": "0\n",
+			},
+			expected: map[string]info.HugetlbSpec{
+				"2MB": {Limit: 4194304},
+				"1GB": {Limit: math.MaxUint64},
+			},
+		},
+		{
+			// cgroup v2, with 1GB pages unlimited.
+			files: map[string]string{
+				"hugetlb.2MB.max":      "4194304\n",
+				"hugetlb.2MB.rsvd.max": "max\n",
+				"hugetlb.2MB.current":  "2097152\n",
+				"hugetlb.1GB.max":      "max\n",
+				"hugetlb.1GB.events":   "max 0\n",
+			},
+			expected: map[string]info.HugetlbSpec{
+				"2MB": {Limit: 4194304},
+				"1GB": {Limit: math.MaxUint64},
+			},
+		},
+	} {
+		hugetlbRoot, err := ioutil.TempDir("", "hugetlb")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(hugetlbRoot)
+		for name, contents := range test.files {
+			if err := ioutil.WriteFile(path.Join(hugetlbRoot, name), []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		spec, err := GetSpec(map[string]string{"hugetlb": hugetlbRoot}, fakeMachineInfoFactory{}, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !spec.HasHugetlb {
+			t.Errorf("%v: expected hugetlb spec", test.files)
+		}
+		if !reflect.DeepEqual(test.expected, spec.Hugetlb) {
+			t.Errorf("%v: expected hugetlb limits %v, got %v", test.files, test.expected, spec.Hugetlb)
+		}
+	}
+}
+
+func TestGetSpecNetwork(t *testing.T) {
+	for _, test := range []struct {
+		netClsFiles  map[string]string
+		netPrioFiles map[string]string
+		expected     info.NetworkSpec
+	}{
+		{
+			netClsFiles:  map[string]string{"net_cls.classid": "1048577\n"},
+			netPrioFiles: map[string]string{"net_prio.ifpriomap": "lo 0\neth0 5\n", "net_prio.prioidx": "2\n"},
+			expected: info.NetworkSpec{
+				ClassID:      1048577,
+				IfPriorities: map[string]uint32{"lo": 0, "eth0": 5},
+			},
+		},
+		{
+			// The controllers are not available.
+		},
+	} {
+		cgroupPaths := map[string]string{}
+		for subsystem, files := range map[string]map[string]string{
+			"net_cls":  test.netClsFiles,
+			"net_prio": test.netPrioFiles,
+		} {
+			if files == nil {
+				cgroupPaths[subsystem] = "/does/not/exist"
+				continue
+			}
+			root, err := ioutil.TempDir("", subsystem)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(root)
+			for name, contents := range files {
+				if err := ioutil.WriteFile(path.Join(root, name), []byte(contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cgroupPaths[subsystem] = root
+		}
+
+		spec, err := GetSpec(cgroupPaths, fakeMachineInfoFactory{}, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(test.expected, spec.Network) {
+			t.Errorf("expected network spec %+v, got %+v", test.expected, spec.Network)
+		}
+	}
+}
+
+func TestCgroupPathCollisions(t *testing.T) {
+	newHandler := func(name, cgroupPath string) *containertest.MockContainerHandler {
+		handler := containertest.NewMockContainerHandler(name)
+		if cgroupPath == "" {
+			handler.On("GetCgroupPath", "cpu").Return("", fmt.Errorf("no cpu cgroup"))
+		} else {
+			handler.On("GetCgroupPath", "cpu").Return(cgroupPath, nil)
+		}
+		return handler
+	}
+	handlers := []container.ContainerHandler{
+		newHandler("/docker/b", "/sys/fs/cgroup/cpu/docker/a"),
+		newHandler("/docker/a", "/sys/fs/cgroup/cpu/docker/a"),
+		newHandler("/docker/c", "/sys/fs/cgroup/cpu/docker/c"),
+		newHandler("/docker/d", ""),
+	}
+
+	collisions, err := CgroupPathCollisions(handlers, "cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		"/sys/fs/cgroup/cpu/docker/a": {"/docker/a", "/docker/b"},
+	}
+	if !reflect.DeepEqual(expected, collisions) {
+		t.Errorf("expected collisions %v, got %v", expected, collisions)
+	}
+}
+
+func TestGetRelativeCgroupPath(t *testing.T) {
+	mountPoints := map[string]string{
+		"cpu":    "/sys/fs/cgroup/cpu,cpuacct",
+		"memory": "/sys/fs/cgroup/memory",
+	}
+	for _, test := range []struct {
+		name     string
+		expected string
+	}{
+		{name: "/kubepods/pod123/container456", expected: "/kubepods/pod123/container456"},
+		{name: "/", expected: "/"},
+	} {
+		cgroupPaths := MakeCgroupPaths(mountPoints, test.name)
+		for resource := range mountPoints {
+			relativePath, err := GetRelativeCgroupPath(cgroupPaths, test.name, resource)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if relativePath != test.expected {
+				t.Errorf("%s: expected relative %s cgroup path %q, got %q", test.name, resource, test.expected, relativePath)
+			}
+		}
+		if _, err := GetRelativeCgroupPath(cgroupPaths, test.name, "blkio"); err == nil {
+			t.Errorf("%s: expected an error for a resource without a cgroup", test.name)
+		}
+	}
+}
+
+func TestGetResourceLimits(t *testing.T) {
+	for _, test := range []struct {
+		cpuFiles    map[string]string
+		memoryFiles map[string]string
+		expected    info.ContainerResourceLimits
+	}{
+		{
+			// cgroup v1 limits.
+			cpuFiles:    map[string]string{"cpu.cfs_quota_us": "50000\n", "cpu.cfs_period_us": "100000\n"},
+			memoryFiles: map[string]string{"memory.limit_in_bytes": "536870912\n"},
+			expected:    info.ContainerResourceLimits{CpuQuota: 50000, CpuPeriod: 100000, MemoryLimit: 536870912},
+		},
+		{
+			// Unlimited cgroup v1 cgroups.
+			cpuFiles:    map[string]string{"cpu.cfs_quota_us": "-1\n", "cpu.cfs_period_us": "100000\n"},
+			memoryFiles: map[string]string{"memory.limit_in_bytes": fmt.Sprintf("%d\n", unlimitedMemory)},
+			expected:    info.ContainerResourceLimits{CpuPeriod: 100000},
+		},
+		{
+			// cgroup v2 limits.
+			cpuFiles:    map[string]string{"cpu.max": "25000 100000\n"},
+			memoryFiles: map[string]string{"memory.max": "1073741824\n"},
+			expected:    info.ContainerResourceLimits{CpuQuota: 25000, CpuPeriod: 100000, MemoryLimit: 1073741824},
+		},
+		{
+			// Unlimited cgroup v2 cgroups.
+			cpuFiles:    map[string]string{"cpu.max": "max 100000\n"},
+			memoryFiles: map[string]string{"memory.max": "max\n"},
+			expected:    info.ContainerResourceLimits{CpuPeriod: 100000},
+		},
+	} {
+		cpuRoot, err := ioutil.TempDir("", "cpu")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(cpuRoot)
+		memoryRoot, err := ioutil.TempDir("", "memory")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(memoryRoot)
+		for root, files := range map[string]map[string]string{cpuRoot: test.cpuFiles, memoryRoot: test.memoryFiles} {
+			for name, contents := range files {
+				if err := ioutil.WriteFile(path.Join(root, name), []byte(contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		limits, err := GetResourceLimits(map[string]string{"cpu": cpuRoot, "memory": memoryRoot})
+		if err != nil {
+			t.Fatalf("%v %v: unexpected error: %v", test.cpuFiles, test.memoryFiles, err)
+		}
+		if limits != test.expected {
+			t.Errorf("%v %v: expected %+v, got %+v", test.cpuFiles, test.memoryFiles, test.expected, limits)
+		}
+	}
+}
+
+func TestGetResourceLimitsErrors(t *testing.T) {
+	cpuRoot, err := ioutil.TempDir("", "cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cpuRoot)
+	if err := ioutil.WriteFile(path.Join(cpuRoot, "cpu.max"), []byte("100000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GetResourceLimits(map[string]string{"cpu": cpuRoot}); err == nil {
+		t.Errorf("expected an error for a malformed cpu.max")
+	}
+	if _, err := GetResourceLimits(map[string]string{"memory": path.Join(cpuRoot, "removed")}); err == nil {
+		t.Errorf("expected an error for a removed cgroup")
+	}
+}
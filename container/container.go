@@ -16,7 +16,11 @@
 // defines an interface for container operation handlers.
 package container
 
-import info "github.com/matthewygf/cadvisor/info/v1"
+import (
+	"context"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
 
 // ListType describes whether listing should be just for a
 // specific container or performed recursively.
@@ -37,6 +41,7 @@ const (
 	ContainerTypeCrio
 	ContainerTypeContainerd
 	ContainerTypeMesos
+	ContainerTypePodman
 )
 
 // Interface for container operation handlers.
@@ -59,6 +64,10 @@ type ContainerHandler interface {
 	// Returns absolute cgroup path for the requested resource.
 	GetCgroupPath(resource string) (string, error)
 
+	// Returns the cgroup path for the requested resource relative to the
+	// root of its hierarchy.
+	GetRelativeCgroupPath(resource string) (string, error)
+
 	// Returns container labels, if available.
 	GetContainerLabels() map[string]string
 
@@ -78,3 +87,31 @@ type ContainerHandler interface {
 	// Type of handler
 	Type() ContainerType
 }
+
+// ContainerHandlerWithContext is implemented by container handlers that stop
+// collecting stats when a context is done.
+type ContainerHandlerWithContext interface {
+	// Returns the current stats values of the container, or the error of ctx
+	// if it is done before they are collected.
+	GetStatsContext(ctx context.Context) (*info.ContainerStats, error)
+}
+
+// ContainerHandlerWithResourceLimits is implemented by container handlers that
+// read the limits of the container without building its spec.
+type ContainerHandlerWithResourceLimits interface {
+	// Returns the CPU and memory limits currently set on the container.
+	GetResourceLimits() (info.ContainerResourceLimits, error)
+}
+
+// GetStatsContext returns the current stats values of the container of
+// handler. Handlers not implementing ContainerHandlerWithContext are only
+// checked for cancellation of ctx before their stats are collected.
+func GetStatsContext(ctx context.Context, handler ContainerHandler) (*info.ContainerStats, error) {
+	if h, ok := handler.(ContainerHandlerWithContext); ok {
+		return h.GetStatsContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return handler.GetStats()
+}
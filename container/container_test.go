@@ -0,0 +1,46 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matthewygf/cadvisor/container"
+	containertest "github.com/matthewygf/cadvisor/container/testing"
+	info "github.com/matthewygf/cadvisor/info/v1"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStatsContext(t *testing.T) {
+	as := assert.New(t)
+	handler := containertest.NewMockContainerHandler(testContainerName)
+	expected := &info.ContainerStats{}
+	handler.On("GetStats").Return(expected, nil)
+
+	stats, err := container.GetStatsContext(context.Background(), handler)
+	as.NoError(err)
+	as.Equal(expected, stats)
+	handler.AssertNumberOfCalls(t, "GetStats", 1)
+
+	// Handlers without context support are not called once it is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stats, err = container.GetStatsContext(ctx, handler)
+	as.Equal(context.Canceled, err)
+	as.Nil(stats)
+	handler.AssertNumberOfCalls(t, "GetStats", 1)
+}
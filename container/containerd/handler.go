@@ -16,8 +16,13 @@
 package containerd
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,8 +37,12 @@ import (
 	"github.com/matthewygf/cadvisor/fs"
 	info "github.com/matthewygf/cadvisor/info/v1"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"k8s.io/klog"
 )
 
+// The snapshotter that stores the writable layer of containers in an overlay upper dir.
+const overlayfsSnapshotter = "overlayfs"
+
 type containerdContainerHandler struct {
 	machineInfoFactory info.MachineInfoFactory
 	// Absolute path to the cgroup hierarchies of this container.
@@ -49,6 +58,12 @@ type containerdContainerHandler struct {
 	// Filesystem handler.
 	includedMetrics container.MetricSet
 
+	// The directory holding the writable layer of the container, empty if
+	// its disk usage is not known.
+	rootfsStorageDir string
+	// Filesystem handler for the writable layer, nil if its disk usage is not known.
+	fsHandler common.FsHandler
+
 	libcontainerHandler *containerlibcontainer.Handler
 }
 
@@ -137,6 +152,18 @@ func newContainerdContainerHandler(
 	}
 	// Add the name and bare ID as aliases of the container.
 	handler.image = cntr.Image
+
+	// Like the writable layer of docker's overlay2 driver, the upper dir of
+	// the container's overlay rootfs holds everything it wrote.
+	if includedMetrics.Has(container.DiskUsageMetrics) && cntr.Snapshotter == overlayfsSnapshotter {
+		upperDir, err := getOverlayUpperDir(path.Join(rootfs, "proc", strconv.Itoa(int(taskPid)), "mountinfo"))
+		if err != nil {
+			klog.V(4).Infof("unable to determine the writable layer of containerd container %q: %v", id, err)
+		} else if upperDir != "" {
+			handler.rootfsStorageDir = path.Join(rootfs, upperDir)
+			handler.fsHandler = common.NewFsHandler(common.DefaultPeriod, handler.rootfsStorageDir, "", fsInfo)
+		}
+	}
 	for _, envVar := range spec.Process.Env {
 		if envVar != "" {
 			splits := strings.SplitN(envVar, "=", 2)
@@ -165,9 +192,8 @@ func (self *containerdContainerHandler) needNet() bool {
 }
 
 func (self *containerdContainerHandler) GetSpec() (info.ContainerSpec, error) {
-	// TODO: Since we dont collect disk usage stats for containerd, we set hasFilesystem
-	// to false. Revisit when we support disk usage stats for containerd
-	hasFilesystem := false
+	// Disk usage is only known for containers using the overlayfs snapshotter.
+	hasFilesystem := self.fsHandler != nil
 	spec, err := common.GetSpec(self.cgroupPaths, self.machineInfoFactory, self.needNet(), hasFilesystem)
 	spec.Labels = self.labels
 	spec.Envs = self.envs
@@ -185,9 +211,79 @@ func (self *containerdContainerHandler) getFsStats(stats *info.ContainerStats) e
 	if self.includedMetrics.Has(container.DiskIOMetrics) {
 		common.AssignDeviceNamesToDiskStats((*common.MachineInfoNamer)(mi), &stats.DiskIo)
 	}
+
+	if !self.includedMetrics.Has(container.DiskUsageMetrics) || self.fsHandler == nil {
+		return nil
+	}
+	deviceInfo, err := self.fsInfo.GetDirFsDevice(self.rootfsStorageDir)
+	if err != nil {
+		return fmt.Errorf("unable to determine device info for dir: %v: %v", self.rootfsStorageDir, err)
+	}
+	device := deviceInfo.Device
+
+	var (
+		limit  uint64
+		fsType string
+	)
+
+	// containerd does not impose any filesystem limits for containers. So use capacity as limit.
+	for _, fs := range mi.Filesystems {
+		if fs.Device == device {
+			limit = fs.Capacity
+			fsType = fs.Type
+			break
+		}
+	}
+
+	usage := self.fsHandler.Usage()
+	stats.Filesystem = append(stats.Filesystem, info.FsStats{
+		Device:    device,
+		Type:      fsType,
+		Limit:     limit,
+		Usage:     usage.TotalUsageBytes,
+		BaseUsage: usage.BaseUsageBytes,
+		Inodes:    usage.InodeUsage,
+	})
 	return nil
 }
 
+// getOverlayUpperDir returns the upper dir of the overlay mounted as root in
+// the mountinfo file, or an empty string if root is not an overlay.
+func getOverlayUpperDir(mountinfoPath string) (string, error) {
+	f, err := os.Open(mountinfoPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return parseOverlayUpperDir(f)
+}
+
+func parseOverlayUpperDir(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// e.g. "1 0 0:45 / / rw,relatime - overlay overlay rw,lowerdir=...,upperdir=...,workdir=..."
+		sections := strings.SplitN(scanner.Text(), " - ", 2)
+		if len(sections) != 2 {
+			continue
+		}
+		fields := strings.Fields(sections[0])
+		if len(fields) < 5 || fields[4] != "/" {
+			continue
+		}
+		superFields := strings.Fields(sections[1])
+		if len(superFields) < 3 || superFields[0] != "overlay" {
+			return "", nil
+		}
+		for _, opt := range strings.Split(superFields[2], ",") {
+			if strings.HasPrefix(opt, "upperdir=") {
+				return strings.TrimPrefix(opt, "upperdir="), nil
+			}
+		}
+		return "", nil
+	}
+	return "", scanner.Err()
+}
+
 func (self *containerdContainerHandler) GetStats() (*info.ContainerStats, error) {
 	stats, err := self.libcontainerHandler.GetStats()
 	if err != nil {
@@ -218,6 +314,10 @@ func (self *containerdContainerHandler) GetCgroupPath(resource string) (string,
 	return path, nil
 }
 
+func (self *containerdContainerHandler) GetRelativeCgroupPath(resource string) (string, error) {
+	return common.GetRelativeCgroupPath(self.cgroupPaths, self.reference.Name, resource)
+}
+
 func (self *containerdContainerHandler) GetContainerLabels() map[string]string {
 	return self.labels
 }
@@ -235,9 +335,15 @@ func (self *containerdContainerHandler) Type() container.ContainerType {
 }
 
 func (self *containerdContainerHandler) Start() {
+	if self.fsHandler != nil {
+		self.fsHandler.Start()
+	}
 }
 
 func (self *containerdContainerHandler) Cleanup() {
+	if self.fsHandler != nil {
+		self.fsHandler.Stop()
+	}
 }
 
 func (self *containerdContainerHandler) GetContainerIPAddress() string {
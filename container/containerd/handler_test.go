@@ -16,13 +16,16 @@
 package containerd
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/containerd/containerd/containers"
 	"github.com/containerd/typeurl"
 	"github.com/matthewygf/cadvisor/container"
+	"github.com/matthewygf/cadvisor/container/common"
 	containerlibcontainer "github.com/matthewygf/cadvisor/container/libcontainer"
 	"github.com/matthewygf/cadvisor/fs"
+	fstest "github.com/matthewygf/cadvisor/fs/testing"
 	info "github.com/matthewygf/cadvisor/info/v1"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
@@ -106,3 +109,64 @@ func TestHandler(t *testing.T) {
 		}
 	}
 }
+
+func TestParseOverlayUpperDir(t *testing.T) {
+	as := assert.New(t)
+	upperDir := "/var/lib/containerd/io.containerd.snapshotter.v1.overlayfs/snapshots/42/fs"
+	mountinfo := `1037 1001 0:120 / / rw,relatime master:319 - overlay overlay rw,lowerdir=/var/lib/containerd/io.containerd.snapshotter.v1.overlayfs/snapshots/41/fs,upperdir=` + upperDir + `,workdir=/var/lib/containerd/io.containerd.snapshotter.v1.overlayfs/snapshots/42/work
+1038 1037 0:122 / /proc rw,nosuid,nodev,noexec,relatime - proc proc rw
+`
+	actual, err := parseOverlayUpperDir(strings.NewReader(mountinfo))
+	as.Nil(err)
+	as.Equal(upperDir, actual)
+
+	// The root of the container is not an overlay.
+	actual, err = parseOverlayUpperDir(strings.NewReader("25 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw\n"))
+	as.Nil(err)
+	as.Equal("", actual)
+}
+
+type fakeMachineInfoFactory struct {
+	machineInfo info.MachineInfo
+}
+
+func (f *fakeMachineInfoFactory) GetMachineInfo() (*info.MachineInfo, error) {
+	return &f.machineInfo, nil
+}
+
+func (f *fakeMachineInfoFactory) GetVersionInfo() (*info.VersionInfo, error) {
+	return &info.VersionInfo{}, nil
+}
+
+type fakeFsHandler struct {
+	usage common.FsUsage
+}
+
+func (f *fakeFsHandler) Start()                {}
+func (f *fakeFsHandler) Stop()                 {}
+func (f *fakeFsHandler) Usage() common.FsUsage { return f.usage }
+
+func TestGetFsStats(t *testing.T) {
+	as := assert.New(t)
+	const rootfsStorageDir = "/rootfs/var/lib/containerd/io.containerd.snapshotter.v1.overlayfs/snapshots/42/fs"
+	fsInfo := fstest.NewFakeFsInfo()
+	fsInfo.DirDevices[rootfsStorageDir] = &fs.DeviceInfo{Device: "/dev/sda1"}
+	handler := &containerdContainerHandler{
+		machineInfoFactory: &fakeMachineInfoFactory{machineInfo: info.MachineInfo{
+			Filesystems: []info.FsInfo{{Device: "/dev/sda1", Type: "ext4", Capacity: 1 << 30}},
+		}},
+		fsInfo:           fsInfo,
+		includedMetrics:  container.MetricSet{container.DiskUsageMetrics: struct{}{}},
+		rootfsStorageDir: rootfsStorageDir,
+		fsHandler:        &fakeFsHandler{usage: common.FsUsage{BaseUsageBytes: 10, TotalUsageBytes: 10, InodeUsage: 2}},
+	}
+	stats := &info.ContainerStats{}
+	as.Nil(handler.getFsStats(stats))
+	as.Equal([]info.FsStats{{Device: "/dev/sda1", Type: "ext4", Limit: 1 << 30, Usage: 10, BaseUsage: 10, Inodes: 2}}, stats.Filesystem)
+
+	// Without a writable layer, no filesystem stats are reported.
+	handler.fsHandler = nil
+	stats = &info.ContainerStats{}
+	as.Nil(handler.getFsStats(stats))
+	as.Empty(stats.Filesystem)
+}
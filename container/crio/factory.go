@@ -43,6 +43,9 @@ const (
 	overlay2StorageDriver storageDriver = "overlay2"
 )
 
+// The directory of an overlay layer holding its writable contents.
+const overlayRWLayer = "diff"
+
 type crioFactory struct {
 	machineInfoFactory info.MachineInfoFactory
 
@@ -18,7 +18,6 @@ package crio
 import (
 	"fmt"
 	"path"
-	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -134,17 +133,7 @@ func newCrioContainerHandler(
 	storageLogDir := cInfo.LogPath
 
 	// Determine the rootfs storage dir
-	rootfsStorageDir := cInfo.Root
-	// TODO(runcom): CRI-O doesn't strip /merged but we need to in order to
-	// get device ID from root, otherwise, it's going to error out as overlay
-	// mounts doesn't have fixed dev ids.
-	rootfsStorageDir = strings.TrimSuffix(rootfsStorageDir, "/merged")
-	switch storageDriver {
-	case overlayStorageDriver, overlay2StorageDriver:
-		// overlay and overlay2 driver are the same "overlay2" driver so treat
-		// them the same.
-		rootfsStorageDir = filepath.Join(rootfsStorageDir, "diff")
-	}
+	rootfsStorageDir := getRootfsStorageDir(cInfo.Root, storageDriver, storageDir, rootFs)
 
 	containerReference := info.ContainerReference{
 		Id:        id,
@@ -199,6 +188,29 @@ func newCrioContainerHandler(
 	return handler, nil
 }
 
+// getRootfsStorageDir returns the directory of the writable layer of a
+// container whose root filesystem is mounted at root. For the overlay drivers
+// it is the upper directory of the layer in the CRI-O storage root.
+func getRootfsStorageDir(root string, sd storageDriver, storageDir string, rootFs string) string {
+	// TODO(runcom): CRI-O doesn't strip /merged but we need to in order to
+	// get device ID from root, otherwise, it's going to error out as overlay
+	// mounts doesn't have fixed dev ids.
+	layerDir := strings.TrimSuffix(root, "/merged")
+	if layerDir == "" {
+		return ""
+	}
+	switch sd {
+	case overlayStorageDriver, overlay2StorageDriver:
+		// overlay and overlay2 driver are the same "overlay2" driver so treat
+		// them the same.
+		if storageDir != "" {
+			return path.Join(storageDir, string(sd), path.Base(layerDir), overlayRWLayer)
+		}
+		return path.Join(rootFs, layerDir, overlayRWLayer)
+	}
+	return path.Join(rootFs, layerDir)
+}
+
 func (self *crioContainerHandler) Start() {
 	if self.fsHandler != nil {
 		self.fsHandler.Start()
@@ -337,6 +349,10 @@ func (self *crioContainerHandler) GetCgroupPath(resource string) (string, error)
 	return path, nil
 }
 
+func (self *crioContainerHandler) GetRelativeCgroupPath(resource string) (string, error) {
+	return common.GetRelativeCgroupPath(self.cgroupPaths, self.reference.Name, resource)
+}
+
 func (self *crioContainerHandler) GetContainerLabels() map[string]string {
 	return self.labels
 }
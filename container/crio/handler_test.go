@@ -116,3 +116,22 @@ func TestHandler(t *testing.T) {
 		}
 	}
 }
+
+func TestGetRootfsStorageDir(t *testing.T) {
+	as := assert.New(t)
+	root := "/var/lib/containers/storage/overlay/2b7d5a1c3f9e8d4c6b0a1f2e3d4c5b6a7f8e9d0c1b2a3f4e5d6c7b8a9f0e1d2c/merged"
+	for _, ts := range []struct {
+		storageDriver storageDriver
+		storageDir    string
+		rootFs        string
+		expected      string
+	}{
+		{overlayStorageDriver, "/var/lib/containers/storage", "/", "/var/lib/containers/storage/overlay/2b7d5a1c3f9e8d4c6b0a1f2e3d4c5b6a7f8e9d0c1b2a3f4e5d6c7b8a9f0e1d2c/diff"},
+		{overlay2StorageDriver, "/rootfs/var/lib/containers/storage", "/rootfs", "/rootfs/var/lib/containers/storage/overlay2/2b7d5a1c3f9e8d4c6b0a1f2e3d4c5b6a7f8e9d0c1b2a3f4e5d6c7b8a9f0e1d2c/diff"},
+		{overlayStorageDriver, "", "/rootfs", "/rootfs/var/lib/containers/storage/overlay/2b7d5a1c3f9e8d4c6b0a1f2e3d4c5b6a7f8e9d0c1b2a3f4e5d6c7b8a9f0e1d2c/diff"},
+		{"vfs", "/var/lib/containers/storage", "/rootfs", "/rootfs/var/lib/containers/storage/overlay/2b7d5a1c3f9e8d4c6b0a1f2e3d4c5b6a7f8e9d0c1b2a3f4e5d6c7b8a9f0e1d2c"},
+	} {
+		as.Equal(ts.expected, getRootfsStorageDir(root, ts.storageDriver, ts.storageDir, ts.rootFs))
+	}
+	as.Equal("", getRootfsStorageDir("", overlayStorageDriver, "/var/lib/containers/storage", "/"))
+}
@@ -63,6 +63,7 @@ func StatusFromDockerInfo(dockerInfo dockertypes.Info) (v1.DockerStatus, error)
 	out.Hostname = dockerInfo.Name
 	out.RootDir = dockerInfo.DockerRootDir
 	out.Driver = dockerInfo.Driver
+	out.CgroupDriver = dockerInfo.CgroupDriver
 	out.NumImages = dockerInfo.Images
 	out.NumContainers = dockerInfo.Containers
 	out.DriverStatus = make(map[string]string, len(dockerInfo.DriverStatus))
@@ -113,6 +114,72 @@ func Images() ([]v1.DockerImage, error) {
 
 }
 
+// DiskUsage returns the disk space used by the images, containers, volumes
+// and build cache of the docker daemon.
+func DiskUsage() (v1.DockerDiskUsage, error) {
+	client, err := Client()
+	if err != nil {
+		return v1.DockerDiskUsage{}, fmt.Errorf("unable to communicate with docker daemon: %v", err)
+	}
+	du, err := client.DiskUsage(defaultContext())
+	if err != nil {
+		return v1.DockerDiskUsage{}, err
+	}
+	return DiskUsageFromDockerDiskUsage(du), nil
+}
+
+// DiskUsageFromDockerDiskUsage summarizes the result of docker's df API the
+// same way `docker system df` does.
+func DiskUsageFromDockerDiskUsage(du dockertypes.DiskUsage) v1.DockerDiskUsage {
+	out := v1.DockerDiskUsage{}
+
+	out.Images.Count = len(du.Images)
+	out.Images.Size = du.LayersSize
+	var usedImagesSize int64
+	for _, image := range du.Images {
+		if image.Containers <= 0 {
+			continue
+		}
+		out.Images.Active++
+		// Layers shared with other images are only counted as used once
+		// through LayersSize. A shared size of -1 means it was not computed.
+		if image.SharedSize == -1 {
+			continue
+		}
+		usedImagesSize += image.Size - image.SharedSize
+	}
+	out.Images.Reclaimable = du.LayersSize - usedImagesSize
+
+	out.Containers.Count = len(du.Containers)
+	for _, ctnr := range du.Containers {
+		out.Containers.Size += ctnr.SizeRw
+		if ctnr.State == "running" || ctnr.State == "paused" {
+			out.Containers.Active++
+		} else {
+			out.Containers.Reclaimable += ctnr.SizeRw
+		}
+	}
+
+	out.Volumes.Count = len(du.Volumes)
+	for _, volume := range du.Volumes {
+		// Usage data is not computed for volumes of some drivers.
+		if volume.UsageData == nil || volume.UsageData.Size == -1 {
+			continue
+		}
+		out.Volumes.Size += volume.UsageData.Size
+		if volume.UsageData.RefCount > 0 {
+			out.Volumes.Active++
+		} else {
+			out.Volumes.Reclaimable += volume.UsageData.Size
+		}
+	}
+
+	// The build cache is not broken down per object by this API version.
+	out.BuildCache.Size = du.BuilderSize
+	out.BuildCache.Reclaimable = du.BuilderSize
+	return out
+}
+
 // Checks whether the dockerInfo reflects a valid docker setup, and returns it if it does, or an
 // error otherwise.
 func ValidateInfo() (*dockertypes.Info, error) {
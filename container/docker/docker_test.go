@@ -18,6 +18,11 @@ import (
 	"reflect"
 	"regexp"
 	"testing"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"golang.org/x/net/context"
 )
 
 func TestParseDockerAPIVersion(t *testing.T) {
@@ -49,3 +54,40 @@ func TestParseDockerAPIVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestDiskUsage(t *testing.T) {
+	daemon, client, cleanup := newFakeDockerDaemon(t)
+	defer cleanup()
+	daemon.diskUsage = dockertypes.DiskUsage{
+		LayersSize: 1000,
+		Images: []*dockertypes.ImageSummary{
+			// In use, shares 100 bytes with the unused image.
+			{ID: "used", Containers: 1, Size: 600, SharedSize: 100},
+			{ID: "unused", Containers: 0, Size: 500, SharedSize: 100},
+		},
+		Containers: []*dockertypes.Container{
+			{ID: "running", State: "running", SizeRw: 10},
+			{ID: "exited", State: "exited", SizeRw: 20},
+		},
+		Volumes: []*dockertypes.Volume{
+			{Name: "used", UsageData: &dockertypes.VolumeUsageData{RefCount: 1, Size: 300}},
+			{Name: "unused", UsageData: &dockertypes.VolumeUsageData{RefCount: 0, Size: 200}},
+			{Name: "unknown", UsageData: &dockertypes.VolumeUsageData{RefCount: -1, Size: -1}},
+		},
+		BuilderSize: 50,
+	}
+
+	du, err := client.DiskUsage(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := info.DockerDiskUsage{
+		Images:     info.DockerDiskUsageItem{Count: 2, Active: 1, Size: 1000, Reclaimable: 500},
+		Containers: info.DockerDiskUsageItem{Count: 2, Active: 1, Size: 30, Reclaimable: 20},
+		Volumes:    info.DockerDiskUsageItem{Count: 3, Active: 1, Size: 500, Reclaimable: 200},
+		BuildCache: info.DockerDiskUsageItem{Size: 50, Reclaimable: 50},
+	}
+	if actual := DiskUsageFromDockerDiskUsage(du); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
@@ -22,10 +22,12 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/blang/semver"
 	dockertypes "github.com/docker/docker/api/types"
 	"github.com/matthewygf/cadvisor/container"
+	"github.com/matthewygf/cadvisor/container/common"
 	"github.com/matthewygf/cadvisor/container/libcontainer"
 	"github.com/matthewygf/cadvisor/devicemapper"
 	"github.com/matthewygf/cadvisor/fs"
@@ -38,6 +40,7 @@ import (
 	docker "github.com/docker/docker/client"
 	"golang.org/x/net/context"
 	"k8s.io/klog"
+	"k8s.io/utils/clock"
 )
 
 var ArgDockerEndpoint = flag.String("docker", "unix:///var/run/docker.sock", "docker endpoint")
@@ -53,7 +56,29 @@ const DockerNamespace = "docker"
 // --cgroup-parent have another prefix than 'docker'
 var dockerCgroupRegexp = regexp.MustCompile(`([a-z0-9]{64})`)
 
-var dockerEnvWhitelist = flag.String("docker_env_metadata_whitelist", "", "a comma-separated list of environment variable keys that needs to be collected for docker containers")
+var dockerEnvWhitelist = flag.String("docker_env_metadata_whitelist", "", "a comma-separated list of environment variable keys that needs to be collected for docker containers. Entries prefixed with \"prefix:\" collect every variable starting with the rest of the entry, keyed by the remainder of its name; entries prefixed with \"re:\" collect every variable whose whole name matches the regular expression, keyed by its first non-empty capture group if any")
+
+var dockerEnvMetadataFromProc = flag.Bool("docker_env_metadata_from_proc", false, "read the whitelisted environment variables of docker containers from the live environment of their main process instead of their configuration")
+
+var dockerMergedFsUsage = flag.Bool("docker_merged_fs_usage", false, "additionally report the disk usage of the merged overlay view (image layers plus writable layer) of docker containers")
+
+var disableContainerIP = flag.Bool("disable_container_ip", false, "do not resolve the IP address of docker containers, saving a container inspect for each container sharing another container's network")
+
+var dockerCNIResultDir = flag.String("docker_cni_result_dir", "", "directory with cached CNI results to look up the IP address of docker containers in if docker does not report one, e.g. /var/lib/cni/results")
+
+var dockerPreferredNetwork = flag.String("docker_preferred_network", "", "name of the network whose address is reported as the IP address of docker containers attached only to user-defined networks, by default the first network with an address")
+
+var dockerCpuUsageDeltas = flag.Bool("docker_cpu_usage_deltas", false, "also report the CPU usage of docker containers since the previous collection, in usage_delta next to the cumulative usage")
+
+var dockerSchedPolicy = flag.Bool("docker_sched_policy", false, "report the scheduling policy and real-time priority of the main process of docker containers in their spec")
+
+var dockerInspectCacheTTL = flag.Duration("docker_inspect_cache_ttl", 2*time.Second, "how long results of docker container inspects are reused when creating handlers, 0 to disable")
+
+var thinPoolWatcherInterval = flag.Duration("thin_pool_watcher_interval", devicemapper.DefaultThinPoolWatcherPeriod, fmt.Sprintf("how often the usage of devicemapper thin devices of docker containers is refreshed, at least %v", devicemapper.MinThinPoolWatcherPeriod))
+
+var dockerSkipDiskUsageLabel = flag.String("docker_skip_du_label", "", "label selector, as key=value or key, of docker containers whose disk usage is not collected, e.g. cadvisor.io/skip-du=true")
+
+var dockerCollectionLatency = flag.Bool("docker_collection_latency", false, "record how long each metric group takes to collect for docker containers")
 
 var (
 	// Basepath to all container specific information that libcontainer stores.
@@ -90,32 +115,57 @@ const (
 	overlayStorageDriver      storageDriver = "overlay"
 	overlay2StorageDriver     storageDriver = "overlay2"
 	zfsStorageDriver          storageDriver = "zfs"
+	btrfsStorageDriver        storageDriver = "btrfs"
+	vfsStorageDriver          storageDriver = "vfs"
 )
 
-type dockerFactory struct {
-	machineInfoFactory info.MachineInfoFactory
-
-	storageDriver storageDriver
-	storageDir    string
+// supportedStorageDrivers lists the storage drivers for which filesystem
+// stats of docker containers can be reported.
+var supportedStorageDrivers = []storageDriver{
+	aufsStorageDriver,
+	btrfsStorageDriver,
+	devicemapperStorageDriver,
+	overlayStorageDriver,
+	overlay2StorageDriver,
+	vfsStorageDriver,
+	zfsStorageDriver,
+}
 
-	client *docker.Client
+// SupportedStorageDrivers returns the names of the docker storage drivers
+// for which filesystem stats can be reported.
+func SupportedStorageDrivers() []string {
+	drivers := make([]string, 0, len(supportedStorageDrivers))
+	for _, driver := range supportedStorageDrivers {
+		drivers = append(drivers, string(driver))
+	}
+	return drivers
+}
 
-	// Information about the mounted cgroup subsystems.
-	cgroupSubsystems libcontainer.CgroupSubsystems
+// IsSupportedStorageDriver returns true if filesystem stats can be reported
+// for docker containers using the named storage driver.
+func IsSupportedStorageDriver(driver string) bool {
+	for _, supported := range supportedStorageDrivers {
+		if string(supported) == driver {
+			return true
+		}
+	}
+	return false
+}
 
-	// Information about mounted filesystems.
-	fsInfo fs.FsInfo
+// Cgroup drivers docker can use to manage container cgroups.
+const (
+	cgroupfsCgroupDriver = "cgroupfs"
+	systemdCgroupDriver  = "systemd"
+)
 
-	dockerVersion []int
+type dockerFactory struct {
+	client *docker.Client
 
 	dockerAPIVersion []int
 
-	includedMetrics container.MetricSet
-
-	thinPoolName    string
-	thinPoolWatcher *devicemapper.ThinPoolWatcher
-
-	zfsWatcher *zfs.ZfsWatcher
+	// Configuration of the handlers created by the factory, including the
+	// inspect cache the factory checks containers with.
+	handlerOptions handlerOptions
 }
 
 func (self *dockerFactory) String() string {
@@ -128,24 +178,7 @@ func (self *dockerFactory) NewContainerHandler(name string, inHostNamespace bool
 		return
 	}
 
-	metadataEnvs := strings.Split(*dockerEnvWhitelist, ",")
-
-	handler, err = newDockerContainerHandler(
-		client,
-		name,
-		self.machineInfoFactory,
-		self.fsInfo,
-		self.storageDriver,
-		self.storageDir,
-		&self.cgroupSubsystems,
-		inHostNamespace,
-		metadataEnvs,
-		self.dockerVersion,
-		self.includedMetrics,
-		self.thinPoolName,
-		self.thinPoolWatcher,
-		self.zfsWatcher,
-	)
+	handler, err = newDockerContainerHandler(client, name, inHostNamespace, &self.handlerOptions)
 	return
 }
 
@@ -160,6 +193,57 @@ func ContainerNameToDockerId(name string) string {
 	return id
 }
 
+// containerCgroupName returns the cgroup name docker uses for the container
+// with the given id under the given cgroup driver and cgroup parent. It
+// returns an empty string if the cgroup driver is unknown.
+func containerCgroupName(cgroupDriver, cgroupParent, id string) string {
+	switch cgroupDriver {
+	case systemdCgroupDriver:
+		if cgroupParent == "" {
+			cgroupParent = "system.slice"
+		}
+		return path.Join(expandSystemdSlice(cgroupParent), "docker-"+id+".scope")
+	case cgroupfsCgroupDriver:
+		if cgroupParent == "" {
+			cgroupParent = "/docker"
+		}
+		return path.Join("/", cgroupParent, id)
+	}
+	return ""
+}
+
+// containerCgroupPaths returns the cgroup paths of the container with the
+// given id and cgroup name. They are those of the cgroup its cgroup driver
+// places it in if that cgroup exists, and those of name otherwise.
+func containerCgroupPaths(mountPoints map[string]string, cgroupDriver, cgroupParent, id, name string) map[string]string {
+	expected := containerCgroupName(cgroupDriver, cgroupParent, id)
+	if expected == "" || expected == name {
+		return common.MakeCgroupPaths(mountPoints, name)
+	}
+	if cgroupPaths := common.MakeCgroupPaths(mountPoints, expected); common.CgroupExists(cgroupPaths) {
+		return cgroupPaths
+	}
+	// A container outside of where its cgroup driver would place it usually
+	// means docker and the host disagree on the cgroup driver in use.
+	klog.V(2).Infof("cgroup %q of container %q does not match %q expected for the %q cgroup driver", name, id, expected, cgroupDriver)
+	return common.MakeCgroupPaths(mountPoints, name)
+}
+
+// expandSystemdSlice returns the cgroup path of a systemd slice, e.g.
+// "a-b.slice" is placed at "/a.slice/a-b.slice".
+func expandSystemdSlice(slice string) string {
+	if !strings.HasSuffix(slice, ".slice") {
+		return path.Join("/", slice)
+	}
+	cgroupPath := "/"
+	prefix := ""
+	for _, component := range strings.Split(strings.TrimSuffix(slice, ".slice"), "-") {
+		cgroupPath = path.Join(cgroupPath, prefix+component+".slice")
+		prefix += component + "-"
+	}
+	return cgroupPath
+}
+
 // isContainerName returns true if the cgroup with associated name
 // corresponds to a docker container.
 func isContainerName(name string) bool {
@@ -181,7 +265,7 @@ func (self *dockerFactory) CanHandleAndAccept(name string) (bool, bool, error) {
 	id := ContainerNameToDockerId(name)
 
 	// We assume that if Inspect fails then the container is not known to docker.
-	ctnr, err := self.client.ContainerInspect(context.Background(), id)
+	ctnr, err := self.handlerOptions.inspectCache.Inspect(id)
 	if err != nil || !ctnr.State.Running {
 		return false, true, fmt.Errorf("error inspecting container: %v", err)
 	}
@@ -200,7 +284,7 @@ var (
 	apiversion_re            = regexp.MustCompile(apiversion_regexp_string)
 )
 
-func startThinPoolWatcher(dockerInfo *dockertypes.Info) (*devicemapper.ThinPoolWatcher, error) {
+func startThinPoolWatcher(dockerInfo *dockertypes.Info, period time.Duration) (*devicemapper.ThinPoolWatcher, error) {
 	_, err := devicemapper.ThinLsBinaryPresent()
 	if err != nil {
 		return nil, err
@@ -224,7 +308,7 @@ func startThinPoolWatcher(dockerInfo *dockertypes.Info) (*devicemapper.ThinPoolW
 		return nil, err
 	}
 
-	thinPoolWatcher, err := devicemapper.NewThinPoolWatcher(dockerThinPoolName, dockerMetadataDevice)
+	thinPoolWatcher, err := devicemapper.NewThinPoolWatcher(dockerThinPoolName, dockerMetadataDevice, period)
 	if err != nil {
 		return nil, err
 	}
@@ -310,6 +394,10 @@ func ensureThinLsKernelVersion(kernelVersion string) error {
 
 // Register root container before running this function!
 func Register(factory info.MachineInfoFactory, fsInfo fs.FsInfo, includedMetrics container.MetricSet) error {
+	if err := validateMetadataEnvs(strings.Split(*dockerEnvWhitelist, ",")); err != nil {
+		return fmt.Errorf("invalid docker env metadata whitelist: %v", err)
+	}
+
 	client, err := Client()
 	if err != nil {
 		return fmt.Errorf("unable to communicate with docker daemon: %v", err)
@@ -335,7 +423,7 @@ func Register(factory info.MachineInfoFactory, fsInfo fs.FsInfo, includedMetrics
 		thinPoolName    string
 	)
 	if storageDriver(dockerInfo.Driver) == devicemapperStorageDriver {
-		thinPoolWatcher, err = startThinPoolWatcher(dockerInfo)
+		thinPoolWatcher, err = startThinPoolWatcher(dockerInfo, *thinPoolWatcherInterval)
 		if err != nil {
 			klog.Errorf("devicemapper filesystem stats will not be reported: %v", err)
 		}
@@ -353,20 +441,42 @@ func Register(factory info.MachineInfoFactory, fsInfo fs.FsInfo, includedMetrics
 		}
 	}
 
+	if !IsSupportedStorageDriver(dockerInfo.Driver) {
+		klog.Warningf("docker storage driver %q is not supported, filesystem stats will not be reported. Supported drivers: %s", dockerInfo.Driver, strings.Join(SupportedStorageDrivers(), ", "))
+	}
+
 	klog.V(1).Infof("Registering Docker factory")
+	realClock := clock.RealClock{}
 	f := &dockerFactory{
-		cgroupSubsystems:   cgroupSubsystems,
-		client:             client,
-		dockerVersion:      dockerVersion,
-		dockerAPIVersion:   dockerAPIVersion,
-		fsInfo:             fsInfo,
-		machineInfoFactory: factory,
-		storageDriver:      storageDriver(dockerInfo.Driver),
-		storageDir:         RootDir(),
-		includedMetrics:    includedMetrics,
-		thinPoolName:       thinPoolName,
-		thinPoolWatcher:    thinPoolWatcher,
-		zfsWatcher:         zfsWatcher,
+		client:           client,
+		dockerAPIVersion: dockerAPIVersion,
+		handlerOptions: handlerOptions{
+			machineInfoFactory:  factory,
+			fsInfo:              fsInfo,
+			storageDriver:       storageDriver(dockerInfo.Driver),
+			storageDir:          RootDir(),
+			cgroupSubsystems:    &cgroupSubsystems,
+			metadataEnvs:        strings.Split(*dockerEnvWhitelist, ","),
+			dockerVersion:       dockerVersion,
+			includedMetrics:     includedMetrics,
+			thinPoolName:        thinPoolName,
+			thinPoolWatcher:     thinPoolWatcher,
+			zfsWatcher:          zfsWatcher,
+			cgroupDriver:        dockerInfo.CgroupDriver,
+			clock:               realClock,
+			collectionLatency:   *dockerCollectionLatency,
+			mergedFsUsage:       *dockerMergedFsUsage,
+			disableContainerIP:  *disableContainerIP,
+			envMetadataFromProc: *dockerEnvMetadataFromProc,
+			cniResultDir:        *dockerCNIResultDir,
+			preferredNetwork:    *dockerPreferredNetwork,
+			cpuUsageDeltas:      *dockerCpuUsageDeltas,
+			schedPolicy:         *dockerSchedPolicy,
+			skipDiskUsageLabel:  *dockerSkipDiskUsageLabel,
+			inspectCache: newInspectCache(func(id string) (dockertypes.ContainerJSON, error) {
+				return client.ContainerInspect(context.Background(), id)
+			}, *dockerInspectCacheTTL, realClock),
+		},
 	}
 
 	container.RegisterContainerHandlerFactory(f, []watcher.ContainerWatchSource{watcher.Raw})
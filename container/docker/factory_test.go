@@ -70,3 +70,44 @@ func TestIsContainerName(t *testing.T) {
 		}
 	}
 }
+
+func TestContainerCgroupName(t *testing.T) {
+	id := "72e5a5ff5eef3c4222a6551b992b9360a99122f77d2229783f0ee0946dfd800e"
+	tests := []struct {
+		cgroupDriver string
+		cgroupParent string
+		expected     string
+	}{
+		{cgroupfsCgroupDriver, "", "/docker/" + id},
+		{cgroupfsCgroupDriver, "/kubepods/burstable/pod068e8fa0", "/kubepods/burstable/pod068e8fa0/" + id},
+		{systemdCgroupDriver, "", "/system.slice/docker-" + id + ".scope"},
+		{systemdCgroupDriver, "kubepods-burstable-pod068e8fa0.slice", "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod068e8fa0.slice/docker-" + id + ".scope"},
+		{"", "", ""},
+	}
+	for _, test := range tests {
+		if actual := containerCgroupName(test.cgroupDriver, test.cgroupParent, id); actual != test.expected {
+			t.Errorf("%s %q: expected: %q, actual: %q", test.cgroupDriver, test.cgroupParent, test.expected, actual)
+		}
+	}
+}
+
+func TestSupportedStorageDrivers(t *testing.T) {
+	expected := []string{"aufs", "btrfs", "devicemapper", "overlay", "overlay2", "vfs", "zfs"}
+	actual := SupportedStorageDrivers()
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %v, actual %v", expected, actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("expected %v, actual %v", expected, actual)
+		}
+		if !IsSupportedStorageDriver(expected[i]) {
+			t.Errorf("expected %q to be supported", expected[i])
+		}
+	}
+	for _, driver := range []string{"", "windowsfilter"} {
+		if IsSupportedStorageDriver(driver) {
+			t.Errorf("expected %q not to be supported", driver)
+		}
+	}
+}
@@ -16,11 +16,20 @@
 package docker
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/matthewygf/cadvisor/container"
@@ -32,12 +41,16 @@ import (
 	dockerutil "github.com/matthewygf/cadvisor/utils/docker"
 	"github.com/matthewygf/cadvisor/zfs"
 
+	dockertypes "github.com/docker/docker/api/types"
 	dockercontainer "github.com/docker/docker/api/types/container"
+	dockernetwork "github.com/docker/docker/api/types/network"
 	docker "github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
 	cgroupfs "github.com/opencontainers/runc/libcontainer/cgroups/fs"
 	libcontainerconfigs "github.com/opencontainers/runc/libcontainer/configs"
-	"golang.org/x/net/context"
+	"github.com/syndtr/gocapability/capability"
 	"k8s.io/klog"
+	"k8s.io/utils/clock"
 )
 
 const (
@@ -46,8 +59,28 @@ const (
 	overlayRWLayer  = "upper"
 	overlay2RWLayer = "diff"
 
+	// The btrfs driver stores each layer in a subvolume in this directory.
+	btrfsSubvolumesDir = "subvolumes"
+
+	// The vfs driver stores a full copy of the image for each container in
+	// this directory.
+	vfsDir = "dir"
+
 	// Path to the directory where docker stores log files if the json logging driver is enabled.
 	pathToContainersDir = "containers"
+
+	// Number of GetStats errors kept for each container.
+	maxRecentErrors = 10
+
+	// The logging driver that writes json log files to the containers dir.
+	jsonFileLogDriver = "json-file"
+
+	// Labels set by the kubelet on the containers it creates.
+	kubernetesContainerTypeLabel = "io.kubernetes.docker.type"
+	kubernetesContainerNameLabel = "io.kubernetes.container.name"
+	kubernetesSandboxType        = "podsandbox"
+	kubernetesContainerType      = "container"
+	kubernetesSandboxName        = "POD"
 )
 
 type dockerContainerHandler struct {
@@ -71,8 +104,15 @@ type dockerContainerHandler struct {
 	envs   map[string]string
 	labels map[string]string
 
+	// Keys of the environment variables exposed as metadata, and whether
+	// their values are read from the live environment of the container.
+	metadataEnvs        []string
+	envMetadataFromProc bool
+
 	// Image name used for this container.
 	image string
+	// Image ID, the digest of the image configuration.
+	imageID string
 
 	// The network mode of the container
 	networkMode dockercontainer.NetworkMode
@@ -80,9 +120,67 @@ type dockerContainerHandler struct {
 	// Filesystem handler.
 	fsHandler common.FsHandler
 
-	// The IP address of the container
+	// Filesystem handler for the merged overlay view, nil unless enabled.
+	mergedFsHandler common.FsHandler
+
+	// Filesystem handler for the read-only image layers, nil unless enabled.
+	imageFsHandler common.FsHandler
+
+	// The primary IP address of the container
 	ipAddress string
 
+	// All IP addresses of the container, the primary one first.
+	ipAddresses []string
+
+	// The global IPv6 address of the container
+	ipv6Address string
+
+	// Ports exposed or published by the container.
+	ports []info.PortMapping
+
+	// Host paths and volumes mounted into the container.
+	mounts []info.Mount
+
+	// The configured memory swappiness, nil if unset.
+	memorySwappiness *uint64
+
+	// The configured OOM score adjustment.
+	oomScoreAdj int
+
+	// The hostname seen inside the container.
+	hostname string
+
+	// DNS aliases of the container, keyed by network name.
+	networkAliases map[string][]string
+
+	// The configured healthcheck, nil if there is none.
+	healthcheck *info.HealthcheckSpec
+
+	// Entrypoint and arguments the container was started with.
+	entrypoint []string
+	cmd        []string
+
+	// The health status reported by the healthcheck, empty if there is none.
+	health string
+	// Recent inspect results, used to refresh the health status and restart
+	// count. Nil if inspects are not cached.
+	inspectCache *inspectCache
+
+	// Whether this is the sandbox (pause) container of a kubernetes pod.
+	isSandbox bool
+
+	// Path to the container's log file, empty if its logging driver does
+	// not write to a file.
+	logPath string
+
+	// Root of the host filesystem and pid of the container's main process,
+	// used to read live process attributes from /proc.
+	rootFs string
+	pid    int
+
+	// Whether the scheduling policy of the main process is reported in the spec.
+	schedPolicy bool
+
 	includedMetrics container.MetricSet
 
 	// the devicemapper poolname
@@ -90,14 +188,50 @@ type dockerContainerHandler struct {
 
 	// zfsParent is the parent for docker zfs
 	zfsParent string
+	// zfsWatcher is the zfs filesystem watcher, nil if not using zfs
+	zfsWatcher zfsUsageSource
+	// zfsFilesystem is the docker zfs filesystem of the container
+	zfsFilesystem string
 
 	// Reference to the container
 	reference info.ContainerReference
 
 	libcontainerHandler *containerlibcontainer.Handler
+
+	// clock is used for all time-dependent behavior of the handler.
+	clock clock.Clock
+
+	// Restart counts observed for the container, recorded when they change.
+	restarts restartHistory
+
+	// stateLock guards health and restartCount, the latest values inspected.
+	stateLock sync.Mutex
+	// Restart count reported by docker.
+	restartCount int
+
+	// The most recent errors returned by GetStats.
+	recentErrors *common.ErrorHistory
+
+	// Time of the filesystem usage update last reported in stats.
+	lastFsUpdate     time.Time
+	lastFsUpdateLock sync.Mutex
+
+	// Whether the CPU usage since the previous call to GetStats is reported
+	// next to the cumulative usage.
+	cpuUsageDeltas bool
+	// Cumulative CPU usage of the previous call to GetStats, nil before the
+	// first call.
+	lastCpuUsage *info.CpuUsage
+	cpuUsageLock sync.Mutex
+
+	// Set to 1 while stats are collected, also by a collection that
+	// GetStatsContext gave up on.
+	collecting int32
 }
 
 var _ container.ContainerHandler = &dockerContainerHandler{}
+var _ container.ContainerHandlerWithContext = &dockerContainerHandler{}
+var _ container.ContainerHandlerWithResourceLimits = &dockerContainerHandler{}
 
 func getRwLayerID(containerID, storageDir string, sd storageDriver, dockerVersion []int) (string, error) {
 	const (
@@ -116,25 +250,96 @@ func getRwLayerID(containerID, storageDir string, sd storageDriver, dockerVersio
 	return string(bytes), err
 }
 
+// handlerOptions holds the configuration shared by all handlers created by a
+// factory.
+type handlerOptions struct {
+	machineInfoFactory info.MachineInfoFactory
+	fsInfo             fs.FsInfo
+	storageDriver      storageDriver
+	storageDir         string
+	cgroupSubsystems   *containerlibcontainer.CgroupSubsystems
+	// Environment variables of containers reported as metadata.
+	metadataEnvs    []string
+	dockerVersion   []int
+	includedMetrics container.MetricSet
+	thinPoolName    string
+	thinPoolWatcher *devicemapper.ThinPoolWatcher
+	zfsWatcher      *zfs.ZfsWatcher
+	// The cgroup driver used by docker, e.g. "cgroupfs" or "systemd".
+	cgroupDriver string
+	clock        clock.Clock
+
+	collectionLatency   bool
+	mergedFsUsage       bool
+	disableContainerIP  bool
+	envMetadataFromProc bool
+	cniResultDir        string
+	preferredNetwork    string
+	cpuUsageDeltas      bool
+	schedPolicy         bool
+	skipDiskUsageLabel  string
+
+	// Recent inspect results, nil to always inspect containers.
+	inspectCache *inspectCache
+}
+
+// inspectFunc returns the function handlers inspect containers with, which
+// goes through the inspect cache if there is one.
+func (opts *handlerOptions) inspectFunc(client *docker.Client) func(id string) (dockertypes.ContainerJSON, error) {
+	if opts.inspectCache != nil {
+		return opts.inspectCache.Inspect
+	}
+	return func(id string) (dockertypes.ContainerJSON, error) {
+		return client.ContainerInspect(context.Background(), id)
+	}
+}
+
 // newDockerContainerHandler returns a new container.ContainerHandler
 func newDockerContainerHandler(
 	client *docker.Client,
 	name string,
-	machineInfoFactory info.MachineInfoFactory,
-	fsInfo fs.FsInfo,
-	storageDriver storageDriver,
-	storageDir string,
-	cgroupSubsystems *containerlibcontainer.CgroupSubsystems,
 	inHostNamespace bool,
-	metadataEnvs []string,
-	dockerVersion []int,
-	includedMetrics container.MetricSet,
-	thinPoolName string,
-	thinPoolWatcher *devicemapper.ThinPoolWatcher,
-	zfsWatcher *zfs.ZfsWatcher,
+	opts *handlerOptions,
 ) (container.ContainerHandler, error) {
+	id := ContainerNameToDockerId(name)
+
+	// We assume that if Inspect fails then the container is not known to docker.
+	ctnr, err := opts.inspectFunc(client)(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container %q: %v", id, err)
+	}
+
+	return newDockerContainerHandlerFromInspect(client, ctnr, name, inHostNamespace, opts)
+}
+
+// newDockerContainerHandlerFromInspect returns a new container.ContainerHandler
+// for a container that has already been inspected. The client is only used if
+// the container shares the network of another container.
+func newDockerContainerHandlerFromInspect(
+	client *docker.Client,
+	ctnr dockertypes.ContainerJSON,
+	name string,
+	inHostNamespace bool,
+	opts *handlerOptions,
+) (container.ContainerHandler, error) {
+	includedMetrics := opts.includedMetrics
+	storageDir := opts.storageDir
+
+	// The disk usage of containers matching the label selector is not collected.
+	if includedMetrics.Has(container.DiskUsageMetrics) && matchesLabelSelector(ctnr.Config.Labels, opts.skipDiskUsageLabel) {
+		metrics := container.MetricSet{}
+		for metric := range includedMetrics {
+			if metric != container.DiskUsageMetrics {
+				metrics.Add(metric)
+			}
+		}
+		includedMetrics = metrics
+	}
+
+	id := ContainerNameToDockerId(name)
+
 	// Create the cgroup paths.
-	cgroupPaths := common.MakeCgroupPaths(cgroupSubsystems.MountPoints, name)
+	cgroupPaths := containerCgroupPaths(opts.cgroupSubsystems.MountPoints, opts.cgroupDriver, ctnr.HostConfig.CgroupParent, id, name)
 
 	// Generate the equivalent cgroup manager for this container.
 	cgroupManager := &cgroupfs.Manager{
@@ -150,13 +355,11 @@ func newDockerContainerHandler(
 		storageDir = path.Join(rootFs, storageDir)
 	}
 
-	id := ContainerNameToDockerId(name)
-
 	// Add the Containers dir where the log files are stored.
 	// FIXME: Give `otherStorageDir` a more descriptive name.
 	otherStorageDir := path.Join(storageDir, pathToContainersDir, id)
 
-	rwLayerID, err := getRwLayerID(id, storageDir, storageDriver, dockerVersion)
+	rwLayerID, err := getRwLayerID(id, storageDir, opts.storageDriver, opts.dockerVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -167,14 +370,23 @@ func newDockerContainerHandler(
 		rootfsStorageDir string
 		zfsFilesystem    string
 		zfsParent        string
+		deviceID         string
 	)
-	switch storageDriver {
+	switch opts.storageDriver {
 	case aufsStorageDriver:
 		rootfsStorageDir = path.Join(storageDir, string(aufsStorageDriver), aufsRWLayer, rwLayerID)
 	case overlayStorageDriver:
-		rootfsStorageDir = path.Join(storageDir, string(storageDriver), rwLayerID, overlayRWLayer)
+		rootfsStorageDir = path.Join(storageDir, string(opts.storageDriver), rwLayerID, overlayRWLayer)
 	case overlay2StorageDriver:
-		rootfsStorageDir = path.Join(storageDir, string(storageDriver), rwLayerID, overlay2RWLayer)
+		rootfsStorageDir = path.Join(storageDir, string(opts.storageDriver), rwLayerID, overlay2RWLayer)
+	case btrfsStorageDriver:
+		// TODO: Use the usage tracked by btrfs quota groups when quotas are
+		// enabled instead of walking the subvolume.
+		rootfsStorageDir = path.Join(storageDir, string(opts.storageDriver), btrfsSubvolumesDir, rwLayerID)
+	case vfsStorageDriver:
+		// The rootfs is a full copy of the image, so its base usage includes
+		// the image layers.
+		rootfsStorageDir = path.Join(storageDir, string(opts.storageDriver), vfsDir, rwLayerID)
 	case zfsStorageDriver:
 		status, err := Status()
 		if err != nil {
@@ -182,26 +394,33 @@ func newDockerContainerHandler(
 		}
 		zfsParent = status.DriverStatus[dockerutil.DriverStatusParentDataset]
 		zfsFilesystem = path.Join(zfsParent, rwLayerID)
-	}
-
-	// We assume that if Inspect fails then the container is not known to docker.
-	ctnr, err := client.ContainerInspect(context.Background(), id)
-	if err != nil {
-		return nil, fmt.Errorf("failed to inspect container %q: %v", id, err)
+	case devicemapperStorageDriver:
+		// The base usage is looked up in the thin pool by the device id,
+		// without one it would never be found.
+		if includedMetrics.Has(container.DiskUsageMetrics) {
+			deviceID, err = getDeviceID(ctnr.GraphDriver)
+			if err != nil {
+				return nil, fmt.Errorf("unable to determine devicemapper device of container %q: %v", id, err)
+			}
+		}
 	}
 
 	// TODO: extract object mother method
 	handler := &dockerContainerHandler{
-		machineInfoFactory: machineInfoFactory,
+		machineInfoFactory: opts.machineInfoFactory,
 		cgroupPaths:        cgroupPaths,
-		fsInfo:             fsInfo,
-		storageDriver:      storageDriver,
-		poolName:           thinPoolName,
+		fsInfo:             opts.fsInfo,
+		storageDriver:      opts.storageDriver,
+		poolName:           opts.thinPoolName,
 		rootfsStorageDir:   rootfsStorageDir,
-		envs:               make(map[string]string),
 		labels:             ctnr.Config.Labels,
 		includedMetrics:    includedMetrics,
 		zfsParent:          zfsParent,
+		zfsFilesystem:      zfsFilesystem,
+		clock:              opts.clock,
+		cpuUsageDeltas:     opts.cpuUsageDeltas,
+		schedPolicy:        opts.schedPolicy,
+		recentErrors:       common.NewErrorHistory(maxRecentErrors),
 	}
 	// Timestamp returned by Docker is in time.RFC3339Nano format.
 	handler.creationTime, err = time.Parse(time.RFC3339Nano, ctnr.Created)
@@ -210,6 +429,9 @@ func newDockerContainerHandler(
 		return nil, fmt.Errorf("failed to parse the create timestamp %q for container %q: %v", ctnr.Created, id, err)
 	}
 	handler.libcontainerHandler = containerlibcontainer.NewHandler(cgroupManager, rootFs, ctnr.State.Pid, includedMetrics)
+	if opts.collectionLatency {
+		handler.libcontainerHandler.EnableCollectionLatencies(opts.clock)
+	}
 
 	// Add the name and bare ID as aliases of the container.
 	handler.reference = info.ContainerReference{
@@ -219,51 +441,657 @@ func newDockerContainerHandler(
 		Namespace: DockerNamespace,
 	}
 	handler.image = ctnr.Config.Image
+	handler.imageID = ctnr.Image
 	handler.networkMode = ctnr.HostConfig.NetworkMode
-	// Only adds restartcount label if it's greater than 0
-	if ctnr.RestartCount > 0 {
-		handler.labels["restartcount"] = strconv.Itoa(ctnr.RestartCount)
+	handler.memorySwappiness = getMemorySwappiness(ctnr.HostConfig)
+	handler.oomScoreAdj = ctnr.HostConfig.OomScoreAdj
+	handler.isSandbox = isSandboxContainer(handler.labels, handler.image)
+	handler.hostname = getHostname(ctnr.Config.Hostname, ctnr.HostConfig.NetworkMode, id)
+	handler.healthcheck = getHealthcheck(ctnr.Config.Healthcheck)
+	handler.entrypoint = ctnr.Config.Entrypoint
+	handler.cmd = ctnr.Config.Cmd
+	if ctnr.State.Health != nil {
+		handler.health = ctnr.State.Health.Status
 	}
-
-	// Obtain the IP address for the container.
-	// If the NetworkMode starts with 'container:' then we need to use the IP address of the container specified.
-	// This happens in cases such as kubernetes where the containers doesn't have an IP address itself and we need to use the pod's address
-	ipAddress := ctnr.NetworkSettings.IPAddress
-	networkMode := string(ctnr.HostConfig.NetworkMode)
-	if ipAddress == "" && strings.HasPrefix(networkMode, "container:") {
-		containerId := strings.TrimPrefix(networkMode, "container:")
-		c, err := client.ContainerInspect(context.Background(), containerId)
+	handler.rootFs = rootFs
+	handler.pid = ctnr.State.Pid
+	handler.logPath = getLogPath(ctnr.HostConfig.LogConfig.Type, ctnr.LogPath, otherStorageDir, id, rootFs)
+	handler.restartCount = ctnr.RestartCount
+	handler.restarts.record(opts.clock.Now(), ctnr.RestartCount)
+
+	handler.inspectCache = opts.inspectCache
+
+	if !opts.disableContainerIP {
+		// The CNI results are on the host.
+		cniResultDir := opts.cniResultDir
+		if cniResultDir != "" {
+			cniResultDir = path.Join(rootFs, cniResultDir)
+		}
+		handler.ipAddress, handler.ipAddresses, handler.ipv6Address, err = getContainerIPAddresses(&ctnr, opts.inspectFunc(client), cniResultDir, opts.preferredNetwork)
 		if err != nil {
 			return nil, fmt.Errorf("failed to inspect container %q: %v", id, err)
 		}
-		ipAddress = c.NetworkSettings.IPAddress
 	}
-
-	handler.ipAddress = ipAddress
+	handler.ports = getPortMappings(ctnr.NetworkSettings.Ports)
+	handler.mounts = getMounts(ctnr.Mounts)
+	handler.networkAliases = getNetworkAliases(ctnr.NetworkSettings.Networks)
 
 	if includedMetrics.Has(container.DiskUsageMetrics) {
-		handler.fsHandler = &dockerFsHandler{
-			fsHandler:       common.NewFsHandler(common.DefaultPeriod, rootfsStorageDir, otherStorageDir, fsInfo),
-			thinPoolWatcher: thinPoolWatcher,
-			zfsWatcher:      zfsWatcher,
-			deviceID:        ctnr.GraphDriver.Data["DeviceId"],
-			zfsFilesystem:   zfsFilesystem,
+		logDir := ""
+		if ctnr.HostConfig.LogConfig.Type == jsonFileLogDriver {
+			logDir = otherStorageDir
+		}
+		fsHandler := &dockerFsHandler{
+			fsHandler:     common.NewFsHandlerWithClock(common.DefaultPeriod, rootfsStorageDir, otherStorageDir, opts.fsInfo, opts.clock),
+			deviceID:      deviceID,
+			zfsFilesystem: zfsFilesystem,
+			logDir:        logDir,
+		}
+		// Keep the interfaces nil rather than holding a nil watcher.
+		if opts.thinPoolWatcher != nil {
+			fsHandler.thinPoolWatcher = opts.thinPoolWatcher
+		}
+		if opts.zfsWatcher != nil {
+			fsHandler.zfsWatcher = opts.zfsWatcher
+			handler.zfsWatcher = opts.zfsWatcher
+		}
+		handler.fsHandler = fsHandler
+		if includedMetrics.Has(container.DiskImageUsageMetrics) && opts.storageDriver == overlay2StorageDriver {
+			lowerDirs, err := getOverlay2LowerDirs(path.Join(storageDir, string(opts.storageDriver), rwLayerID))
+			if err != nil {
+				klog.V(4).Infof("unable to determine image layers of container %q: %v", id, err)
+			} else if len(lowerDirs) > 0 {
+				handler.imageFsHandler = newImageFsHandler(lowerDirs, opts.fsInfo, opts.clock)
+			}
+		}
+		if opts.mergedFsUsage {
+			if mergedDir := getMergedDir(ctnr.GraphDriver, opts.storageDriver, rootFs); mergedDir != "" {
+				handler.mergedFsHandler = common.NewFsHandlerWithClock(common.DefaultPeriod, mergedDir, "", opts.fsInfo, opts.clock)
+			}
 		}
 	}
 
+	handler.envs = getMetadataEnvs(ctnr.Config.Env, opts.metadataEnvs)
+	handler.metadataEnvs = opts.metadataEnvs
+	handler.envMetadataFromProc = opts.envMetadataFromProc
+
+	return handler, nil
+}
+
+// Metadata env entries with these prefixes collect a family of environment
+// variables rather than the one named by the entry.
+const (
+	metadataEnvPrefixEntry = "prefix:"
+	metadataEnvRegexpEntry = "re:"
+)
+
+// compileMetadataEnvRegexp compiles the regular expression of a "re:" entry,
+// anchored to match whole names.
+func compileMetadataEnvRegexp(entry string) (*regexp.Regexp, error) {
+	pattern := strings.TrimPrefix(entry, metadataEnvRegexpEntry)
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid environment variable regular expression %q: %v", pattern, err)
+	}
+	return re, nil
+}
+
+// validateMetadataEnvs returns an error if a regular expression entry of
+// metadataEnvs doesn't compile.
+func validateMetadataEnvs(metadataEnvs []string) error {
+	for _, entry := range metadataEnvs {
+		if strings.HasPrefix(entry, metadataEnvRegexpEntry) {
+			if _, err := compileMetadataEnvRegexp(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getMetadataEnvs returns the values of the exposed environment variables.
+// Variables named by plain entries are keyed by their lowercased names.
+// Variables collected by "prefix:" entries are keyed by the rest of their
+// names, and those collected by "re:" entries by the first non-empty capture
+// group of the expression, or their whole names without one. Keys of plain entries
+// take precedence, then those of the entries listed first.
+func getMetadataEnvs(env []string, metadataEnvs []string) map[string]string {
+	envs := make(map[string]string)
 	// split env vars to get metadata map.
+	var vars [][]string
+	for _, envVar := range env {
+		if envVar != "" {
+			splits := strings.SplitN(envVar, "=", 2)
+			if len(splits) == 2 {
+				vars = append(vars, splits)
+			}
+		}
+	}
+
+	var patterns []string
 	for _, exposedEnv := range metadataEnvs {
-		for _, envVar := range ctnr.Config.Env {
-			if envVar != "" {
-				splits := strings.SplitN(envVar, "=", 2)
-				if len(splits) == 2 && splits[0] == exposedEnv {
-					handler.envs[strings.ToLower(exposedEnv)] = splits[1]
+		if strings.HasPrefix(exposedEnv, metadataEnvPrefixEntry) || strings.HasPrefix(exposedEnv, metadataEnvRegexpEntry) {
+			patterns = append(patterns, exposedEnv)
+			continue
+		}
+		for _, v := range vars {
+			if v[0] == exposedEnv {
+				envs[strings.ToLower(exposedEnv)] = v[1]
+			}
+		}
+	}
+
+	for _, pattern := range patterns {
+		var re *regexp.Regexp
+		if strings.HasPrefix(pattern, metadataEnvRegexpEntry) {
+			var err error
+			if re, err = compileMetadataEnvRegexp(pattern); err != nil {
+				klog.V(4).Infof("Skipping metadata env entry: %v", err)
+				continue
+			}
+		}
+		captured := make(map[string]string)
+		for _, v := range vars {
+			key := ""
+			if re == nil {
+				prefix := strings.TrimPrefix(pattern, metadataEnvPrefixEntry)
+				if strings.HasPrefix(v[0], prefix) {
+					key = strings.TrimPrefix(v[0], prefix)
+				}
+			} else if match := re.FindStringSubmatch(v[0]); match != nil {
+				key = match[0]
+				for _, group := range match[1:] {
+					if group != "" {
+						key = group
+						break
+					}
 				}
 			}
+			if key != "" {
+				captured[key] = v[1]
+			}
 		}
+		for key, value := range captured {
+			if _, ok := envs[key]; ok {
+				klog.V(4).Infof("Skipping environment variable collected by %q as %q, which is already collected", pattern, key)
+				continue
+			}
+			envs[key] = value
+		}
+	}
+	return envs
+}
+
+// readProcessEnv reads the live environment of a process.
+func readProcessEnv(rootFs string, pid int) ([]string, error) {
+	out, err := ioutil.ReadFile(path.Join(rootFs, "proc", strconv.Itoa(pid), "environ"))
+	if err != nil {
+		return nil, err
 	}
+	return strings.Split(strings.TrimRight(string(out), "\x00"), "\x00"), nil
+}
 
-	return handler, nil
+// schedPolicies maps the scheduling policies in /proc/<pid>/stat to their names.
+var schedPolicies = map[uint64]string{
+	0: "SCHED_OTHER",
+	1: "SCHED_FIFO",
+	2: "SCHED_RR",
+	3: "SCHED_BATCH",
+	5: "SCHED_IDLE",
+	6: "SCHED_DEADLINE",
+}
+
+// readSchedPolicy reads the scheduling policy and real-time priority of a
+// process from its stat file.
+func readSchedPolicy(rootFs string, pid int) (*info.SchedSpec, error) {
+	out, err := ioutil.ReadFile(path.Join(rootFs, "proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return nil, err
+	}
+	// The command name may contain spaces and parentheses, the fields after
+	// it start with the state, which is field 3.
+	stat := string(out)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	const (
+		rtPriorityField = 40 - 3
+		policyField     = 41 - 3
+	)
+	if len(fields) <= policyField {
+		return nil, fmt.Errorf("too few fields in stat of process %d", pid)
+	}
+	policy, err := strconv.ParseUint(fields[policyField], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse scheduling policy of process %d: %v", pid, err)
+	}
+	priority, err := strconv.ParseUint(fields[rtPriorityField], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse real-time priority of process %d: %v", pid, err)
+	}
+	name, ok := schedPolicies[policy]
+	if !ok {
+		name = strconv.FormatUint(policy, 10)
+	}
+	return &info.SchedSpec{Policy: name, Priority: priority}, nil
+}
+
+// readEffectiveCapabilities returns the names of the capabilities in the
+// effective set of a process, e.g. "CAP_NET_ADMIN", as listed in the CapEff
+// field of its status file.
+func readEffectiveCapabilities(rootFs string, pid int) ([]string, error) {
+	out, err := ioutil.ReadFile(path.Join(rootFs, "proc", strconv.Itoa(pid), "status"))
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+		mask, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse effective capabilities %q: %v", line, err)
+		}
+		capabilities := []string{}
+		for _, c := range capability.List() {
+			if mask&(1<<uint(c)) != 0 {
+				capabilities = append(capabilities, "CAP_"+strings.ToUpper(c.String()))
+			}
+		}
+		return capabilities, nil
+	}
+	return nil, fmt.Errorf("no effective capabilities in status of process %d", pid)
+}
+
+// isSandboxContainer returns true if the container is the sandbox (pause)
+// container of a kubernetes pod, based on the labels set by the kubelet or,
+// failing that, on the image it runs.
+func isSandboxContainer(labels map[string]string, image string) bool {
+	if labels[kubernetesContainerTypeLabel] == kubernetesSandboxType || labels[kubernetesContainerNameLabel] == kubernetesSandboxName {
+		return true
+	}
+	if _, ok := labels[kubernetesContainerTypeLabel]; ok {
+		return false
+	}
+	// Strip the digest and tag, e.g. "k8s.gcr.io/pause-amd64:3.1" is a pause image.
+	repo := strings.SplitN(image, "@", 2)[0]
+	if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo = repo[:i]
+	}
+	name := path.Base(repo)
+	return name == "pause" || strings.HasPrefix(name, "pause-")
+}
+
+// getCommand returns the command line of a container run with the given
+// entrypoint and arguments. Arguments that are empty or contain whitespace or
+// quotes are quoted, so the result can be split back into them.
+func getCommand(entrypoint, cmd []string) string {
+	args := make([]string, 0, len(entrypoint)+len(cmd))
+	for _, arg := range append(append([]string{}, entrypoint...), cmd...) {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+			arg = strconv.Quote(arg)
+		}
+		args = append(args, arg)
+	}
+	return strings.Join(args, " ")
+}
+
+// getHostname returns the hostname seen inside the container. Docker
+// defaults the hostname of containers with their own UTS namespace to the
+// short container ID.
+func getHostname(hostname string, networkMode dockercontainer.NetworkMode, id string) string {
+	if hostname != "" || networkMode.IsHost() {
+		return hostname
+	}
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// getContainerIPAddresses returns the primary IP address of the container,
+// all the addresses it has on its networks and its global IPv6 address.
+// If the NetworkMode starts with 'container:' then we need to use the IP address of the container specified.
+// This happens in cases such as kubernetes where the containers doesn't have an IP address itself and we need to use the pod's address
+// Containers attached only to user-defined networks have no top-level address,
+// their primary address is then the one on preferredNetwork, if set, or the
+// first network with an address.
+// If docker does not know the address, it is looked up in the CNI results in cniResultDir, if set.
+func getContainerIPAddresses(ctnr *dockertypes.ContainerJSON, inspect func(id string) (dockertypes.ContainerJSON, error), cniResultDir, preferredNetwork string) (string, []string, string, error) {
+	networkSettings := ctnr.NetworkSettings
+	networkContainerID := ctnr.ID
+	networkMode := string(ctnr.HostConfig.NetworkMode)
+	ipAddresses := getNetworkIPAddresses(networkSettings, preferredNetwork)
+	ipv6Address := getNetworkIPv6Address(networkSettings, preferredNetwork)
+	if len(ipAddresses) == 0 && ipv6Address == "" && strings.HasPrefix(networkMode, "container:") {
+		containerId := strings.TrimPrefix(networkMode, "container:")
+		c, err := inspect(containerId)
+		if err != nil {
+			return "", nil, "", err
+		}
+		networkSettings = c.NetworkSettings
+		networkContainerID = c.ID
+		ipAddresses = getNetworkIPAddresses(networkSettings, preferredNetwork)
+		ipv6Address = getNetworkIPv6Address(networkSettings, preferredNetwork)
+	}
+	if len(ipAddresses) == 0 && cniResultDir != "" {
+		cniIPAddress, err := getCNIIPAddress(cniResultDir, networkContainerID)
+		if err != nil {
+			klog.V(4).Infof("unable to read CNI result for container %q: %v", networkContainerID, err)
+		}
+		if cniIPAddress != "" {
+			ipAddresses = []string{cniIPAddress}
+		}
+	}
+	if len(ipAddresses) == 0 {
+		return "", nil, ipv6Address, nil
+	}
+	return ipAddresses[0], ipAddresses, ipv6Address, nil
+}
+
+// getNetworkIPv6Address returns the global IPv6 address in the network
+// settings, looked up in the same order as by getNetworkIPAddresses.
+func getNetworkIPv6Address(networkSettings *dockertypes.NetworkSettings, preferredNetwork string) string {
+	if networkSettings == nil {
+		return ""
+	}
+	if networkSettings.GlobalIPv6Address != "" {
+		return networkSettings.GlobalIPv6Address
+	}
+	if network, ok := networkSettings.Networks[preferredNetwork]; ok && network != nil && network.GlobalIPv6Address != "" {
+		return network.GlobalIPv6Address
+	}
+	names := make([]string, 0, len(networkSettings.Networks))
+	for name := range networkSettings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if network := networkSettings.Networks[name]; network != nil && network.GlobalIPv6Address != "" {
+			return network.GlobalIPv6Address
+		}
+	}
+	return ""
+}
+
+// getNetworkIPAddresses returns the distinct IP addresses in the network
+// settings. The top-level address comes first, followed by the address on
+// preferredNetwork and those on the other networks in order of network name.
+func getNetworkIPAddresses(networkSettings *dockertypes.NetworkSettings, preferredNetwork string) []string {
+	if networkSettings == nil {
+		return nil
+	}
+	var ipAddresses []string
+	add := func(ipAddress string) {
+		if ipAddress == "" {
+			return
+		}
+		for _, seen := range ipAddresses {
+			if seen == ipAddress {
+				return
+			}
+		}
+		ipAddresses = append(ipAddresses, ipAddress)
+	}
+
+	add(networkSettings.IPAddress)
+	if network, ok := networkSettings.Networks[preferredNetwork]; ok && network != nil {
+		add(network.IPAddress)
+	}
+	names := make([]string, 0, len(networkSettings.Networks))
+	for name := range networkSettings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if network := networkSettings.Networks[name]; network != nil {
+			add(network.IPAddress)
+		}
+	}
+	return ipAddresses
+}
+
+// cniResult is the part of a cached CNI ADD result holding the assigned addresses.
+type cniResult struct {
+	ContainerID string `json:"containerId"`
+	Result      struct {
+		IPs []struct {
+			Address string `json:"address"`
+		} `json:"ips"`
+	} `json:"result"`
+}
+
+// getCNIIPAddress returns the first IP address assigned to the container in
+// the CNI results cached in dir, or an empty string if there is none. Results
+// are cached by libcni in files named "<network>-<container ID>-<interface>".
+func getCNIIPAddress(dir, containerID string) (string, error) {
+	if containerID == "" {
+		return "", nil
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.Contains(file.Name(), "-"+containerID+"-") {
+			continue
+		}
+		out, err := ioutil.ReadFile(path.Join(dir, file.Name()))
+		if err != nil {
+			return "", err
+		}
+		var result cniResult
+		if err := json.Unmarshal(out, &result); err != nil {
+			return "", fmt.Errorf("failed to parse CNI result %q: %v", file.Name(), err)
+		}
+		if result.ContainerID != "" && result.ContainerID != containerID {
+			continue
+		}
+		for _, ip := range result.Result.IPs {
+			// Addresses are in CIDR notation.
+			if address := strings.SplitN(ip.Address, "/", 2)[0]; address != "" {
+				return address, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// getPortMappings flattens the port map reported by docker into a list with
+// one entry per binding. Exposed ports that are not published are reported
+// without host information.
+func getPortMappings(portMap nat.PortMap) []info.PortMapping {
+	var ports []info.PortMapping
+	for port, bindings := range portMap {
+		containerPort := uint16(port.Int())
+		if len(bindings) == 0 {
+			ports = append(ports, info.PortMapping{
+				ContainerPort: containerPort,
+				Protocol:      port.Proto(),
+			})
+			continue
+		}
+		for _, binding := range bindings {
+			hostPort, err := strconv.ParseUint(binding.HostPort, 10, 16)
+			if err != nil {
+				klog.V(4).Infof("unable to parse host port %q for port %s: %v", binding.HostPort, port, err)
+			}
+			ports = append(ports, info.PortMapping{
+				ContainerPort: containerPort,
+				Protocol:      port.Proto(),
+				HostIP:        binding.HostIP,
+				HostPort:      uint16(hostPort),
+			})
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].ContainerPort != ports[j].ContainerPort {
+			return ports[i].ContainerPort < ports[j].ContainerPort
+		}
+		if ports[i].Protocol != ports[j].Protocol {
+			return ports[i].Protocol < ports[j].Protocol
+		}
+		return ports[i].HostIP < ports[j].HostIP
+	})
+	return ports
+}
+
+// getMounts converts the mounts reported by docker, in the order docker
+// reports them.
+func getMounts(mountPoints []dockertypes.MountPoint) []info.Mount {
+	if len(mountPoints) == 0 {
+		return nil
+	}
+	mounts := make([]info.Mount, 0, len(mountPoints))
+	for _, mountPoint := range mountPoints {
+		mounts = append(mounts, info.Mount{
+			Type:        string(mountPoint.Type),
+			Name:        mountPoint.Name,
+			Source:      mountPoint.Source,
+			Destination: mountPoint.Destination,
+			Mode:        mountPoint.Mode,
+			RW:          mountPoint.RW,
+		})
+	}
+	return mounts
+}
+
+// getState returns the health status and restart count of the container.
+// They are refreshed from the inspect cache, so that the container is
+// inspected at most once per cache ttl; without a cache they are the ones of
+// when the handler was created.
+func (self *dockerContainerHandler) getState() (string, int) {
+	if self.inspectCache != nil && self.inspectCache.ttl > 0 {
+		ctnr, err := self.inspectCache.Inspect(self.reference.Id)
+		if err != nil {
+			klog.V(4).Infof("unable to inspect container %q for its state: %v", self.reference.Name, err)
+		} else if ctnr.ContainerJSONBase != nil {
+			health := ""
+			if ctnr.State != nil && ctnr.State.Health != nil {
+				health = ctnr.State.Health.Status
+			}
+			self.setState(health, ctnr.RestartCount)
+		}
+	}
+	self.stateLock.Lock()
+	defer self.stateLock.Unlock()
+	return self.health, self.restartCount
+}
+
+// setState stores the inspected health status and restart count of the
+// container, recording the restart count in the history if it changed.
+func (self *dockerContainerHandler) setState(health string, restartCount int) {
+	self.stateLock.Lock()
+	defer self.stateLock.Unlock()
+	self.health = health
+	if restartCount != self.restartCount {
+		self.restarts.record(self.clock.Now(), restartCount)
+	}
+	self.restartCount = restartCount
+}
+
+// getHealthcheck returns the healthcheck configured for the container, or nil
+// if it has none or it is disabled.
+func getHealthcheck(healthConfig *dockercontainer.HealthConfig) *info.HealthcheckSpec {
+	if healthConfig == nil || len(healthConfig.Test) == 0 || healthConfig.Test[0] == "NONE" {
+		return nil
+	}
+	return &info.HealthcheckSpec{
+		Test:        append([]string(nil), healthConfig.Test...),
+		Interval:    healthConfig.Interval,
+		Timeout:     healthConfig.Timeout,
+		StartPeriod: healthConfig.StartPeriod,
+		Retries:     healthConfig.Retries,
+	}
+}
+
+// getNetworkAliases returns the DNS aliases of the container on each network
+// it is attached to. Networks without aliases are left out.
+func getNetworkAliases(networks map[string]*dockernetwork.EndpointSettings) map[string][]string {
+	var aliases map[string][]string
+	for name, settings := range networks {
+		if settings == nil || len(settings.Aliases) == 0 {
+			continue
+		}
+		if aliases == nil {
+			aliases = make(map[string][]string)
+		}
+		aliases[name] = append([]string(nil), settings.Aliases...)
+	}
+	return aliases
+}
+
+// getMemorySwappiness returns the memory swappiness configured for the
+// container. Docker uses nil or -1 to indicate that the system default is used.
+func getMemorySwappiness(hostConfig *dockercontainer.HostConfig) *uint64 {
+	if hostConfig == nil || hostConfig.MemorySwappiness == nil || *hostConfig.MemorySwappiness < 0 {
+		return nil
+	}
+	swappiness := uint64(*hostConfig.MemorySwappiness)
+	return &swappiness
+}
+
+// matchesLabelSelector returns whether labels match the selector, given as
+// key=value or as key to match any value. An empty selector matches nothing.
+func matchesLabelSelector(labels map[string]string, selector string) bool {
+	if selector == "" {
+		return false
+	}
+	parts := strings.SplitN(selector, "=", 2)
+	value, ok := labels[parts[0]]
+	if !ok {
+		return false
+	}
+	return len(parts) == 1 || value == parts[1]
+}
+
+// getDeviceID returns the id of the thin device of a container using the
+// devicemapper storage driver.
+func getDeviceID(graphDriver dockertypes.GraphDriverData) (string, error) {
+	deviceID, ok := graphDriver.Data["DeviceId"]
+	if !ok || deviceID == "" {
+		return "", fmt.Errorf("no device id in the data of graph driver %q", graphDriver.Name)
+	}
+	if _, err := strconv.ParseUint(deviceID, 10, 64); err != nil {
+		return "", fmt.Errorf("malformed device id %q", deviceID)
+	}
+	return deviceID, nil
+}
+
+// getMergedDir returns the path of the merged overlay view of a container's
+// filesystem, or an empty string if the storage driver does not provide one.
+func getMergedDir(graphDriver dockertypes.GraphDriverData, sd storageDriver, rootFs string) string {
+	if sd != overlayStorageDriver && sd != overlay2StorageDriver {
+		return ""
+	}
+	mergedDir := graphDriver.Data["MergedDir"]
+	if mergedDir == "" {
+		return ""
+	}
+	return path.Join(rootFs, mergedDir)
+}
+
+// getLogPath returns the path to a container's log file. Log files written by
+// the json-file logging driver live in the container's directory, for other
+// drivers the path reported by docker, if any, is used.
+func getLogPath(logDriver, reportedLogPath, containerDir, id, rootFs string) string {
+	if logDriver == jsonFileLogDriver {
+		return path.Join(containerDir, id+"-json.log")
+	}
+	if reportedLogPath == "" {
+		return ""
+	}
+	return path.Join(rootFs, reportedLogPath)
+}
+
+// readOomScoreAdj reads the live OOM score adjustment of a process.
+func readOomScoreAdj(rootFs string, pid int) (int, error) {
+	out, err := ioutil.ReadFile(path.Join(rootFs, "proc", strconv.Itoa(pid), "oom_score_adj"))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(out)))
 }
 
 // dockerFsHandler is a composite FsHandler implementation the incorporates
@@ -272,18 +1100,43 @@ type dockerFsHandler struct {
 	fsHandler common.FsHandler
 
 	// thinPoolWatcher is the devicemapper thin pool watcher
-	thinPoolWatcher *devicemapper.ThinPoolWatcher
+	thinPoolWatcher thinPoolUsageSource
 	// deviceID is the id of the container's fs device
 	deviceID string
 
+	thinPoolFailuresLock sync.Mutex
+	// thinPoolFailures is the number of consecutive failures to get the
+	// usage of the device from the thin pool watcher.
+	thinPoolFailures int
+	// thinPoolFailureRefreshes is the number of thin pool refreshes at the
+	// first of those failures.
+	thinPoolFailureRefreshes uint64
+
 	// zfsWatcher is the zfs filesystem watcher
-	zfsWatcher *zfs.ZfsWatcher
+	zfsWatcher zfsUsageSource
 	// zfsFilesystem is the docker zfs filesystem
 	zfsFilesystem string
+
+	// logDir is the directory the json-file logging driver writes the
+	// container's logs to, empty for other logging drivers.
+	logDir string
 }
 
 var _ common.FsHandler = &dockerFsHandler{}
 
+// thinPoolUsageSource is the part of devicemapper.ThinPoolWatcher used by
+// dockerFsHandler.
+type thinPoolUsageSource interface {
+	GetUsage(deviceId string) (uint64, error)
+	Refreshes() uint64
+}
+
+// zfsUsageSource is the part of zfs.ZfsWatcher used by the docker handlers.
+type zfsUsageSource interface {
+	GetUsage(filesystem string) (uint64, error)
+	GetQuota(filesystem string) (uint64, error)
+}
+
 func (h *dockerFsHandler) Start() {
 	h.fsHandler.Start()
 }
@@ -303,11 +1156,15 @@ func (h *dockerFsHandler) Usage() common.FsUsage {
 	if h.thinPoolWatcher != nil {
 		thinPoolUsage, err := h.thinPoolWatcher.GetUsage(h.deviceID)
 		if err != nil {
-			// TODO: ideally we should keep track of how many times we failed to get the usage for this
-			// device vs how many refreshes of the cache there have been, and display an error e.g. if we've
-			// had at least 1 refresh and we still can't find the device.
-			klog.V(5).Infof("unable to get fs usage from thin pool for device %s: %v", h.deviceID, err)
+			// A device missing from the cache right after the container
+			// started is expected, but still missing after the cache has
+			// been refreshed the base usage is unknown.
+			if h.recordThinPoolFailure(err) {
+				usage.BaseUsageBytes = 0
+				usage.Stale = true
+			}
 		} else {
+			h.resetThinPoolFailures()
 			usage.BaseUsageBytes = thinPoolUsage
 			usage.TotalUsageBytes += thinPoolUsage
 		}
@@ -322,19 +1179,90 @@ func (h *dockerFsHandler) Usage() common.FsUsage {
 			usage.TotalUsageBytes += zfsUsage
 		}
 	}
+
+	if h.logDir != "" {
+		logUsage, err := getLogUsage(h.logDir)
+		if err != nil {
+			klog.V(5).Infof("unable to get log usage for %s: %v", h.logDir, err)
+		} else {
+			usage.LogUsageBytes = logUsage
+		}
+	}
 	return usage
 }
 
+// recordThinPoolFailure records a failure to get the usage of the device from
+// the thin pool and returns whether the device has been missing since before
+// the last refresh of the thin pool cache.
+func (h *dockerFsHandler) recordThinPoolFailure(err error) bool {
+	h.thinPoolFailuresLock.Lock()
+	defer h.thinPoolFailuresLock.Unlock()
+
+	refreshes := h.thinPoolWatcher.Refreshes()
+	if h.thinPoolFailures == 0 {
+		h.thinPoolFailureRefreshes = refreshes
+	}
+	h.thinPoolFailures++
+	stale := refreshes > h.thinPoolFailureRefreshes
+	if stale {
+		klog.V(2).Infof("unable to get fs usage from thin pool for device %s after %d attempts: %v", h.deviceID, h.thinPoolFailures, err)
+	} else {
+		klog.V(5).Infof("unable to get fs usage from thin pool for device %s: %v", h.deviceID, err)
+	}
+	return stale
+}
+
+func (h *dockerFsHandler) resetThinPoolFailures() {
+	h.thinPoolFailuresLock.Lock()
+	defer h.thinPoolFailuresLock.Unlock()
+
+	h.thinPoolFailures = 0
+}
+
+// getLogUsage returns the bytes used by the log files, including rotated ones,
+// the json-file logging driver wrote to dir.
+func getLogUsage(dir string) (uint64, error) {
+	logFiles, err := filepath.Glob(path.Join(dir, "*-json.log*"))
+	if err != nil {
+		return 0, err
+	}
+	var usage uint64
+	for _, logFile := range logFiles {
+		fi, err := os.Stat(logFile)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// The file was rotated away since it was listed.
+				continue
+			}
+			return 0, err
+		}
+		usage += uint64(fi.Size())
+	}
+	return usage, nil
+}
+
 func (self *dockerContainerHandler) Start() {
 	if self.fsHandler != nil {
 		self.fsHandler.Start()
 	}
+	if self.mergedFsHandler != nil {
+		self.mergedFsHandler.Start()
+	}
+	if self.imageFsHandler != nil {
+		self.imageFsHandler.Start()
+	}
 }
 
 func (self *dockerContainerHandler) Cleanup() {
 	if self.fsHandler != nil {
 		self.fsHandler.Stop()
 	}
+	if self.mergedFsHandler != nil {
+		self.mergedFsHandler.Stop()
+	}
+	if self.imageFsHandler != nil {
+		self.imageFsHandler.Stop()
+	}
 }
 
 func (self *dockerContainerHandler) ContainerReference() (info.ContainerReference, error) {
@@ -343,19 +1271,77 @@ func (self *dockerContainerHandler) ContainerReference() (info.ContainerReferenc
 
 func (self *dockerContainerHandler) needNet() bool {
 	if self.includedMetrics.Has(container.NetworkUsageMetrics) {
+		if self.isSandbox {
+			return true
+		}
+		// The network stats of a kubernetes pod are reported by its sandbox,
+		// also for pods using the host network.
+		if self.labels[kubernetesContainerTypeLabel] == kubernetesContainerType {
+			return false
+		}
 		return !self.networkMode.IsContainer()
 	}
 	return false
 }
 
+// IsSandbox returns true if the container is the sandbox (pause) container of
+// a kubernetes pod, which holds the pod's network namespace.
+func (self *dockerContainerHandler) IsSandbox() bool {
+	return self.isSandbox
+}
+
+// GetResourceLimits returns the CPU and memory limits currently set on the
+// container's cgroups, without building its spec.
+func (self *dockerContainerHandler) GetResourceLimits() (info.ContainerResourceLimits, error) {
+	return common.GetResourceLimits(self.cgroupPaths)
+}
+
 func (self *dockerContainerHandler) GetSpec() (info.ContainerSpec, error) {
 	hasFilesystem := self.includedMetrics.Has(container.DiskUsageMetrics)
 	spec, err := common.GetSpec(self.cgroupPaths, self.machineInfoFactory, self.needNet(), hasFilesystem)
 
 	spec.Labels = self.labels
 	spec.Envs = self.envs
+	if self.envMetadataFromProc {
+		if envs, err := self.LiveEnvs(); err == nil {
+			spec.Envs = envs
+		} else {
+			// Reading the environment of another user's process requires
+			// privileges, keep the configured values if it fails.
+			klog.V(4).Infof("unable to read live environment of container %q: %v", self.reference.Name, err)
+		}
+	}
 	spec.Image = self.image
+	spec.ImageID = self.imageID
+	spec.Entrypoint = self.entrypoint
+	spec.Cmd = self.cmd
+	spec.Command = getCommand(self.entrypoint, self.cmd)
 	spec.CreationTime = self.creationTime
+	spec.Ports = self.ports
+	spec.Mounts = self.mounts
+	if spec.HasMemory {
+		spec.Memory.Swappiness = self.memorySwappiness
+	}
+	spec.OomScoreAdj = self.oomScoreAdj
+	spec.Hostname = self.hostname
+	spec.NetworkAliases = self.networkAliases
+	spec.Healthcheck = self.healthcheck
+	spec.Health, spec.RestartCount = self.getState()
+	if self.schedPolicy {
+		if sched, err := self.SchedPolicy(); err == nil {
+			spec.Sched = sched
+		} else {
+			klog.V(4).Infof("unable to read scheduling policy of container %q: %v", self.reference.Name, err)
+		}
+	}
+	// Prefer the live value, it may have been changed after the container started.
+	if self.pid > 0 {
+		if oomScoreAdj, err := readOomScoreAdj(self.rootFs, self.pid); err == nil {
+			spec.OomScoreAdj = oomScoreAdj
+		} else {
+			klog.V(4).Infof("unable to read oom_score_adj for container %q: %v", self.reference.Name, err)
+		}
+	}
 
 	return spec, err
 }
@@ -370,7 +1356,7 @@ func (self *dockerContainerHandler) getFsStats(stats *info.ContainerStats) error
 		common.AssignDeviceNamesToDiskStats((*common.MachineInfoNamer)(mi), &stats.DiskIo)
 	}
 
-	if !self.includedMetrics.Has(container.DiskUsageMetrics) {
+	if !self.includedMetrics.Has(container.DiskUsageMetrics) || !IsSupportedStorageDriver(string(self.storageDriver)) {
 		return nil
 	}
 	var device string
@@ -379,7 +1365,7 @@ func (self *dockerContainerHandler) getFsStats(stats *info.ContainerStats) error
 		// Device has to be the pool name to correlate with the device name as
 		// set in the machine info filesystems.
 		device = self.poolName
-	case aufsStorageDriver, overlayStorageDriver, overlay2StorageDriver:
+	case aufsStorageDriver, overlayStorageDriver, overlay2StorageDriver, btrfsStorageDriver, vfsStorageDriver:
 		deviceInfo, err := self.fsInfo.GetDirFsDevice(self.rootfsStorageDir)
 		if err != nil {
 			return fmt.Errorf("unable to determine device info for dir: %v: %v", self.rootfsStorageDir, err)
@@ -387,8 +1373,6 @@ func (self *dockerContainerHandler) getFsStats(stats *info.ContainerStats) error
 		device = deviceInfo.Device
 	case zfsStorageDriver:
 		device = self.zfsParent
-	default:
-		return nil
 	}
 
 	var (
@@ -404,20 +1388,123 @@ func (self *dockerContainerHandler) getFsStats(stats *info.ContainerStats) error
 			break
 		}
 	}
+	// Except for a quota set on the zfs dataset of the container.
+	if self.storageDriver == zfsStorageDriver && self.zfsWatcher != nil {
+		quota, err := self.zfsWatcher.GetQuota(self.zfsFilesystem)
+		if err != nil {
+			klog.V(5).Infof("unable to get quota from zfs for filesystem %s: %v", self.zfsFilesystem, err)
+		} else if quota != 0 {
+			limit = quota
+		}
+	}
 
 	fsStat := info.FsStats{Device: device, Type: fsType, Limit: limit}
 	usage := self.fsHandler.Usage()
+	if self.fsUsageReported(usage.LastUpdate) {
+		stats.CachedComponents = append(stats.CachedComponents, info.StatsComponentFilesystem)
+	}
+	if usage.Stale {
+		self.recentErrors.Record(self.clock.Now(), fmt.Errorf("base usage of device %q is stale", device))
+	}
 	fsStat.BaseUsage = usage.BaseUsageBytes
+	fsStat.LogUsage = usage.LogUsageBytes
 	fsStat.Usage = usage.TotalUsageBytes
 	fsStat.Inodes = usage.InodeUsage
+	if self.imageFsHandler != nil {
+		if imageUsage := self.imageFsHandler.Usage(); !imageUsage.LastUpdate.IsZero() {
+			fsStat.UsageIncludingImage = usage.TotalUsageBytes + imageUsage.TotalUsageBytes
+		}
+	}
 
 	stats.Filesystem = append(stats.Filesystem, fsStat)
 
+	if self.mergedFsHandler != nil {
+		mergedUsage := self.mergedFsHandler.Usage()
+		stats.Filesystem = append(stats.Filesystem, info.FsStats{
+			Device: device,
+			Type:   fsType,
+			Limit:  limit,
+			Usage:  mergedUsage.TotalUsageBytes,
+			Inodes: mergedUsage.InodeUsage,
+			Merged: true,
+		})
+	}
+
 	return nil
 }
 
-// TODO(vmarmol): Get from libcontainer API instead of cgroup manager when we don't have to support older Dockers.
+// fsUsageReported records lastUpdate as the time of the filesystem usage
+// update reported last and returns whether it had already been reported.
+func (self *dockerContainerHandler) fsUsageReported(lastUpdate time.Time) bool {
+	if lastUpdate.IsZero() {
+		return false
+	}
+	self.lastFsUpdateLock.Lock()
+	defer self.lastFsUpdateLock.Unlock()
+	reported := lastUpdate.Equal(self.lastFsUpdate)
+	self.lastFsUpdate = lastUpdate
+	return reported
+}
+
 func (self *dockerContainerHandler) GetStats() (*info.ContainerStats, error) {
+	return self.GetStatsContext(context.Background())
+}
+
+// GetStatsContext returns the current stats of the container, or the error of
+// ctx if it is done while the stats are collected. Collection that is given up
+// on keeps running in the background and no new collection is started until
+// it finishes.
+func (self *dockerContainerHandler) GetStatsContext(ctx context.Context) (*info.ContainerStats, error) {
+	stats, err := self.getStats(ctx)
+	if err != nil {
+		self.recentErrors.Record(self.clock.Now(), err)
+	}
+	return stats, err
+}
+
+// RecentErrors returns the most recent errors returned by GetStats, oldest first.
+func (self *dockerContainerHandler) RecentErrors() []common.CollectionError {
+	return self.recentErrors.Errors()
+}
+
+// errCollectionInProgress is returned when stats are requested while a previous
+// collection of the container is still running.
+var errCollectionInProgress = errors.New("previous stats collection is still in progress")
+
+// getStats collects the stats of the container in the background and returns
+// them, or the error of ctx if it is done first.
+func (self *dockerContainerHandler) getStats(ctx context.Context) (*info.ContainerStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !atomic.CompareAndSwapInt32(&self.collecting, 0, 1) {
+		return nil, errCollectionInProgress
+	}
+	if ctx.Done() == nil {
+		defer atomic.StoreInt32(&self.collecting, 0)
+		return self.collectStats()
+	}
+
+	type result struct {
+		stats *info.ContainerStats
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer atomic.StoreInt32(&self.collecting, 0)
+		stats, err := self.collectStats()
+		done <- result{stats, err}
+	}()
+	select {
+	case r := <-done:
+		return r.stats, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// TODO(vmarmol): Get from libcontainer API instead of cgroup manager when we don't have to support older Dockers.
+func (self *dockerContainerHandler) collectStats() (*info.ContainerStats, error) {
 	stats, err := self.libcontainerHandler.GetStats()
 	if err != nil {
 		return stats, err
@@ -429,9 +1516,16 @@ func (self *dockerContainerHandler) GetStats() (*info.ContainerStats, error) {
 	if !self.needNet() {
 		stats.Network = info.NetworkStats{}
 	}
+	if self.cpuUsageDeltas {
+		self.setCpuUsageDelta(stats)
+	}
+	stats.Uptime = uptime(self.creationTime, self.clock.Now())
 
 	// Get filesystem stats.
+	start := self.clock.Now()
 	err = self.getFsStats(stats)
+	self.libcontainerHandler.RecordLatency(containerlibcontainer.FsLatencyGroup, self.clock.Since(start))
+	stats.CollectionLatencies = self.libcontainerHandler.CollectionLatencies()
 	if err != nil {
 		return stats, err
 	}
@@ -439,6 +1533,59 @@ func (self *dockerContainerHandler) GetStats() (*info.ContainerStats, error) {
 	return stats, nil
 }
 
+// uptime returns the time between the creation of the container and now,
+// zero if the creation time is unknown or, as the clock was set back, later.
+func uptime(creationTime time.Time, now time.Time) time.Duration {
+	if creationTime.IsZero() || now.Before(creationTime) {
+		return 0
+	}
+	return now.Sub(creationTime)
+}
+
+// setCpuUsageDelta sets the CPU usage since the previous call in stats, next
+// to the cumulative usage. The first call reports no usage.
+func (self *dockerContainerHandler) setCpuUsageDelta(stats *info.ContainerStats) {
+	self.cpuUsageLock.Lock()
+	defer self.cpuUsageLock.Unlock()
+	current := stats.Cpu.Usage
+	current.PerCpu = append([]uint64(nil), current.PerCpu...)
+	if self.lastCpuUsage == nil {
+		self.lastCpuUsage = &info.CpuUsage{}
+		*self.lastCpuUsage = current
+	}
+	delta := cpuUsageDelta(*self.lastCpuUsage, current)
+	stats.Cpu.UsageDelta = &delta
+	*self.lastCpuUsage = current
+}
+
+// cpuUsageDelta returns the CPU usage between two cumulative samples. A
+// counter lower than in the previous sample was reset, its current value is
+// the usage since the reset.
+func cpuUsageDelta(prev, cur info.CpuUsage) info.CpuUsage {
+	delta := func(prev, cur uint64) uint64 {
+		if cur < prev {
+			return cur
+		}
+		return cur - prev
+	}
+	usage := info.CpuUsage{
+		Total:  delta(prev.Total, cur.Total),
+		User:   delta(prev.User, cur.User),
+		System: delta(prev.System, cur.System),
+	}
+	if len(cur.PerCpu) > 0 {
+		usage.PerCpu = make([]uint64, len(cur.PerCpu))
+		for i := range cur.PerCpu {
+			var prevPerCpu uint64
+			if i < len(prev.PerCpu) {
+				prevPerCpu = prev.PerCpu[i]
+			}
+			usage.PerCpu[i] = delta(prevPerCpu, cur.PerCpu[i])
+		}
+	}
+	return usage
+}
+
 func (self *dockerContainerHandler) ListContainers(listType container.ListType) ([]info.ContainerReference, error) {
 	// No-op for Docker driver.
 	return []info.ContainerReference{}, nil
@@ -452,6 +1599,10 @@ func (self *dockerContainerHandler) GetCgroupPath(resource string) (string, erro
 	return path, nil
 }
 
+func (self *dockerContainerHandler) GetRelativeCgroupPath(resource string) (string, error) {
+	return common.GetRelativeCgroupPath(self.cgroupPaths, self.reference.Name, resource)
+}
+
 func (self *dockerContainerHandler) GetContainerLabels() map[string]string {
 	return self.labels
 }
@@ -460,6 +1611,66 @@ func (self *dockerContainerHandler) GetContainerIPAddress() string {
 	return self.ipAddress
 }
 
+// GetContainerIPAddresses returns all IP addresses of the container, the one
+// returned by GetContainerIPAddress first.
+func (self *dockerContainerHandler) GetContainerIPAddresses() []string {
+	return self.ipAddresses
+}
+
+// GetContainerIPv6Address returns the global IPv6 address of the container,
+// if it has one.
+func (self *dockerContainerHandler) GetContainerIPv6Address() string {
+	return self.ipv6Address
+}
+
+// LiveEnvs returns the exposed environment variables of the container as
+// currently set in the environment of its main process.
+func (self *dockerContainerHandler) LiveEnvs() (map[string]string, error) {
+	if self.pid <= 0 {
+		return nil, fmt.Errorf("pid of container %q is unknown", self.reference.Name)
+	}
+	env, err := readProcessEnv(self.rootFs, self.pid)
+	if err != nil {
+		return nil, err
+	}
+	return getMetadataEnvs(env, self.metadataEnvs), nil
+}
+
+// EffectiveCapabilities returns the names of the capabilities in the
+// effective set of the container's main process.
+func (self *dockerContainerHandler) EffectiveCapabilities() ([]string, error) {
+	if self.pid <= 0 {
+		return nil, fmt.Errorf("pid of container %q is unknown", self.reference.Name)
+	}
+	capabilities, err := readEffectiveCapabilities(self.rootFs, self.pid)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("main process of container %q has exited", self.reference.Name)
+	}
+	return capabilities, err
+}
+
+// SchedPolicy returns the scheduling policy and real-time priority of the
+// container's main process.
+func (self *dockerContainerHandler) SchedPolicy() (*info.SchedSpec, error) {
+	if self.pid <= 0 {
+		return nil, fmt.Errorf("pid of container %q is unknown", self.reference.Name)
+	}
+	return readSchedPolicy(self.rootFs, self.pid)
+}
+
+// LogPath returns the path to the container's log file, or an empty string
+// if its logging driver does not write to a file.
+func (self *dockerContainerHandler) LogPath() string {
+	return self.logPath
+}
+
+// RestartsSince returns the number of restarts of the container observed
+// after since, refreshing its restart count from the inspect cache first.
+func (self *dockerContainerHandler) RestartsSince(since time.Time) int {
+	self.getState()
+	return self.restarts.restartsSince(since)
+}
+
 func (self *dockerContainerHandler) ListProcesses(listType container.ListType) ([]int, error) {
 	return self.libcontainerHandler.GetProcesses()
 }
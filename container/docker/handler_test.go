@@ -16,14 +16,151 @@
 package docker
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/matthewygf/cadvisor/container"
+	"github.com/matthewygf/cadvisor/container/common"
+	containerlibcontainer "github.com/matthewygf/cadvisor/container/libcontainer"
+	"github.com/matthewygf/cadvisor/fs"
+	fstest "github.com/matthewygf/cadvisor/fs/testing"
+	info "github.com/matthewygf/cadvisor/info/v1"
+
+	dockertypes "github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	dockernetwork "github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/strslice"
+	docker "github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
 	"github.com/stretchr/testify/assert"
+	clock "k8s.io/utils/clock/testing"
 )
 
+// fakeDockerDaemon serves container inspect requests for a fixed set of
+// containers and counts how often each container is inspected.
+type fakeDockerDaemon struct {
+	sync.Mutex
+	containers map[string]dockertypes.ContainerJSON
+	inspects   map[string]int
+	diskUsage  dockertypes.DiskUsage
+}
+
+func newFakeDockerDaemon(t *testing.T, containers ...dockertypes.ContainerJSON) (*fakeDockerDaemon, *docker.Client, func()) {
+	daemon := &fakeDockerDaemon{
+		containers: make(map[string]dockertypes.ContainerJSON),
+		inspects:   make(map[string]int),
+	}
+	for _, ctnr := range containers {
+		daemon.containers[ctnr.ID] = ctnr
+	}
+	server := httptest.NewServer(daemon)
+	client, err := docker.NewClient("tcp://"+strings.TrimPrefix(server.URL, "http://"), "", nil, nil)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return daemon, client, server.Close
+}
+
+func (d *fakeDockerDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/system/df" {
+		d.Lock()
+		defer d.Unlock()
+		json.NewEncoder(w).Encode(d.diskUsage)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "containers" || parts[2] != "json" {
+		http.NotFound(w, r)
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+	d.inspects[parts[1]]++
+	ctnr, ok := d.containers[parts[1]]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "No such container: " + parts[1]})
+		return
+	}
+	json.NewEncoder(w).Encode(ctnr)
+}
+
+func (d *fakeDockerDaemon) inspectCount(id string) int {
+	d.Lock()
+	defer d.Unlock()
+	return d.inspects[id]
+}
+
+// newTestContainerJSON returns the inspect result of a running container.
+func newTestContainerJSON(id string) dockertypes.ContainerJSON {
+	return dockertypes.ContainerJSON{
+		ContainerJSONBase: &dockertypes.ContainerJSONBase{
+			ID:         id,
+			Name:       "/test",
+			Created:    "2018-01-01T00:00:00Z",
+			State:      &dockertypes.ContainerState{Running: true, Pid: 1},
+			HostConfig: &dockercontainer.HostConfig{},
+		},
+		Config:          &dockercontainer.Config{Labels: map[string]string{}},
+		NetworkSettings: &dockertypes.NetworkSettings{},
+	}
+}
+
+type testHandlerOptions struct {
+	storageDriver       storageDriver
+	includedMetrics     container.MetricSet
+	metadataEnvs        []string
+	disableContainerIP  bool
+	envMetadataFromProc bool
+	cniResultDir        string
+	preferredNetwork    string
+	skipDiskUsageLabel  string
+	inspectCache        *inspectCache
+	collectionLatency   bool
+}
+
+// newTestDockerContainerHandler creates a handler for the container with the
+// given id, which must be known to the client.
+func newTestDockerContainerHandler(client *docker.Client, id string, opts testHandlerOptions) (*dockerContainerHandler, error) {
+	if opts.storageDriver == "" {
+		opts.storageDriver = "vfs"
+	}
+	handler, err := newDockerContainerHandler(client, "/docker/"+id, true, &handlerOptions{
+		machineInfoFactory:  &fakeMachineInfoFactory{},
+		fsInfo:              &fakeFsInfo{},
+		storageDriver:       opts.storageDriver,
+		storageDir:          "/var/lib/docker",
+		cgroupSubsystems:    &containerlibcontainer.CgroupSubsystems{},
+		metadataEnvs:        opts.metadataEnvs,
+		dockerVersion:       []int{1, 9, 0},
+		includedMetrics:     opts.includedMetrics,
+		clock:               clock.NewFakeClock(time.Unix(1000, 0)),
+		disableContainerIP:  opts.disableContainerIP,
+		envMetadataFromProc: opts.envMetadataFromProc,
+		cniResultDir:        opts.cniResultDir,
+		preferredNetwork:    opts.preferredNetwork,
+		skipDiskUsageLabel:  opts.skipDiskUsageLabel,
+		inspectCache:        opts.inspectCache,
+		collectionLatency:   opts.collectionLatency,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return handler.(*dockerContainerHandler), nil
+}
+
 func TestStorageDirDetectionWithOldVersions(t *testing.T) {
 	as := assert.New(t)
 	rwLayer, err := getRwLayerID("abcd", "/", aufsStorageDriver, []int{1, 9, 0})
@@ -48,3 +185,1482 @@ func TestStorageDirDetectionWithNewVersions(t *testing.T) {
 	as.Equal(rwLayer, randomizedID)
 
 }
+
+func TestGetPortMappings(t *testing.T) {
+	as := assert.New(t)
+	portMap := nat.PortMap{
+		"80/tcp": []nat.PortBinding{
+			{HostIP: "0.0.0.0", HostPort: "8080"},
+			{HostIP: "127.0.0.1", HostPort: "8081"},
+		},
+		"53/udp": []nat.PortBinding{
+			{HostIP: "0.0.0.0", HostPort: "5353"},
+		},
+		"9090/tcp": nil,
+	}
+	expected := []info.PortMapping{
+		{ContainerPort: 53, Protocol: "udp", HostIP: "0.0.0.0", HostPort: 5353},
+		{ContainerPort: 80, Protocol: "tcp", HostIP: "0.0.0.0", HostPort: 8080},
+		{ContainerPort: 80, Protocol: "tcp", HostIP: "127.0.0.1", HostPort: 8081},
+		{ContainerPort: 9090, Protocol: "tcp"},
+	}
+	as.Equal(expected, getPortMappings(portMap))
+}
+
+func TestGetPortMappingsNoPorts(t *testing.T) {
+	as := assert.New(t)
+	as.Empty(getPortMappings(nil))
+	as.Empty(getPortMappings(nat.PortMap{}))
+}
+
+func TestMounts(t *testing.T) {
+	as := assert.New(t)
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.Mounts = []dockertypes.MountPoint{
+		{
+			Type:        "bind",
+			Source:      "/etc/ssl/certs",
+			Destination: "/etc/ssl/certs",
+			Mode:        "ro",
+			RW:          false,
+		},
+		{
+			Type:        "volume",
+			Name:        "data",
+			Source:      "/var/lib/docker/volumes/data/_data",
+			Destination: "/data",
+			Driver:      "local",
+			Mode:        "z",
+			RW:          true,
+		},
+	}
+	noMounts := newTestContainerJSON("efgh")
+	_, client, cleanup := newFakeDockerDaemon(t, ctnr, noMounts)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	spec, err := handler.GetSpec()
+	as.Nil(err)
+	as.Equal([]info.Mount{
+		{Type: "bind", Source: "/etc/ssl/certs", Destination: "/etc/ssl/certs", Mode: "ro", RW: false},
+		{Type: "volume", Name: "data", Source: "/var/lib/docker/volumes/data/_data", Destination: "/data", Mode: "z", RW: true},
+	}, spec.Mounts)
+
+	handler, err = newTestDockerContainerHandler(client, "efgh", testHandlerOptions{})
+	as.Nil(err)
+	spec, err = handler.GetSpec()
+	as.Nil(err)
+	as.Nil(spec.Mounts)
+}
+
+func TestNetworkAliases(t *testing.T) {
+	as := assert.New(t)
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.NetworkSettings.Networks = map[string]*dockernetwork.EndpointSettings{
+		"frontend": {Aliases: []string{"web", "abcd"}},
+		"backend":  {Aliases: []string{"api"}},
+		"bridge":   {},
+	}
+	noAliases := newTestContainerJSON("efgh")
+	noAliases.NetworkSettings.Networks = map[string]*dockernetwork.EndpointSettings{
+		"bridge": {},
+	}
+	_, client, cleanup := newFakeDockerDaemon(t, ctnr, noAliases)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	spec, err := handler.GetSpec()
+	as.Nil(err)
+	as.Equal(map[string][]string{
+		"frontend": {"web", "abcd"},
+		"backend":  {"api"},
+	}, spec.NetworkAliases)
+
+	handler, err = newTestDockerContainerHandler(client, "efgh", testHandlerOptions{})
+	as.Nil(err)
+	spec, err = handler.GetSpec()
+	as.Nil(err)
+	as.Nil(spec.NetworkAliases)
+}
+
+func TestHealthcheck(t *testing.T) {
+	as := assert.New(t)
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.Config.Healthcheck = &dockercontainer.HealthConfig{
+		Test:     []string{"CMD-SHELL", "curl -f http://localhost/"},
+		Interval: 10 * time.Second,
+		Timeout:  time.Second,
+		Retries:  3,
+	}
+	noHealthcheck := newTestContainerJSON("efgh")
+	disabled := newTestContainerJSON("ijkl")
+	disabled.Config.Healthcheck = &dockercontainer.HealthConfig{Test: []string{"NONE"}}
+	_, client, cleanup := newFakeDockerDaemon(t, ctnr, noHealthcheck, disabled)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	spec, err := handler.GetSpec()
+	as.Nil(err)
+	as.Equal(&info.HealthcheckSpec{
+		Test:     []string{"CMD-SHELL", "curl -f http://localhost/"},
+		Interval: 10 * time.Second,
+		Timeout:  time.Second,
+		Retries:  3,
+	}, spec.Healthcheck)
+
+	for _, id := range []string{"efgh", "ijkl"} {
+		handler, err = newTestDockerContainerHandler(client, id, testHandlerOptions{})
+		as.Nil(err)
+		spec, err = handler.GetSpec()
+		as.Nil(err)
+		as.Nil(spec.Healthcheck, "container %s", id)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	as := assert.New(t)
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.Config.Entrypoint = strslice.StrSlice{"/docker-entrypoint.sh"}
+	ctnr.Config.Cmd = strslice.StrSlice{"nginx", "-g", "daemon off;"}
+	noCommand := newTestContainerJSON("efgh")
+	_, client, cleanup := newFakeDockerDaemon(t, ctnr, noCommand)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	spec, err := handler.GetSpec()
+	as.Nil(err)
+	as.Equal([]string{"/docker-entrypoint.sh"}, spec.Entrypoint)
+	as.Equal([]string{"nginx", "-g", "daemon off;"}, spec.Cmd)
+	as.Equal(`/docker-entrypoint.sh nginx -g "daemon off;"`, spec.Command)
+
+	handler, err = newTestDockerContainerHandler(client, "efgh", testHandlerOptions{})
+	as.Nil(err)
+	spec, err = handler.GetSpec()
+	as.Nil(err)
+	as.Empty(spec.Entrypoint)
+	as.Empty(spec.Cmd)
+	as.Equal("", spec.Command)
+}
+
+func TestGetCommand(t *testing.T) {
+	for _, tc := range []struct {
+		entrypoint []string
+		cmd        []string
+		expected   string
+	}{
+		{nil, nil, ""},
+		{nil, []string{"sleep", "3600"}, "sleep 3600"},
+		{[]string{"/bin/sh", "-c"}, []string{"echo 'hi' && sleep 1"}, `/bin/sh -c "echo 'hi' && sleep 1"`},
+		{[]string{"/app"}, []string{"--name", ""}, `/app --name ""`},
+	} {
+		assert.Equal(t, tc.expected, getCommand(tc.entrypoint, tc.cmd))
+	}
+}
+
+func TestRestartCount(t *testing.T) {
+	as := assert.New(t)
+	restarted := newTestContainerJSON("abcd")
+	restarted.RestartCount = 3
+	neverRestarted := newTestContainerJSON("efgh")
+	_, client, cleanup := newFakeDockerDaemon(t, restarted, neverRestarted)
+	defer cleanup()
+
+	for id, restartCount := range map[string]int{"abcd": 3, "efgh": 0} {
+		handler, err := newTestDockerContainerHandler(client, id, testHandlerOptions{})
+		as.Nil(err)
+		spec, err := handler.GetSpec()
+		as.Nil(err)
+		as.Equal(restartCount, spec.RestartCount, id)
+		as.NotContains(spec.Labels, "restartcount", id)
+		as.NotContains(handler.GetContainerLabels(), "restartcount", id)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	as := assert.New(t)
+	healthy := newTestContainerJSON("abcd")
+	healthy.State.Health = &dockertypes.Health{Status: dockertypes.Healthy}
+	unhealthy := newTestContainerJSON("efgh")
+	unhealthy.State.Health = &dockertypes.Health{Status: dockertypes.Unhealthy, FailingStreak: 3}
+	noHealth := newTestContainerJSON("ijkl")
+	_, client, cleanup := newFakeDockerDaemon(t, healthy, unhealthy, noHealth)
+	defer cleanup()
+
+	for id, expected := range map[string]string{
+		"abcd": "healthy",
+		"efgh": "unhealthy",
+		"ijkl": "",
+	} {
+		handler, err := newTestDockerContainerHandler(client, id, testHandlerOptions{})
+		as.Nil(err)
+		spec, err := handler.GetSpec()
+		as.Nil(err)
+		as.Equal(expected, spec.Health, "container %s", id)
+	}
+}
+
+func TestHealthChanges(t *testing.T) {
+	as := assert.New(t)
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.Config.Healthcheck = &dockercontainer.HealthConfig{Test: []string{"CMD", "true"}}
+	ctnr.State.Health = &dockertypes.Health{Status: dockertypes.Starting}
+	daemon, client, cleanup := newFakeDockerDaemon(t, ctnr)
+	defer cleanup()
+	fakeClock := clock.NewFakeClock(time.Unix(1000, 0))
+	cache := newInspectCache(func(id string) (dockertypes.ContainerJSON, error) {
+		return client.ContainerInspect(context.Background(), id)
+	}, time.Second, fakeClock)
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{inspectCache: cache})
+	as.Nil(err)
+	spec, err := handler.GetSpec()
+	as.Nil(err)
+	as.Equal(dockertypes.Starting, spec.Health)
+
+	daemon.Lock()
+	ctnr.State.Health = &dockertypes.Health{Status: dockertypes.Healthy}
+	daemon.containers["abcd"] = ctnr
+	daemon.Unlock()
+	inspects := daemon.inspectCount("abcd")
+
+	// The cached inspect result is reused until it expires.
+	for i := 0; i < 3; i++ {
+		spec, err = handler.GetSpec()
+		as.Nil(err)
+		as.Equal(dockertypes.Starting, spec.Health)
+	}
+	as.Equal(inspects, daemon.inspectCount("abcd"))
+
+	fakeClock.Step(time.Second)
+	spec, err = handler.GetSpec()
+	as.Nil(err)
+	as.Equal(dockertypes.Healthy, spec.Health)
+}
+
+func TestHealthWithoutInspectCache(t *testing.T) {
+	as := assert.New(t)
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.Config.Healthcheck = &dockercontainer.HealthConfig{Test: []string{"CMD", "true"}}
+	ctnr.State.Health = &dockertypes.Health{Status: dockertypes.Starting}
+	daemon, client, cleanup := newFakeDockerDaemon(t, ctnr)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	inspects := daemon.inspectCount("abcd")
+	spec, err := handler.GetSpec()
+	as.Nil(err)
+	as.Equal(dockertypes.Starting, spec.Health)
+	as.Equal(inspects, daemon.inspectCount("abcd"))
+}
+
+func TestGetMemorySwappiness(t *testing.T) {
+	as := assert.New(t)
+	swappiness := int64(10)
+	hostConfig := &dockercontainer.HostConfig{}
+	hostConfig.MemorySwappiness = &swappiness
+	actual := getMemorySwappiness(hostConfig)
+	if as.NotNil(actual) {
+		as.Equal(uint64(10), *actual)
+	}
+}
+
+func TestGetMemorySwappinessDefault(t *testing.T) {
+	as := assert.New(t)
+	as.Nil(getMemorySwappiness(nil))
+	as.Nil(getMemorySwappiness(&dockercontainer.HostConfig{}))
+	unset := int64(-1)
+	hostConfig := &dockercontainer.HostConfig{}
+	hostConfig.MemorySwappiness = &unset
+	as.Nil(getMemorySwappiness(hostConfig))
+}
+
+func TestReadOomScoreAdj(t *testing.T) {
+	as := assert.New(t)
+	testDir, err := ioutil.TempDir("", "")
+	as.Nil(err)
+	defer os.RemoveAll(testDir)
+	procDir := path.Join(testDir, "proc", "1234")
+	as.Nil(os.MkdirAll(procDir, os.ModePerm))
+	as.Nil(ioutil.WriteFile(path.Join(procDir, "oom_score_adj"), []byte("-500\n"), os.ModePerm))
+
+	oomScoreAdj, err := readOomScoreAdj(testDir, 1234)
+	as.Nil(err)
+	as.Equal(-500, oomScoreAdj)
+
+	_, err = readOomScoreAdj(testDir, 4321)
+	as.NotNil(err)
+}
+
+func TestEffectiveCapabilities(t *testing.T) {
+	as := assert.New(t)
+	rootFs, err := ioutil.TempDir("", "")
+	as.Nil(err)
+	defer os.RemoveAll(rootFs)
+	procDir := path.Join(rootFs, "proc", "1234")
+	as.Nil(os.MkdirAll(procDir, os.ModePerm))
+	status := "Name:\tnginx\nCapInh:\t0000000000000000\nCapPrm:\t00000000a80425fb\nCapEff:\t0000000000003001\nCapBnd:\t00000000a80425fb\n"
+	as.Nil(ioutil.WriteFile(path.Join(procDir, "status"), []byte(status), os.ModePerm))
+
+	handler := &dockerContainerHandler{rootFs: rootFs, pid: 1234}
+	capabilities, err := handler.EffectiveCapabilities()
+	as.Nil(err)
+	as.Equal([]string{"CAP_CHOWN", "CAP_NET_ADMIN", "CAP_NET_RAW"}, capabilities)
+
+	// The container has exited.
+	handler = &dockerContainerHandler{rootFs: rootFs, pid: 4321}
+	_, err = handler.EffectiveCapabilities()
+	as.NotNil(err)
+	handler = &dockerContainerHandler{rootFs: rootFs}
+	_, err = handler.EffectiveCapabilities()
+	as.NotNil(err)
+}
+
+func TestSchedPolicy(t *testing.T) {
+	as := assert.New(t)
+	rootFs, err := ioutil.TempDir("", "")
+	as.Nil(err)
+	defer os.RemoveAll(rootFs)
+	procDir := path.Join(rootFs, "proc", "1234")
+	as.Nil(os.MkdirAll(procDir, os.ModePerm))
+	// A SCHED_FIFO process with real-time priority 50 and a command name
+	// containing spaces.
+	stat := "1234 (rt worker) S 1 1234 1234 0 -1 4194560 152 0 0 0 10 5 0 0 -51 0 1 0 1843 4419584 190 18446744073709551615 1 1 0 0 0 0 0 4096 0 0 0 0 17 2 50 1 0 0 0 0 0 0 0 0 0 0 0\n"
+	as.Nil(ioutil.WriteFile(path.Join(procDir, "stat"), []byte(stat), os.ModePerm))
+
+	handler := &dockerContainerHandler{rootFs: rootFs, pid: 1234, schedPolicy: true}
+	sched, err := handler.SchedPolicy()
+	as.Nil(err)
+	as.Equal(&info.SchedSpec{Policy: "SCHED_FIFO", Priority: 50}, sched)
+
+	// The process has exited.
+	handler = &dockerContainerHandler{rootFs: rootFs, pid: 4321}
+	_, err = handler.SchedPolicy()
+	as.NotNil(err)
+}
+
+type fakeMachineInfoFactory struct {
+	machineInfo info.MachineInfo
+	err         error
+}
+
+func (f *fakeMachineInfoFactory) GetMachineInfo() (*info.MachineInfo, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &f.machineInfo, nil
+}
+
+func (f *fakeMachineInfoFactory) GetVersionInfo() (*info.VersionInfo, error) {
+	return &info.VersionInfo{}, nil
+}
+
+// fakeFsInfo places every directory on a single device and measures real
+// directory usage.
+type fakeFsInfo struct {
+	fs.FsInfo
+	device string
+}
+
+func (f *fakeFsInfo) GetDirFsDevice(dir string) (*fs.DeviceInfo, error) {
+	return &fs.DeviceInfo{Device: f.device}, nil
+}
+
+func (f *fakeFsInfo) GetDirUsage(dir string) (fs.UsageInfo, error) {
+	return fs.GetDirUsage(dir)
+}
+
+type fakeFsHandler struct {
+	usage common.FsUsage
+}
+
+func (f *fakeFsHandler) Start()                {}
+func (f *fakeFsHandler) Stop()                 {}
+func (f *fakeFsHandler) Usage() common.FsUsage { return f.usage }
+
+func TestGetDeviceID(t *testing.T) {
+	as := assert.New(t)
+	deviceID, err := getDeviceID(dockertypes.GraphDriverData{Name: "devicemapper", Data: map[string]string{"DeviceId": "42"}})
+	as.Nil(err)
+	as.Equal("42", deviceID)
+
+	_, err = getDeviceID(dockertypes.GraphDriverData{Name: "devicemapper"})
+	as.NotNil(err)
+	_, err = getDeviceID(dockertypes.GraphDriverData{Name: "devicemapper", Data: map[string]string{"DeviceId": "not-a-number"}})
+	as.NotNil(err)
+}
+
+func TestDevicemapperHandlerWithoutDeviceID(t *testing.T) {
+	as := assert.New(t)
+	ctnr := newTestContainerJSON("abcd")
+	_, client, cleanup := newFakeDockerDaemon(t, ctnr)
+	defer cleanup()
+
+	opts := testHandlerOptions{
+		storageDriver:   devicemapperStorageDriver,
+		includedMetrics: container.MetricSet{container.DiskUsageMetrics: struct{}{}},
+	}
+	_, err := newTestDockerContainerHandler(client, "abcd", opts)
+	if as.NotNil(err) {
+		as.Contains(err.Error(), "no device id")
+	}
+
+	// The device id is not needed without disk usage metrics.
+	opts.includedMetrics = container.MetricSet{}
+	_, err = newTestDockerContainerHandler(client, "abcd", opts)
+	as.Nil(err)
+}
+
+func TestMatchesLabelSelector(t *testing.T) {
+	as := assert.New(t)
+	labels := map[string]string{"cadvisor.io/skip-du": "true"}
+	as.True(matchesLabelSelector(labels, "cadvisor.io/skip-du=true"))
+	as.True(matchesLabelSelector(labels, "cadvisor.io/skip-du"))
+	as.False(matchesLabelSelector(labels, "cadvisor.io/skip-du=false"))
+	as.False(matchesLabelSelector(labels, "other"))
+	as.False(matchesLabelSelector(labels, ""))
+}
+
+func TestSkipDiskUsageLabel(t *testing.T) {
+	as := assert.New(t)
+	skipped := newTestContainerJSON("skipped")
+	skipped.Config.Labels["cadvisor.io/skip-du"] = "true"
+	collected := newTestContainerJSON("collected")
+	collected.Config.Labels["cadvisor.io/skip-du"] = "false"
+	_, client, cleanup := newFakeDockerDaemon(t, skipped, collected)
+	defer cleanup()
+
+	opts := testHandlerOptions{
+		includedMetrics:    container.MetricSet{container.DiskUsageMetrics: struct{}{}, container.CpuUsageMetrics: struct{}{}},
+		skipDiskUsageLabel: "cadvisor.io/skip-du=true",
+	}
+	handler, err := newTestDockerContainerHandler(client, "skipped", opts)
+	as.Nil(err)
+	as.Nil(handler.fsHandler)
+	as.False(handler.includedMetrics.Has(container.DiskUsageMetrics))
+	as.True(handler.includedMetrics.Has(container.CpuUsageMetrics))
+	stats := &info.ContainerStats{}
+	as.Nil(handler.getFsStats(stats))
+	as.Empty(stats.Filesystem)
+	// The metrics of the factory are left alone.
+	as.True(opts.includedMetrics.Has(container.DiskUsageMetrics))
+
+	handler, err = newTestDockerContainerHandler(client, "collected", opts)
+	as.Nil(err)
+	as.NotNil(handler.fsHandler)
+	as.True(handler.includedMetrics.Has(container.DiskUsageMetrics))
+}
+
+func TestGetMergedDir(t *testing.T) {
+	as := assert.New(t)
+	graphDriver := dockertypes.GraphDriverData{
+		Name: "overlay2",
+		Data: map[string]string{"MergedDir": "/var/lib/docker/overlay2/abcd/merged"},
+	}
+	as.Equal("/rootfs/var/lib/docker/overlay2/abcd/merged", getMergedDir(graphDriver, overlay2StorageDriver, "/rootfs"))
+	as.Equal("/var/lib/docker/overlay2/abcd/merged", getMergedDir(graphDriver, overlay2StorageDriver, "/"))
+	as.Equal("", getMergedDir(graphDriver, devicemapperStorageDriver, "/"))
+	as.Equal("", getMergedDir(dockertypes.GraphDriverData{}, overlay2StorageDriver, "/"))
+}
+
+func TestGetFsStatsMergedView(t *testing.T) {
+	as := assert.New(t)
+	mergedDir, err := ioutil.TempDir("", "merged")
+	as.Nil(err)
+	defer os.RemoveAll(mergedDir)
+	as.Nil(ioutil.WriteFile(path.Join(mergedDir, "layer"), make([]byte, 64*1024), os.ModePerm))
+
+	fsInfo := &fakeFsInfo{device: "/dev/sda1"}
+	mergedFsHandler := common.NewFsHandler(common.DefaultPeriod, mergedDir, "", fsInfo)
+	mergedFsHandler.Start()
+	defer mergedFsHandler.Stop()
+
+	handler := &dockerContainerHandler{
+		machineInfoFactory: &fakeMachineInfoFactory{machineInfo: info.MachineInfo{
+			Filesystems: []info.FsInfo{{Device: "/dev/sda1", Type: "ext4", Capacity: 1 << 30}},
+		}},
+		storageDriver:   overlay2StorageDriver,
+		fsInfo:          fsInfo,
+		includedMetrics: container.MetricSet{container.DiskUsageMetrics: struct{}{}},
+		fsHandler:       &fakeFsHandler{usage: common.FsUsage{BaseUsageBytes: 10, TotalUsageBytes: 20, InodeUsage: 2}},
+		mergedFsHandler: mergedFsHandler,
+	}
+
+	// Wait for the initial usage update of the merged view.
+	for i := 0; i < 100 && mergedFsHandler.Usage().TotalUsageBytes == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := &info.ContainerStats{}
+	as.Nil(handler.getFsStats(stats))
+	if as.Len(stats.Filesystem, 2) {
+		as.Equal(info.FsStats{Device: "/dev/sda1", Type: "ext4", Limit: 1 << 30, Usage: 20, BaseUsage: 10, Inodes: 2}, stats.Filesystem[0])
+		merged := stats.Filesystem[1]
+		as.True(merged.Merged)
+		as.Equal("/dev/sda1", merged.Device)
+		as.True(merged.Usage >= 64*1024, "expected merged usage to include the layer file, got %d", merged.Usage)
+	}
+}
+
+func TestGetFsStatsStorageDrivers(t *testing.T) {
+	const rootfsStorageDir = "/var/lib/docker/overlay2/abcd/diff"
+	fsInfo := fstest.NewFakeFsInfo()
+	fsInfo.DirDevices[rootfsStorageDir] = &fs.DeviceInfo{Device: "/dev/sda1"}
+	machineInfoFactory := &fakeMachineInfoFactory{machineInfo: info.MachineInfo{
+		Filesystems: []info.FsInfo{
+			{Device: "/dev/sda1", Type: "ext4", Capacity: 1 << 30},
+			{Device: "docker-pool", Type: "devicemapper", Capacity: 2 << 30},
+			{Device: "tank/docker", Type: "zfs", Capacity: 3 << 30},
+		},
+	}}
+	usage := common.FsUsage{BaseUsageBytes: 10, TotalUsageBytes: 20, InodeUsage: 2}
+
+	for _, test := range []struct {
+		storageDriver    storageDriver
+		rootfsStorageDir string
+		expected         []info.FsStats
+		expectErr        bool
+	}{
+		{
+			storageDriver:    aufsStorageDriver,
+			rootfsStorageDir: rootfsStorageDir,
+			expected:         []info.FsStats{{Device: "/dev/sda1", Type: "ext4", Limit: 1 << 30, Usage: 20, BaseUsage: 10, Inodes: 2}},
+		},
+		{
+			storageDriver:    overlayStorageDriver,
+			rootfsStorageDir: rootfsStorageDir,
+			expected:         []info.FsStats{{Device: "/dev/sda1", Type: "ext4", Limit: 1 << 30, Usage: 20, BaseUsage: 10, Inodes: 2}},
+		},
+		{
+			storageDriver:    overlay2StorageDriver,
+			rootfsStorageDir: rootfsStorageDir,
+			expected:         []info.FsStats{{Device: "/dev/sda1", Type: "ext4", Limit: 1 << 30, Usage: 20, BaseUsage: 10, Inodes: 2}},
+		},
+		{
+			// The device of the storage dir is unknown.
+			storageDriver:    overlay2StorageDriver,
+			rootfsStorageDir: "/var/lib/docker/overlay2/unknown/diff",
+			expectErr:        true,
+		},
+		{
+			storageDriver:    btrfsStorageDriver,
+			rootfsStorageDir: rootfsStorageDir,
+			expected:         []info.FsStats{{Device: "/dev/sda1", Type: "ext4", Limit: 1 << 30, Usage: 20, BaseUsage: 10, Inodes: 2}},
+		},
+		{
+			storageDriver:    vfsStorageDriver,
+			rootfsStorageDir: rootfsStorageDir,
+			expected:         []info.FsStats{{Device: "/dev/sda1", Type: "ext4", Limit: 1 << 30, Usage: 20, BaseUsage: 10, Inodes: 2}},
+		},
+		{
+			storageDriver: devicemapperStorageDriver,
+			expected:      []info.FsStats{{Device: "docker-pool", Type: "devicemapper", Limit: 2 << 30, Usage: 20, BaseUsage: 10, Inodes: 2}},
+		},
+		{
+			storageDriver: zfsStorageDriver,
+			expected:      []info.FsStats{{Device: "tank/docker", Type: "zfs", Limit: 3 << 30, Usage: 20, BaseUsage: 10, Inodes: 2}},
+		},
+		{
+			// Filesystem stats are not reported for unsupported drivers.
+			storageDriver: "windowsfilter",
+		},
+	} {
+		handler := &dockerContainerHandler{
+			machineInfoFactory: machineInfoFactory,
+			storageDriver:      test.storageDriver,
+			fsInfo:             fsInfo,
+			rootfsStorageDir:   test.rootfsStorageDir,
+			poolName:           "docker-pool",
+			zfsParent:          "tank/docker",
+			includedMetrics:    container.MetricSet{container.DiskUsageMetrics: struct{}{}},
+			fsHandler:          &fakeFsHandler{usage: usage},
+		}
+		stats := &info.ContainerStats{}
+		err := handler.getFsStats(stats)
+		if test.expectErr {
+			assert.Error(t, err, "storage driver %q", test.storageDriver)
+			continue
+		}
+		assert.NoError(t, err, "storage driver %q", test.storageDriver)
+		assert.Equal(t, test.expected, stats.Filesystem, "storage driver %q", test.storageDriver)
+	}
+}
+
+type fakeZfsWatcher struct {
+	usage  map[string]uint64
+	quotas map[string]uint64
+}
+
+func (w *fakeZfsWatcher) GetUsage(filesystem string) (uint64, error) {
+	usage, ok := w.usage[filesystem]
+	if !ok {
+		return 0, fmt.Errorf("no cached value for usage of filesystem %v", filesystem)
+	}
+	return usage, nil
+}
+
+func (w *fakeZfsWatcher) GetQuota(filesystem string) (uint64, error) {
+	quota, ok := w.quotas[filesystem]
+	if !ok {
+		return 0, fmt.Errorf("no cached value for quota of filesystem %v", filesystem)
+	}
+	return quota, nil
+}
+
+func TestGetFsStatsZfsQuota(t *testing.T) {
+	machineInfoFactory := &fakeMachineInfoFactory{machineInfo: info.MachineInfo{
+		Filesystems: []info.FsInfo{{Device: "tank/docker", Type: "zfs", Capacity: 3 << 30}},
+	}}
+	watcher := &fakeZfsWatcher{quotas: map[string]uint64{
+		"tank/docker/quota":    1 << 30,
+		"tank/docker/no-quota": 0,
+	}}
+	for filesystem, expectedLimit := range map[string]uint64{
+		"tank/docker/quota":    1 << 30,
+		"tank/docker/no-quota": 3 << 30,
+		// The quota is not known yet.
+		"tank/docker/unknown": 3 << 30,
+	} {
+		handler := &dockerContainerHandler{
+			machineInfoFactory: machineInfoFactory,
+			storageDriver:      zfsStorageDriver,
+			zfsParent:          "tank/docker",
+			zfsWatcher:         watcher,
+			zfsFilesystem:      filesystem,
+			includedMetrics:    container.MetricSet{container.DiskUsageMetrics: struct{}{}},
+			fsHandler:          &fakeFsHandler{},
+		}
+		stats := &info.ContainerStats{}
+		assert.NoError(t, handler.getFsStats(stats), filesystem)
+		if assert.Len(t, stats.Filesystem, 1, filesystem) {
+			assert.Equal(t, expectedLimit, stats.Filesystem[0].Limit, filesystem)
+		}
+	}
+}
+
+func TestBtrfsRootfsStorageDir(t *testing.T) {
+	as := assert.New(t)
+	_, client, cleanup := newFakeDockerDaemon(t, newTestContainerJSON("abcd"))
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{storageDriver: btrfsStorageDriver})
+	as.Nil(err)
+	as.Equal("/var/lib/docker/btrfs/subvolumes/abcd", handler.rootfsStorageDir)
+}
+
+func TestVfsRootfsStorageDir(t *testing.T) {
+	as := assert.New(t)
+	_, client, cleanup := newFakeDockerDaemon(t, newTestContainerJSON("abcd"))
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{storageDriver: vfsStorageDriver})
+	as.Nil(err)
+	as.Equal("/var/lib/docker/vfs/dir/abcd", handler.rootfsStorageDir)
+}
+
+func TestVfsFsUsage(t *testing.T) {
+	as := assert.New(t)
+	storageDir, err := ioutil.TempDir("", "docker")
+	as.Nil(err)
+	defer os.RemoveAll(storageDir)
+	rootfsDir := path.Join(storageDir, "vfs", "dir", "abcd")
+	as.Nil(os.MkdirAll(path.Join(rootfsDir, "usr", "bin"), os.ModePerm))
+	// Files copied from the image count towards the base usage.
+	as.Nil(ioutil.WriteFile(path.Join(rootfsDir, "usr", "bin", "app"), make([]byte, 64*1024), os.ModePerm))
+	containerDir := path.Join(storageDir, "containers", "abcd")
+	as.Nil(os.MkdirAll(containerDir, os.ModePerm))
+
+	fsInfo := &fakeFsInfo{device: "/dev/sda1"}
+	fsHandler := common.NewFsHandler(common.DefaultPeriod, rootfsDir, containerDir, fsInfo)
+	fsHandler.Start()
+	defer fsHandler.Stop()
+	for i := 0; i < 100 && fsHandler.Usage().BaseUsageBytes == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	handler := &dockerContainerHandler{
+		machineInfoFactory: &fakeMachineInfoFactory{machineInfo: info.MachineInfo{
+			Filesystems: []info.FsInfo{{Device: "/dev/sda1", Type: "ext4", Capacity: 1 << 30}},
+		}},
+		storageDriver:    vfsStorageDriver,
+		fsInfo:           fsInfo,
+		rootfsStorageDir: rootfsDir,
+		includedMetrics:  container.MetricSet{container.DiskUsageMetrics: struct{}{}},
+		fsHandler:        fsHandler,
+	}
+	stats := &info.ContainerStats{}
+	as.Nil(handler.getFsStats(stats))
+	if as.Len(stats.Filesystem, 1) {
+		as.True(stats.Filesystem[0].BaseUsage >= 64*1024, "expected base usage to include the image files, got %d", stats.Filesystem[0].BaseUsage)
+		as.True(stats.Filesystem[0].Usage >= stats.Filesystem[0].BaseUsage, "expected usage to include the base usage, got %d", stats.Filesystem[0].Usage)
+	}
+}
+
+// fakeThinPoolWatcher is a thin pool watcher whose cache has been refreshed
+// refreshes times and holds the usage of the devices in usage.
+type fakeThinPoolWatcher struct {
+	usage     map[string]uint64
+	refreshes uint64
+}
+
+func (w *fakeThinPoolWatcher) GetUsage(deviceId string) (uint64, error) {
+	usage, ok := w.usage[deviceId]
+	if !ok {
+		return 0, fmt.Errorf("no cached value for usage of device %v", deviceId)
+	}
+	return usage, nil
+}
+
+func (w *fakeThinPoolWatcher) Refreshes() uint64 {
+	return w.refreshes
+}
+
+func TestDockerFsHandlerThinPoolUsage(t *testing.T) {
+	as := assert.New(t)
+	watcher := &fakeThinPoolWatcher{usage: map[string]uint64{"1": 1000}, refreshes: 1}
+	fsHandler := &dockerFsHandler{
+		fsHandler:       &fakeFsHandler{usage: common.FsUsage{BaseUsageBytes: 10, TotalUsageBytes: 50}},
+		thinPoolWatcher: watcher,
+		deviceID:        "1",
+	}
+	usage := fsHandler.Usage()
+	as.Equal(uint64(1000), usage.BaseUsageBytes)
+	as.Equal(uint64(1050), usage.TotalUsageBytes)
+	as.False(usage.Stale)
+}
+
+func TestDockerFsHandlerMissingThinPoolDevice(t *testing.T) {
+	as := assert.New(t)
+	watcher := &fakeThinPoolWatcher{usage: map[string]uint64{}, refreshes: 1}
+	fsHandler := &dockerFsHandler{
+		fsHandler:       &fakeFsHandler{usage: common.FsUsage{BaseUsageBytes: 10, TotalUsageBytes: 50}},
+		thinPoolWatcher: watcher,
+		deviceID:        "1",
+	}
+
+	// The device of a new container may not be in the cache yet.
+	for i := 0; i < 3; i++ {
+		usage := fsHandler.Usage()
+		as.False(usage.Stale)
+		as.Equal(uint64(10), usage.BaseUsageBytes)
+	}
+
+	// Still missing after the cache was refreshed.
+	watcher.refreshes++
+	for i := 0; i < 3; i++ {
+		usage := fsHandler.Usage()
+		as.True(usage.Stale)
+		as.Equal(uint64(0), usage.BaseUsageBytes)
+		as.Equal(uint64(50), usage.TotalUsageBytes)
+	}
+	as.Equal(6, fsHandler.thinPoolFailures)
+
+	// The device showing up resets the failures.
+	watcher.usage["1"] = 1000
+	usage := fsHandler.Usage()
+	as.False(usage.Stale)
+	as.Equal(uint64(1000), usage.BaseUsageBytes)
+	as.Equal(0, fsHandler.thinPoolFailures)
+
+	// Going missing again needs another refresh to be reported as stale.
+	delete(watcher.usage, "1")
+	as.False(fsHandler.Usage().Stale)
+	watcher.refreshes++
+	as.True(fsHandler.Usage().Stale)
+}
+
+func TestDockerFsHandlerLogUsage(t *testing.T) {
+	as := assert.New(t)
+	containerDir, err := ioutil.TempDir("", "containers")
+	as.Nil(err)
+	defer os.RemoveAll(containerDir)
+	as.Nil(ioutil.WriteFile(path.Join(containerDir, "abcd-json.log"), make([]byte, 100), os.ModePerm))
+	as.Nil(ioutil.WriteFile(path.Join(containerDir, "abcd-json.log.1"), make([]byte, 50), os.ModePerm))
+	as.Nil(ioutil.WriteFile(path.Join(containerDir, "config.v2.json"), make([]byte, 10), os.ModePerm))
+
+	usage := common.FsUsage{BaseUsageBytes: 10, TotalUsageBytes: 170, InodeUsage: 2}
+	fsHandler := &dockerFsHandler{fsHandler: &fakeFsHandler{usage: usage}, logDir: containerDir}
+	as.Equal(uint64(150), fsHandler.Usage().LogUsageBytes)
+	as.Equal(uint64(170), fsHandler.Usage().TotalUsageBytes)
+
+	// Logs are not in the containers dir for other logging drivers.
+	fsHandler = &dockerFsHandler{fsHandler: &fakeFsHandler{usage: usage}}
+	as.Equal(uint64(0), fsHandler.Usage().LogUsageBytes)
+
+	// A missing dir is not an error.
+	logUsage, err := getLogUsage(path.Join(containerDir, "missing"))
+	as.Nil(err)
+	as.Equal(uint64(0), logUsage)
+}
+
+func TestLogUsageDependsOnLogDriver(t *testing.T) {
+	as := assert.New(t)
+	for logDriver, hasLogDir := range map[string]bool{
+		"json-file": true,
+		"journald":  false,
+		"none":      false,
+	} {
+		ctnr := newTestContainerJSON("abcd")
+		ctnr.HostConfig.LogConfig.Type = logDriver
+		_, client, cleanup := newFakeDockerDaemon(t, ctnr)
+		handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{
+			includedMetrics: container.MetricSet{container.DiskUsageMetrics: struct{}{}},
+		})
+		cleanup()
+		as.Nil(err)
+		fsHandler := handler.fsHandler.(*dockerFsHandler)
+		as.Equal(hasLogDir, fsHandler.logDir != "", "log driver %s", logDriver)
+	}
+}
+
+func TestGetFsStatsCachedComponents(t *testing.T) {
+	as := assert.New(t)
+	fsHandler := &fakeFsHandler{usage: common.FsUsage{TotalUsageBytes: 20, LastUpdate: time.Unix(1000, 0)}}
+	handler := &dockerContainerHandler{
+		machineInfoFactory: &fakeMachineInfoFactory{},
+		storageDriver:      overlay2StorageDriver,
+		fsInfo:             &fakeFsInfo{device: "/dev/sda1"},
+		includedMetrics:    container.MetricSet{container.DiskUsageMetrics: struct{}{}},
+		fsHandler:          fsHandler,
+	}
+
+	// The first collection after an update reports fresh usage.
+	stats := &info.ContainerStats{}
+	as.Nil(handler.getFsStats(stats))
+	as.Empty(stats.CachedComponents)
+
+	// Until the next update, the same usage is served from the cache.
+	stats = &info.ContainerStats{}
+	as.Nil(handler.getFsStats(stats))
+	as.Equal([]string{info.StatsComponentFilesystem}, stats.CachedComponents)
+
+	fsHandler.usage = common.FsUsage{TotalUsageBytes: 30, LastUpdate: time.Unix(1060, 0)}
+	stats = &info.ContainerStats{}
+	as.Nil(handler.getFsStats(stats))
+	as.Empty(stats.CachedComponents)
+}
+
+func TestGetFsStatsWithoutDiskUsageMetrics(t *testing.T) {
+	handler := &dockerContainerHandler{
+		machineInfoFactory: &fakeMachineInfoFactory{},
+		storageDriver:      overlay2StorageDriver,
+		fsInfo:             fstest.NewFakeFsInfo(),
+		includedMetrics:    container.MetricSet{},
+	}
+	stats := &info.ContainerStats{}
+	assert.NoError(t, handler.getFsStats(stats))
+	assert.Empty(t, stats.Filesystem)
+}
+
+func TestGetLogPath(t *testing.T) {
+	as := assert.New(t)
+	containerDir := "/rootfs/var/lib/docker/containers/abcd"
+	as.Equal("/rootfs/var/lib/docker/containers/abcd/abcd-json.log", getLogPath("json-file", "/var/lib/docker/containers/abcd/abcd-json.log", containerDir, "abcd", "/rootfs"))
+	as.Equal("/rootfs/var/log/abcd.log", getLogPath("local", "/var/log/abcd.log", containerDir, "abcd", "/rootfs"))
+	as.Equal("", getLogPath("journald", "", containerDir, "abcd", "/rootfs"))
+	as.Equal("", getLogPath("none", "", containerDir, "abcd", "/"))
+}
+
+func TestContainerIPAddress(t *testing.T) {
+	as := assert.New(t)
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.NetworkSettings.IPAddress = "10.0.0.2"
+	daemon, client, cleanup := newFakeDockerDaemon(t, ctnr)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	as.Equal("10.0.0.2", handler.GetContainerIPAddress())
+	as.Equal(1, daemon.inspectCount("abcd"))
+}
+
+func TestContainerIPAddressesFromNetworks(t *testing.T) {
+	as := assert.New(t)
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.NetworkSettings.Networks = map[string]*dockernetwork.EndpointSettings{
+		"frontend": {IPAddress: "10.1.0.2"},
+		"backend":  {IPAddress: "10.2.0.2"},
+		"none":     {},
+	}
+	bridge := newTestContainerJSON("efgh")
+	bridge.NetworkSettings.IPAddress = "172.17.0.2"
+	bridge.NetworkSettings.Networks = map[string]*dockernetwork.EndpointSettings{
+		"bridge":   {IPAddress: "172.17.0.2"},
+		"frontend": {IPAddress: "10.1.0.3"},
+	}
+	_, client, cleanup := newFakeDockerDaemon(t, ctnr, bridge)
+	defer cleanup()
+
+	// Without a preferred network, the first network by name is used.
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	as.Equal("10.2.0.2", handler.GetContainerIPAddress())
+	as.Equal([]string{"10.2.0.2", "10.1.0.2"}, handler.GetContainerIPAddresses())
+
+	handler, err = newTestDockerContainerHandler(client, "abcd", testHandlerOptions{preferredNetwork: "frontend"})
+	as.Nil(err)
+	as.Equal("10.1.0.2", handler.GetContainerIPAddress())
+	as.Equal([]string{"10.1.0.2", "10.2.0.2"}, handler.GetContainerIPAddresses())
+
+	// The top-level address is still the primary one.
+	handler, err = newTestDockerContainerHandler(client, "efgh", testHandlerOptions{preferredNetwork: "frontend"})
+	as.Nil(err)
+	as.Equal("172.17.0.2", handler.GetContainerIPAddress())
+	as.Equal([]string{"172.17.0.2", "10.1.0.3"}, handler.GetContainerIPAddresses())
+}
+
+func TestContainerIPAddressFromNetworkContainer(t *testing.T) {
+	as := assert.New(t)
+	sandbox := newTestContainerJSON("sandbox")
+	sandbox.NetworkSettings.IPAddress = "10.0.0.3"
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.HostConfig.NetworkMode = "container:sandbox"
+	daemon, client, cleanup := newFakeDockerDaemon(t, sandbox, ctnr)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	as.Equal("10.0.0.3", handler.GetContainerIPAddress())
+	as.Equal(1, daemon.inspectCount("sandbox"))
+}
+
+func TestContainerIPv6Address(t *testing.T) {
+	as := assert.New(t)
+	ipv6Only := newTestContainerJSON("ipv6")
+	ipv6Only.NetworkSettings.Networks = map[string]*dockernetwork.EndpointSettings{
+		"v6net": {GlobalIPv6Address: "2001:db8::2"},
+	}
+	dualStack := newTestContainerJSON("dual")
+	dualStack.NetworkSettings.IPAddress = "10.0.0.2"
+	dualStack.NetworkSettings.GlobalIPv6Address = "2001:db8::3"
+	_, client, cleanup := newFakeDockerDaemon(t, ipv6Only, dualStack)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "ipv6", testHandlerOptions{})
+	as.Nil(err)
+	as.Equal("", handler.GetContainerIPAddress())
+	as.Equal("2001:db8::2", handler.GetContainerIPv6Address())
+
+	handler, err = newTestDockerContainerHandler(client, "dual", testHandlerOptions{})
+	as.Nil(err)
+	as.Equal("10.0.0.2", handler.GetContainerIPAddress())
+	as.Equal("2001:db8::3", handler.GetContainerIPv6Address())
+}
+
+func TestContainerIPv6AddressFromNetworkContainer(t *testing.T) {
+	as := assert.New(t)
+	sandbox := newTestContainerJSON("sandbox")
+	sandbox.NetworkSettings.IPAddress = "10.0.0.3"
+	sandbox.NetworkSettings.GlobalIPv6Address = "2001:db8::4"
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.HostConfig.NetworkMode = "container:sandbox"
+	daemon, client, cleanup := newFakeDockerDaemon(t, sandbox, ctnr)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	as.Equal("10.0.0.3", handler.GetContainerIPAddress())
+	as.Equal("2001:db8::4", handler.GetContainerIPv6Address())
+	as.Equal(1, daemon.inspectCount("sandbox"))
+}
+
+func TestContainerIPAddressDisabled(t *testing.T) {
+	as := assert.New(t)
+	sandbox := newTestContainerJSON("sandbox")
+	sandbox.NetworkSettings.IPAddress = "10.0.0.3"
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.HostConfig.NetworkMode = "container:sandbox"
+	daemon, client, cleanup := newFakeDockerDaemon(t, sandbox, ctnr)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{disableContainerIP: true})
+	as.Nil(err)
+	as.Equal("", handler.GetContainerIPAddress())
+	as.Equal(0, daemon.inspectCount("sandbox"))
+}
+
+func TestHostname(t *testing.T) {
+	as := assert.New(t)
+	id := "72e5a5ff5eef3c4222a6551b992b9360a99122f77d2229783f0ee0946dfd800e"
+	ctnr := newTestContainerJSON(id)
+	ctnr.Config.Hostname = "web-1"
+	_, client, cleanup := newFakeDockerDaemon(t, ctnr)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, id, testHandlerOptions{})
+	as.Nil(err)
+	as.Equal("web-1", handler.hostname)
+}
+
+func TestGetHostnameDefault(t *testing.T) {
+	as := assert.New(t)
+	id := "72e5a5ff5eef3c4222a6551b992b9360a99122f77d2229783f0ee0946dfd800e"
+	as.Equal("72e5a5ff5eef", getHostname("72e5a5ff5eef", "bridge", id))
+	as.Equal("72e5a5ff5eef", getHostname("", "bridge", id))
+	as.Equal("", getHostname("", "host", id))
+}
+
+func TestLiveEnvs(t *testing.T) {
+	as := assert.New(t)
+	rootFs, err := ioutil.TempDir("", "")
+	as.Nil(err)
+	defer os.RemoveAll(rootFs)
+	procDir := path.Join(rootFs, "proc", "1234")
+	as.Nil(os.MkdirAll(procDir, os.ModePerm))
+	as.Nil(ioutil.WriteFile(path.Join(procDir, "environ"), []byte("PATH=/bin\x00APP_VERSION=2\x00"), os.ModePerm))
+
+	handler := &dockerContainerHandler{
+		rootFs:       rootFs,
+		pid:          1234,
+		metadataEnvs: []string{"APP_VERSION", "MISSING"},
+	}
+	envs, err := handler.LiveEnvs()
+	as.Nil(err)
+	as.Equal(map[string]string{"app_version": "2"}, envs)
+
+	handler.pid = 4321
+	_, err = handler.LiveEnvs()
+	as.NotNil(err)
+}
+
+func TestMetadataEnvsFromConfig(t *testing.T) {
+	as := assert.New(t)
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.Config.Env = []string{"PATH=/bin", "APP_VERSION=1"}
+	_, client, cleanup := newFakeDockerDaemon(t, ctnr)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{metadataEnvs: []string{"APP_VERSION"}})
+	as.Nil(err)
+	as.Equal(map[string]string{"app_version": "1"}, handler.envs)
+}
+
+func TestGetMetadataEnvs(t *testing.T) {
+	as := assert.New(t)
+	env := []string{
+		"PATH=/bin",
+		"APP_VERSION=1.2",
+		"APP_Region=eu",
+		"APP_=empty suffix",
+		"TEAM_OWNER=infra",
+		"TEAM_OWNER_EMAIL=infra@example.com",
+		"version=lowercase",
+		"app_version=prefixed",
+	}
+	for _, tc := range []struct {
+		description  string
+		metadataEnvs []string
+		expected     map[string]string
+	}{
+		{
+			description:  "exact names are lowercased",
+			metadataEnvs: []string{"APP_VERSION", "MISSING", ""},
+			expected:     map[string]string{"app_version": "1.2"},
+		},
+		{
+			description:  "prefixes keep the rest of the name",
+			metadataEnvs: []string{"prefix:APP_"},
+			expected:     map[string]string{"VERSION": "1.2", "Region": "eu"},
+		},
+		{
+			description:  "regular expressions are keyed by their capture group",
+			metadataEnvs: []string{`re:TEAM_([A-Z]+)`},
+			expected:     map[string]string{"OWNER": "infra"},
+		},
+		{
+			description:  "regular expressions without capture group keep the name",
+			metadataEnvs: []string{`re:TEAM_.*`},
+			expected:     map[string]string{"TEAM_OWNER": "infra", "TEAM_OWNER_EMAIL": "infra@example.com"},
+		},
+		{
+			description:  "exact names take precedence over patterns",
+			metadataEnvs: []string{"prefix:app_", "version"},
+			expected:     map[string]string{"version": "lowercase"},
+		},
+		{
+			description:  "regular expressions are keyed by the group that matched",
+			metadataEnvs: []string{`re:APP_(VERSION)|TEAM_(OWNER)`},
+			expected:     map[string]string{"VERSION": "1.2", "OWNER": "infra"},
+		},
+		{
+			description:  "patterns listed first take precedence",
+			metadataEnvs: []string{"prefix:TEAM_", `re:TEAM_(OWNER)_EMAIL`},
+			expected:     map[string]string{"OWNER": "infra", "OWNER_EMAIL": "infra@example.com"},
+		},
+	} {
+		as.Equal(tc.expected, getMetadataEnvs(env, tc.metadataEnvs), tc.description)
+	}
+}
+
+func TestValidateMetadataEnvs(t *testing.T) {
+	as := assert.New(t)
+	as.Nil(validateMetadataEnvs([]string{"APP_VERSION", "prefix:APP_", `re:TEAM_(\w+)`}))
+	as.NotNil(validateMetadataEnvs([]string{"APP_VERSION", "re:TEAM_("}))
+}
+
+func TestNewDockerContainerHandlerFromInspect(t *testing.T) {
+	as := assert.New(t)
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.Config.Image = "busybox"
+	ctnr.NetworkSettings.IPAddress = "10.0.0.2"
+
+	// No client is needed for a container with its own network.
+	handler, err := newDockerContainerHandlerFromInspect(nil, ctnr, "/docker/abcd", true, &handlerOptions{
+		machineInfoFactory: &fakeMachineInfoFactory{},
+		fsInfo:             &fakeFsInfo{},
+		storageDriver:      storageDriver("vfs"),
+		storageDir:         "/var/lib/docker",
+		cgroupSubsystems:   &containerlibcontainer.CgroupSubsystems{},
+		dockerVersion:      []int{1, 9, 0},
+		includedMetrics:    container.MetricSet{},
+		clock:              clock.NewFakeClock(time.Unix(1000, 0)),
+	})
+	as.Nil(err)
+	ref, err := handler.ContainerReference()
+	as.Nil(err)
+	as.Equal("abcd", ref.Id)
+	as.Equal([]string{"test", "abcd"}, ref.Aliases)
+	as.Equal("10.0.0.2", handler.GetContainerIPAddress())
+	as.Equal("busybox", handler.(*dockerContainerHandler).image)
+}
+
+func TestNewDockerContainerHandlerCgroupDriver(t *testing.T) {
+	as := assert.New(t)
+	tests := []struct {
+		cgroupDriver string
+		cgroupParent string
+		cgroup       string
+	}{
+		{cgroupfsCgroupDriver, "", "/docker/abcd"},
+		{cgroupfsCgroupDriver, "/kubepods/pod1234", "/kubepods/pod1234/abcd"},
+		{systemdCgroupDriver, "", "/system.slice/docker-abcd.scope"},
+		{systemdCgroupDriver, "kubepods-pod1234.slice", "/kubepods.slice/kubepods-pod1234.slice/docker-abcd.scope"},
+	}
+	for _, test := range tests {
+		mountPoint, err := ioutil.TempDir("", "cgroup")
+		as.Nil(err)
+		defer os.RemoveAll(mountPoint)
+		as.Nil(os.MkdirAll(path.Join(mountPoint, test.cgroup), 0755))
+
+		ctnr := newTestContainerJSON("abcd")
+		ctnr.HostConfig.CgroupParent = test.cgroupParent
+		handler, err := newDockerContainerHandlerFromInspect(nil, ctnr, "/docker/abcd", true, &handlerOptions{
+			machineInfoFactory: &fakeMachineInfoFactory{},
+			fsInfo:             &fakeFsInfo{},
+			storageDriver:      storageDriver("vfs"),
+			storageDir:         "/var/lib/docker",
+			cgroupSubsystems: &containerlibcontainer.CgroupSubsystems{
+				MountPoints: map[string]string{"cpu": mountPoint},
+			},
+			cgroupDriver:    test.cgroupDriver,
+			dockerVersion:   []int{1, 9, 0},
+			includedMetrics: container.MetricSet{},
+			clock:           clock.NewFakeClock(time.Unix(1000, 0)),
+		})
+		as.Nil(err)
+		as.Equal(map[string]string{"cpu": path.Join(mountPoint, test.cgroup)}, handler.(*dockerContainerHandler).cgroupPaths, "%s %q", test.cgroupDriver, test.cgroupParent)
+	}
+
+	// A container whose cgroup is not where its cgroup driver places it keeps
+	// the cgroup it was found in.
+	mountPoint, err := ioutil.TempDir("", "cgroup")
+	as.Nil(err)
+	defer os.RemoveAll(mountPoint)
+	handler, err := newDockerContainerHandlerFromInspect(nil, newTestContainerJSON("abcd"), "/docker/abcd", true, &handlerOptions{
+		machineInfoFactory: &fakeMachineInfoFactory{},
+		fsInfo:             &fakeFsInfo{},
+		storageDriver:      storageDriver("vfs"),
+		storageDir:         "/var/lib/docker",
+		cgroupSubsystems: &containerlibcontainer.CgroupSubsystems{
+			MountPoints: map[string]string{"cpu": mountPoint},
+		},
+		cgroupDriver:    systemdCgroupDriver,
+		dockerVersion:   []int{1, 9, 0},
+		includedMetrics: container.MetricSet{},
+		clock:           clock.NewFakeClock(time.Unix(1000, 0)),
+	})
+	as.Nil(err)
+	as.Equal(map[string]string{"cpu": path.Join(mountPoint, "/docker/abcd")}, handler.(*dockerContainerHandler).cgroupPaths)
+}
+
+func TestGetSpecImage(t *testing.T) {
+	as := assert.New(t)
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.Config.Image = "nginx:latest"
+	ctnr.Image = "sha256:53f3fd8007f76bd23bf663ad5f5009c8941f63828ae458cef584b5f85dc0a7bf"
+	_, client, cleanup := newFakeDockerDaemon(t, ctnr)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	handler.machineInfoFactory = &fakeMachineInfoFactory{}
+	spec, err := handler.GetSpec()
+	as.Nil(err)
+	as.Equal("nginx:latest", spec.Image)
+	as.Equal("sha256:53f3fd8007f76bd23bf663ad5f5009c8941f63828ae458cef584b5f85dc0a7bf", spec.ImageID)
+}
+
+func TestIsSandbox(t *testing.T) {
+	as := assert.New(t)
+	sandbox := newTestContainerJSON("sandbox")
+	sandbox.Config.Image = "k8s.gcr.io/pause:3.1"
+	sandbox.Config.Labels = map[string]string{"io.kubernetes.docker.type": "podsandbox"}
+	sandbox.HostConfig.NetworkMode = "host"
+	app := newTestContainerJSON("app")
+	app.Config.Image = "nginx:1.15"
+	app.Config.Labels = map[string]string{"io.kubernetes.docker.type": "container"}
+	app.HostConfig.NetworkMode = "host"
+	_, client, cleanup := newFakeDockerDaemon(t, sandbox, app)
+	defer cleanup()
+
+	opts := testHandlerOptions{includedMetrics: container.MetricSet{container.NetworkUsageMetrics: struct{}{}}}
+	sandboxHandler, err := newTestDockerContainerHandler(client, "sandbox", opts)
+	as.Nil(err)
+	as.True(sandboxHandler.IsSandbox())
+	as.True(sandboxHandler.needNet())
+
+	appHandler, err := newTestDockerContainerHandler(client, "app", opts)
+	as.Nil(err)
+	as.False(appHandler.IsSandbox())
+	as.False(appHandler.needNet())
+}
+
+func TestIsSandboxContainer(t *testing.T) {
+	as := assert.New(t)
+	as.True(isSandboxContainer(map[string]string{"io.kubernetes.container.name": "POD"}, "busybox"))
+	as.True(isSandboxContainer(nil, "k8s.gcr.io/pause-amd64:3.1"))
+	as.True(isSandboxContainer(nil, "kubernetes/pause"))
+	as.True(isSandboxContainer(nil, "localhost:5000/pause@sha256:abcd"))
+	as.False(isSandboxContainer(map[string]string{"io.kubernetes.docker.type": "container"}, "k8s.gcr.io/pause:3.1"))
+	as.False(isSandboxContainer(nil, "nginx:1.15"))
+	as.False(isSandboxContainer(nil, "localhost:5000/nginx"))
+}
+
+func TestContainerIPAddressFromCNIResult(t *testing.T) {
+	as := assert.New(t)
+	cniResultDir, err := ioutil.TempDir("", "cni")
+	as.Nil(err)
+	defer os.RemoveAll(cniResultDir)
+	result := `{"kind":"cniCacheV1","containerId":"sandbox","ifName":"eth0","networkName":"pod-network","result":{"cniVersion":"0.3.1","ips":[{"version":"4","address":"10.1.0.5/16"}]}}`
+	as.Nil(ioutil.WriteFile(path.Join(cniResultDir, "pod-network-sandbox-eth0"), []byte(result), os.ModePerm))
+
+	sandbox := newTestContainerJSON("sandbox")
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.HostConfig.NetworkMode = "container:sandbox"
+	other := newTestContainerJSON("other")
+	_, client, cleanup := newFakeDockerDaemon(t, sandbox, ctnr, other)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{cniResultDir: cniResultDir})
+	as.Nil(err)
+	as.Equal("10.1.0.5", handler.GetContainerIPAddress())
+
+	// Without a result for the container the address stays unknown.
+	handler, err = newTestDockerContainerHandler(client, "other", testHandlerOptions{cniResultDir: cniResultDir})
+	as.Nil(err)
+	as.Equal("", handler.GetContainerIPAddress())
+
+	// Without a result dir the current behavior is preserved.
+	handler, err = newTestDockerContainerHandler(client, "abcd", testHandlerOptions{cniResultDir: path.Join(cniResultDir, "missing")})
+	as.Nil(err)
+	as.Equal("", handler.GetContainerIPAddress())
+}
+
+func TestCpuUsageDelta(t *testing.T) {
+	as := assert.New(t)
+	handler := &dockerContainerHandler{cpuUsageDeltas: true}
+
+	first := &info.ContainerStats{Cpu: info.CpuStats{
+		Usage: info.CpuUsage{Total: 1000, User: 600, System: 400, PerCpu: []uint64{300, 700}},
+	}}
+	handler.setCpuUsageDelta(first)
+	as.Equal(&info.CpuUsage{PerCpu: []uint64{0, 0}}, first.Cpu.UsageDelta)
+	// The cumulative usage is left alone.
+	as.Equal(info.CpuUsage{Total: 1000, User: 600, System: 400, PerCpu: []uint64{300, 700}}, first.Cpu.Usage)
+
+	second := &info.ContainerStats{Cpu: info.CpuStats{
+		Usage: info.CpuUsage{Total: 1500, User: 900, System: 600, PerCpu: []uint64{500, 1000}},
+	}}
+	handler.setCpuUsageDelta(second)
+	as.Equal(&info.CpuUsage{Total: 500, User: 300, System: 200, PerCpu: []uint64{200, 300}}, second.Cpu.UsageDelta)
+
+	// The counters were reset, e.g. because the cgroup was recreated.
+	third := &info.ContainerStats{Cpu: info.CpuStats{
+		Usage: info.CpuUsage{Total: 100, User: 60, System: 40, PerCpu: []uint64{50, 50}},
+	}}
+	handler.setCpuUsageDelta(third)
+	as.Equal(&info.CpuUsage{Total: 100, User: 60, System: 40, PerCpu: []uint64{50, 50}}, third.Cpu.UsageDelta)
+}
+
+// blockingMachineInfoFactory blocks getting the machine info until unblocked.
+type blockingMachineInfoFactory struct {
+	fakeMachineInfoFactory
+	started chan struct{}
+	unblock chan struct{}
+}
+
+func (f *blockingMachineInfoFactory) GetMachineInfo() (*info.MachineInfo, error) {
+	close(f.started)
+	<-f.unblock
+	return f.fakeMachineInfoFactory.GetMachineInfo()
+}
+
+func TestGetStatsContextCancelled(t *testing.T) {
+	as := assert.New(t)
+	_, client, cleanup := newFakeDockerDaemon(t, newTestContainerJSON("abcd"))
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	machineInfoFactory := &blockingMachineInfoFactory{
+		started: make(chan struct{}),
+		unblock: make(chan struct{}),
+	}
+	handler.machineInfoFactory = machineInfoFactory
+
+	// Cancel while the filesystem stats are collected.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-machineInfoFactory.started
+		cancel()
+	}()
+	stats, err := handler.GetStatsContext(ctx)
+	as.Equal(context.Canceled, err)
+	as.Nil(stats)
+
+	// No new collection is started while the abandoned one is still running.
+	stats, err = handler.GetStatsContext(context.Background())
+	as.Equal(errCollectionInProgress, err)
+	as.Nil(stats)
+
+	close(machineInfoFactory.unblock)
+	for atomic.LoadInt32(&handler.collecting) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	handler.machineInfoFactory = &fakeMachineInfoFactory{}
+	stats, err = handler.GetStatsContext(context.Background())
+	as.Nil(err)
+	as.NotNil(stats)
+}
+
+func TestGetStatsContextDone(t *testing.T) {
+	as := assert.New(t)
+	_, client, cleanup := newFakeDockerDaemon(t, newTestContainerJSON("abcd"))
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	handler.machineInfoFactory = &fakeMachineInfoFactory{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stats, err := handler.GetStatsContext(ctx)
+	as.Equal(context.Canceled, err)
+	as.Nil(stats)
+
+	stats, err = handler.GetStatsContext(context.Background())
+	as.Nil(err)
+	as.NotNil(stats)
+}
+
+func TestRecentErrors(t *testing.T) {
+	as := assert.New(t)
+	_, client, cleanup := newFakeDockerDaemon(t, newTestContainerJSON("abcd"))
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	as.Empty(handler.RecentErrors())
+
+	machineInfoFactory := &fakeMachineInfoFactory{}
+	handler.machineInfoFactory = machineInfoFactory
+	fakeClock := handler.clock.(*clock.FakeClock)
+	for i := 0; i < maxRecentErrors+2; i++ {
+		machineInfoFactory.err = fmt.Errorf("error %d", i)
+		_, err := handler.GetStats()
+		as.NotNil(err)
+		fakeClock.Step(time.Second)
+	}
+	// Successful collections are not recorded.
+	machineInfoFactory.err = nil
+	_, err = handler.GetStats()
+	as.Nil(err)
+
+	recentErrors := handler.RecentErrors()
+	if as.Len(recentErrors, maxRecentErrors) {
+		as.Equal("error 2", recentErrors[0].Err.Error())
+		as.Equal(time.Unix(1002, 0), recentErrors[0].Timestamp)
+		as.Equal(fmt.Sprintf("error %d", maxRecentErrors+1), recentErrors[maxRecentErrors-1].Err.Error())
+	}
+}
+
+func TestGetStatsUptime(t *testing.T) {
+	as := assert.New(t)
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.Created = "1970-01-01T00:10:00Z"
+	_, client, cleanup := newFakeDockerDaemon(t, ctnr)
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	handler.machineInfoFactory = &fakeMachineInfoFactory{}
+	fakeClock := handler.clock.(*clock.FakeClock)
+
+	stats, err := handler.GetStats()
+	as.Nil(err)
+	as.Equal(400*time.Second, stats.Uptime)
+
+	fakeClock.Step(time.Minute)
+	stats, err = handler.GetStats()
+	as.Nil(err)
+	as.Equal(460*time.Second, stats.Uptime)
+}
+
+func TestGetStatsCollectionLatencies(t *testing.T) {
+	as := assert.New(t)
+	_, client, cleanup := newFakeDockerDaemon(t, newTestContainerJSON("abcd"))
+	defer cleanup()
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{})
+	as.Nil(err)
+	handler.machineInfoFactory = &fakeMachineInfoFactory{}
+	stats, err := handler.GetStats()
+	as.Nil(err)
+	as.Nil(stats.CollectionLatencies)
+
+	handler, err = newTestDockerContainerHandler(client, "abcd", testHandlerOptions{collectionLatency: true})
+	as.Nil(err)
+	handler.machineInfoFactory = &fakeMachineInfoFactory{}
+	stats, err = handler.GetStats()
+	as.Nil(err)
+	as.Equal(map[string]time.Duration{
+		containerlibcontainer.CgroupLatencyGroup: 0,
+		containerlibcontainer.FsLatencyGroup:     0,
+	}, stats.CollectionLatencies)
+}
+
+func TestUptime(t *testing.T) {
+	as := assert.New(t)
+	created := time.Unix(1000, 0)
+	as.Equal(time.Minute, uptime(created, created.Add(time.Minute)))
+	as.Equal(time.Duration(0), uptime(time.Time{}, created))
+	// The clock was set back.
+	as.Equal(time.Duration(0), uptime(created, created.Add(-time.Second)))
+}
+
+func TestGetResourceLimits(t *testing.T) {
+	as := assert.New(t)
+	memoryRoot, err := ioutil.TempDir("", "memory")
+	as.Nil(err)
+	defer os.RemoveAll(memoryRoot)
+	as.Nil(ioutil.WriteFile(path.Join(memoryRoot, "memory.max"), []byte("268435456\n"), 0644))
+
+	handler := &dockerContainerHandler{cgroupPaths: map[string]string{"memory": memoryRoot}}
+	limits, err := handler.GetResourceLimits()
+	as.Nil(err)
+	as.Equal(info.ContainerResourceLimits{MemoryLimit: 268435456}, limits)
+}
@@ -0,0 +1,225 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matthewygf/cadvisor/container/common"
+	"github.com/matthewygf/cadvisor/fs"
+
+	"k8s.io/klog"
+	"k8s.io/utils/clock"
+)
+
+// The file of an overlay2 layer listing the layers below it, as
+// colon-separated "l/<short id>" links to their diff dirs.
+const overlay2LowerFile = "lower"
+
+// getOverlay2LowerDirs returns the diff dirs of the read-only layers below the
+// overlay2 layer in layerDir, or nil if it has none.
+func getOverlay2LowerDirs(layerDir string) ([]string, error) {
+	lower, err := ioutil.ReadFile(path.Join(layerDir, overlay2LowerFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	layersDir := path.Dir(layerDir)
+	var lowerDirs []string
+	for _, link := range strings.Split(strings.TrimSpace(string(lower)), ":") {
+		if link == "" {
+			continue
+		}
+		// The link points to "../<layer id>/diff", relative to the links dir.
+		target, err := os.Readlink(path.Join(layersDir, link))
+		if err != nil {
+			return nil, err
+		}
+		lowerDirs = append(lowerDirs, path.Join(layersDir, path.Base(path.Dir(target)), overlay2RWLayer))
+	}
+	return lowerDirs, nil
+}
+
+// imageLayers caches the usage of image layers by diff dir. Image layers are
+// read-only and shared by the containers created from the image, so each of
+// them only needs to be walked once. A layer is cached while the image fs
+// handler of a container using it is started.
+var imageLayers = struct {
+	sync.Mutex
+	layers map[string]*imageLayer
+}{layers: make(map[string]*imageLayer)}
+
+type imageLayer struct {
+	// Number of started handlers using the layer.
+	refs int
+	// Usage of the layer, nil until computed.
+	usage *fs.UsageInfo
+}
+
+// acquireImageLayers keeps the usage of the layers in dirs cached until they
+// are released.
+func acquireImageLayers(dirs []string) {
+	imageLayers.Lock()
+	defer imageLayers.Unlock()
+	for _, dir := range dirs {
+		layer, ok := imageLayers.layers[dir]
+		if !ok {
+			layer = &imageLayer{}
+			imageLayers.layers[dir] = layer
+		}
+		layer.refs++
+	}
+}
+
+// releaseImageLayers drops the usage of the layers in dirs from the cache
+// once no handler uses them anymore.
+func releaseImageLayers(dirs []string) {
+	imageLayers.Lock()
+	defer imageLayers.Unlock()
+	for _, dir := range dirs {
+		layer, ok := imageLayers.layers[dir]
+		if !ok {
+			continue
+		}
+		layer.refs--
+		if layer.refs <= 0 {
+			delete(imageLayers.layers, dir)
+		}
+	}
+}
+
+func getImageLayerUsage(fsInfo fs.FsInfo, dir string) (fs.UsageInfo, error) {
+	imageLayers.Lock()
+	layer, ok := imageLayers.layers[dir]
+	if ok && layer.usage != nil {
+		usage := *layer.usage
+		imageLayers.Unlock()
+		return usage, nil
+	}
+	imageLayers.Unlock()
+	usage, err := fsInfo.GetDirUsage(dir)
+	if err != nil {
+		return fs.UsageInfo{}, err
+	}
+	imageLayers.Lock()
+	// The layer may have been released in the meantime.
+	if layer, ok := imageLayers.layers[dir]; ok {
+		layer.usage = &usage
+	}
+	imageLayers.Unlock()
+	return usage, nil
+}
+
+// Period after which computing the usage of the image layers is tried again
+// when it failed. It doubles on every failure, up to maxImageLayersRetryPeriod.
+const (
+	imageLayersRetryPeriod    = 10 * time.Second
+	maxImageLayersRetryPeriod = 10 * time.Minute
+)
+
+// imageFsHandler is a FsHandler reporting the usage of the read-only image
+// layers of a container. The usage is computed once when the handler is
+// started since the layers never change, and tried again until it succeeds.
+type imageFsHandler struct {
+	lowerDirs []string
+	fsInfo    fs.FsInfo
+	clock     clock.Clock
+	stopChan  chan struct{}
+
+	lock  sync.RWMutex
+	usage common.FsUsage
+}
+
+var _ common.FsHandler = &imageFsHandler{}
+
+func newImageFsHandler(lowerDirs []string, fsInfo fs.FsInfo, clock clock.Clock) *imageFsHandler {
+	return &imageFsHandler{
+		lowerDirs: lowerDirs,
+		fsInfo:    fsInfo,
+		clock:     clock,
+		stopChan:  make(chan struct{}),
+	}
+}
+
+func (h *imageFsHandler) Start() {
+	acquireImageLayers(h.lowerDirs)
+	go h.trackUsage()
+}
+
+func (h *imageFsHandler) Stop() {
+	close(h.stopChan)
+	releaseImageLayers(h.lowerDirs)
+}
+
+// trackUsage computes the usage of the image layers until it succeeds or the
+// handler is stopped.
+func (h *imageFsHandler) trackUsage() {
+	period := imageLayersRetryPeriod
+	for {
+		err := h.update()
+		if err == nil {
+			return
+		}
+		klog.Errorf("failed to collect usage of image layers, retrying in %v: %v", period, err)
+		select {
+		case <-h.stopChan:
+			return
+		case <-h.clock.After(period):
+		}
+		period *= 2
+		if period > maxImageLayersRetryPeriod {
+			period = maxImageLayersRetryPeriod
+		}
+	}
+}
+
+// Usage returns the usage of the image layers, or a zero usage until it has
+// been computed.
+func (h *imageFsHandler) Usage() common.FsUsage {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.usage
+}
+
+func (h *imageFsHandler) update() error {
+	start := h.clock.Now()
+	var usage common.FsUsage
+	for _, dir := range h.lowerDirs {
+		layerUsage, err := getImageLayerUsage(h.fsInfo, dir)
+		if err != nil {
+			return fmt.Errorf("failed to collect usage of image layer %q: %v", dir, err)
+		}
+		usage.TotalUsageBytes += layerUsage.Bytes
+		usage.InodeUsage += layerUsage.Inodes
+	}
+	usage.BaseUsageBytes = usage.TotalUsageBytes
+	usage.LastUpdate = h.clock.Now()
+	if duration := usage.LastUpdate.Sub(start); duration > time.Second {
+		klog.V(2).Infof("image layers usage of %d layers took %v", len(h.lowerDirs), duration)
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.usage = usage
+	return nil
+}
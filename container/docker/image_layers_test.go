@@ -0,0 +1,162 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/matthewygf/cadvisor/container"
+	"github.com/matthewygf/cadvisor/container/common"
+	"github.com/matthewygf/cadvisor/fs"
+	fstest "github.com/matthewygf/cadvisor/fs/testing"
+	info "github.com/matthewygf/cadvisor/info/v1"
+
+	"github.com/stretchr/testify/assert"
+	clock "k8s.io/utils/clock/testing"
+)
+
+// newOverlay2Layer creates the dir of an overlay2 layer with the given short
+// id linked to its diff dir, and the given lower layers.
+func newOverlay2Layer(t *testing.T, layersDir, id, shortID, lower string) {
+	layerDir := path.Join(layersDir, id)
+	if err := os.MkdirAll(path.Join(layerDir, "diff"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(layerDir, "link"), []byte(shortID), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(path.Join("..", id, "diff"), path.Join(layersDir, "l", shortID)); err != nil {
+		t.Fatal(err)
+	}
+	if lower != "" {
+		if err := ioutil.WriteFile(path.Join(layerDir, "lower"), []byte(lower), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetOverlay2LowerDirs(t *testing.T) {
+	as := assert.New(t)
+	layersDir, err := ioutil.TempDir("", "overlay2")
+	as.Nil(err)
+	defer os.RemoveAll(layersDir)
+	as.Nil(os.Mkdir(path.Join(layersDir, "l"), os.ModePerm))
+
+	newOverlay2Layer(t, layersDir, "base", "BASE", "")
+	newOverlay2Layer(t, layersDir, "app", "APP", "l/BASE")
+	newOverlay2Layer(t, layersDir, "abcd-init", "INIT", "l/APP:l/BASE")
+	newOverlay2Layer(t, layersDir, "abcd", "ABCD", "l/INIT:l/APP:l/BASE")
+
+	lowerDirs, err := getOverlay2LowerDirs(path.Join(layersDir, "abcd"))
+	as.Nil(err)
+	as.Equal([]string{
+		path.Join(layersDir, "abcd-init", "diff"),
+		path.Join(layersDir, "app", "diff"),
+		path.Join(layersDir, "base", "diff"),
+	}, lowerDirs)
+
+	// The lowest layer has no layers below it.
+	lowerDirs, err = getOverlay2LowerDirs(path.Join(layersDir, "base"))
+	as.Nil(err)
+	as.Empty(lowerDirs)
+}
+
+func TestImageFsHandler(t *testing.T) {
+	as := assert.New(t)
+	fsInfo := fstest.NewFakeFsInfo()
+	fsInfo.DirUsage["/image/app/diff"] = fs.UsageInfo{Bytes: 100, Inodes: 10}
+	fsInfo.DirUsage["/image/base/diff"] = fs.UsageInfo{Bytes: 1000, Inodes: 50}
+	fakeClock := clock.NewFakeClock(time.Unix(1000, 0))
+
+	h := newImageFsHandler([]string{"/image/app/diff", "/image/base/diff"}, fsInfo, fakeClock)
+	as.Equal(common.FsUsage{}, h.Usage())
+	acquireImageLayers(h.lowerDirs)
+	as.Nil(h.update())
+	as.Equal(common.FsUsage{BaseUsageBytes: 1100, TotalUsageBytes: 1100, InodeUsage: 60, LastUpdate: fakeClock.Now()}, h.Usage())
+
+	// Usage of the shared layers is not computed again.
+	fsInfo.DirUsage["/image/base/diff"] = fs.UsageInfo{Bytes: 2000, Inodes: 50}
+	other := newImageFsHandler([]string{"/image/base/diff"}, fsInfo, fakeClock)
+	acquireImageLayers(other.lowerDirs)
+	as.Nil(other.update())
+	as.Equal(uint64(1000), other.Usage().TotalUsageBytes)
+
+	// It is once no started handler uses the layers anymore.
+	h.Stop()
+	other.Stop()
+	imageLayers.Lock()
+	as.Empty(imageLayers.layers)
+	imageLayers.Unlock()
+	acquireImageLayers(other.lowerDirs)
+	defer releaseImageLayers(other.lowerDirs)
+	as.Nil(other.update())
+	as.Equal(uint64(2000), other.Usage().TotalUsageBytes)
+}
+
+func TestImageFsHandlerRetries(t *testing.T) {
+	as := assert.New(t)
+	fsInfo := fstest.NewFakeFsInfo()
+	fakeClock := clock.NewFakeClock(time.Unix(1000, 0))
+
+	// The usage of the layer can't be computed at first.
+	h := newImageFsHandler([]string{"/image/retry/diff"}, fsInfo, fakeClock)
+	h.Start()
+	defer h.Stop()
+	for !fakeClock.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	as.Equal(common.FsUsage{}, h.Usage())
+
+	fsInfo.DirUsage["/image/retry/diff"] = fs.UsageInfo{Bytes: 100, Inodes: 10}
+	fakeClock.Step(imageLayersRetryPeriod)
+	for h.Usage().TotalUsageBytes == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	as.Equal(common.FsUsage{BaseUsageBytes: 100, TotalUsageBytes: 100, InodeUsage: 10, LastUpdate: fakeClock.Now()}, h.Usage())
+}
+
+func TestGetFsStatsUsageIncludingImage(t *testing.T) {
+	as := assert.New(t)
+	imageFsHandler := &fakeFsHandler{}
+	handler := &dockerContainerHandler{
+		machineInfoFactory: &fakeMachineInfoFactory{},
+		storageDriver:      overlay2StorageDriver,
+		fsInfo:             &fakeFsInfo{device: "/dev/sda1"},
+		includedMetrics:    container.MetricSet{container.DiskUsageMetrics: struct{}{}, container.DiskImageUsageMetrics: struct{}{}},
+		fsHandler:          &fakeFsHandler{usage: common.FsUsage{BaseUsageBytes: 10, TotalUsageBytes: 20}},
+		imageFsHandler:     imageFsHandler,
+	}
+
+	// The image usage is not known yet.
+	stats := &info.ContainerStats{}
+	as.Nil(handler.getFsStats(stats))
+	if as.Len(stats.Filesystem, 1) {
+		as.Equal(uint64(10), stats.Filesystem[0].BaseUsage)
+		as.Equal(uint64(0), stats.Filesystem[0].UsageIncludingImage)
+	}
+
+	imageFsHandler.usage = common.FsUsage{TotalUsageBytes: 1100, LastUpdate: time.Unix(1000, 0)}
+	stats = &info.ContainerStats{}
+	as.Nil(handler.getFsStats(stats))
+	if as.Len(stats.Filesystem, 1) {
+		as.Equal(uint64(10), stats.Filesystem[0].BaseUsage)
+		as.Equal(uint64(20), stats.Filesystem[0].Usage)
+		as.Equal(uint64(1120), stats.Filesystem[0].UsageIncludingImage)
+	}
+}
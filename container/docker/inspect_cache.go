@@ -0,0 +1,90 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"sync"
+	"time"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"k8s.io/utils/clock"
+)
+
+// inspectCache keeps the results of container inspects for a short time, so
+// that creating handlers for many containers at once, each of which may also
+// inspect the container whose network it shares, does not flood the docker
+// daemon with inspects.
+type inspectCache struct {
+	inspect func(id string) (dockertypes.ContainerJSON, error)
+	ttl     time.Duration
+	clock   clock.Clock
+
+	lock    sync.Mutex
+	entries map[string]inspectCacheEntry
+}
+
+type inspectCacheEntry struct {
+	ctnr    dockertypes.ContainerJSON
+	fetched time.Time
+}
+
+// newInspectCache returns a cache serving inspect results for ttl, fetching
+// them with inspect. A ttl of zero disables caching.
+func newInspectCache(inspect func(id string) (dockertypes.ContainerJSON, error), ttl time.Duration, clock clock.Clock) *inspectCache {
+	return &inspectCache{
+		inspect: inspect,
+		ttl:     ttl,
+		clock:   clock,
+		entries: make(map[string]inspectCacheEntry),
+	}
+}
+
+// Inspect returns the inspect result of the container with the given id or
+// name, from the cache if it was fetched less than the ttl ago.
+func (c *inspectCache) Inspect(id string) (dockertypes.ContainerJSON, error) {
+	if c.ttl <= 0 {
+		return c.inspect(id)
+	}
+	c.lock.Lock()
+	entry, ok := c.entries[id]
+	c.lock.Unlock()
+	if ok && c.clock.Since(entry.fetched) < c.ttl {
+		return entry.ctnr, nil
+	}
+
+	ctnr, err := c.inspect(id)
+	if err != nil {
+		return ctnr, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.clock.Now()
+	for key, entry := range c.entries {
+		// Drop expired entries, and entries of a container that was
+		// recreated under the same name.
+		if now.Sub(entry.fetched) >= c.ttl || (isSameContainerName(entry.ctnr, ctnr) && entry.ctnr.Created != ctnr.Created) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[id] = inspectCacheEntry{ctnr: ctnr, fetched: now}
+	return ctnr, nil
+}
+
+// isSameContainerName returns true if both inspect results are of containers
+// with the same name.
+func isSameContainerName(a, b dockertypes.ContainerJSON) bool {
+	return a.ContainerJSONBase != nil && b.ContainerJSONBase != nil && a.Name != "" && a.Name == b.Name
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	dockertypes "github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	clock "k8s.io/utils/clock/testing"
+)
+
+func newTestInspectCache(client *docker.Client, ttl time.Duration, fakeClock *clock.FakeClock) *inspectCache {
+	return newInspectCache(func(id string) (dockertypes.ContainerJSON, error) {
+		return client.ContainerInspect(context.Background(), id)
+	}, ttl, fakeClock)
+}
+
+func TestInspectCache(t *testing.T) {
+	as := assert.New(t)
+	daemon, client, cleanup := newFakeDockerDaemon(t, newTestContainerJSON("abcd"))
+	defer cleanup()
+	fakeClock := clock.NewFakeClock(time.Unix(1000, 0))
+	cache := newTestInspectCache(client, 2*time.Second, fakeClock)
+
+	for i := 0; i < 3; i++ {
+		ctnr, err := cache.Inspect("abcd")
+		as.Nil(err)
+		as.Equal("abcd", ctnr.ID)
+	}
+	as.Equal(1, daemon.inspectCount("abcd"))
+
+	// Results are fetched again once they expire.
+	fakeClock.Step(2 * time.Second)
+	_, err := cache.Inspect("abcd")
+	as.Nil(err)
+	as.Equal(2, daemon.inspectCount("abcd"))
+
+	// Failed inspects are not cached.
+	_, err = cache.Inspect("missing")
+	as.NotNil(err)
+	_, err = cache.Inspect("missing")
+	as.NotNil(err)
+	as.Equal(2, daemon.inspectCount("missing"))
+}
+
+func TestInspectCacheDisabled(t *testing.T) {
+	as := assert.New(t)
+	daemon, client, cleanup := newFakeDockerDaemon(t, newTestContainerJSON("abcd"))
+	defer cleanup()
+	cache := newTestInspectCache(client, 0, clock.NewFakeClock(time.Unix(1000, 0)))
+
+	for i := 0; i < 3; i++ {
+		_, err := cache.Inspect("abcd")
+		as.Nil(err)
+	}
+	as.Equal(3, daemon.inspectCount("abcd"))
+}
+
+func TestInspectCacheRecreatedContainer(t *testing.T) {
+	as := assert.New(t)
+	fakeClock := clock.NewFakeClock(time.Unix(1000, 0))
+	created := "2018-01-01T00:00:00Z"
+	cache := newInspectCache(func(id string) (dockertypes.ContainerJSON, error) {
+		ctnr := newTestContainerJSON(id)
+		ctnr.Name = "/web"
+		ctnr.Created = created
+		return ctnr, nil
+	}, 2*time.Second, fakeClock)
+
+	_, err := cache.Inspect("web")
+	as.Nil(err)
+	// The container is recreated under the same name and inspected by its
+	// new ID, the entry for its name must not be served anymore.
+	created = "2018-01-01T00:01:00Z"
+	_, err = cache.Inspect("efgh")
+	as.Nil(err)
+	ctnr, err := cache.Inspect("web")
+	as.Nil(err)
+	as.Equal("2018-01-01T00:01:00Z", ctnr.Created)
+}
+
+func TestInspectCacheConcurrentUse(t *testing.T) {
+	daemon, client, cleanup := newFakeDockerDaemon(t, newTestContainerJSON("abcd"), newTestContainerJSON("efgh"))
+	defer cleanup()
+	cache := newTestInspectCache(client, time.Minute, clock.NewFakeClock(time.Unix(1000, 0)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			_, err := cache.Inspect(id)
+			assert.Nil(t, err)
+		}([]string{"abcd", "efgh"}[i%2])
+	}
+	wg.Wait()
+	_, err := cache.Inspect("abcd")
+	assert.Nil(t, err)
+	assert.True(t, daemon.inspectCount("abcd") <= 5)
+}
+
+func TestHandlersShareInspectCache(t *testing.T) {
+	as := assert.New(t)
+	sandbox := newTestContainerJSON("sandbox")
+	sandbox.NetworkSettings.IPAddress = "10.1.0.5"
+	app1 := newTestContainerJSON("app1")
+	app1.HostConfig.NetworkMode = "container:sandbox"
+	app2 := newTestContainerJSON("app2")
+	app2.HostConfig.NetworkMode = "container:sandbox"
+	daemon, client, cleanup := newFakeDockerDaemon(t, sandbox, app1, app2)
+	defer cleanup()
+	cache := newTestInspectCache(client, 2*time.Second, clock.NewFakeClock(time.Unix(1000, 0)))
+
+	for _, id := range []string{"sandbox", "app1", "app2"} {
+		handler, err := newTestDockerContainerHandler(client, id, testHandlerOptions{inspectCache: cache})
+		as.Nil(err)
+		as.Equal("10.1.0.5", handler.GetContainerIPAddress())
+	}
+	as.Equal(1, daemon.inspectCount("sandbox"))
+}
@@ -0,0 +1,67 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"sync"
+	"time"
+)
+
+// maxRestartSamples is the number of restart count samples kept per container.
+const maxRestartSamples = 32
+
+type restartSample struct {
+	timestamp time.Time
+	count     int
+}
+
+// restartHistory keeps the most recent restart counts observed for a container.
+type restartHistory struct {
+	lock    sync.Mutex
+	samples []restartSample
+}
+
+// record adds the restart count observed at the given time.
+func (r *restartHistory) record(timestamp time.Time, count int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.samples = append(r.samples, restartSample{timestamp: timestamp, count: count})
+	if len(r.samples) > maxRestartSamples {
+		r.samples = r.samples[len(r.samples)-maxRestartSamples:]
+	}
+}
+
+// restartsSince returns the number of restarts observed after since. The
+// last sample at or before since is the baseline; without one the oldest
+// sample in the window is. A count lower than the previous one means the
+// container was recreated and its counter started again from zero.
+func (r *restartHistory) restartsSince(since time.Time) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	restarts := 0
+	var prev *restartSample
+	for i := range r.samples {
+		sample := &r.samples[i]
+		if prev != nil && sample.timestamp.After(since) {
+			if sample.count >= prev.count {
+				restarts += sample.count - prev.count
+			} else {
+				restarts += sample.count
+			}
+		}
+		prev = sample
+	}
+	return restarts
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	clock "k8s.io/utils/clock/testing"
+)
+
+func TestRestartsSince(t *testing.T) {
+	as := assert.New(t)
+	start := time.Unix(1000, 0)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+	history := &restartHistory{}
+	as.Equal(0, history.restartsSince(start))
+
+	// First window: two restarts.
+	history.record(at(0), 1)
+	history.record(at(5), 2)
+	history.record(at(10), 3)
+	as.Equal(2, history.restartsSince(at(0)))
+	as.Equal(1, history.restartsSince(at(5)))
+
+	// Second window: the container is recreated and its count starts over.
+	history.record(at(15), 4)
+	history.record(at(20), 1)
+	history.record(at(25), 2)
+	as.Equal(3, history.restartsSince(at(10)))
+	as.Equal(5, history.restartsSince(at(0)))
+	as.Equal(0, history.restartsSince(at(25)))
+}
+
+func TestRestartsSinceKeepsRecentSamples(t *testing.T) {
+	history := &restartHistory{}
+	start := time.Unix(1000, 0)
+	for i := 0; i < 2*maxRestartSamples; i++ {
+		history.record(start.Add(time.Duration(i)*time.Minute), i)
+	}
+	assert.Len(t, history.samples, maxRestartSamples)
+	assert.Equal(t, maxRestartSamples-1, history.restartsSince(start))
+}
+
+func TestHandlerRestartsSince(t *testing.T) {
+	as := assert.New(t)
+	ctnr := newTestContainerJSON("abcd")
+	ctnr.RestartCount = 2
+	daemon, client, cleanup := newFakeDockerDaemon(t, ctnr)
+	defer cleanup()
+	fakeClock := clock.NewFakeClock(time.Unix(1000, 0))
+	cache := newInspectCache(func(id string) (dockertypes.ContainerJSON, error) {
+		return client.ContainerInspect(context.Background(), id)
+	}, time.Second, fakeClock)
+
+	handler, err := newTestDockerContainerHandler(client, "abcd", testHandlerOptions{inspectCache: cache})
+	as.Nil(err)
+	handler.clock = fakeClock
+	created := fakeClock.Now()
+	as.Equal(0, handler.RestartsSince(created))
+
+	setRestartCount := func(restartCount int) {
+		daemon.Lock()
+		defer daemon.Unlock()
+		ctnr.RestartCount = restartCount
+		daemon.containers["abcd"] = ctnr
+	}
+	fakeClock.Step(time.Minute)
+	setRestartCount(5)
+	as.Equal(3, handler.RestartsSince(created))
+	spec, err := handler.GetSpec()
+	as.Nil(err)
+	as.Equal(5, spec.RestartCount)
+
+	fakeClock.Step(time.Minute)
+	as.Equal(0, handler.RestartsSince(created.Add(time.Minute)))
+	as.Equal(3, handler.RestartsSince(created))
+}
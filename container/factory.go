@@ -16,6 +16,7 @@ package container
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/matthewygf/cadvisor/fs"
@@ -39,6 +40,16 @@ type ContainerHandlerFactory interface {
 	DebugInfo() map[string][]string
 }
 
+// ContainerHandlerFactoryWithExplain is implemented by factories that can
+// describe why they would or would not handle and accept a container.
+type ContainerHandlerFactoryWithExplain interface {
+	ContainerHandlerFactory
+
+	// Explain returns the decision CanHandleAndAccept would make for the
+	// specified container and the rule that made it.
+	Explain(name string) string
+}
+
 // MetricKind represents the kind of metrics that cAdvisor exposes.
 type MetricKind string
 
@@ -50,12 +61,16 @@ const (
 	CpuLoadMetrics          MetricKind = "cpuLoad"
 	DiskIOMetrics           MetricKind = "diskIO"
 	DiskUsageMetrics        MetricKind = "disk"
+	DiskImageUsageMetrics   MetricKind = "diskImage"
 	NetworkUsageMetrics     MetricKind = "network"
 	NetworkTcpUsageMetrics  MetricKind = "tcp"
 	NetworkUdpUsageMetrics  MetricKind = "udp"
 	AcceleratorUsageMetrics MetricKind = "accelerator"
 	AppMetrics              MetricKind = "app"
 	ProcessMetrics          MetricKind = "process"
+	// Network usage of all interfaces summed into one, rather than broken out
+	// per interface.
+	SummedNetworkMetrics MetricKind = "summedNetwork"
 )
 
 func (mk MetricKind) String() string {
@@ -181,6 +196,36 @@ func NewContainerHandler(name string, watchType watcher.ContainerWatchSource, in
 	return nil, false, fmt.Errorf("no known factory can handle creation of container")
 }
 
+// FactoryInfo describes a registered container handler factory.
+type FactoryInfo struct {
+	// Name of the factory.
+	Name string
+
+	// Watch sources whose containers the factory is asked to handle.
+	WatchSources []watcher.ContainerWatchSource
+}
+
+// Returns the registered container handler factories, sorted by name.
+func RegisteredFactories() []FactoryInfo {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+
+	watchSources := make(map[string][]watcher.ContainerWatchSource)
+	for watchSource, factoriesSlice := range factories {
+		for _, factory := range factoriesSlice {
+			name := factory.String()
+			watchSources[name] = append(watchSources[name], watchSource)
+		}
+	}
+	infos := make([]FactoryInfo, 0, len(watchSources))
+	for name, sources := range watchSources {
+		sort.Slice(sources, func(i, j int) bool { return sources[i] < sources[j] })
+		infos = append(infos, FactoryInfo{Name: name, WatchSources: sources})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
 // Clear the known factories.
 func ClearContainerHandlerFactories() {
 	factoriesLock.Lock()
@@ -204,3 +249,28 @@ func DebugInfo() map[string][]string {
 	}
 	return out
 }
+
+// Explain returns, for each registered factory able to explain itself, the
+// decision it would make for the specified container, sorted by factory name.
+func Explain(name string) []string {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+
+	explained := make(map[string]struct{})
+	var out []string
+	for _, factoriesSlice := range factories {
+		for _, factory := range factoriesSlice {
+			explainer, ok := factory.(ContainerHandlerFactoryWithExplain)
+			if !ok {
+				continue
+			}
+			if _, ok := explained[factory.String()]; ok {
+				continue
+			}
+			explained[factory.String()] = struct{}{}
+			out = append(out, fmt.Sprintf("%s: %s", factory, explainer.Explain(name)))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
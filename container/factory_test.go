@@ -15,6 +15,7 @@
 package container_test
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/matthewygf/cadvisor/container"
@@ -160,3 +161,55 @@ func TestNewContainerHandler_Accept(t *testing.T) {
 		t.Error("Expected NewContainerHandler to ignore the container.")
 	}
 }
+
+func TestRegisteredFactories(t *testing.T) {
+	container.ClearContainerHandlerFactories()
+	defer container.ClearContainerHandlerFactories()
+
+	if factories := container.RegisteredFactories(); len(factories) != 0 {
+		t.Errorf("Expected no factories, got %+v", factories)
+	}
+
+	container.RegisterContainerHandlerFactory(&mockContainerHandlerFactory{Name: "rkt"}, []watcher.ContainerWatchSource{watcher.Rkt})
+	container.RegisterContainerHandlerFactory(&mockContainerHandlerFactory{Name: "raw"}, []watcher.ContainerWatchSource{watcher.Raw, watcher.Rkt})
+
+	expected := []container.FactoryInfo{
+		{Name: "raw", WatchSources: []watcher.ContainerWatchSource{watcher.Raw, watcher.Rkt}},
+		{Name: "rkt", WatchSources: []watcher.ContainerWatchSource{watcher.Rkt}},
+	}
+	if factories := container.RegisteredFactories(); !reflect.DeepEqual(expected, factories) {
+		t.Errorf("Expected factories %+v, got %+v", expected, factories)
+	}
+}
+
+type explainingContainerHandlerFactory struct {
+	mockContainerHandlerFactory
+	Explanation string
+}
+
+func (self *explainingContainerHandlerFactory) Explain(name string) string {
+	return self.Explanation
+}
+
+func TestExplain(t *testing.T) {
+	container.ClearContainerHandlerFactories()
+	defer container.ClearContainerHandlerFactories()
+
+	container.RegisterContainerHandlerFactory(&mockContainerHandlerFactory{Name: "silent"}, []watcher.ContainerWatchSource{watcher.Raw})
+	container.RegisterContainerHandlerFactory(&explainingContainerHandlerFactory{
+		mockContainerHandlerFactory: mockContainerHandlerFactory{Name: "raw"},
+		Explanation:                 "accepted: the raw cgroup whitelist is empty",
+	}, []watcher.ContainerWatchSource{watcher.Raw})
+	container.RegisterContainerHandlerFactory(&explainingContainerHandlerFactory{
+		mockContainerHandlerFactory: mockContainerHandlerFactory{Name: "rkt"},
+		Explanation:                 "not handled: not a cgroup of a running rkt pod",
+	}, []watcher.ContainerWatchSource{watcher.Rkt})
+
+	expected := []string{
+		"raw: accepted: the raw cgroup whitelist is empty",
+		"rkt: not handled: not a cgroup of a running rkt pod",
+	}
+	if explanations := container.Explain(testContainerName); !reflect.DeepEqual(explanations, expected) {
+		t.Errorf("expected %v, got %v", expected, explanations)
+	}
+}
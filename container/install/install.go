@@ -20,6 +20,7 @@ import (
 	_ "github.com/matthewygf/cadvisor/container/crio/install"
 	_ "github.com/matthewygf/cadvisor/container/docker/install"
 	_ "github.com/matthewygf/cadvisor/container/mesos/install"
+	_ "github.com/matthewygf/cadvisor/container/podman/install"
 	_ "github.com/matthewygf/cadvisor/container/rkt/install"
 	_ "github.com/matthewygf/cadvisor/container/systemd/install"
 )
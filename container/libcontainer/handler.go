@@ -23,6 +23,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/matthewygf/cadvisor/container"
@@ -34,6 +35,7 @@ import (
 	"github.com/opencontainers/runc/libcontainer"
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"k8s.io/klog"
+	"k8s.io/utils/clock"
 )
 
 type Handler struct {
@@ -42,8 +44,25 @@ type Handler struct {
 	pid             int
 	includedMetrics container.MetricSet
 	pidMetricsCache map[int]*info.CpuSchedstat
+
+	// latencyLock guards collectionLatencies, which holds how long each metric
+	// group took to collect during the last call to GetStats. It is nil unless
+	// recording is enabled.
+	latencyLock         sync.Mutex
+	collectionLatencies map[string]time.Duration
+	// Clock the collection latencies are measured with.
+	clock clock.Clock
 }
 
+// Metric groups for which collection latency is recorded.
+const (
+	CgroupLatencyGroup    = "cgroup"
+	SchedLatencyGroup     = "sched"
+	NetworkLatencyGroup   = "network"
+	ProcessesLatencyGroup = "processes"
+	FsLatencyGroup        = "fs"
+)
+
 func NewHandler(cgroupManager cgroups.Manager, rootFs string, pid int, includedMetrics container.MetricSet) *Handler {
 	return &Handler{
 		cgroupManager:   cgroupManager,
@@ -51,11 +70,61 @@ func NewHandler(cgroupManager cgroups.Manager, rootFs string, pid int, includedM
 		pid:             pid,
 		includedMetrics: includedMetrics,
 		pidMetricsCache: make(map[int]*info.CpuSchedstat),
+		clock:           clock.RealClock{},
+	}
+}
+
+// EnableCollectionLatencies makes GetStats record how long each metric group
+// takes to collect, as measured with the given clock.
+func (h *Handler) EnableCollectionLatencies(clock clock.Clock) {
+	h.latencyLock.Lock()
+	defer h.latencyLock.Unlock()
+	h.clock = clock
+	if h.collectionLatencies == nil {
+		h.collectionLatencies = make(map[string]time.Duration)
+	}
+}
+
+// resetLatencies drops the collection latencies of the previous call to
+// GetStats, so that groups which are no longer collected are not reported.
+func (h *Handler) resetLatencies() {
+	h.latencyLock.Lock()
+	defer h.latencyLock.Unlock()
+	if h.collectionLatencies != nil {
+		h.collectionLatencies = make(map[string]time.Duration)
+	}
+}
+
+// CollectionLatencies returns how long each metric group took to collect
+// during the last call to GetStats, or nil if recording is not enabled.
+func (h *Handler) CollectionLatencies() map[string]time.Duration {
+	h.latencyLock.Lock()
+	defer h.latencyLock.Unlock()
+	if h.collectionLatencies == nil {
+		return nil
+	}
+	latencies := make(map[string]time.Duration, len(h.collectionLatencies))
+	for group, latency := range h.collectionLatencies {
+		latencies[group] = latency
+	}
+	return latencies
+}
+
+// RecordLatency records the collection latency of a metric group. Handlers
+// wrapping this one use it for groups collected outside of GetStats, e.g.
+// filesystem stats. It is a no-op if recording is not enabled.
+func (h *Handler) RecordLatency(group string, latency time.Duration) {
+	h.latencyLock.Lock()
+	defer h.latencyLock.Unlock()
+	if h.collectionLatencies != nil {
+		h.collectionLatencies[group] = latency
 	}
 }
 
 // Get cgroup and networking stats of the specified container
 func (h *Handler) GetStats() (*info.ContainerStats, error) {
+	h.resetLatencies()
+	start := h.clock.Now()
 	cgroupStats, err := h.cgroupManager.GetStats()
 	if err != nil {
 		return nil, err
@@ -64,8 +133,37 @@ func (h *Handler) GetStats() (*info.ContainerStats, error) {
 		CgroupStats: cgroupStats,
 	}
 	stats := newContainerStats(libcontainerStats, h.includedMetrics)
+	if cgroups.IsCgroup2UnifiedMode() {
+		// cgroup v2 reports the throttled time in microseconds, which the
+		// cgroup manager does not parse.
+		if cpuPath, ok := h.cgroupManager.GetPaths()["cpu"]; ok {
+			throttledTime, err := throttledTimeFromCpuStat(path.Join(cpuPath, "cpu.stat"))
+			if err != nil {
+				klog.V(4).Infof("Unable to get throttled time from %q: %v", cpuPath, err)
+			} else {
+				stats.Cpu.CFS.ThrottledTime = throttledTime
+			}
+		}
+	}
+	if h.includedMetrics.Has(container.MemoryUsageMetrics) {
+		if memoryPath, ok := h.cgroupManager.GetPaths()["memory"]; ok {
+			// cgroup v1 reports the OOM kills in memory.oom_control, v2 in memory.events.
+			file := path.Join(memoryPath, "memory.oom_control")
+			if cgroups.IsCgroup2UnifiedMode() {
+				file = path.Join(memoryPath, "memory.events")
+			}
+			oomKills, err := oomKillsFromFile(file)
+			if err != nil {
+				klog.V(4).Infof("Unable to get OOM kills from %q: %v", file, err)
+			} else {
+				stats.Memory.OomKills = oomKills
+			}
+		}
+	}
+	h.RecordLatency(CgroupLatencyGroup, h.clock.Since(start))
 
 	if h.includedMetrics.Has(container.ProcessSchedulerMetrics) {
+		start := h.clock.Now()
 		pids, err := h.cgroupManager.GetAllPids()
 		if err != nil {
 			klog.V(4).Infof("Could not get PIDs for container %d: %v", h.pid, err)
@@ -75,12 +173,14 @@ func (h *Handler) GetStats() (*info.ContainerStats, error) {
 				klog.V(4).Infof("Unable to get Process Scheduler Stats: %v", err)
 			}
 		}
+		h.RecordLatency(SchedLatencyGroup, h.clock.Since(start))
 	}
 
 	// If we know the pid then get network stats from /proc/<pid>/net/dev
 	if h.pid == 0 {
 		return stats, nil
 	}
+	start = h.clock.Now()
 	if h.includedMetrics.Has(container.NetworkUsageMetrics) {
 		netStats, err := networkStatsFromProc(h.rootFs, h.pid)
 		if err != nil {
@@ -119,7 +219,11 @@ func (h *Handler) GetStats() (*info.ContainerStats, error) {
 			stats.Network.Udp6 = u6
 		}
 	}
+	if h.includedMetrics.Has(container.NetworkUsageMetrics) || h.includedMetrics.Has(container.NetworkTcpUsageMetrics) || h.includedMetrics.Has(container.NetworkUdpUsageMetrics) {
+		h.RecordLatency(NetworkLatencyGroup, h.clock.Since(start))
+	}
 	if h.includedMetrics.Has(container.ProcessMetrics) {
+		start := h.clock.Now()
 		paths := h.cgroupManager.GetPaths()
 		path, ok := paths["cpu"]
 		if !ok {
@@ -133,6 +237,13 @@ func (h *Handler) GetStats() (*info.ContainerStats, error) {
 
 		// if include processes metrics, just set threads metrics if exist, and has no relationship with cpu path
 		setThreadsStats(cgroupStats, stats)
+		h.RecordLatency(ProcessesLatencyGroup, h.clock.Since(start))
+	}
+
+	// Summing the interfaces is opt-in. It cuts the number of series exported
+	// for containers with several interfaces, but loses their names.
+	if h.includedMetrics.Has(container.SummedNetworkMetrics) {
+		stats.Network.Interfaces = sumInterfaceStats(stats.Network.Interfaces)
 	}
 
 	// For backwards compatibility.
@@ -143,8 +254,28 @@ func (h *Handler) GetStats() (*info.ContainerStats, error) {
 	return stats, nil
 }
 
+// sumInterfaceStats returns the stats of all interfaces summed into a single
+// one. The result is unnamed, unless there is only one interface.
+func sumInterfaceStats(interfaces []info.InterfaceStats) []info.InterfaceStats {
+	if len(interfaces) <= 1 {
+		return interfaces
+	}
+	var sum info.InterfaceStats
+	for _, i := range interfaces {
+		sum.RxBytes += i.RxBytes
+		sum.RxPackets += i.RxPackets
+		sum.RxErrors += i.RxErrors
+		sum.RxDropped += i.RxDropped
+		sum.TxBytes += i.TxBytes
+		sum.TxPackets += i.TxPackets
+		sum.TxErrors += i.TxErrors
+		sum.TxDropped += i.TxDropped
+	}
+	return []info.InterfaceStats{sum}
+}
+
 func processStatsFromProcs(rootFs string, cgroupPath string) (info.ProcessStats, error) {
-	var fdCount, socketCount uint64
+	var fdCount, socketCount, threadCount uint64
 	filePath := path.Join(cgroupPath, "cgroup.procs")
 	out, err := ioutil.ReadFile(filePath)
 	if err != nil {
@@ -161,6 +292,12 @@ func processStatsFromProcs(rootFs string, cgroupPath string) (info.ProcessStats,
 	}
 
 	for _, pid := range pids {
+		threads, err := threadCountFromStatus(path.Join(rootFs, "/proc", pid, "status"))
+		if err != nil {
+			klog.V(4).Infof("error while reading the thread count of process %s: %v", pid, err)
+		}
+		threadCount += threads
+
 		dirPath := path.Join(rootFs, "/proc", pid, "fd")
 		fds, err := ioutil.ReadDir(dirPath)
 		if err != nil {
@@ -182,22 +319,39 @@ func processStatsFromProcs(rootFs string, cgroupPath string) (info.ProcessStats,
 	}
 
 	processStats := info.ProcessStats{
-		ProcessCount: uint64(len(pids)),
-		FdCount:      fdCount,
-		SocketCount:  socketCount,
+		ProcessCount:   uint64(len(pids)),
+		FdCount:        fdCount,
+		SocketCount:    socketCount,
+		ThreadsCurrent: threadCount,
 	}
 
 	return processStats, nil
 }
 
+// threadCountFromStatus returns the number of threads of the process from the
+// "Threads:" line of its /proc/<pid>/status file.
+func threadCountFromStatus(statusFile string) (uint64, error) {
+	contents, err := ioutil.ReadFile(statusFile)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		if !strings.HasPrefix(line, "Threads:") {
+			continue
+		}
+		return strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "Threads:")), 10, 64)
+	}
+	return 0, fmt.Errorf("no thread count in %q", statusFile)
+}
+
 func schedulerStatsFromProcs(rootFs string, pids []int, pidMetricsCache map[int]*info.CpuSchedstat) (info.CpuSchedstat, error) {
 	for _, pid := range pids {
-		f, err := os.Open(path.Join(rootFs, "proc", strconv.Itoa(pid), "schedstat"))
-		if err != nil {
-			return info.CpuSchedstat{}, fmt.Errorf("couldn't open scheduler statistics for process %d: %v", pid, err)
+		contents, err := ioutil.ReadFile(path.Join(rootFs, "proc", strconv.Itoa(pid), "schedstat"))
+		if os.IsNotExist(err) {
+			// The process exited after the pids were listed. Its last
+			// statistics remain in the cache.
+			continue
 		}
-		defer f.Close()
-		contents, err := ioutil.ReadAll(f)
 		if err != nil {
 			return info.CpuSchedstat{}, fmt.Errorf("couldn't read scheduler statistics for process %d: %v", pid, err)
 		}
@@ -484,6 +638,61 @@ func minUint32(x, y uint32) uint32 {
 // var to allow unit tests to stub it out
 var numCpusFunc = getNumberOnlineCPUs
 
+// throttledTimeFromCpuStat returns the total time tasks in the cgroup have
+// been throttled in nanoseconds, from a cgroup v1 (throttled_time, in
+// nanoseconds) or cgroup v2 (throttled_usec, in microseconds) cpu.stat file.
+func throttledTimeFromCpuStat(cpuStatPath string) (uint64, error) {
+	out, err := ioutil.ReadFile(cpuStatPath)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		var unit uint64
+		switch fields[0] {
+		case "throttled_time":
+			unit = 1
+		case "throttled_usec":
+			unit = uint64(time.Microsecond)
+		default:
+			continue
+		}
+		val, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %q: %v", line, err)
+		}
+		return val * unit, nil
+	}
+	return 0, fmt.Errorf("no throttled time in %q", cpuStatPath)
+}
+
+// oomKillsFromFile returns the oom_kill count of a memory.oom_control or
+// memory.events file, 0 if the file or the count does not exist.
+func oomKillsFromFile(file string) (uint64, error) {
+	out, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "oom_kill" {
+			continue
+		}
+		val, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %q: %v", line, err)
+		}
+		return val, nil
+	}
+	return 0, nil
+}
+
 // Convert libcontainer stats to info.ContainerStats.
 func setCpuStats(s *cgroups.Stats, ret *info.ContainerStats, withPerCPU bool) {
 	ret.Cpu.Usage.User = s.CpuStats.CpuUsage.UsageInUsermode
@@ -549,17 +758,25 @@ func setMemoryStats(s *cgroups.Stats, ret *info.ContainerStats) {
 	ret.Memory.Usage = s.MemoryStats.Usage.Usage
 	ret.Memory.MaxUsage = s.MemoryStats.Usage.MaxUsage
 	ret.Memory.Failcnt = s.MemoryStats.Usage.Failcnt
+	ret.Memory.TCPUsage = s.MemoryStats.KernelTCPUsage.Usage
+	ret.Memory.TCPLimit = s.MemoryStats.KernelTCPUsage.Limit
 
 	if s.MemoryStats.UseHierarchy {
 		ret.Memory.Cache = s.MemoryStats.Stats["total_cache"]
 		ret.Memory.RSS = s.MemoryStats.Stats["total_rss"]
 		ret.Memory.Swap = s.MemoryStats.Stats["total_swap"]
 		ret.Memory.MappedFile = s.MemoryStats.Stats["total_mapped_file"]
+		ret.Memory.AnonTHP = s.MemoryStats.Stats["total_rss_huge"]
 	} else {
 		ret.Memory.Cache = s.MemoryStats.Stats["cache"]
 		ret.Memory.RSS = s.MemoryStats.Stats["rss"]
 		ret.Memory.Swap = s.MemoryStats.Stats["swap"]
 		ret.Memory.MappedFile = s.MemoryStats.Stats["mapped_file"]
+		ret.Memory.AnonTHP = s.MemoryStats.Stats["rss_huge"]
+	}
+	// cgroup v2 reports transparent huge pages as anon_thp.
+	if v, ok := s.MemoryStats.Stats["anon_thp"]; ok {
+		ret.Memory.AnonTHP = v
 	}
 	if v, ok := s.MemoryStats.Stats["pgfault"]; ok {
 		ret.Memory.ContainerData.Pgfault = v
@@ -581,6 +798,20 @@ func setMemoryStats(s *cgroups.Stats, ret *info.ContainerStats) {
 	ret.Memory.WorkingSet = workingSet
 }
 
+func setHugetlbStats(s *cgroups.Stats, ret *info.ContainerStats) {
+	if len(s.HugetlbStats) == 0 {
+		return
+	}
+	ret.Hugetlb = make(map[string]info.HugetlbStats, len(s.HugetlbStats))
+	for pageSize, stats := range s.HugetlbStats {
+		ret.Hugetlb[pageSize] = info.HugetlbStats{
+			Usage:    stats.Usage,
+			MaxUsage: stats.MaxUsage,
+			Failcnt:  stats.Failcnt,
+		}
+	}
+}
+
 func setNetworkStats(libcontainerStats *libcontainer.Stats, ret *info.ContainerStats) {
 	ret.Network.Interfaces = make([]info.InterfaceStats, len(libcontainerStats.Interfaces))
 	for i := range libcontainerStats.Interfaces {
@@ -603,10 +834,13 @@ func setNetworkStats(libcontainerStats *libcontainer.Stats, ret *info.ContainerS
 	}
 }
 
-// read from pids path not cpu
+// read from pids path not cpu. The threads counted in the processes' status
+// files are kept if the pids controller doesn't track the container.
 func setThreadsStats(s *cgroups.Stats, ret *info.ContainerStats) {
 	if s != nil {
-		ret.Processes.ThreadsCurrent = s.PidsStats.Current
+		if s.PidsStats.Current != 0 {
+			ret.Processes.ThreadsCurrent = s.PidsStats.Current
+		}
 		ret.Processes.ThreadsMax = s.PidsStats.Limit
 	}
 
@@ -623,6 +857,7 @@ func newContainerStats(libcontainerStats *libcontainer.Stats, includedMetrics co
 			setDiskIoStats(s, ret)
 		}
 		setMemoryStats(s, ret)
+		setHugetlbStats(s, ret)
 	}
 	if len(libcontainerStats.Interfaces) > 0 {
 		setNetworkStats(libcontainerStats, ret)
@@ -15,14 +15,39 @@
 package libcontainer
 
 import (
+	"io/ioutil"
 	"os"
+	"path"
+	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/matthewygf/cadvisor/container"
 	info "github.com/matthewygf/cadvisor/info/v1"
 	"github.com/opencontainers/runc/libcontainer/cgroups"
+	cgroupfs "github.com/opencontainers/runc/libcontainer/cgroups/fs"
 	"github.com/opencontainers/runc/libcontainer/system"
+	clock "k8s.io/utils/clock/testing"
 )
 
+// fakeCgroupManager returns empty stats and no pids.
+type fakeCgroupManager struct {
+	cgroups.Manager
+}
+
+func (m *fakeCgroupManager) GetStats() (*cgroups.Stats, error) {
+	return cgroups.NewStats(), nil
+}
+
+func (m *fakeCgroupManager) GetAllPids() ([]int, error) {
+	return nil, nil
+}
+
+func (m *fakeCgroupManager) GetPaths() map[string]string {
+	return nil
+}
+
 func TestScanInterfaceStats(t *testing.T) {
 	stats, err := scanInterfaceStats("testdata/procnetdev")
 	if err != nil {
@@ -175,3 +200,463 @@ func TestSetProcessesStats(t *testing.T) {
 	}
 
 }
+
+func TestProcessStatsFromProcs(t *testing.T) {
+	rootFs, err := ioutil.TempDir("", "rootfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootFs)
+	cgroupPath := path.Join(rootFs, "sys/fs/cgroup/pids/docker/abcd")
+	if err := os.MkdirAll(cgroupPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(cgroupPath, "cgroup.procs"), []byte("10\n20\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for pid, threads := range map[string]int{"10": 3, "20": 4} {
+		procPath := path.Join(rootFs, "proc", pid)
+		if err := os.MkdirAll(path.Join(procPath, "fd"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		status := "Name:\tapp\nState:\tS (sleeping)\nThreads:\t" + strconv.Itoa(threads) + "\nSigQ:\t0/31116\n"
+		if err := ioutil.WriteFile(path.Join(procPath, "status"), []byte(status), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	processStats, err := processStatsFromProcs(rootFs, cgroupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processStats.ProcessCount != 2 {
+		t.Errorf("expected 2 processes, got %d", processStats.ProcessCount)
+	}
+	if processStats.ThreadsCurrent != 7 {
+		t.Errorf("expected 7 threads, got %d", processStats.ThreadsCurrent)
+	}
+
+	// Without the pids controller, the counted threads are kept.
+	ret := info.ContainerStats{Processes: processStats}
+	setThreadsStats(cgroups.NewStats(), &ret)
+	if ret.Processes.ThreadsCurrent != 7 {
+		t.Errorf("expected 7 threads without pids.current, got %d", ret.Processes.ThreadsCurrent)
+	}
+	// Otherwise, pids.current is reported.
+	s := cgroups.NewStats()
+	s.PidsStats.Current = 8
+	setThreadsStats(s, &ret)
+	if ret.Processes.ThreadsCurrent != 8 {
+		t.Errorf("expected pids.current threads, got %d", ret.Processes.ThreadsCurrent)
+	}
+}
+
+func TestSetHugetlbStats(t *testing.T) {
+	ret := info.ContainerStats{}
+	s := &cgroups.Stats{
+		HugetlbStats: map[string]cgroups.HugetlbStats{
+			"2MB": {Usage: 2097152, MaxUsage: 4194304, Failcnt: 1},
+		},
+	}
+	setHugetlbStats(s, &ret)
+
+	expected := map[string]info.HugetlbStats{
+		"2MB": {Usage: 2097152, MaxUsage: 4194304, Failcnt: 1},
+	}
+	if !reflect.DeepEqual(expected, ret.Hugetlb) {
+		t.Fatalf("expected hugetlb stats %+v, got %+v", expected, ret.Hugetlb)
+	}
+}
+
+func TestThrottledTimeFromCpuStat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range []struct {
+		cpuStat  string
+		expected uint64
+	}{
+		{
+			// cgroup v1, in nanoseconds.
+			cpuStat:  "nr_periods 100\nnr_throttled 10\nthrottled_time 2500000000\n",
+			expected: 2500000000,
+		},
+		{
+			// cgroup v2, in microseconds.
+			cpuStat:  "usage_usec 8000000\nuser_usec 6000000\nsystem_usec 2000000\nnr_periods 100\nnr_throttled 10\nthrottled_usec 2500000\n",
+			expected: 2500000000,
+		},
+	} {
+		cpuStatPath := path.Join(dir, "cpu.stat")
+		if err := ioutil.WriteFile(cpuStatPath, []byte(test.cpuStat), 0644); err != nil {
+			t.Fatal(err)
+		}
+		throttledTime, err := throttledTimeFromCpuStat(cpuStatPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if throttledTime != test.expected {
+			t.Errorf("expected throttled time %dns, got %dns for %q", test.expected, throttledTime, test.cpuStat)
+		}
+	}
+
+	if err := ioutil.WriteFile(path.Join(dir, "cpu.stat"), []byte("nr_periods 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := throttledTimeFromCpuStat(path.Join(dir, "cpu.stat")); err == nil {
+		t.Error("expected an error without throttled time")
+	}
+}
+
+func TestOomKillsFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range []struct {
+		file     string
+		contents string
+		expected uint64
+	}{
+		{
+			file:     "memory.oom_control",
+			contents: "oom_kill_disable 0\nunder_oom 0\noom_kill 3\n",
+			expected: 3,
+		},
+		{
+			// Kernels older than 4.13 don't count OOM kills.
+			file:     "memory.oom_control",
+			contents: "oom_kill_disable 0\nunder_oom 0\n",
+			expected: 0,
+		},
+		{
+			file:     "memory.events",
+			contents: "low 0\nhigh 0\nmax 12\noom 2\noom_kill 2\n",
+			expected: 2,
+		},
+	} {
+		file := path.Join(dir, test.file)
+		if err := ioutil.WriteFile(file, []byte(test.contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		oomKills, err := oomKillsFromFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if oomKills != test.expected {
+			t.Errorf("expected %d OOM kills, got %d for %q", test.expected, oomKills, test.contents)
+		}
+	}
+
+	oomKills, err := oomKillsFromFile(path.Join(dir, "does-not-exist"))
+	if err != nil || oomKills != 0 {
+		t.Errorf("expected no OOM kills without error for a missing file, got %d, %v", oomKills, err)
+	}
+}
+
+func TestSetMemoryStatsTCP(t *testing.T) {
+	for _, test := range []struct {
+		files         map[string]string
+		expectedUsage uint64
+		expectedLimit uint64
+	}{
+		{
+			files: map[string]string{
+				"memory.kmem.tcp.usage_in_bytes":     "1048576",
+				"memory.kmem.tcp.max_usage_in_bytes": "2097152",
+				"memory.kmem.tcp.failcnt":            "0",
+				"memory.kmem.tcp.limit_in_bytes":     "4194304",
+			},
+			expectedUsage: 1048576,
+			expectedLimit: 4194304,
+		},
+		{
+			// The kernel memory controller is not available.
+			files: map[string]string{},
+		},
+	} {
+		memoryPath, err := ioutil.TempDir("", "memory")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(memoryPath)
+		files := map[string]string{
+			"memory.stat":               "cache 0\nrss 0\n",
+			"memory.usage_in_bytes":     "8388608",
+			"memory.max_usage_in_bytes": "8388608",
+			"memory.failcnt":            "0",
+			"memory.limit_in_bytes":     "16777216",
+			"memory.use_hierarchy":      "1",
+		}
+		for name, contents := range test.files {
+			files[name] = contents
+		}
+		for name, contents := range files {
+			if err := ioutil.WriteFile(path.Join(memoryPath, name), []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		s := cgroups.NewStats()
+		if err := (&cgroupfs.MemoryGroup{}).GetStats(memoryPath, s); err != nil {
+			t.Fatal(err)
+		}
+		ret := info.ContainerStats{}
+		setMemoryStats(s, &ret)
+		if ret.Memory.TCPUsage != test.expectedUsage {
+			t.Errorf("expected tcp usage %d, got %d", test.expectedUsage, ret.Memory.TCPUsage)
+		}
+		if ret.Memory.TCPLimit != test.expectedLimit {
+			t.Errorf("expected tcp limit %d, got %d", test.expectedLimit, ret.Memory.TCPLimit)
+		}
+	}
+}
+
+func TestSetMemoryStatsTHP(t *testing.T) {
+	for _, test := range []struct {
+		memoryStat   string
+		useHierarchy string
+		expected     uint64
+	}{
+		{
+			memoryStat:   "anon 8388608\nanon_thp 4194304\nfile 0\n",
+			useHierarchy: "0",
+			expected:     4194304,
+		},
+		{
+			memoryStat:   "cache 0\nrss 8388608\nrss_huge 2097152\ntotal_rss_huge 6291456\n",
+			useHierarchy: "1",
+			expected:     6291456,
+		},
+		{
+			memoryStat:   "cache 0\nrss 8388608\nrss_huge 2097152\n",
+			useHierarchy: "0",
+			expected:     2097152,
+		},
+		{
+			// The kernel does not report transparent huge pages.
+			memoryStat:   "cache 0\nrss 8388608\n",
+			useHierarchy: "1",
+		},
+	} {
+		memoryPath, err := ioutil.TempDir("", "memory")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(memoryPath)
+		files := map[string]string{
+			"memory.stat":               test.memoryStat,
+			"memory.usage_in_bytes":     "8388608",
+			"memory.max_usage_in_bytes": "8388608",
+			"memory.failcnt":            "0",
+			"memory.limit_in_bytes":     "16777216",
+			"memory.use_hierarchy":      test.useHierarchy,
+		}
+		for name, contents := range files {
+			if err := ioutil.WriteFile(path.Join(memoryPath, name), []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		s := cgroups.NewStats()
+		if err := (&cgroupfs.MemoryGroup{}).GetStats(memoryPath, s); err != nil {
+			t.Fatal(err)
+		}
+		ret := info.ContainerStats{}
+		setMemoryStats(s, &ret)
+		if ret.Memory.AnonTHP != test.expected {
+			t.Errorf("expected anon thp %d for %q, got %d", test.expected, test.memoryStat, ret.Memory.AnonTHP)
+		}
+	}
+}
+
+func TestCollectionLatencies(t *testing.T) {
+	includedMetrics := container.MetricSet{
+		container.CpuUsageMetrics:         struct{}{},
+		container.ProcessSchedulerMetrics: struct{}{},
+		container.NetworkUsageMetrics:     struct{}{},
+	}
+	handler := NewHandler(&fakeCgroupManager{}, "/", 0, includedMetrics)
+	if _, err := handler.GetStats(); err != nil {
+		t.Fatal(err)
+	}
+	if latencies := handler.CollectionLatencies(); latencies != nil {
+		t.Errorf("expected no latencies when recording is disabled, got %v", latencies)
+	}
+
+	handler.EnableCollectionLatencies(clock.NewFakeClock(time.Unix(1000, 0)))
+	if _, err := handler.GetStats(); err != nil {
+		t.Fatal(err)
+	}
+	handler.RecordLatency(FsLatencyGroup, time.Second)
+
+	latencies := handler.CollectionLatencies()
+	for _, group := range []string{CgroupLatencyGroup, SchedLatencyGroup, FsLatencyGroup} {
+		if _, ok := latencies[group]; !ok {
+			t.Errorf("expected latency for group %q to be recorded, got %v", group, latencies)
+		}
+	}
+	// Network stats are read from the container's pid, which is unknown here.
+	if _, ok := latencies[NetworkLatencyGroup]; ok {
+		t.Errorf("expected no latency for group %q, got %v", NetworkLatencyGroup, latencies)
+	}
+	if latencies[FsLatencyGroup] != time.Second {
+		t.Errorf("expected %q latency of %v, got %v", FsLatencyGroup, time.Second, latencies[FsLatencyGroup])
+	}
+	// The clock did not move during the collection.
+	if latencies[CgroupLatencyGroup] != 0 {
+		t.Errorf("expected %q latency of 0, got %v", CgroupLatencyGroup, latencies[CgroupLatencyGroup])
+	}
+
+	// Each call to GetStats starts over.
+	if _, err := handler.GetStats(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := handler.CollectionLatencies()[FsLatencyGroup]; ok {
+		t.Errorf("expected latency for group %q to be reset, got %v", FsLatencyGroup, handler.CollectionLatencies())
+	}
+}
+
+func TestGetStatsPerInterfaceNetwork(t *testing.T) {
+	rootFs, err := ioutil.TempDir("", "netdev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootFs)
+	const pid = 42
+	procNetDev, err := ioutil.ReadFile("testdata/procnetdev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := path.Join(rootFs, "proc", strconv.Itoa(pid), "net")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "dev"), procNetDev, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wlp4s0 := info.InterfaceStats{
+		Name:      "wlp4s0",
+		RxBytes:   1,
+		RxPackets: 2,
+		RxErrors:  3,
+		RxDropped: 4,
+		TxBytes:   9,
+		TxPackets: 10,
+		TxErrors:  11,
+		TxDropped: 12,
+	}
+	em1 := info.InterfaceStats{
+		Name:      "em1",
+		RxBytes:   315849,
+		RxPackets: 1172,
+		TxBytes:   315850,
+		TxPackets: 1173,
+	}
+	summed := info.InterfaceStats{
+		RxBytes:   315850,
+		RxPackets: 1174,
+		RxErrors:  3,
+		RxDropped: 4,
+		TxBytes:   315859,
+		TxPackets: 1183,
+		TxErrors:  11,
+		TxDropped: 12,
+	}
+
+	for _, tc := range []struct {
+		perInterface bool
+		interfaces   []info.InterfaceStats
+		aggregate    info.InterfaceStats
+	}{
+		{
+			perInterface: false,
+			interfaces:   []info.InterfaceStats{summed},
+			aggregate:    summed,
+		},
+		{
+			perInterface: true,
+			interfaces:   []info.InterfaceStats{wlp4s0, em1},
+			aggregate:    wlp4s0,
+		},
+	} {
+		includedMetrics := container.MetricSet{
+			container.NetworkUsageMetrics: struct{}{},
+		}
+		if !tc.perInterface {
+			includedMetrics.Add(container.SummedNetworkMetrics)
+		}
+		handler := NewHandler(&fakeCgroupManager{}, rootFs, pid, includedMetrics)
+		stats, err := handler.GetStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(stats.Network.Interfaces, tc.interfaces) {
+			t.Errorf("perInterface=%v: expected interfaces %+v, got %+v", tc.perInterface, tc.interfaces, stats.Network.Interfaces)
+		}
+		if stats.Network.InterfaceStats != tc.aggregate {
+			t.Errorf("perInterface=%v: expected aggregate %+v, got %+v", tc.perInterface, tc.aggregate, stats.Network.InterfaceStats)
+		}
+	}
+}
+
+func writeSchedstat(t *testing.T, rootFs string, pid int, contents string) {
+	dir := path.Join(rootFs, "proc", strconv.Itoa(pid))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "schedstat"), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchedulerStatsFromProcs(t *testing.T) {
+	rootFs, err := ioutil.TempDir("", "schedstat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootFs)
+	writeSchedstat(t, rootFs, 1, "1000 200 3\n")
+	writeSchedstat(t, rootFs, 2, "2000 300 4\n")
+
+	cache := make(map[int]*info.CpuSchedstat)
+	stats, err := schedulerStatsFromProcs(rootFs, []int{1, 2}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := info.CpuSchedstat{RunTime: 3000, RunqueueTime: 500, RunPeriods: 7}
+	if stats != expected {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+
+	// Statistics of exited processes are kept so the sums stay cumulative.
+	os.RemoveAll(path.Join(rootFs, "proc", "2"))
+	writeSchedstat(t, rootFs, 1, "1500 250 5\n")
+	stats, err = schedulerStatsFromProcs(rootFs, []int{1, 2}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = info.CpuSchedstat{RunTime: 3500, RunqueueTime: 550, RunPeriods: 9}
+	if stats != expected {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+}
+
+func TestSchedulerStatsFromProcsMalformed(t *testing.T) {
+	rootFs, err := ioutil.TempDir("", "schedstat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootFs)
+	writeSchedstat(t, rootFs, 1, "1000 200\n")
+
+	if _, err := schedulerStatsFromProcs(rootFs, []int{1}, make(map[int]*info.CpuSchedstat)); err == nil {
+		t.Error("expected an error for a malformed schedstat file")
+	}
+}
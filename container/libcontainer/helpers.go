@@ -104,14 +104,24 @@ func getCgroupSubsystemsHelper(allCgroups []cgroups.Mount, disableCgroups map[st
 
 // Cgroup subsystems we support listing (should be the minimal set we need stats from).
 var supportedSubsystems map[string]struct{} = map[string]struct{}{
-	"cpu":     {},
-	"cpuacct": {},
-	"memory":  {},
-	"pids":    {},
-	"cpuset":  {},
-	"blkio":   {},
-	"io":      {},
-	"devices": {},
+	"cpu":      {},
+	"cpuacct":  {},
+	"memory":   {},
+	"pids":     {},
+	"cpuset":   {},
+	"blkio":    {},
+	"io":       {},
+	"devices":  {},
+	"hugetlb":  {},
+	"net_cls":  {},
+	"net_prio": {},
+}
+
+// IsSupportedSubsystem returns whether the cgroup subsystem is one we collect
+// stats from.
+func IsSupportedSubsystem(subsystem string) bool {
+	_, ok := supportedSubsystems[subsystem]
+	return ok
 }
 
 func DiskStatsCopy0(major, minor uint64) *info.PerDiskStats {
@@ -42,7 +42,7 @@ func cgroupMountsAt(path string, subsystems []string) []cgroups.Mount {
 }
 
 func TestGetCgroupSubsystems(t *testing.T) {
-	ourSubsystems := []string{"cpu,cpuacct", "devices", "memory", "cpuset", "blkio", "pids"}
+	ourSubsystems := []string{"cpu,cpuacct", "devices", "memory", "cpuset", "blkio", "hugetlb", "pids", "net_cls,net_prio"}
 
 	testCases := []struct {
 		mounts   []cgroups.Mount
@@ -58,13 +58,16 @@ func TestGetCgroupSubsystems(t *testing.T) {
 			mounts: cgroupMountsAt("/sys/fs/cgroup", defaultCgroupSubsystems),
 			expected: CgroupSubsystems{
 				MountPoints: map[string]string{
-					"blkio":   "/sys/fs/cgroup/blkio",
-					"cpu":     "/sys/fs/cgroup/cpu,cpuacct",
-					"cpuacct": "/sys/fs/cgroup/cpu,cpuacct",
-					"cpuset":  "/sys/fs/cgroup/cpuset",
-					"devices": "/sys/fs/cgroup/devices",
-					"memory":  "/sys/fs/cgroup/memory",
-					"pids":    "/sys/fs/cgroup/pids",
+					"blkio":    "/sys/fs/cgroup/blkio",
+					"cpu":      "/sys/fs/cgroup/cpu,cpuacct",
+					"cpuacct":  "/sys/fs/cgroup/cpu,cpuacct",
+					"cpuset":   "/sys/fs/cgroup/cpuset",
+					"devices":  "/sys/fs/cgroup/devices",
+					"hugetlb":  "/sys/fs/cgroup/hugetlb",
+					"memory":   "/sys/fs/cgroup/memory",
+					"net_cls":  "/sys/fs/cgroup/net_cls,net_prio",
+					"net_prio": "/sys/fs/cgroup/net_cls,net_prio",
+					"pids":     "/sys/fs/cgroup/pids",
 				},
 				Mounts: cgroupMountsAt("/sys/fs/cgroup", ourSubsystems),
 			},
@@ -75,13 +78,16 @@ func TestGetCgroupSubsystems(t *testing.T) {
 				cgroupMountsAt("/var/lib/rkt/pods/run/ccdd4e36-2d4c-49fd-8b94-4fb06133913d/stage1/rootfs/opt/stage2/flannel/rootfs/sys/fs/cgroup", defaultCgroupSubsystems)...),
 			expected: CgroupSubsystems{
 				MountPoints: map[string]string{
-					"blkio":   "/sys/fs/cgroup/blkio",
-					"cpu":     "/sys/fs/cgroup/cpu,cpuacct",
-					"cpuacct": "/sys/fs/cgroup/cpu,cpuacct",
-					"cpuset":  "/sys/fs/cgroup/cpuset",
-					"devices": "/sys/fs/cgroup/devices",
-					"memory":  "/sys/fs/cgroup/memory",
-					"pids":    "/sys/fs/cgroup/pids",
+					"blkio":    "/sys/fs/cgroup/blkio",
+					"cpu":      "/sys/fs/cgroup/cpu,cpuacct",
+					"cpuacct":  "/sys/fs/cgroup/cpu,cpuacct",
+					"cpuset":   "/sys/fs/cgroup/cpuset",
+					"devices":  "/sys/fs/cgroup/devices",
+					"hugetlb":  "/sys/fs/cgroup/hugetlb",
+					"memory":   "/sys/fs/cgroup/memory",
+					"net_cls":  "/sys/fs/cgroup/net_cls,net_prio",
+					"net_prio": "/sys/fs/cgroup/net_cls,net_prio",
+					"pids":     "/sys/fs/cgroup/pids",
 				},
 				Mounts: cgroupMountsAt("/sys/fs/cgroup", ourSubsystems),
 			},
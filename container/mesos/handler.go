@@ -183,6 +183,10 @@ func (self *mesosContainerHandler) GetCgroupPath(resource string) (string, error
 	return path, nil
 }
 
+func (self *mesosContainerHandler) GetRelativeCgroupPath(resource string) (string, error) {
+	return common.GetRelativeCgroupPath(self.cgroupPaths, self.name, resource)
+}
+
 func (self *mesosContainerHandler) GetContainerLabels() map[string]string {
 	return self.labels
 }
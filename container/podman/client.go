@@ -0,0 +1,63 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podman
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sync"
+
+	dockertypes "github.com/docker/docker/api/types"
+	dclient "github.com/docker/docker/client"
+)
+
+var ArgPodmanEndpoint = flag.String("podman", "unix:///run/podman/podman.sock", "podman endpoint")
+
+// Endpoint of the API service of a rootless podman user, formatted with the
+// uid of the user.
+const rootlessEndpointFormat = "unix:///run/user/%s/podman/podman.sock"
+
+// podmanClient is the subset of the Docker compatible podman API used by
+// the podman container handler.
+type podmanClient interface {
+	ContainerInspect(ctx context.Context, id string) (dockertypes.ContainerJSON, error)
+}
+
+var (
+	podmanClientsLock sync.Mutex
+	podmanClients     = map[string]podmanClient{}
+)
+
+// Client returns a client for the podman API service that manages the
+// containers of the given user. An empty uid selects the rootful service.
+func Client(uid string) (podmanClient, error) {
+	podmanClientsLock.Lock()
+	defer podmanClientsLock.Unlock()
+	if client, ok := podmanClients[uid]; ok {
+		return client, nil
+	}
+
+	endpoint := *ArgPodmanEndpoint
+	if uid != "" {
+		endpoint = fmt.Sprintf(rootlessEndpointFormat, uid)
+	}
+	client, err := dclient.NewClient(endpoint, "", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	podmanClients[uid] = client
+	return client, nil
+}
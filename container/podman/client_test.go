@@ -0,0 +1,45 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podman
+
+import (
+	"context"
+	"fmt"
+
+	dockertypes "github.com/docker/docker/api/types"
+)
+
+type podmanClientMock struct {
+	containers map[string]dockertypes.ContainerJSON
+	err        error
+}
+
+func (c *podmanClientMock) ContainerInspect(ctx context.Context, id string) (dockertypes.ContainerJSON, error) {
+	if c.err != nil {
+		return dockertypes.ContainerJSON{}, c.err
+	}
+	ctnr, ok := c.containers[id]
+	if !ok {
+		return dockertypes.ContainerJSON{}, fmt.Errorf("no container with id %s", id)
+	}
+	return ctnr, nil
+}
+
+func mockPodmanClient(containers map[string]dockertypes.ContainerJSON, err error) podmanClient {
+	return &podmanClientMock{
+		containers: containers,
+		err:        err,
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podman
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"path"
+	"regexp"
+
+	"github.com/matthewygf/cadvisor/container"
+	"github.com/matthewygf/cadvisor/container/libcontainer"
+	"github.com/matthewygf/cadvisor/fs"
+	info "github.com/matthewygf/cadvisor/info/v1"
+	"github.com/matthewygf/cadvisor/watcher"
+
+	"k8s.io/klog"
+)
+
+// The namespace under which podman aliases are unique.
+const PodmanNamespace = "podman"
+
+// Storage root of rootful podman. Rootless podman keeps its storage under
+// rootlessStorageDir in the home directory of the user.
+const (
+	rootfulStorageDir  = "/var/lib/containers/storage"
+	rootlessStorageDir = ".local/share/containers/storage"
+)
+
+// Regexp that identifies libpod container cgroups, both the systemd
+// ("libpod-<id>.scope") and the cgroupfs ("libpod-<id>") flavours. The
+// cgroups of the conmon monitor processes ("libpod-conmon-<id>.scope") do
+// not match.
+var podmanCgroupRegexp = regexp.MustCompile(`^libpod-([a-f0-9]{64})(?:\.scope)?$`)
+
+// Regexp that identifies the systemd user manager a rootless container runs
+// under, e.g. "/user.slice/user-1000.slice/user@1000.service/...".
+var rootlessCgroupRegexp = regexp.MustCompile(`/user@(\d+)\.service(?:/|$)`)
+
+type podmanFactory struct {
+	machineInfoFactory info.MachineInfoFactory
+
+	// Information about the mounted cgroup subsystems.
+	cgroupSubsystems libcontainer.CgroupSubsystems
+
+	// Information about mounted filesystems.
+	fsInfo fs.FsInfo
+
+	includedMetrics container.MetricSet
+
+	// Returns the client of the podman service owning the containers of the
+	// given user, the rootful service for an empty uid.
+	client func(uid string) (podmanClient, error)
+
+	// Returns the home directory of the given user.
+	homeDir func(uid string) (string, error)
+}
+
+func (self *podmanFactory) String() string {
+	return PodmanNamespace
+}
+
+func (self *podmanFactory) NewContainerHandler(name string, inHostNamespace bool) (handler container.ContainerHandler, err error) {
+	uid := rootlessUid(name)
+	client, err := self.client(uid)
+	if err != nil {
+		return
+	}
+	storageDir, err := self.storageDir(uid)
+	if err != nil {
+		return
+	}
+	handler, err = newPodmanContainerHandler(
+		client,
+		name,
+		self.machineInfoFactory,
+		self.fsInfo,
+		storageDir,
+		&self.cgroupSubsystems,
+		inHostNamespace,
+		self.includedMetrics,
+	)
+	return
+}
+
+// Returns the podman ID from the full container name.
+func ContainerNameToPodmanId(name string) string {
+	id := path.Base(name)
+
+	if matches := podmanCgroupRegexp.FindStringSubmatch(id); matches != nil {
+		return matches[1]
+	}
+
+	return id
+}
+
+// isContainerName returns true if the cgroup with associated name
+// corresponds to a podman container.
+func isContainerName(name string) bool {
+	return podmanCgroupRegexp.MatchString(path.Base(name))
+}
+
+// rootlessUid returns the uid of the user running the container with the
+// given cgroup name, or an empty string for a rootful container.
+func rootlessUid(name string) string {
+	if matches := rootlessCgroupRegexp.FindStringSubmatch(name); matches != nil {
+		return matches[1]
+	}
+	return ""
+}
+
+// storageDir returns the storage root of the podman containers of the given
+// user.
+func (self *podmanFactory) storageDir(uid string) (string, error) {
+	if uid == "" {
+		return rootfulStorageDir, nil
+	}
+	home, err := self.homeDir(uid)
+	if err != nil {
+		return "", fmt.Errorf("unable to determine the home directory of user %s: %v", uid, err)
+	}
+	return path.Join(home, rootlessStorageDir), nil
+}
+
+// podman handles all libpod containers, rootful ones under machine.slice or
+// libpod_parent and rootless ones under the slice of their user.
+func (self *podmanFactory) CanHandleAndAccept(name string) (bool, bool, error) {
+	if !isContainerName(name) {
+		return false, false, nil
+	}
+	// Leave containers unknown to the podman service, e.g. because the
+	// service of a rootless user is not running, to the raw handler.
+	client, err := self.client(rootlessUid(name))
+	if err != nil {
+		klog.V(4).Infof("no podman client for container %q: %v", name, err)
+		return false, false, nil
+	}
+	ctnr, err := client.ContainerInspect(context.Background(), ContainerNameToPodmanId(name))
+	if err != nil {
+		klog.V(4).Infof("podman service does not know container %q: %v", name, err)
+		return false, false, nil
+	}
+	if ctnr.State == nil || !ctnr.State.Running {
+		return false, true, fmt.Errorf("podman container %q is not running", name)
+	}
+	return true, true, nil
+}
+
+func (self *podmanFactory) DebugInfo() map[string][]string {
+	return map[string][]string{}
+}
+
+func lookupHomeDir(uid string) (string, error) {
+	u, err := user.LookupId(uid)
+	if err != nil {
+		return "", err
+	}
+	return u.HomeDir, nil
+}
+
+// Register root container before running this function!
+func Register(factory info.MachineInfoFactory, fsInfo fs.FsInfo, includedMetrics container.MetricSet) error {
+	cgroupSubsystems, err := libcontainer.GetCgroupSubsystems(includedMetrics)
+	if err != nil {
+		return fmt.Errorf("failed to get cgroup subsystems: %v", err)
+	}
+
+	// Podman has no long running daemon and its API service is usually
+	// socket activated, so the factory does not connect to it up front.
+	klog.V(1).Infof("Registering Podman factory")
+	f := &podmanFactory{
+		cgroupSubsystems:   cgroupSubsystems,
+		fsInfo:             fsInfo,
+		machineInfoFactory: factory,
+		includedMetrics:    includedMetrics,
+		client:             Client,
+		homeDir:            lookupHomeDir,
+	}
+
+	container.RegisterContainerHandlerFactory(f, []watcher.ContainerWatchSource{watcher.Raw})
+	return nil
+}
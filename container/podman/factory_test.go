@@ -0,0 +1,119 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podman
+
+import (
+	"fmt"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const testId = "81e5c2990803c383229c9680ce964738d5e566d97f5bd436ac34808d2ec75d5f"
+
+func newTestContainerJSON(running bool) dockertypes.ContainerJSON {
+	return dockertypes.ContainerJSON{
+		ContainerJSONBase: &dockertypes.ContainerJSONBase{
+			ID:    testId,
+			State: &dockertypes.ContainerState{Running: running},
+		},
+	}
+}
+
+func TestCanHandleAndAccept(t *testing.T) {
+	as := assert.New(t)
+	clients := map[string]podmanClient{
+		"":     mockPodmanClient(map[string]dockertypes.ContainerJSON{testId: newTestContainerJSON(true)}, nil),
+		"1000": mockPodmanClient(map[string]dockertypes.ContainerJSON{testId: newTestContainerJSON(true)}, nil),
+		"1001": mockPodmanClient(nil, nil),
+	}
+	f := &podmanFactory{
+		client: func(uid string) (podmanClient, error) {
+			client, ok := clients[uid]
+			if !ok {
+				return nil, fmt.Errorf("no podman service for user %q", uid)
+			}
+			return client, nil
+		},
+	}
+	for k, v := range map[string]bool{
+		"/machine.slice/libpod-" + testId + ".scope":                                                  true,
+		"/libpod_parent/libpod-" + testId:                                                             true,
+		"/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-" + testId + ".scope":        true,
+		"/machine.slice/libpod-conmon-" + testId + ".scope":                                           false,
+		"/machine.slice/libpod-" + testId + ".scope/container":                                        false,
+		"/machine.slice/libpod-990803c383229c9680ce964738d5e566d97f5bd436ac34808d2ec75.scope":         false,
+		"/system.slice/docker-" + testId + ".scope":                                                   false,
+		"/user.slice/user-1001.slice/user@1001.service/user.slice/libpod-" + testId + ".scope":        false,
+		"/user.slice/user-1002.slice/user@1002.service/user.slice/libpod-" + testId + ".scope":        false,
+		"/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-conmon-" + testId + ".scope": false,
+	} {
+		b1, b2, err := f.CanHandleAndAccept(k)
+		as.Nil(err, k)
+		as.Equal(b1, v, k)
+		as.Equal(b2, v, k)
+	}
+}
+
+func TestCanHandleAndAcceptStopped(t *testing.T) {
+	as := assert.New(t)
+	f := &podmanFactory{
+		client: func(uid string) (podmanClient, error) {
+			return mockPodmanClient(map[string]dockertypes.ContainerJSON{testId: newTestContainerJSON(false)}, nil), nil
+		},
+	}
+	canHandle, canAccept, err := f.CanHandleAndAccept("/machine.slice/libpod-" + testId + ".scope")
+	as.NotNil(err)
+	as.False(canHandle)
+	as.True(canAccept)
+}
+
+func TestRootlessUid(t *testing.T) {
+	for name, uid := range map[string]string{
+		"/machine.slice/libpod-" + testId + ".scope":                                           "",
+		"/libpod_parent/libpod-" + testId:                                                      "",
+		"/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-" + testId + ".scope": "1000",
+		"/user.slice/user-0.slice/user@0.service/libpod-" + testId + ".scope":                  "0",
+		"/user.slice/user-1000.slice/user@1000.services/libpod-" + testId + ".scope":           "",
+	} {
+		if actual := rootlessUid(name); actual != uid {
+			t.Errorf("rootlessUid(%q) = %q, expected %q", name, actual, uid)
+		}
+	}
+}
+
+func TestStorageDir(t *testing.T) {
+	as := assert.New(t)
+	f := &podmanFactory{
+		homeDir: func(uid string) (string, error) {
+			if uid == "1000" {
+				return "/home/user", nil
+			}
+			return "", fmt.Errorf("unknown user %s", uid)
+		},
+	}
+
+	dir, err := f.storageDir("")
+	as.Nil(err)
+	as.Equal("/var/lib/containers/storage", dir)
+
+	dir, err = f.storageDir("1000")
+	as.Nil(err)
+	as.Equal("/home/user/.local/share/containers/storage", dir)
+
+	_, err = f.storageDir("1001")
+	as.NotNil(err)
+}
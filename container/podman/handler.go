@@ -0,0 +1,300 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Handler for Podman containers.
+package podman
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/matthewygf/cadvisor/container"
+	"github.com/matthewygf/cadvisor/container/common"
+	containerlibcontainer "github.com/matthewygf/cadvisor/container/libcontainer"
+	"github.com/matthewygf/cadvisor/fs"
+	info "github.com/matthewygf/cadvisor/info/v1"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+	cgroupfs "github.com/opencontainers/runc/libcontainer/cgroups/fs"
+	libcontainerconfigs "github.com/opencontainers/runc/libcontainer/configs"
+)
+
+const overlayStorageDriver = "overlay"
+
+type podmanContainerHandler struct {
+	machineInfoFactory info.MachineInfoFactory
+
+	// Absolute path to the cgroup hierarchies of this container.
+	// (e.g.: "cpu" -> "/sys/fs/cgroup/cpu/test")
+	cgroupPaths map[string]string
+
+	// The podman storage driver and the writable layer of the container.
+	storageDriver    string
+	fsInfo           fs.FsInfo
+	rootfsStorageDir string
+
+	// Time at which this container was created.
+	creationTime time.Time
+
+	// Metadata associated with the container.
+	envs   map[string]string
+	labels map[string]string
+
+	// Image name used for this container.
+	image string
+
+	// The network mode of the container
+	networkMode dockercontainer.NetworkMode
+
+	// Filesystem handler.
+	fsHandler common.FsHandler
+
+	// The IP address of the container
+	ipAddress string
+
+	includedMetrics container.MetricSet
+
+	reference info.ContainerReference
+
+	libcontainerHandler *containerlibcontainer.Handler
+}
+
+var _ container.ContainerHandler = &podmanContainerHandler{}
+
+// newPodmanContainerHandler returns a new container.ContainerHandler
+func newPodmanContainerHandler(
+	client podmanClient,
+	name string,
+	machineInfoFactory info.MachineInfoFactory,
+	fsInfo fs.FsInfo,
+	storageDir string,
+	cgroupSubsystems *containerlibcontainer.CgroupSubsystems,
+	inHostNamespace bool,
+	includedMetrics container.MetricSet,
+) (container.ContainerHandler, error) {
+	// Create the cgroup paths.
+	cgroupPaths := common.MakeCgroupPaths(cgroupSubsystems.MountPoints, name)
+
+	// Generate the equivalent cgroup manager for this container.
+	cgroupManager := &cgroupfs.Manager{
+		Cgroups: &libcontainerconfigs.Cgroup{
+			Name: name,
+		},
+		Paths: cgroupPaths,
+	}
+
+	id := ContainerNameToPodmanId(name)
+	ctnr, err := client.ContainerInspect(context.Background(), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container %q: %v", id, err)
+	}
+
+	rootFs := "/"
+	if !inHostNamespace {
+		rootFs = "/rootfs"
+		storageDir = path.Join(rootFs, storageDir)
+	}
+
+	// The writable layer of the container is reported by podman as an
+	// absolute path, which for rootless podman is below the home directory of
+	// the user. The container's userdata dir holds its log file and config.
+	rootfsStorageDir := ctnr.GraphDriver.Data["UpperDir"]
+	if rootfsStorageDir != "" {
+		rootfsStorageDir = path.Join(rootFs, rootfsStorageDir)
+	}
+	otherStorageDir := path.Join(storageDir, ctnr.GraphDriver.Name+"-containers", id, "userdata")
+
+	handler := &podmanContainerHandler{
+		machineInfoFactory: machineInfoFactory,
+		cgroupPaths:        cgroupPaths,
+		storageDriver:      ctnr.GraphDriver.Name,
+		fsInfo:             fsInfo,
+		rootfsStorageDir:   rootfsStorageDir,
+		envs:               make(map[string]string),
+		labels:             ctnr.Config.Labels,
+		image:              ctnr.Config.Image,
+		networkMode:        ctnr.HostConfig.NetworkMode,
+		includedMetrics:    includedMetrics,
+	}
+	if handler.labels == nil {
+		handler.labels = make(map[string]string)
+	}
+	// Timestamp returned by podman is in time.RFC3339Nano format.
+	handler.creationTime, err = time.Parse(time.RFC3339Nano, ctnr.Created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the create timestamp %q for container %q: %v", ctnr.Created, id, err)
+	}
+	handler.libcontainerHandler = containerlibcontainer.NewHandler(cgroupManager, rootFs, ctnr.State.Pid, includedMetrics)
+
+	// Add the name and bare ID as aliases of the container.
+	handler.reference = info.ContainerReference{
+		Id:        id,
+		Name:      name,
+		Aliases:   []string{strings.TrimPrefix(ctnr.Name, "/"), id},
+		Namespace: PodmanNamespace,
+	}
+	if ctnr.NetworkSettings != nil {
+		handler.ipAddress = ctnr.NetworkSettings.IPAddress
+	}
+
+	// we optionally collect disk usage metrics
+	if includedMetrics.Has(container.DiskUsageMetrics) && handler.storageDriver == overlayStorageDriver && rootfsStorageDir != "" {
+		handler.fsHandler = common.NewFsHandler(common.DefaultPeriod, rootfsStorageDir, otherStorageDir, fsInfo)
+	}
+
+	return handler, nil
+}
+
+func (self *podmanContainerHandler) Start() {
+	if self.fsHandler != nil {
+		self.fsHandler.Start()
+	}
+}
+
+func (self *podmanContainerHandler) Cleanup() {
+	if self.fsHandler != nil {
+		self.fsHandler.Stop()
+	}
+}
+
+func (self *podmanContainerHandler) ContainerReference() (info.ContainerReference, error) {
+	return self.reference, nil
+}
+
+// needNet returns whether the container has a network of its own. Members of
+// a podman pod join the network namespace of the pod's infra container,
+// which reports the network stats of the pod.
+func (self *podmanContainerHandler) needNet() bool {
+	if self.includedMetrics.Has(container.NetworkUsageMetrics) {
+		return !self.networkMode.IsContainer()
+	}
+	return false
+}
+
+func (self *podmanContainerHandler) GetSpec() (info.ContainerSpec, error) {
+	hasFilesystem := self.fsHandler != nil
+	spec, err := common.GetSpec(self.cgroupPaths, self.machineInfoFactory, self.needNet(), hasFilesystem)
+
+	spec.CreationTime = self.creationTime
+	spec.Labels = self.labels
+	spec.Envs = self.envs
+	spec.Image = self.image
+
+	return spec, err
+}
+
+func (self *podmanContainerHandler) getFsStats(stats *info.ContainerStats) error {
+	mi, err := self.machineInfoFactory.GetMachineInfo()
+	if err != nil {
+		return err
+	}
+
+	if self.includedMetrics.Has(container.DiskIOMetrics) {
+		common.AssignDeviceNamesToDiskStats((*common.MachineInfoNamer)(mi), &stats.DiskIo)
+	}
+
+	if self.fsHandler == nil {
+		return nil
+	}
+	deviceInfo, err := self.fsInfo.GetDirFsDevice(self.rootfsStorageDir)
+	if err != nil {
+		return fmt.Errorf("unable to determine device info for dir: %v: %v", self.rootfsStorageDir, err)
+	}
+	device := deviceInfo.Device
+
+	var (
+		limit  uint64
+		fsType string
+	)
+
+	// podman does not impose any filesystem limits for containers. So use capacity as limit.
+	for _, fs := range mi.Filesystems {
+		if fs.Device == device {
+			limit = fs.Capacity
+			fsType = fs.Type
+			break
+		}
+	}
+
+	fsStat := info.FsStats{Device: device, Type: fsType, Limit: limit}
+	usage := self.fsHandler.Usage()
+	fsStat.BaseUsage = usage.BaseUsageBytes
+	fsStat.Usage = usage.TotalUsageBytes
+	fsStat.Inodes = usage.InodeUsage
+
+	stats.Filesystem = append(stats.Filesystem, fsStat)
+
+	return nil
+}
+
+func (self *podmanContainerHandler) GetStats() (*info.ContainerStats, error) {
+	stats, err := self.libcontainerHandler.GetStats()
+	if err != nil {
+		return stats, err
+	}
+	// Clean up stats for containers that don't have their own network. This
+	// stops the network stats of a pod being reported for each of its
+	// containers.
+	if !self.needNet() {
+		stats.Network = info.NetworkStats{}
+	}
+
+	// Get filesystem stats.
+	err = self.getFsStats(stats)
+	if err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+func (self *podmanContainerHandler) ListContainers(listType container.ListType) ([]info.ContainerReference, error) {
+	// No-op for podman driver.
+	return []info.ContainerReference{}, nil
+}
+
+func (self *podmanContainerHandler) GetCgroupPath(resource string) (string, error) {
+	path, ok := self.cgroupPaths[resource]
+	if !ok {
+		return "", fmt.Errorf("could not find path for resource %q for container %q\n", resource, self.reference.Name)
+	}
+	return path, nil
+}
+
+func (self *podmanContainerHandler) GetRelativeCgroupPath(resource string) (string, error) {
+	return common.GetRelativeCgroupPath(self.cgroupPaths, self.reference.Name, resource)
+}
+
+func (self *podmanContainerHandler) GetContainerLabels() map[string]string {
+	return self.labels
+}
+
+func (self *podmanContainerHandler) GetContainerIPAddress() string {
+	return self.ipAddress
+}
+
+func (self *podmanContainerHandler) ListProcesses(listType container.ListType) ([]int, error) {
+	return self.libcontainerHandler.GetProcesses()
+}
+
+func (self *podmanContainerHandler) Exists() bool {
+	return common.CgroupExists(self.cgroupPaths)
+}
+
+func (self *podmanContainerHandler) Type() container.ContainerType {
+	return container.ContainerTypePodman
+}
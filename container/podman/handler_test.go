@@ -0,0 +1,97 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podman
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/matthewygf/cadvisor/container"
+	containerlibcontainer "github.com/matthewygf/cadvisor/container/libcontainer"
+	info "github.com/matthewygf/cadvisor/info/v1"
+
+	dockertypes "github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestInspect(networkMode string) dockertypes.ContainerJSON {
+	ctnr := newTestContainerJSON(true)
+	ctnr.Name = "/test"
+	ctnr.Created = "2019-10-01T12:00:00.123456789Z"
+	ctnr.State.Pid = 1234
+	ctnr.HostConfig = &dockercontainer.HostConfig{NetworkMode: dockercontainer.NetworkMode(networkMode)}
+	ctnr.GraphDriver = dockertypes.GraphDriverData{
+		Name: "overlay",
+		Data: map[string]string{"UpperDir": "/home/user/.local/share/containers/storage/overlay/abc/diff"},
+	}
+	ctnr.Config = &dockercontainer.Config{
+		Image:  "docker.io/library/busybox:latest",
+		Labels: map[string]string{"foo": "bar"},
+	}
+	ctnr.NetworkSettings = &dockertypes.NetworkSettings{
+		DefaultNetworkSettings: dockertypes.DefaultNetworkSettings{IPAddress: "10.88.0.2"},
+	}
+	return ctnr
+}
+
+func TestNewPodmanContainerHandler(t *testing.T) {
+	as := assert.New(t)
+	name := "/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-" + testId + ".scope"
+	storageDir := "/home/user/.local/share/containers/storage"
+	includedMetrics := container.MetricSet{container.DiskUsageMetrics: struct{}{}, container.NetworkUsageMetrics: struct{}{}}
+
+	_, err := newPodmanContainerHandler(mockPodmanClient(nil, fmt.Errorf("no client returned")), name, nil, nil, storageDir, &containerlibcontainer.CgroupSubsystems{}, true, includedMetrics)
+	as.NotNil(err)
+	as.Contains(err.Error(), "no client returned")
+
+	client := mockPodmanClient(map[string]dockertypes.ContainerJSON{testId: newTestInspect("bridge")}, nil)
+	handler, err := newPodmanContainerHandler(client, name, nil, nil, storageDir, &containerlibcontainer.CgroupSubsystems{}, false, includedMetrics)
+	as.Nil(err)
+
+	reference, err := handler.ContainerReference()
+	as.Nil(err)
+	as.Equal(info.ContainerReference{
+		Id:        testId,
+		Name:      name,
+		Aliases:   []string{"test", testId},
+		Namespace: PodmanNamespace,
+	}, reference)
+	as.Equal(map[string]string{"foo": "bar"}, handler.GetContainerLabels())
+	as.Equal("10.88.0.2", handler.GetContainerIPAddress())
+	as.Equal(container.ContainerTypePodman, handler.Type())
+
+	podmanHandler := handler.(*podmanContainerHandler)
+	as.Equal("/rootfs/home/user/.local/share/containers/storage/overlay/abc/diff", podmanHandler.rootfsStorageDir)
+	as.NotNil(podmanHandler.fsHandler)
+	as.Equal(time.Date(2019, 10, 1, 12, 0, 0, 123456789, time.UTC), podmanHandler.creationTime)
+	as.True(podmanHandler.needNet())
+}
+
+func TestPodmanPodMemberNetwork(t *testing.T) {
+	as := assert.New(t)
+	name := "/machine.slice/libpod-" + testId + ".scope"
+	includedMetrics := container.MetricSet{container.NetworkUsageMetrics: struct{}{}}
+
+	client := mockPodmanClient(map[string]dockertypes.ContainerJSON{testId: newTestInspect("container:0123456789")}, nil)
+	handler, err := newPodmanContainerHandler(client, name, nil, nil, rootfulStorageDir, &containerlibcontainer.CgroupSubsystems{}, true, includedMetrics)
+	as.Nil(err)
+
+	podmanHandler := handler.(*podmanContainerHandler)
+	as.False(podmanHandler.needNet())
+	// Disk usage is not collected unless enabled.
+	as.Nil(podmanHandler.fsHandler)
+}
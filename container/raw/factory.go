@@ -17,6 +17,9 @@ package raw
 import (
 	"flag"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/matthewygf/cadvisor/container"
@@ -30,7 +33,109 @@ import (
 )
 
 var dockerOnly = flag.Bool("docker_only", false, "Only report docker containers in addition to root stats")
-var disableRootCgroupStats = flag.Bool("disable_root_cgroup_stats", false, "Disable collecting root Cgroup stats")
+var dockerOnlyAcceptKubepods = flag.Bool("docker_only_accept_kubepods", true, "With --docker_only, still report the cgroups of kubernetes pods, under /kubepods or /kubepods.slice, without whitelisting them")
+var disableRootCgroupStats = &rootCgroupStatsValue{}
+var hostCpuTime = flag.Bool("host_cpu_time", false, "Report the time the host's CPUs spent in each mode, including iowait and steal, with the root Cgroup stats")
+var rawCgroupSubsystems = flag.String("raw_cgroup_subsystems", "", "A comma-separated list of cgroup `subsystems` (e.g. --raw_cgroup_subsystems=cpu,memory) whose hierarchies the raw factory watches and collects from. Subsystems co-mounted with them are kept too. Empty means all of them")
+
+func init() {
+	flag.Var(disableRootCgroupStats, "disable_root_cgroup_stats", "Disable collecting root Cgroup stats. Set to true to disable all of them, or to a comma-separated list of cgroup `subsystems`, which must be given with = (e.g. --disable_root_cgroup_stats=blkio,hugetlb), to only skip those")
+}
+
+// rootCgroupStatsValue is the value of --disable_root_cgroup_stats: either a
+// boolean disabling all root cgroup stats, or a list of subsystems to skip.
+type rootCgroupStatsValue struct {
+	all        bool
+	subsystems map[string]struct{}
+}
+
+func (self *rootCgroupStatsValue) String() string {
+	if self.all {
+		return "true"
+	}
+	if len(self.subsystems) == 0 {
+		return "false"
+	}
+	subsystems := make([]string, 0, len(self.subsystems))
+	for subsystem := range self.subsystems {
+		subsystems = append(subsystems, subsystem)
+	}
+	sort.Strings(subsystems)
+	return strings.Join(subsystems, ",")
+}
+
+func (self *rootCgroupStatsValue) Set(value string) error {
+	self.all = false
+	self.subsystems = nil
+	if value == "" {
+		return nil
+	}
+	if all, err := strconv.ParseBool(value); err == nil {
+		self.all = all
+		return nil
+	}
+	self.subsystems = make(map[string]struct{})
+	for _, subsystem := range strings.Split(value, ",") {
+		subsystem = strings.TrimSpace(subsystem)
+		if subsystem == "" {
+			return fmt.Errorf("empty subsystem in %q", value)
+		}
+		if !libcontainer.IsSupportedSubsystem(subsystem) {
+			return fmt.Errorf("unknown cgroup subsystem %q", subsystem)
+		}
+		self.subsystems[subsystem] = struct{}{}
+	}
+	return nil
+}
+
+// IsBoolFlag allows --disable_root_cgroup_stats to be passed without a value,
+// as when it was a boolean flag. A list of subsystems must then be given with
+// "=", as the flag package doesn't consume a separate argument for it.
+func (self *rootCgroupStatsValue) IsBoolFlag() bool {
+	return true
+}
+
+// disabled returns whether stats of the given subsystem are disabled.
+func (self *rootCgroupStatsValue) disabled(subsystem string) bool {
+	if self.all {
+		return true
+	}
+	_, ok := self.subsystems[subsystem]
+	return ok
+}
+
+// restrictCgroupSubsystems keeps only the mounts of subsystems listed in only,
+// a comma-separated list, along with the subsystems co-mounted with them. An
+// empty list keeps all of them. Subsystems that are not mounted are an error.
+func restrictCgroupSubsystems(subsystems libcontainer.CgroupSubsystems, only string) (libcontainer.CgroupSubsystems, error) {
+	if only == "" {
+		return subsystems, nil
+	}
+	mountpoints := make(map[string]struct{})
+	for _, subsystem := range strings.Split(only, ",") {
+		subsystem = strings.TrimSpace(subsystem)
+		mountpoint, ok := subsystems.MountPoints[subsystem]
+		if !ok {
+			return libcontainer.CgroupSubsystems{}, fmt.Errorf("cgroup subsystem %q is unknown or not mounted", subsystem)
+		}
+		mountpoints[mountpoint] = struct{}{}
+	}
+
+	restricted := libcontainer.CgroupSubsystems{
+		MountPoints: make(map[string]string),
+	}
+	for _, mount := range subsystems.Mounts {
+		if _, ok := mountpoints[mount.Mountpoint]; ok {
+			restricted.Mounts = append(restricted.Mounts, mount)
+		}
+	}
+	for subsystem, mountpoint := range subsystems.MountPoints {
+		if _, ok := mountpoints[mountpoint]; ok {
+			restricted.MountPoints[subsystem] = mountpoint
+		}
+	}
+	return restricted, nil
+}
 
 type rawFactory struct {
 	// Factory for machine information.
@@ -50,6 +155,65 @@ type rawFactory struct {
 
 	// List of raw container cgroup path prefix whitelist.
 	rawPrefixWhiteList []string
+
+	// Compiled rawPrefixWhiteList.
+	rawWhiteList *cgroupPathFilter
+
+	// Cgroup paths that are never collected, even if whitelisted.
+	rawBlackList *cgroupPathFilter
+}
+
+// Whitelist entries with this prefix are regular expressions rather than path
+// prefixes.
+const regexpEntryPrefix = "re:"
+
+// cgroupPathFilter matches cgroup paths against prefixes and anchored regular
+// expressions.
+type cgroupPathFilter struct {
+	prefixes []string
+	regexps  []*regexp.Regexp
+	// Entries the regexps were compiled from.
+	regexpEntries []string
+}
+
+// newCgroupPathFilter parses entries, which are either path prefixes or, if
+// prefixed with "re:", regular expressions that must match the whole path.
+func newCgroupPathFilter(entries []string) (*cgroupPathFilter, error) {
+	filter := &cgroupPathFilter{}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry, regexpEntryPrefix) {
+			filter.prefixes = append(filter.prefixes, entry)
+			continue
+		}
+		pattern := strings.TrimPrefix(entry, regexpEntryPrefix)
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid cgroup path regular expression %q: %v", pattern, err)
+		}
+		filter.regexps = append(filter.regexps, re)
+		filter.regexpEntries = append(filter.regexpEntries, entry)
+	}
+	return filter, nil
+}
+
+func (self *cgroupPathFilter) matches(name string) bool {
+	_, ok := self.match(name)
+	return ok
+}
+
+// match returns the first entry matching name.
+func (self *cgroupPathFilter) match(name string) (string, bool) {
+	for _, prefix := range self.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return prefix, true
+		}
+	}
+	for i, re := range self.regexps {
+		if re.MatchString(name) {
+			return self.regexpEntries[i], true
+		}
+	}
+	return "", false
 }
 
 func (self *rawFactory) String() string {
@@ -64,31 +228,96 @@ func (self *rawFactory) NewContainerHandler(name string, inHostNamespace bool) (
 	return newRawContainerHandler(name, self.cgroupSubsystems, self.machineInfoFactory, self.fsInfo, self.watcher, rootFs, self.includedMetrics)
 }
 
-// The raw factory can handle any container. If --docker_only is set to true, non-docker containers are ignored except for "/" and those whitelisted by raw_cgroup_prefix_whitelist flag.
+// Roots of the cgroups of kubernetes pods, with the cgroupfs and systemd
+// cgroup drivers.
+var kubepodsRoots = []string{"/kubepods", "/kubepods.slice"}
+
+// isKubepodsCgroup returns whether the cgroup is a kubepods root or under one.
+func isKubepodsCgroup(name string) bool {
+	for _, root := range kubepodsRoots {
+		if name == root || strings.HasPrefix(name, root+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// The raw factory can handle any container. If --docker_only is set to true, non-docker containers are ignored except for "/", kubernetes pods unless
+// --docker_only_accept_kubepods is false, and those whitelisted by raw_cgroup_prefix_whitelist flag.
+// Containers blacklisted by raw_cgroup_prefix_blacklist flag are ignored, whitelisted or not.
 func (self *rawFactory) CanHandleAndAccept(name string) (bool, bool, error) {
 	if name == "/" {
 		return true, true, nil
 	}
+	if self.rawBlackList.matches(name) {
+		return true, false, nil
+	}
+	if *dockerOnly && *dockerOnlyAcceptKubepods && isKubepodsCgroup(name) {
+		return true, true, nil
+	}
 	if *dockerOnly && self.rawPrefixWhiteList[0] == "" {
 		return true, false, nil
 	}
-	for _, prefix := range self.rawPrefixWhiteList {
-		if strings.HasPrefix(name, prefix) {
-			return true, true, nil
-		}
+	return true, self.rawWhiteList.matches(name), nil
+}
+
+// Explain returns the decision CanHandleAndAccept makes for the container and
+// the flag or list entry behind it.
+func (self *rawFactory) Explain(name string) string {
+	if name == "/" {
+		return "accepted: the root container is always accepted"
+	}
+	if entry, ok := self.rawBlackList.match(name); ok {
+		return fmt.Sprintf("ignored: matches raw cgroup blacklist entry %q", entry)
+	}
+	if *dockerOnly && *dockerOnlyAcceptKubepods && isKubepodsCgroup(name) {
+		return "accepted: --docker_only is set but kubernetes pods are accepted"
+	}
+	if *dockerOnly && self.rawPrefixWhiteList[0] == "" {
+		return "ignored: --docker_only is set and the raw cgroup whitelist is empty"
+	}
+	entry, ok := self.rawWhiteList.match(name)
+	if !ok {
+		return "ignored: matches no raw cgroup whitelist entry"
 	}
-	return true, false, nil
+	if entry == "" {
+		return "accepted: the raw cgroup whitelist is empty"
+	}
+	return fmt.Sprintf("accepted: matches raw cgroup whitelist entry %q", entry)
 }
 
 func (self *rawFactory) DebugInfo() map[string][]string {
 	return common.DebugInfo(self.watcher.GetWatches())
 }
 
-func Register(machineInfoFactory info.MachineInfoFactory, fsInfo fs.FsInfo, includedMetrics map[container.MetricKind]struct{}, rawPrefixWhiteList []string) error {
+// Register registers the raw factory. Entries of rawPrefixWhiteList and
+// rawPrefixBlackList are cgroup path prefixes, or regular expressions matching
+// the whole path if prefixed with "re:".
+func Register(machineInfoFactory info.MachineInfoFactory, fsInfo fs.FsInfo, includedMetrics map[container.MetricKind]struct{}, rawPrefixWhiteList []string, rawPrefixBlackList []string) error {
+	rawWhiteList, err := newCgroupPathFilter(rawPrefixWhiteList)
+	if err != nil {
+		return fmt.Errorf("invalid raw cgroup whitelist: %v", err)
+	}
+	// Unlike in the whitelist, an empty entry would match everything.
+	var blackListEntries []string
+	for _, entry := range rawPrefixBlackList {
+		if entry != "" {
+			blackListEntries = append(blackListEntries, entry)
+		}
+	}
+	rawBlackList, err := newCgroupPathFilter(blackListEntries)
+	if err != nil {
+		return fmt.Errorf("invalid raw cgroup blacklist: %v", err)
+	}
+
 	cgroupSubsystems, err := libcontainer.GetCgroupSubsystems(includedMetrics)
 	if err != nil {
 		return fmt.Errorf("failed to get cgroup subsystems: %v", err)
 	}
+	cgroupSubsystems, err = restrictCgroupSubsystems(cgroupSubsystems, *rawCgroupSubsystems)
+	if err != nil {
+		return fmt.Errorf("invalid raw cgroup subsystems: %v", err)
+	}
 	if len(cgroupSubsystems.Mounts) == 0 {
 		return fmt.Errorf("failed to find supported cgroup mounts for the raw factory")
 	}
@@ -106,6 +335,8 @@ func Register(machineInfoFactory info.MachineInfoFactory, fsInfo fs.FsInfo, incl
 		watcher:            watcher,
 		includedMetrics:    includedMetrics,
 		rawPrefixWhiteList: rawPrefixWhiteList,
+		rawWhiteList:       rawWhiteList,
+		rawBlackList:       rawBlackList,
 	}
 	container.RegisterContainerHandlerFactory(factory, []watch.ContainerWatchSource{watch.Raw})
 	return nil
@@ -0,0 +1,402 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raw
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/matthewygf/cadvisor/container/common"
+	"github.com/matthewygf/cadvisor/container/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+)
+
+func TestCanHandleAndAccept(t *testing.T) {
+	testCases := map[string]struct {
+		whiteList      []string
+		dockerOnly     bool
+		acceptKubepods bool
+		accepted       []string
+		rejected       []string
+	}{
+		"no_whitelist": {
+			whiteList: []string{""},
+			accepted:  []string{"/", "/system.slice", "/kubepods/burstable/pod1"},
+		},
+		"no_whitelist_docker_only": {
+			whiteList:  []string{""},
+			dockerOnly: true,
+			accepted:   []string{"/"},
+			rejected:   []string{"/system.slice", "/kubepods/burstable/pod1"},
+		},
+		"no_whitelist_docker_only_kubepods": {
+			whiteList:      []string{""},
+			dockerOnly:     true,
+			acceptKubepods: true,
+			accepted: []string{
+				"/",
+				"/kubepods",
+				"/kubepods/burstable/pod1",
+				"/kubepods.slice",
+				"/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1.slice",
+			},
+			rejected: []string{"/system.slice", "/kubepods2", "/parent/kubepods/pod1"},
+		},
+		"prefixes_and_regexps": {
+			whiteList:  []string{"/system.slice/kubelet", `re:/kubepods/burstable/pod[^/]+`},
+			dockerOnly: true,
+			accepted: []string{
+				"/",
+				"/system.slice/kubelet.service",
+				"/kubepods/burstable/pod1",
+			},
+			rejected: []string{
+				"/system.slice/docker.service",
+				"/kubepods/pod2",
+				// Regular expressions are anchored.
+				"/kubepods/burstable/pod1/container3",
+				"/parent/kubepods/burstable/pod1",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		whiteList, err := newCgroupPathFilter(tc.whiteList)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		factory := &rawFactory{
+			rawPrefixWhiteList: tc.whiteList,
+			rawWhiteList:       whiteList,
+			rawBlackList:       &cgroupPathFilter{},
+		}
+		*dockerOnly = tc.dockerOnly
+		*dockerOnlyAcceptKubepods = tc.acceptKubepods
+		for _, cgroup := range tc.accepted {
+			if _, accept, _ := factory.CanHandleAndAccept(cgroup); !accept {
+				t.Errorf("%s: expected %q to be accepted", name, cgroup)
+			}
+		}
+		for _, cgroup := range tc.rejected {
+			if _, accept, _ := factory.CanHandleAndAccept(cgroup); accept {
+				t.Errorf("%s: expected %q to be rejected", name, cgroup)
+			}
+		}
+	}
+	*dockerOnly = false
+	*dockerOnlyAcceptKubepods = true
+}
+
+func TestCanHandleAndAcceptPrecedence(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		whiteList   []string
+		blackList   []string
+		dockerOnly  bool
+		name        string
+		accept      bool
+	}{
+		{
+			description: "root is accepted even if blacklisted",
+			whiteList:   []string{""},
+			blackList:   []string{"/"},
+			name:        "/",
+			accept:      true,
+		},
+		{
+			description: "blacklist overrides the whitelist",
+			whiteList:   []string{"/system.slice"},
+			blackList:   []string{"/system.slice/run-"},
+			dockerOnly:  true,
+			name:        "/system.slice/run-r1234.scope",
+			accept:      false,
+		},
+		{
+			description: "blacklist regexp overrides the whitelist",
+			whiteList:   []string{"/system.slice"},
+			blackList:   []string{`re:/system.slice/session-[0-9]+\.scope`},
+			dockerOnly:  true,
+			name:        "/system.slice/session-12.scope",
+			accept:      false,
+		},
+		{
+			description: "blacklist applies without docker_only",
+			whiteList:   []string{""},
+			blackList:   []string{"/system.slice/run-"},
+			name:        "/system.slice/run-r1234.scope",
+			accept:      false,
+		},
+		{
+			description: "blacklist overrides kubepods acceptance under docker_only",
+			whiteList:   []string{""},
+			blackList:   []string{"/kubepods/besteffort"},
+			dockerOnly:  true,
+			name:        "/kubepods/besteffort/pod1",
+			accept:      false,
+		},
+		{
+			description: "whitelist applies if not blacklisted",
+			whiteList:   []string{"/system.slice"},
+			blackList:   []string{"/system.slice/run-"},
+			dockerOnly:  true,
+			name:        "/system.slice/kubelet.service",
+			accept:      true,
+		},
+		{
+			description: "docker_only rejects non-whitelisted containers",
+			whiteList:   []string{"/system.slice"},
+			blackList:   []string{"/system.slice/run-"},
+			dockerOnly:  true,
+			name:        "/user.slice",
+			accept:      false,
+		},
+		{
+			description: "everything is accepted by default",
+			whiteList:   []string{""},
+			name:        "/user.slice",
+			accept:      true,
+		},
+	} {
+		whiteList, err := newCgroupPathFilter(tc.whiteList)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.description, err)
+		}
+		blackList, err := newCgroupPathFilter(tc.blackList)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.description, err)
+		}
+		factory := &rawFactory{
+			rawPrefixWhiteList: tc.whiteList,
+			rawWhiteList:       whiteList,
+			rawBlackList:       blackList,
+		}
+		*dockerOnly = tc.dockerOnly
+		if _, accept, _ := factory.CanHandleAndAccept(tc.name); accept != tc.accept {
+			t.Errorf("%s: expected accept=%v for %q, got %v", tc.description, tc.accept, tc.name, accept)
+		}
+	}
+	*dockerOnly = false
+}
+
+func TestExplain(t *testing.T) {
+	for _, tc := range []struct {
+		whiteList   []string
+		blackList   []string
+		dockerOnly  bool
+		name        string
+		explanation string
+	}{
+		{
+			whiteList:   []string{""},
+			blackList:   []string{"/"},
+			name:        "/",
+			explanation: "accepted: the root container is always accepted",
+		},
+		{
+			whiteList:   []string{"/system.slice"},
+			blackList:   []string{`re:/system.slice/session-[0-9]+\.scope`},
+			name:        "/system.slice/session-12.scope",
+			explanation: `ignored: matches raw cgroup blacklist entry "re:/system.slice/session-[0-9]+\\.scope"`,
+		},
+		{
+			whiteList:   []string{""},
+			dockerOnly:  true,
+			name:        "/system.slice/kubelet.service",
+			explanation: "ignored: --docker_only is set and the raw cgroup whitelist is empty",
+		},
+		{
+			whiteList:   []string{"/user.slice", "re:/system.slice/[a-z]+\\.service"},
+			dockerOnly:  true,
+			name:        "/system.slice/kubelet.service",
+			explanation: `accepted: matches raw cgroup whitelist entry "re:/system.slice/[a-z]+\\.service"`,
+		},
+		{
+			whiteList:   []string{""},
+			dockerOnly:  true,
+			name:        "/kubepods.slice/kubepods-besteffort.slice",
+			explanation: "accepted: --docker_only is set but kubernetes pods are accepted",
+		},
+		{
+			whiteList:   []string{"/user.slice"},
+			name:        "/system.slice/kubelet.service",
+			explanation: "ignored: matches no raw cgroup whitelist entry",
+		},
+		{
+			whiteList:   []string{""},
+			name:        "/system.slice/kubelet.service",
+			explanation: "accepted: the raw cgroup whitelist is empty",
+		},
+	} {
+		whiteList, err := newCgroupPathFilter(tc.whiteList)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		blackList, err := newCgroupPathFilter(tc.blackList)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		factory := &rawFactory{
+			rawPrefixWhiteList: tc.whiteList,
+			rawWhiteList:       whiteList,
+			rawBlackList:       blackList,
+		}
+		*dockerOnly = tc.dockerOnly
+		if explanation := factory.Explain(tc.name); explanation != tc.explanation {
+			t.Errorf("expected explanation %q for %q, got %q", tc.explanation, tc.name, explanation)
+		}
+	}
+	*dockerOnly = false
+}
+
+func TestNewCgroupPathFilterInvalidRegexp(t *testing.T) {
+	_, err := newCgroupPathFilter([]string{"/system.slice", "re:/kubepods/(burstable"})
+	if err == nil {
+		t.Fatalf("expected an error for an invalid regular expression")
+	}
+	if !strings.Contains(err.Error(), "/kubepods/(burstable") {
+		t.Errorf("expected the error to name the invalid pattern, got %q", err)
+	}
+}
+
+func TestRootCgroupStatsValue(t *testing.T) {
+	for _, tc := range []struct {
+		value      string
+		all        bool
+		subsystems []string
+		str        string
+	}{
+		{value: "", str: "false"},
+		{value: "false", str: "false"},
+		{value: "true", all: true, str: "true"},
+		{value: "1", all: true, str: "true"},
+		{value: "blkio", subsystems: []string{"blkio"}, str: "blkio"},
+		{value: "blkio,hugetlb", subsystems: []string{"blkio", "hugetlb"}, str: "blkio,hugetlb"},
+		{value: "pids, blkio", subsystems: []string{"blkio", "pids"}, str: "blkio,pids"},
+	} {
+		v := &rootCgroupStatsValue{}
+		if err := v.Set(tc.value); err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.value, err)
+			continue
+		}
+		if v.all != tc.all {
+			t.Errorf("%q: expected all=%v, got %v", tc.value, tc.all, v.all)
+		}
+		for _, subsystem := range tc.subsystems {
+			if !v.disabled(subsystem) {
+				t.Errorf("%q: expected %q to be disabled", tc.value, subsystem)
+			}
+		}
+		if !tc.all && v.disabled("memory") {
+			t.Errorf("%q: expected memory not to be disabled", tc.value)
+		}
+		if v.String() != tc.str {
+			t.Errorf("%q: expected String() %q, got %q", tc.value, tc.str, v.String())
+		}
+	}
+
+	if err := (&rootCgroupStatsValue{}).Set("blkio,,hugetlb"); err == nil {
+		t.Errorf("expected an error for an empty subsystem")
+	}
+	if err := (&rootCgroupStatsValue{}).Set("blkio,bogus"); err == nil {
+		t.Errorf("expected an error for an unknown subsystem")
+	}
+
+	// Without a value, the flag disables all root cgroup stats.
+	v := &rootCgroupStatsValue{}
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Var(v, "disable_root_cgroup_stats", "")
+	if err := flags.Parse([]string{"--disable_root_cgroup_stats"}); err != nil {
+		t.Fatal(err)
+	}
+	if !v.all {
+		t.Errorf("expected all root cgroup stats to be disabled")
+	}
+}
+
+func TestStatsCgroupPaths(t *testing.T) {
+	cgroupPaths := map[string]string{
+		"blkio":   "/sys/fs/cgroup/blkio",
+		"cpu":     "/sys/fs/cgroup/cpu",
+		"hugetlb": "/sys/fs/cgroup/hugetlb",
+		"memory":  "/sys/fs/cgroup/memory",
+	}
+
+	disabled := &rootCgroupStatsValue{}
+	if err := disabled.Set("blkio,hugetlb"); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"cpu":    "/sys/fs/cgroup/cpu",
+		"memory": "/sys/fs/cgroup/memory",
+	}
+	if paths := statsCgroupPaths(cgroupPaths, disabled); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+	if len(cgroupPaths) != 4 {
+		t.Errorf("expected the container's cgroup paths to be left unchanged, got %v", cgroupPaths)
+	}
+
+	if paths := statsCgroupPaths(cgroupPaths, &rootCgroupStatsValue{}); !reflect.DeepEqual(paths, cgroupPaths) {
+		t.Errorf("expected %v, got %v", cgroupPaths, paths)
+	}
+}
+
+func TestRestrictCgroupSubsystems(t *testing.T) {
+	subsystems := libcontainer.CgroupSubsystems{
+		Mounts: []cgroups.Mount{
+			{Mountpoint: "/sys/fs/cgroup/cpu,cpuacct", Subsystems: []string{"cpu", "cpuacct"}},
+			{Mountpoint: "/sys/fs/cgroup/memory", Subsystems: []string{"memory"}},
+			{Mountpoint: "/sys/fs/cgroup/blkio", Subsystems: []string{"blkio"}},
+		},
+		MountPoints: map[string]string{
+			"cpu":     "/sys/fs/cgroup/cpu,cpuacct",
+			"cpuacct": "/sys/fs/cgroup/cpu,cpuacct",
+			"memory":  "/sys/fs/cgroup/memory",
+			"blkio":   "/sys/fs/cgroup/blkio",
+		},
+	}
+
+	all, err := restrictCgroupSubsystems(subsystems, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(all, subsystems) {
+		t.Errorf("expected %v, got %v", subsystems, all)
+	}
+
+	restricted, err := restrictCgroupSubsystems(subsystems, "cpu,memory")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(restricted.Mounts, subsystems.Mounts[:2]) {
+		t.Errorf("expected mounts %v, got %v", subsystems.Mounts[:2], restricted.Mounts)
+	}
+	expectedPaths := map[string]string{
+		"cpu":     "/sys/fs/cgroup/cpu,cpuacct",
+		"cpuacct": "/sys/fs/cgroup/cpu,cpuacct",
+		"memory":  "/sys/fs/cgroup/memory",
+	}
+	if paths := common.MakeCgroupPaths(restricted.MountPoints, "/"); !reflect.DeepEqual(paths, expectedPaths) {
+		t.Errorf("expected watched paths %v, got %v", expectedPaths, paths)
+	}
+
+	for _, only := range []string{"cpu,foo", "devices"} {
+		if _, err := restrictCgroupSubsystems(subsystems, only); err == nil {
+			t.Errorf("expected an error restricting to %q", only)
+		}
+	}
+}
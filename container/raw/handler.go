@@ -17,6 +17,8 @@ package raw
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path"
 
 	"github.com/matthewygf/cadvisor/container"
 	"github.com/matthewygf/cadvisor/container/common"
@@ -44,6 +46,9 @@ type rawContainerHandler struct {
 	includedMetrics container.MetricSet
 
 	libcontainerHandler *libcontainer.Handler
+
+	// Root of the host filesystem.
+	rootFs string
 }
 
 func isRootCgroup(name string) bool {
@@ -80,6 +85,8 @@ func newRawContainerHandler(name string, cgroupSubsystems *libcontainer.CgroupSu
 
 		// delete pids from cgroup paths because /sys/fs/cgroup/pids/pids.current not exist
 		delete(cgroupPaths, "pids")
+
+		cgroupManager.Paths = statsCgroupPaths(cgroupPaths, disableRootCgroupStats)
 	}
 
 	handler := libcontainer.NewHandler(cgroupManager, rootFs, pid, includedMetrics)
@@ -92,9 +99,22 @@ func newRawContainerHandler(name string, cgroupSubsystems *libcontainer.CgroupSu
 		externalMounts:      externalMounts,
 		includedMetrics:     includedMetrics,
 		libcontainerHandler: handler,
+		rootFs:              rootFs,
 	}, nil
 }
 
+// statsCgroupPaths returns the cgroup paths of the subsystems whose stats are
+// not disabled.
+func statsCgroupPaths(cgroupPaths map[string]string, disabled *rootCgroupStatsValue) map[string]string {
+	paths := make(map[string]string, len(cgroupPaths))
+	for subsystem, path := range cgroupPaths {
+		if !disabled.disabled(subsystem) {
+			paths[subsystem] = path
+		}
+	}
+	return paths
+}
+
 func (self *rawContainerHandler) ContainerReference() (info.ContainerReference, error) {
 	// We only know the container by its one name.
 	return info.ContainerReference{
@@ -147,7 +167,7 @@ func (self *rawContainerHandler) GetSpec() (info.ContainerSpec, error) {
 			spec.HasMemory = true
 		}
 
-		swapLimit, err := machine.GetMachineSwapCapacity()
+		swapLimit, err := machine.GetMachineSwapCapacity("/")
 		if err != nil {
 			klog.Warningf("failed to obtain swap limit for machine container")
 		} else {
@@ -227,7 +247,7 @@ func (self *rawContainerHandler) getFsStats(stats *info.ContainerStats) error {
 }
 
 func (self *rawContainerHandler) GetStats() (*info.ContainerStats, error) {
-	if *disableRootCgroupStats && isRootCgroup(self.name) {
+	if disableRootCgroupStats.all && isRootCgroup(self.name) {
 		return nil, nil
 	}
 	stats, err := self.libcontainerHandler.GetStats()
@@ -235,6 +255,17 @@ func (self *rawContainerHandler) GetStats() (*info.ContainerStats, error) {
 		return stats, err
 	}
 
+	if *hostCpuTime && isRootCgroup(self.name) {
+		procStat, err := ioutil.ReadFile(path.Join(self.rootFs, "proc", "stat"))
+		if err != nil {
+			return stats, err
+		}
+		stats.HostCpu, err = machine.GetCpuTime(procStat)
+		if err != nil {
+			return stats, err
+		}
+	}
+
 	// Get filesystem stats.
 	err = self.getFsStats(stats)
 	if err != nil {
@@ -252,6 +283,10 @@ func (self *rawContainerHandler) GetCgroupPath(resource string) (string, error)
 	return path, nil
 }
 
+func (self *rawContainerHandler) GetRelativeCgroupPath(resource string) (string, error) {
+	return common.GetRelativeCgroupPath(self.cgroupPaths, self.name, resource)
+}
+
 func (self *rawContainerHandler) GetContainerLabels() map[string]string {
 	return map[string]string{}
 }
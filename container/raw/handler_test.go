@@ -16,11 +16,18 @@
 package raw
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/matthewygf/cadvisor/container"
+	"github.com/matthewygf/cadvisor/container/libcontainer"
 	"github.com/matthewygf/cadvisor/fs"
 	info "github.com/matthewygf/cadvisor/info/v1"
+
+	cgroupfs "github.com/opencontainers/runc/libcontainer/cgroups/fs"
 )
 
 func TestFsToFsStats(t *testing.T) {
@@ -124,3 +131,67 @@ func TestFsToFsStats(t *testing.T) {
 		}
 	}
 }
+
+type fakeMachineInfoFactory struct{}
+
+func (f fakeMachineInfoFactory) GetMachineInfo() (*info.MachineInfo, error) {
+	return &info.MachineInfo{NumCores: 4}, nil
+}
+
+func (f fakeMachineInfoFactory) GetVersionInfo() (*info.VersionInfo, error) {
+	return &info.VersionInfo{}, nil
+}
+
+func TestHugetlbSpecAndStats(t *testing.T) {
+	originalHugePageSizes := cgroupfs.HugePageSizes
+	cgroupfs.HugePageSizes = []string{"2MB"}
+	defer func() {
+		cgroupfs.HugePageSizes = originalHugePageSizes
+	}()
+
+	mountPoint, err := ioutil.TempDir("", "hugetlb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(mountPoint)
+	cgroupPath := filepath.Join(mountPoint, "test")
+	if err := os.Mkdir(cgroupPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"hugetlb.2MB.limit_in_bytes":     "4194304\n",
+		"hugetlb.2MB.usage_in_bytes":     "2097152\n",
+		"hugetlb.2MB.max_usage_in_bytes": "4194304\n",
+		"hugetlb.2MB.failcnt":            "1\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(cgroupPath, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cgroupSubsystems := &libcontainer.CgroupSubsystems{
+		MountPoints: map[string]string{"hugetlb": mountPoint},
+	}
+	handler, err := newRawContainerHandler("/test", cgroupSubsystems, fakeMachineInfoFactory{}, nil, nil, "/", container.MetricSet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := handler.GetSpec()
+	if err != nil {
+		t.Fatalf("failed to get spec: %v", err)
+	}
+	expectedSpec := map[string]info.HugetlbSpec{"2MB": {Limit: 4194304}}
+	if !spec.HasHugetlb || !reflect.DeepEqual(spec.Hugetlb, expectedSpec) {
+		t.Errorf("expected huge page limits %+v, got %+v", expectedSpec, spec.Hugetlb)
+	}
+
+	stats, err := handler.GetStats()
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+	expectedStats := map[string]info.HugetlbStats{"2MB": {Usage: 2097152, MaxUsage: 4194304, Failcnt: 1}}
+	if !reflect.DeepEqual(stats.Hugetlb, expectedStats) {
+		t.Errorf("expected huge page stats %+v, got %+v", expectedStats, stats.Hugetlb)
+	}
+}
@@ -49,6 +49,10 @@ func NewRawContainerWatcher() (watcher.ContainerWatcher, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cgroup subsystems: %v", err)
 	}
+	cgroupSubsystems, err = restrictCgroupSubsystems(cgroupSubsystems, *rawCgroupSubsystems)
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw cgroup subsystems: %v", err)
+	}
 	if len(cgroupSubsystems.Mounts) == 0 {
 		return nil, fmt.Errorf("failed to find supported cgroup mounts for the raw factory")
 	}
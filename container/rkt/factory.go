@@ -63,6 +63,13 @@ func (self *rktFactory) CanHandleAndAccept(name string) (bool, bool, error) {
 	return accept, accept, err
 }
 
+// Explain returns the decision CanHandleAndAccept makes for the container and
+// the pod behind it.
+func (self *rktFactory) Explain(name string) string {
+	pod, err := cgroupToPod(name)
+	return explainPod(name, pod, err)
+}
+
 func (self *rktFactory) DebugInfo() map[string][]string {
 	return map[string][]string{}
 }
@@ -94,7 +94,14 @@ func newRktContainerHandler(name string, rktClient rktapi.PublicAPIClient, rktPa
 			annotations = append(annotations, contAnnotations...)
 		}
 	} else { // The Pod container
-		pid = int(resp.Pod.Pid)
+		// The network stats of the pod are read from its stage1 process,
+		// which runs in the pod's network namespace.
+		if resp.Pod.Pid > 0 {
+			pid = int(resp.Pod.Pid)
+		} else {
+			klog.Warningf("couldn't find the pid of pod %v, its network stats will be unavailable", parsed.Pod)
+			pid = 0
+		}
 		apiPod = resp.Pod
 	}
 	labels = createLabels(annotations)
@@ -169,8 +176,14 @@ func (handler *rktContainerHandler) Cleanup() {
 	handler.fsHandler.Stop()
 }
 
+// Apps share the network namespace of their pod, whose network stats are
+// reported by the pod container only.
+func (handler *rktContainerHandler) needNet() bool {
+	return handler.isPod && handler.includedMetrics.Has(container.NetworkUsageMetrics)
+}
+
 func (handler *rktContainerHandler) GetSpec() (info.ContainerSpec, error) {
-	hasNetwork := handler.isPod && handler.includedMetrics.Has(container.NetworkUsageMetrics)
+	hasNetwork := handler.needNet()
 	hasFilesystem := handler.includedMetrics.Has(container.DiskUsageMetrics)
 
 	spec, err := common.GetSpec(handler.cgroupPaths, handler.machineInfoFactory, hasNetwork, hasFilesystem)
@@ -227,6 +240,11 @@ func (handler *rktContainerHandler) GetStats() (*info.ContainerStats, error) {
 		return stats, err
 	}
 
+	// Don't count the pod's network once more for each app.
+	if !handler.needNet() {
+		stats.Network = info.NetworkStats{}
+	}
+
 	// Get filesystem stats.
 	err = handler.getFsStats(stats)
 	if err != nil {
@@ -259,6 +277,10 @@ func (handler *rktContainerHandler) GetCgroupPath(resource string) (string, erro
 	return path, nil
 }
 
+func (handler *rktContainerHandler) GetRelativeCgroupPath(resource string) (string, error) {
+	return common.GetRelativeCgroupPath(handler.cgroupPaths, handler.reference.Name, resource)
+}
+
 func (handler *rktContainerHandler) GetContainerLabels() map[string]string {
 	return handler.labels
 }
@@ -0,0 +1,98 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rkt
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/matthewygf/cadvisor/container"
+	"github.com/matthewygf/cadvisor/container/libcontainer"
+	info "github.com/matthewygf/cadvisor/info/v1"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+)
+
+// fakeCgroupManager returns empty stats.
+type fakeCgroupManager struct {
+	cgroups.Manager
+}
+
+func (m *fakeCgroupManager) GetStats() (*cgroups.Stats, error) {
+	return cgroups.NewStats(), nil
+}
+
+func (m *fakeCgroupManager) GetPaths() map[string]string {
+	return nil
+}
+
+type fakeMachineInfoFactory struct{}
+
+func (fakeMachineInfoFactory) GetMachineInfo() (*info.MachineInfo, error) {
+	return &info.MachineInfo{}, nil
+}
+
+func (fakeMachineInfoFactory) GetVersionInfo() (*info.VersionInfo, error) {
+	return &info.VersionInfo{}, nil
+}
+
+func TestGetStatsNetwork(t *testing.T) {
+	rootFs, err := ioutil.TempDir("", "rkt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootFs)
+	const pid = 42
+	dir := path.Join(rootFs, "proc", strconv.Itoa(pid), "net")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	netDev := `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+  eth0:    1000      10    0    0    0     0          0         0     2000      20    0    0    0     0       0          0
+`
+	if err := ioutil.WriteFile(path.Join(dir, "dev"), []byte(netDev), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	includedMetrics := container.MetricSet{container.NetworkUsageMetrics: struct{}{}}
+	for _, tc := range []struct {
+		isPod   bool
+		rxBytes uint64
+	}{
+		{isPod: true, rxBytes: 1000},
+		{isPod: false, rxBytes: 0},
+	} {
+		handler := &rktContainerHandler{
+			machineInfoFactory:  fakeMachineInfoFactory{},
+			isPod:               tc.isPod,
+			includedMetrics:     includedMetrics,
+			libcontainerHandler: libcontainer.NewHandler(&fakeCgroupManager{}, rootFs, pid, includedMetrics),
+		}
+		stats, err := handler.GetStats()
+		if err != nil {
+			t.Fatalf("isPod=%v: unexpected error: %v", tc.isPod, err)
+		}
+		if stats.Network.RxBytes != tc.rxBytes {
+			t.Errorf("isPod=%v: expected %d received bytes, got %d", tc.isPod, tc.rxBytes, stats.Network.RxBytes)
+		}
+		if !tc.isPod && len(stats.Network.Interfaces) != 0 {
+			t.Errorf("expected no interfaces for an app, got %+v", stats.Network.Interfaces)
+		}
+	}
+}
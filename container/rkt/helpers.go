@@ -38,11 +38,28 @@ func verifyPod(name string) (bool, error) {
 	}
 
 	// Anything handler can handle is also accepted.
-	// Accept cgroups that are sub the pod cgroup, except "system.slice"
-	//   - "system.slice" doesn't contain any processes itself
-	accept := !strings.HasSuffix(name, "/system.slice")
+	return acceptPodCgroup(name), nil
+}
+
+// Accept cgroups that are sub the pod cgroup, except "system.slice"
+//   - "system.slice" doesn't contain any processes itself
+func acceptPodCgroup(name string) bool {
+	return !strings.HasSuffix(name, "/system.slice")
+}
 
-	return accept, nil
+// explainPod describes the decision verifyPod makes for the cgroup, given the
+// pod cgroupToPod found for it.
+func explainPod(name string, pod *rktapi.Pod, err error) string {
+	if err != nil {
+		return fmt.Sprintf("not handled: %v", err)
+	}
+	if pod == nil {
+		return "not handled: not a cgroup of a running rkt pod"
+	}
+	if !acceptPodCgroup(name) {
+		return fmt.Sprintf("ignored: system.slice of rkt pod %s holds no processes", pod.Id)
+	}
+	return fmt.Sprintf("accepted: cgroup of rkt pod %s", pod.Id)
 }
 
 func cgroupToPod(name string) (*rktapi.Pod, error) {
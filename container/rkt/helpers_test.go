@@ -0,0 +1,62 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rkt
+
+import (
+	"fmt"
+	"testing"
+
+	rktapi "github.com/coreos/rkt/api/v1alpha"
+)
+
+func TestExplainPod(t *testing.T) {
+	const podCgroup = `/machine.slice/machine-rkt\x2df556b64a.scope`
+	pod := &rktapi.Pod{Id: "f556b64a"}
+	for _, tc := range []struct {
+		name        string
+		pod         *rktapi.Pod
+		err         error
+		explanation string
+	}{
+		{
+			name:        podCgroup,
+			pod:         pod,
+			explanation: "accepted: cgroup of rkt pod f556b64a",
+		},
+		{
+			name:        podCgroup + "/system.slice/alpine-sh.service",
+			pod:         pod,
+			explanation: "accepted: cgroup of rkt pod f556b64a",
+		},
+		{
+			name:        podCgroup + "/system.slice",
+			pod:         pod,
+			explanation: "ignored: system.slice of rkt pod f556b64a holds no processes",
+		},
+		{
+			name:        "/user.slice",
+			explanation: "not handled: not a cgroup of a running rkt pod",
+		},
+		{
+			name:        "/user.slice",
+			err:         fmt.Errorf("failed to list pods: unavailable"),
+			explanation: "not handled: failed to list pods: unavailable",
+		},
+	} {
+		if explanation := explainPod(tc.name, tc.pod, tc.err); explanation != tc.explanation {
+			t.Errorf("expected explanation %q for %q, got %q", tc.explanation, tc.name, explanation)
+		}
+	}
+}
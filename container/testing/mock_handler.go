@@ -86,6 +86,11 @@ func (self *MockContainerHandler) GetCgroupPath(path string) (string, error) {
 	return args.Get(0).(string), args.Error(1)
 }
 
+func (self *MockContainerHandler) GetRelativeCgroupPath(path string) (string, error) {
+	args := self.Called(path)
+	return args.Get(0).(string), args.Error(1)
+}
+
 func (self *MockContainerHandler) GetContainerLabels() map[string]string {
 	args := self.Called()
 	return args.Get(0).(map[string]string)
@@ -29,29 +29,49 @@ type ThinPoolWatcher struct {
 	metadataDevice string
 	lock           *sync.RWMutex
 	cache          map[string]uint64
+	refreshes      uint64
 	period         time.Duration
 	stopChan       chan struct{}
 	dmsetup        DmsetupClient
 	thinLsClient   thinLsClient
 }
 
+const (
+	// DefaultThinPoolWatcherPeriod is the default period between refreshes of
+	// a ThinPoolWatcher.
+	DefaultThinPoolWatcherPeriod = 15 * time.Second
+	// MinThinPoolWatcherPeriod is the shortest period between refreshes of a
+	// ThinPoolWatcher, each refresh reserves the metadata snapshot of the
+	// thin pool and runs thin_ls.
+	MinThinPoolWatcherPeriod = 5 * time.Second
+)
+
 // NewThinPoolWatcher returns a new ThinPoolWatcher for the given devicemapper
-// thin pool name and metadata device or an error.
-func NewThinPoolWatcher(poolName, metadataDevice string) (*ThinPoolWatcher, error) {
+// thin pool name and metadata device, refreshing its usage every period, or an
+// error.
+func NewThinPoolWatcher(poolName, metadataDevice string, period time.Duration) (*ThinPoolWatcher, error) {
+	if period < MinThinPoolWatcherPeriod {
+		return nil, fmt.Errorf("thin pool watcher period %v is shorter than the minimum of %v", period, MinThinPoolWatcherPeriod)
+	}
+
 	thinLsClient, err := newThinLsClient()
 	if err != nil {
 		return nil, fmt.Errorf("encountered error creating thin_ls client: %v", err)
 	}
 
+	return newThinPoolWatcher(poolName, metadataDevice, period, NewDmsetupClient(), thinLsClient), nil
+}
+
+func newThinPoolWatcher(poolName, metadataDevice string, period time.Duration, dmsetup DmsetupClient, thinLsClient thinLsClient) *ThinPoolWatcher {
 	return &ThinPoolWatcher{poolName: poolName,
 		metadataDevice: metadataDevice,
 		lock:           &sync.RWMutex{},
 		cache:          make(map[string]uint64),
-		period:         15 * time.Second,
+		period:         period,
 		stopChan:       make(chan struct{}),
-		dmsetup:        NewDmsetupClient(),
+		dmsetup:        dmsetup,
 		thinLsClient:   thinLsClient,
-	}, nil
+	}
 }
 
 // Start starts the ThinPoolWatcher.
@@ -146,9 +166,19 @@ func (w *ThinPoolWatcher) Refresh() error {
 	}
 
 	w.cache = newCache
+	w.refreshes++
 	return nil
 }
 
+// Refreshes returns the number of times the cache has been refreshed
+// successfully.
+func (w *ThinPoolWatcher) Refreshes() uint64 {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	return w.refreshes
+}
+
 const (
 	thinPoolDmsetupStatusHeldMetadataRoot = 6
 	thinPoolDmsetupStatusMinFields        = thinPoolDmsetupStatusHeldMetadataRoot + 1
@@ -143,12 +143,19 @@ func TestRefresh(t *testing.T) {
 			if !tc.expectedError {
 				t.Errorf("%v: unexpected error: %v", tc.name, err)
 			}
+			if refreshes := watcher.Refreshes(); refreshes != 0 {
+				t.Errorf("%v: failed refresh was counted: %v refreshes", tc.name, refreshes)
+			}
 			continue
 		} else if tc.expectedError {
 			t.Errorf("%v: unexpected success", tc.name)
 			continue
 		}
 
+		if refreshes := watcher.Refreshes(); refreshes != 1 {
+			t.Errorf("%v: expected 1 refresh, got %v", tc.name, refreshes)
+		}
+
 		actualUsage, err := watcher.GetUsage(tc.deviceId)
 		if err != nil {
 			t.Errorf("%v: device ID not found: %v", tc.deviceId, err)
@@ -214,3 +221,14 @@ func TestCheckReservation(t *testing.T) {
 		}
 	}
 }
+
+func TestThinPoolWatcherPeriod(t *testing.T) {
+	watcher := newThinPoolWatcher("test pool name", "/dev/mapper/metadata-device", 30*time.Second, fake.NewFakeDmsetupClient(t), fake.NewFakeThinLsClient(nil, nil))
+	if watcher.period != 30*time.Second {
+		t.Errorf("expected period of 30s, got %v", watcher.period)
+	}
+
+	if _, err := NewThinPoolWatcher("test pool name", "/dev/mapper/metadata-device", time.Second); err == nil {
+		t.Errorf("expected an error for a period shorter than %v", MinThinPoolWatcherPeriod)
+	}
+}
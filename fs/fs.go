@@ -385,6 +385,12 @@ func (self *RealFsInfo) GetFsInfoForPath(mountSet map[string]struct{}) ([]Fs, er
 	if err != nil {
 		return nil, err
 	}
+	// Read the mount options afresh, as filesystems are remounted read-only
+	// on errors.
+	mountOptions, err := getMountOptionsMap("/proc/self/mountinfo")
+	if err != nil {
+		klog.V(4).Infof("Failed to read mount options: %v", err)
+	}
 	for device, partition := range self.partitions {
 		_, hasMount := mountSet[partition.mountpoint]
 		_, hasDevice := deviceSet[device]
@@ -427,6 +433,11 @@ func (self *RealFsInfo) GetFsInfoForPath(mountSet map[string]struct{}) ([]Fs, er
 					Minor:  uint(partition.minor),
 				}
 				fs.DiskStats = diskStatsMap[device]
+				fs.IsBacked = isBackedFsType(partition.fsType)
+				if options, ok := mountOptions[partition.mountpoint]; ok {
+					fs.MountOptions = options.options
+					fs.ReadOnly = options.readOnly
+				}
 				filesystems = append(filesystems, fs)
 			}
 		}
@@ -434,6 +445,93 @@ func (self *RealFsInfo) GetFsInfoForPath(mountSet map[string]struct{}) ([]Fs, er
 	return filesystems, nil
 }
 
+// Filesystems whose capacity is not disk space.
+var virtualFsTypes = map[string]bool{
+	"overlay": true,
+	"tmpfs":   true,
+}
+
+// isBackedFsType returns whether filesystems of the type are backed by a disk.
+func isBackedFsType(fsType string) bool {
+	return !virtualFsTypes[fsType]
+}
+
+// mountOptions are the options of a mount.
+type mountOptions struct {
+	// Mount-specific options.
+	options string
+	// Whether the mount or, e.g. after errors, its superblock is read-only.
+	readOnly bool
+}
+
+// getMountOptionsMap returns the options of the mounts listed in the
+// mountinfo file, keyed by mountpoint. Of mounts stacked on the same
+// mountpoint, the visible one, listed last, is kept.
+func getMountOptionsMap(mountInfoFile string) (map[string]mountOptions, error) {
+	file, err := os.Open(mountInfoFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	optionsMap := make(map[string]mountOptions)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		words := strings.Fields(scanner.Text())
+		separator := -1
+		for i := 6; i < len(words); i++ {
+			if words[i] == "-" {
+				separator = i
+				break
+			}
+		}
+		if separator < 0 || len(words) < separator+4 {
+			return nil, fmt.Errorf("malformed mountinfo line %q", scanner.Text())
+		}
+		options := words[5]
+		superOptions := words[separator+3]
+		optionsMap[unescapeMountInfo(words[4])] = mountOptions{
+			options:  options,
+			readOnly: hasOption(options, "ro") || hasOption(superOptions, "ro"),
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return optionsMap, nil
+}
+
+// hasOption returns whether the comma-separated options contain option.
+func hasOption(options string, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeMountInfo decodes the octal escapes of whitespace and backslashes,
+// e.g. "\040", in mountinfo paths.
+func unescapeMountInfo(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b = append(b, byte(c))
+				i += 3
+				continue
+			}
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
 var partitionRegex = regexp.MustCompile(`^(?:(?:s|v|xv)d[a-z]+\d*|dm-\d+)$`)
 
 func getDiskStatsMap(diskStatsFile string) (map[string]DiskStats, error) {
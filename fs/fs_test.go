@@ -536,3 +536,56 @@ func TestProcessMounts(t *testing.T) {
 		}
 	}
 }
+
+func TestGetMountOptionsMap(t *testing.T) {
+	optionsMap, err := getMountOptionsMap("test_resources/mountinfo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]mountOptions{
+		"/sys":         {options: "rw,nosuid,nodev,noexec,relatime"},
+		"/":            {options: "rw,relatime"},
+		"/boot":        {options: "rw,relatime"},
+		"/mnt/backup":  {options: "ro,relatime", readOnly: true},
+		"/mnt/my disk": {options: "rw,relatime"},
+		// Remounted read-only after errors: only the superblock is ro.
+		"/var/lib/data": {options: "rw,noatime", readOnly: true},
+		// The last of stacked mounts is the visible one.
+		"/mnt/stacked": {options: "ro,relatime", readOnly: true},
+	}
+	if !reflect.DeepEqual(expected, optionsMap) {
+		t.Errorf("expected %#v, got %#v", expected, optionsMap)
+	}
+}
+
+func TestGetMountOptionsMapMalformed(t *testing.T) {
+	file, err := ioutil.TempFile("", "mountinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString("28 1 253:0 / / rw,relatime shared:1 ext4 /dev/sda1 rw\n"); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	if _, err := getMountOptionsMap(file.Name()); err == nil {
+		t.Errorf("expected an error for a line without separator")
+	}
+}
+
+func TestIsBackedFsType(t *testing.T) {
+	for fsType, backed := range map[string]bool{
+		"ext4":                true,
+		"xfs":                 true,
+		"btrfs":               true,
+		DeviceMapper.String(): true,
+		ZFS.String():          true,
+		"tmpfs":               false,
+		"overlay":             false,
+	} {
+		if isBackedFsType(fsType) != backed {
+			t.Errorf("expected %q to be backed=%v", fsType, backed)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testing
+
+import (
+	"fmt"
+
+	"github.com/matthewygf/cadvisor/fs"
+)
+
+// FakeFsInfo is an fs.FsInfo serving configured information instead of
+// inspecting the filesystems of the host.
+type FakeFsInfo struct {
+	// Filesystems returned by GetGlobalFsInfo and, filtered by mountpoint,
+	// by GetFsInfoForPath.
+	Filesystems []fs.Fs
+
+	// Usage returned by GetDirUsage, keyed by directory.
+	DirUsage map[string]fs.UsageInfo
+
+	// Devices returned by GetDirFsDevice, keyed by directory.
+	DirDevices map[string]*fs.DeviceInfo
+
+	// Devices returned by GetDeviceInfoByFsUUID, keyed by filesystem uuid.
+	UUIDDevices map[string]*fs.DeviceInfo
+
+	// Labels returned by GetLabelsForDevice, keyed by device. A device is
+	// returned by GetDeviceForLabel for each of its labels.
+	DeviceLabels map[string][]string
+
+	// Mountpoints returned by GetMountpointForDevice, keyed by device.
+	Mountpoints map[string]string
+}
+
+var _ fs.FsInfo = &FakeFsInfo{}
+
+// NewFakeFsInfo returns a FakeFsInfo without any filesystems.
+func NewFakeFsInfo() *FakeFsInfo {
+	return &FakeFsInfo{
+		DirUsage:     make(map[string]fs.UsageInfo),
+		DirDevices:   make(map[string]*fs.DeviceInfo),
+		UUIDDevices:  make(map[string]*fs.DeviceInfo),
+		DeviceLabels: make(map[string][]string),
+		Mountpoints:  make(map[string]string),
+	}
+}
+
+func (self *FakeFsInfo) GetGlobalFsInfo() ([]fs.Fs, error) {
+	return self.Filesystems, nil
+}
+
+func (self *FakeFsInfo) GetFsInfoForPath(mountSet map[string]struct{}) ([]fs.Fs, error) {
+	var filesystems []fs.Fs
+	for _, filesystem := range self.Filesystems {
+		if _, ok := mountSet[self.Mountpoints[filesystem.Device]]; ok {
+			filesystems = append(filesystems, filesystem)
+		}
+	}
+	return filesystems, nil
+}
+
+func (self *FakeFsInfo) GetDirUsage(dir string) (fs.UsageInfo, error) {
+	usage, ok := self.DirUsage[dir]
+	if !ok {
+		return fs.UsageInfo{}, fmt.Errorf("no usage for directory %q", dir)
+	}
+	return usage, nil
+}
+
+func (self *FakeFsInfo) GetDeviceInfoByFsUUID(uuid string) (*fs.DeviceInfo, error) {
+	deviceInfo, ok := self.UUIDDevices[uuid]
+	if !ok {
+		return nil, fs.ErrNoSuchDevice
+	}
+	return deviceInfo, nil
+}
+
+func (self *FakeFsInfo) GetDirFsDevice(dir string) (*fs.DeviceInfo, error) {
+	deviceInfo, ok := self.DirDevices[dir]
+	if !ok {
+		return nil, fmt.Errorf("could not find device for directory %q", dir)
+	}
+	return deviceInfo, nil
+}
+
+func (self *FakeFsInfo) GetDeviceForLabel(label string) (string, error) {
+	for device, labels := range self.DeviceLabels {
+		for _, l := range labels {
+			if l == label {
+				return device, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no device with label %q", label)
+}
+
+func (self *FakeFsInfo) GetLabelsForDevice(device string) ([]string, error) {
+	return self.DeviceLabels[device], nil
+}
+
+func (self *FakeFsInfo) GetMountpointForDevice(device string) (string, error) {
+	mountpoint, ok := self.Mountpoints[device]
+	if !ok {
+		return "", fmt.Errorf("no mountpoint for device %q", device)
+	}
+	return mountpoint, nil
+}
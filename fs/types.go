@@ -62,6 +62,13 @@ type Fs struct {
 	Inodes     *uint64
 	InodesFree *uint64
 	DiskStats  DiskStats
+	// Options the filesystem is mounted with, from /proc/self/mountinfo.
+	MountOptions string
+	// Whether the mount or its filesystem is read-only.
+	ReadOnly bool
+	// Whether the filesystem is backed by a disk rather than, e.g. for tmpfs
+	// and overlay, by memory or other filesystems.
+	IsBacked bool
 }
 
 type DiskStats struct {
@@ -17,6 +17,7 @@ package http
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	auth "github.com/abbot/go-http-auth"
 	"github.com/matthewygf/cadvisor/api"
@@ -90,6 +91,9 @@ func RegisterHandlers(mux httpmux.Mux, containerManager manager.Manager, httpAut
 	return nil
 }
 
+// How often the disk usage of the docker daemon is computed for Prometheus.
+const dockerDiskUsageInterval = time.Minute
+
 // RegisterPrometheusHandler creates a new PrometheusCollector and configures
 // the provided HTTP mux to handle the given Prometheus endpoint.
 func RegisterPrometheusHandler(mux httpmux.Mux, containerManager manager.Manager, prometheusEndpoint string,
@@ -100,6 +104,12 @@ func RegisterPrometheusHandler(mux httpmux.Mux, containerManager manager.Manager
 		prometheus.NewGoCollector(),
 		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
 	)
+	// Only export the disk usage of the docker daemon if there is one.
+	if _, err := containerManager.DockerInfo(); err == nil {
+		r.MustRegister(metrics.NewDockerDiskUsageCollector(containerManager, dockerDiskUsageInterval))
+	} else {
+		klog.V(4).Infof("Not exporting docker disk usage: %v", err)
+	}
 	mux.Handle(prometheusEndpoint, promhttp.HandlerFor(r, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError}))
 }
 
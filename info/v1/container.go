@@ -25,6 +25,9 @@ type CpuSpec struct {
 	Mask     string `json:"mask,omitempty"`
 	Quota    uint64 `json:"quota,omitempty"`
 	Period   uint64 `json:"period,omitempty"`
+	// Amount of unused quota the container may accumulate and burst with.
+	// Units: microseconds.
+	Burst uint64 `json:"burst,omitempty"`
 }
 
 type MemorySpec struct {
@@ -39,6 +42,25 @@ type MemorySpec struct {
 	// The amount of swap space requested. Default is unlimited (-1).
 	// Units: bytes.
 	SwapLimit uint64 `json:"swap_limit,omitempty"`
+
+	// The configured memory swappiness (0-100). Nil if the container uses
+	// the system default.
+	Swappiness *uint64 `json:"swappiness,omitempty"`
+}
+
+type HugetlbSpec struct {
+	// The limit on usage of huge pages of this size, math.MaxUint64 if unlimited.
+	// Units: bytes.
+	Limit uint64 `json:"limit"`
+}
+
+type NetworkSpec struct {
+	// The class ID net_cls tags the container's packets with. Zero if not set.
+	ClassID uint32 `json:"class_id,omitempty"`
+
+	// The priority net_prio assigns to the container's traffic, keyed by
+	// network interface.
+	IfPriorities map[string]uint32 `json:"if_priorities,omitempty"`
 }
 
 type ProcessSpec struct {
@@ -61,10 +83,17 @@ type ContainerSpec struct {
 	Memory    MemorySpec `json:"memory,omitempty"`
 
 	HasNetwork bool `json:"has_network"`
+	// Traffic classification of the container by the net_cls and net_prio
+	// cgroup controllers, reported whether or not HasNetwork is set.
+	Network NetworkSpec `json:"network,omitempty"`
 
 	HasProcesses bool        `json:"has_processes"`
 	Processes    ProcessSpec `json:"processes,omitempty"`
 
+	// Huge page limits, keyed by page size as named by the kernel, e.g. "2MB".
+	HasHugetlb bool                   `json:"has_hugetlb"`
+	Hugetlb    map[string]HugetlbSpec `json:"hugetlb,omitempty"`
+
 	HasFilesystem bool `json:"has_filesystem"`
 
 	// HasDiskIo when true, indicates that DiskIo stats will be available.
@@ -75,6 +104,110 @@ type ContainerSpec struct {
 
 	// Image name used for this container.
 	Image string `json:"image,omitempty"`
+
+	// Immutable ID of the image, e.g. "sha256:3fd9065eaf02...", as opposed
+	// to the possibly mutable reference in Image.
+	ImageID string `json:"image_id,omitempty"`
+
+	// Entrypoint and arguments the container was started with.
+	Entrypoint []string `json:"entrypoint,omitempty"`
+	Cmd        []string `json:"cmd,omitempty"`
+
+	// Command line of the container: the entrypoint followed by the
+	// arguments, with arguments containing spaces or quotes quoted.
+	Command string `json:"command,omitempty"`
+
+	// Ports exposed or published by the container.
+	Ports []PortMapping `json:"ports,omitempty"`
+
+	// Host paths and volumes mounted into the container.
+	Mounts []Mount `json:"mounts,omitempty"`
+
+	// OOM score adjustment of the container's main process.
+	OomScoreAdj int `json:"oom_score_adj,omitempty"`
+
+	// Hostname seen inside the container.
+	Hostname string `json:"hostname,omitempty"`
+
+	// DNS aliases of the container, keyed by the name of the network they
+	// are defined on.
+	NetworkAliases map[string][]string `json:"network_aliases,omitempty"`
+
+	// Scheduling policy of the container's main process. Only reported if enabled.
+	Sched *SchedSpec `json:"sched,omitempty"`
+
+	// Healthcheck configured for the container. Nil if it has none.
+	Healthcheck *HealthcheckSpec `json:"healthcheck,omitempty"`
+
+	// Health status of the container reported by its healthcheck, e.g.
+	// "starting", "healthy" or "unhealthy". Empty if it has no healthcheck.
+	Health string `json:"health,omitempty"`
+
+	// Number of times the container was restarted by its runtime.
+	// Docker containers used to report it in a "restartcount" label, only
+	// when non-zero. The label is no longer set, use this field instead.
+	RestartCount int `json:"restart_count"`
+}
+
+// ContainerResourceLimits are the limits currently set on the cgroups of a
+// container. Zero means unlimited.
+type ContainerResourceLimits struct {
+	// CPU time the container may use every CpuPeriod, in microseconds.
+	CpuQuota uint64 `json:"cpu_quota"`
+	// CFS period, in microseconds.
+	CpuPeriod uint64 `json:"cpu_period"`
+	// Memory limit, in bytes.
+	MemoryLimit uint64 `json:"memory_limit"`
+}
+
+type HealthcheckSpec struct {
+	// The command run to check the health of the container, e.g.
+	// ["CMD-SHELL", "curl -f http://localhost/"].
+	Test []string `json:"test"`
+	// Time between checks. Zero means the runtime's default.
+	Interval time.Duration `json:"interval,omitempty"`
+	// Time after which a check is considered to have failed.
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Time given to the container to start before failed checks count.
+	StartPeriod time.Duration `json:"start_period,omitempty"`
+	// Number of consecutive failed checks after which the container is unhealthy.
+	Retries int `json:"retries,omitempty"`
+}
+
+type SchedSpec struct {
+	// Scheduling policy, e.g. "SCHED_OTHER" or "SCHED_FIFO".
+	Policy string `json:"policy"`
+	// Real-time priority (1-99) for SCHED_FIFO and SCHED_RR, 0 otherwise.
+	Priority uint64 `json:"priority"`
+}
+
+// PortMapping describes a port exposed by a container and, if published,
+// the host address it is bound to.
+type PortMapping struct {
+	// Port number inside the container.
+	ContainerPort uint16 `json:"container_port"`
+	// Protocol of the port, e.g. "tcp" or "udp".
+	Protocol string `json:"protocol"`
+	// Host IP the port is published on. Empty if the port is not published.
+	HostIP string `json:"host_ip,omitempty"`
+	// Host port the port is published on. Zero if the port is not published.
+	HostPort uint16 `json:"host_port,omitempty"`
+}
+
+// Mount describes a host path or volume mounted into a container.
+type Mount struct {
+	// Type of the mount, e.g. "bind", "volume" or "tmpfs".
+	Type string `json:"type,omitempty"`
+	// Name of the volume. Empty for bind mounts.
+	Name string `json:"name,omitempty"`
+	// Path of the mounted directory or file on the host.
+	Source string `json:"source"`
+	// Path the source is mounted at inside the container.
+	Destination string `json:"destination"`
+	// Mount options, e.g. "z" or "ro".
+	Mode string `json:"mode,omitempty"`
+	// Whether the container can write to the mount.
+	RW bool `json:"rw"`
 }
 
 // Container reference contains enough information to uniquely identify a container
@@ -322,6 +455,9 @@ type CpuStats struct {
 	// Load is smoothed over the last 10 seconds. Instantaneous value can be read
 	// from LoadStats.NrRunning.
 	LoadAverage int32 `json:"load_average"`
+	// CPU usage since the previous collection, only reported by handlers
+	// configured to do so.
+	UsageDelta *CpuUsage `json:"usage_delta,omitempty"`
 }
 
 type PerDiskStats struct {
@@ -375,6 +511,25 @@ type MemoryStats struct {
 
 	Failcnt uint64 `json:"failcnt"`
 
+	// The amount of memory used for TCP socket buffers, as accounted by the
+	// kernel memory controller. Zero if it is not available.
+	// Units: Bytes.
+	TCPUsage uint64 `json:"tcp_usage,omitempty"`
+
+	// The limit on memory used for TCP socket buffers. Zero if it is not
+	// available.
+	// Units: Bytes.
+	TCPLimit uint64 `json:"tcp_limit,omitempty"`
+
+	// The amount of anonymous memory backed by transparent huge pages. Zero
+	// if the kernel does not report it.
+	// Units: Bytes.
+	AnonTHP uint64 `json:"anon_thp,omitempty"`
+
+	// Number of processes killed by the OOM killer in the memory cgroup of
+	// the container. Zero if the kernel does not report it.
+	OomKills uint64 `json:"oom_kills,omitempty"`
+
 	ContainerData    MemoryStatsMemoryData `json:"container_data,omitempty"`
 	HierarchicalData MemoryStatsMemoryData `json:"hierarchical_data,omitempty"`
 }
@@ -474,6 +629,23 @@ type FsStats struct {
 	// This field is only applicable for docker container's as of now.
 	BaseUsage uint64 `json:"base_usage"`
 
+	// Number of bytes consumed by the container's log files. Included in Usage.
+	// This field is only applicable for docker containers using the json-file
+	// logging driver as of now.
+	LogUsage uint64 `json:"log_usage,omitempty"`
+
+	// Number of bytes consumed by the container on this filesystem including
+	// the read-only image layers it shares with other containers of the same
+	// image. Zero if not known. This field is only applicable for docker
+	// containers using the overlay2 storage driver, and only reported if the
+	// diskImage metrics are enabled.
+	UsageIncludingImage uint64 `json:"usage_including_image,omitempty"`
+
+	// Merged is true if the usage is that of the container's merged
+	// filesystem view (image layers plus writable layer) rather than of its
+	// writable layer. This field is only applicable for docker containers as of now.
+	Merged bool `json:"merged,omitempty"`
+
 	// Number of bytes available for non-root user.
 	Available uint64 `json:"available"`
 
@@ -590,6 +762,33 @@ type ProcessStats struct {
 	ThreadsMax uint64 `json:"threads_max,omitempty"`
 }
 
+// Time all CPUs of the host spent in each mode, as reported in /proc/stat.
+// Unit: nanoseconds.
+type HostCpuTime struct {
+	User    uint64 `json:"user"`
+	Nice    uint64 `json:"nice"`
+	System  uint64 `json:"system"`
+	Idle    uint64 `json:"idle"`
+	IOWait  uint64 `json:"iowait"`
+	IRQ     uint64 `json:"irq"`
+	SoftIRQ uint64 `json:"softirq"`
+	// Time the hypervisor ran other virtual machines while this one was runnable.
+	Steal uint64 `json:"steal"`
+}
+
+type HugetlbStats struct {
+	// Current usage of huge pages of this size.
+	// Units: Bytes.
+	Usage uint64 `json:"usage,omitempty"`
+
+	// Maximum usage ever recorded.
+	// Units: Bytes.
+	MaxUsage uint64 `json:"max_usage,omitempty"`
+
+	// Number of times an allocation failed due to the limit.
+	Failcnt uint64 `json:"failcnt"`
+}
+
 type ContainerStats struct {
 	// The time of this stat point.
 	Timestamp time.Time    `json:"timestamp"`
@@ -610,10 +809,35 @@ type ContainerStats struct {
 	// ProcessStats for Containers
 	Processes ProcessStats `json:"processes,omitempty"`
 
+	// Huge page usage, keyed by page size as named by the kernel, e.g. "2MB".
+	Hugetlb map[string]HugetlbStats `json:"hugetlb,omitempty"`
+
+	// Time the CPUs of the host spent in each mode. Only reported for the
+	// root container, and only if enabled.
+	HostCpu *HostCpuTime `json:"host_cpu,omitempty"`
+
+	// Time since the container was created, computed when the stats were
+	// collected. Zero if the creation time is unknown.
+	Uptime time.Duration `json:"uptime,omitempty"`
+
+	// Time each metric group, e.g. "cgroup" or "fs", took to collect. Only
+	// reported if enabled.
+	CollectionLatencies map[string]time.Duration `json:"collection_latencies,omitempty"`
+
 	// Custom metrics from all collectors
 	CustomMetrics map[string][]MetricVal `json:"custom_metrics,omitempty"`
+
+	// Components of these stats that were served from a cache and were
+	// already reported by a previous collection, e.g. StatsComponentFilesystem.
+	CachedComponents []string `json:"cached_components,omitempty"`
 }
 
+// Components of ContainerStats that may be served from a cache.
+const (
+	// Filesystem usage, which is computed periodically in the background.
+	StatsComponentFilesystem = "filesystem"
+)
+
 func timeEq(t1, t2 time.Time, tolerance time.Duration) bool {
 	// t1 should not be later than t2
 	if t1.After(t2) {
@@ -661,6 +885,12 @@ func (a *ContainerStats) StatsEq(b *ContainerStats) bool {
 	if !reflect.DeepEqual(a.Filesystem, b.Filesystem) {
 		return false
 	}
+	if !reflect.DeepEqual(a.Hugetlb, b.Hugetlb) {
+		return false
+	}
+	if !reflect.DeepEqual(a.HostCpu, b.HostCpu) {
+		return false
+	}
 	return true
 }
 
@@ -25,6 +25,7 @@ type DockerStatus struct {
 	Driver        string            `json:"driver"`
 	DriverStatus  map[string]string `json:"driver_status"`
 	ExecDriver    string            `json:"exec_driver"`
+	CgroupDriver  string            `json:"cgroup_driver"`
 	NumImages     int               `json:"num_images"`
 	NumContainers int               `json:"num_containers"`
 }
@@ -36,3 +37,23 @@ type DockerImage struct {
 	VirtualSize int64    `json:"virtual_size"`
 	Size        int64    `json:"size"`
 }
+
+// DockerDiskUsage is the disk space used by the docker daemon, as reported by
+// `docker system df`.
+type DockerDiskUsage struct {
+	Images     DockerDiskUsageItem `json:"images"`
+	Containers DockerDiskUsageItem `json:"containers"`
+	Volumes    DockerDiskUsageItem `json:"volumes"`
+	BuildCache DockerDiskUsageItem `json:"build_cache"`
+}
+
+type DockerDiskUsageItem struct {
+	// Number of objects of this type.
+	Count int `json:"count"`
+	// Number of objects in use by at least one container.
+	Active int `json:"active"`
+	// Bytes used by the objects.
+	Size int64 `json:"size"`
+	// Bytes that would be freed by removing the objects not in use.
+	Reclaimable int64 `json:"reclaimable"`
+}
@@ -33,6 +33,17 @@ type FsInfo struct {
 
 	// HasInodes when true, indicates that Inodes info will be available.
 	HasInodes bool `json:"has_inodes"`
+
+	// Whether the filesystem is mounted read-only, including when it was
+	// remounted read-only after errors.
+	ReadOnly bool `json:"read_only"`
+
+	// Options the filesystem is mounted with, e.g. "rw,relatime".
+	MountOptions string `json:"mount_options,omitempty"`
+
+	// Whether the filesystem is backed by a disk. The capacity of virtual
+	// filesystems such as tmpfs and overlay doesn't reflect disk space.
+	IsBacked bool `json:"is_backed"`
 }
 
 type Node struct {
@@ -41,6 +52,8 @@ type Node struct {
 	Memory uint64  `json:"memory"`
 	Cores  []Core  `json:"cores"`
 	Caches []Cache `json:"caches"`
+	// Huge pages allocated on this node.
+	HugePages []HugePagesInfo `json:"hugepages,omitempty"`
 }
 
 type Core struct {
@@ -131,6 +144,24 @@ type NetInfo struct {
 
 	// Maximum Transmission Unit
 	Mtu int64 `json:"mtu"`
+
+	// Duplex mode of the link: full or half. Empty if unknown.
+	Duplex string `json:"duplex"`
+}
+
+type AcceleratorInfo struct {
+	// Make of the accelerator (nvidia, amd, google etc.)
+	Make string `json:"make"`
+
+	// Model of the accelerator (tesla-p100, tesla-k80 etc.)
+	Model string `json:"model"`
+
+	// ID of the accelerator, the UUID for NVIDIA GPUs.
+	ID string `json:"id"`
+
+	// Total accelerator memory, zero if unknown.
+	// unit: bytes
+	MemoryTotal uint64 `json:"memory_total"`
 }
 
 type CloudProvider string
@@ -139,6 +170,11 @@ const (
 	GCE             CloudProvider = "GCE"
 	AWS                           = "AWS"
 	Azure                         = "Azure"
+	DigitalOcean                  = "DigitalOcean"
+	OCI                           = "OCI"
+	Alibaba                       = "Alibaba"
+	Tencent                       = "Tencent"
+	IBMCloud                      = "IBMCloud"
 	Baremetal                     = "Baremetal"
 	UnknownProvider               = "Unknown"
 )
@@ -160,13 +196,29 @@ type MachineInfo struct {
 	// The number of cores in this machine.
 	NumCores int `json:"num_cores"`
 
+	// The number of physical CPU sockets in this machine.
+	NumSockets int `json:"num_sockets"`
+
 	// Maximum clock speed for the cores, in KHz.
 	CpuFrequency uint64 `json:"cpu_frequency_khz"`
 
+	// Vendor of the CPUs, e.g. "GenuineIntel" or "ARM".
+	CpuVendor string `json:"cpu_vendor,omitempty"`
+
+	// Model name of the CPUs, e.g. "Intel(R) Xeon(R) CPU E5-2673 v4 @ 2.30GHz"
+	// or "Neoverse-N1".
+	CpuModelName string `json:"cpu_model_name,omitempty"`
+
+	// Stepping, or revision on ARM, of the CPUs.
+	CpuStepping string `json:"cpu_stepping,omitempty"`
+
 	// The amount of memory (in bytes) in this machine
 	MemoryCapacity uint64 `json:"memory_capacity"`
 
-	// HugePages on this machine.
+	// The amount of swap (in bytes) in this machine
+	SwapCapacity uint64 `json:"swap_capacity"`
+
+	// HugePages on this machine, summed over all NUMA nodes.
 	HugePages []HugePagesInfo `json:"hugepages"`
 
 	// The machine id
@@ -199,6 +251,15 @@ type MachineInfo struct {
 
 	// ID of cloud instance (e.g. instance-1) given to it by the cloud provider.
 	InstanceID InstanceID `json:"instance_id"`
+
+	// Hypervisor the machine runs on (e.g. kvm), "none" on bare metal.
+	Virtualization string `json:"virtualization"`
+
+	// Accelerators, such as GPUs, on this machine.
+	Accelerators []AcceleratorInfo `json:"accelerators,omitempty"`
+
+	// Cgroup driver (cgroupfs or systemd) of the docker daemon, if any.
+	CgroupDriver string `json:"cgroup_driver,omitempty"`
 }
 
 type VersionInfo struct {
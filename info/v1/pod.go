@@ -0,0 +1,175 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import "fmt"
+
+// AggregatePodStats sums the stats of the containers of a pod into pod-level
+// stats. specs[i] must be the spec of the container stats[i] was collected from.
+//
+// Containers of a pod usually share the network namespace of one of them.
+// Network stats are therefore only taken from containers whose spec reports
+// a network of their own, so that traffic is not counted once per container.
+func AggregatePodStats(stats []*ContainerStats, specs []ContainerSpec) (*ContainerStats, error) {
+	if len(stats) != len(specs) {
+		return nil, fmt.Errorf("got stats for %d containers but specs for %d", len(stats), len(specs))
+	}
+	pod := &ContainerStats{}
+	for i, s := range stats {
+		if s == nil {
+			continue
+		}
+		if s.Timestamp.After(pod.Timestamp) {
+			pod.Timestamp = s.Timestamp
+		}
+		addCpuStats(&pod.Cpu, &s.Cpu)
+		addMemoryStats(&pod.Memory, &s.Memory)
+		if specs[i].HasNetwork {
+			addNetworkStats(&pod.Network, &s.Network)
+		}
+		pod.Filesystem = addFsStats(pod.Filesystem, s.Filesystem)
+		pod.Processes.ProcessCount += s.Processes.ProcessCount
+		pod.Processes.FdCount += s.Processes.FdCount
+		pod.Processes.SocketCount += s.Processes.SocketCount
+		pod.Processes.ThreadsCurrent += s.Processes.ThreadsCurrent
+	}
+	if len(pod.Network.Interfaces) > 0 {
+		pod.Network.InterfaceStats = pod.Network.Interfaces[0]
+	}
+	return pod, nil
+}
+
+func addCpuStats(sum, s *CpuStats) {
+	sum.Usage.Total += s.Usage.Total
+	sum.Usage.User += s.Usage.User
+	sum.Usage.System += s.Usage.System
+	for len(sum.Usage.PerCpu) < len(s.Usage.PerCpu) {
+		sum.Usage.PerCpu = append(sum.Usage.PerCpu, 0)
+	}
+	for cpu, usage := range s.Usage.PerCpu {
+		sum.Usage.PerCpu[cpu] += usage
+	}
+	sum.CFS.Periods += s.CFS.Periods
+	sum.CFS.ThrottledPeriods += s.CFS.ThrottledPeriods
+	sum.CFS.ThrottledTime += s.CFS.ThrottledTime
+	sum.Schedstat.RunTime += s.Schedstat.RunTime
+	sum.Schedstat.RunqueueTime += s.Schedstat.RunqueueTime
+	sum.Schedstat.RunPeriods += s.Schedstat.RunPeriods
+	sum.LoadAverage += s.LoadAverage
+}
+
+func addMemoryStats(sum, s *MemoryStats) {
+	sum.Usage += s.Usage
+	sum.MaxUsage += s.MaxUsage
+	sum.Cache += s.Cache
+	sum.RSS += s.RSS
+	sum.Swap += s.Swap
+	sum.MappedFile += s.MappedFile
+	sum.WorkingSet += s.WorkingSet
+	sum.Failcnt += s.Failcnt
+	sum.TCPUsage += s.TCPUsage
+	sum.AnonTHP += s.AnonTHP
+	sum.OomKills += s.OomKills
+	sum.ContainerData.Pgfault += s.ContainerData.Pgfault
+	sum.ContainerData.Pgmajfault += s.ContainerData.Pgmajfault
+	sum.HierarchicalData.Pgfault += s.HierarchicalData.Pgfault
+	sum.HierarchicalData.Pgmajfault += s.HierarchicalData.Pgmajfault
+}
+
+func addNetworkStats(sum, s *NetworkStats) {
+	for _, iface := range s.Interfaces {
+		found := false
+		for i := range sum.Interfaces {
+			if sum.Interfaces[i].Name == iface.Name {
+				addInterfaceStats(&sum.Interfaces[i], &iface)
+				found = true
+				break
+			}
+		}
+		if !found {
+			sum.Interfaces = append(sum.Interfaces, iface)
+		}
+	}
+	addTcpStat(&sum.Tcp, &s.Tcp)
+	addTcpStat(&sum.Tcp6, &s.Tcp6)
+	addUdpStat(&sum.Udp, &s.Udp)
+	addUdpStat(&sum.Udp6, &s.Udp6)
+}
+
+func addInterfaceStats(sum, s *InterfaceStats) {
+	sum.RxBytes += s.RxBytes
+	sum.RxPackets += s.RxPackets
+	sum.RxErrors += s.RxErrors
+	sum.RxDropped += s.RxDropped
+	sum.TxBytes += s.TxBytes
+	sum.TxPackets += s.TxPackets
+	sum.TxErrors += s.TxErrors
+	sum.TxDropped += s.TxDropped
+}
+
+func addTcpStat(sum, s *TcpStat) {
+	sum.Established += s.Established
+	sum.SynSent += s.SynSent
+	sum.SynRecv += s.SynRecv
+	sum.FinWait1 += s.FinWait1
+	sum.FinWait2 += s.FinWait2
+	sum.TimeWait += s.TimeWait
+	sum.Close += s.Close
+	sum.CloseWait += s.CloseWait
+	sum.LastAck += s.LastAck
+	sum.Listen += s.Listen
+	sum.Closing += s.Closing
+}
+
+func addUdpStat(sum, s *UdpStat) {
+	sum.Listen += s.Listen
+	sum.Dropped += s.Dropped
+	sum.RxQueued += s.RxQueued
+	sum.TxQueued += s.TxQueued
+}
+
+// addFsStats sums usage per device. Capacity is per device and is not summed.
+func addFsStats(sum []FsStats, stats []FsStats) []FsStats {
+	for _, fs := range stats {
+		found := false
+		for i := range sum {
+			if sum[i].Device == fs.Device && sum[i].Merged == fs.Merged {
+				sum[i].Usage += fs.Usage
+				sum[i].BaseUsage += fs.BaseUsage
+				sum[i].LogUsage += fs.LogUsage
+				sum[i].UsageIncludingImage += fs.UsageIncludingImage
+				sum[i].Inodes += fs.Inodes
+				found = true
+				break
+			}
+		}
+		if !found {
+			sum = append(sum, FsStats{
+				Device:              fs.Device,
+				Type:                fs.Type,
+				Limit:               fs.Limit,
+				Usage:               fs.Usage,
+				BaseUsage:           fs.BaseUsage,
+				LogUsage:            fs.LogUsage,
+				UsageIncludingImage: fs.UsageIncludingImage,
+				Available:           fs.Available,
+				HasInodes:           fs.HasInodes,
+				Inodes:              fs.Inodes,
+				Merged:              fs.Merged,
+			})
+		}
+	}
+	return sum
+}
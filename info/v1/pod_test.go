@@ -0,0 +1,85 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAggregatePodStats(t *testing.T) {
+	now := time.Now()
+	eth0 := InterfaceStats{Name: "eth0", RxBytes: 100, TxBytes: 200}
+	// The sandbox container owns the pod's network namespace.
+	sandbox := &ContainerStats{
+		Timestamp: now.Add(-time.Second),
+		Cpu: CpuStats{
+			Usage: CpuUsage{Total: 10, User: 6, System: 4, PerCpu: []uint64{4, 6}},
+		},
+		Memory: MemoryStats{Usage: 1024, WorkingSet: 512, OomKills: 1},
+		Network: NetworkStats{
+			InterfaceStats: eth0,
+			Interfaces:     []InterfaceStats{eth0},
+			Tcp:            TcpStat{Established: 2},
+		},
+		Filesystem: []FsStats{{Device: "/dev/sda1", Limit: 1000, Usage: 10, LogUsage: 2, UsageIncludingImage: 110, Inodes: 1}},
+	}
+	// The application container joins the sandbox's network namespace, so it
+	// reports the same network stats.
+	app := &ContainerStats{
+		Timestamp: now,
+		Cpu: CpuStats{
+			Usage: CpuUsage{Total: 20, User: 15, System: 5, PerCpu: []uint64{8, 12}},
+		},
+		Memory: MemoryStats{Usage: 4096, WorkingSet: 2048, OomKills: 2},
+		Network: NetworkStats{
+			InterfaceStats: eth0,
+			Interfaces:     []InterfaceStats{eth0},
+			Tcp:            TcpStat{Established: 2},
+		},
+		Filesystem: []FsStats{{Device: "/dev/sda1", Limit: 1000, Usage: 30, LogUsage: 5, UsageIncludingImage: 130, Inodes: 3}},
+		Processes:  ProcessStats{ProcessCount: 2},
+	}
+	specs := []ContainerSpec{{HasNetwork: true}, {HasNetwork: false}}
+
+	pod, err := AggregatePodStats([]*ContainerStats{sandbox, app}, specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ContainerStats{
+		Timestamp: now,
+		Cpu: CpuStats{
+			Usage: CpuUsage{Total: 30, User: 21, System: 9, PerCpu: []uint64{12, 18}},
+		},
+		Memory: MemoryStats{Usage: 5120, WorkingSet: 2560, OomKills: 3},
+		Network: NetworkStats{
+			InterfaceStats: eth0,
+			Interfaces:     []InterfaceStats{eth0},
+			Tcp:            TcpStat{Established: 2},
+		},
+		Filesystem: []FsStats{{Device: "/dev/sda1", Limit: 1000, Usage: 40, LogUsage: 7, UsageIncludingImage: 240, Inodes: 4}},
+		Processes:  ProcessStats{ProcessCount: 2},
+	}
+	if !reflect.DeepEqual(expected, pod) {
+		t.Errorf("expected %+v, got %+v", expected, pod)
+	}
+}
+
+func TestAggregatePodStatsMismatchedSpecs(t *testing.T) {
+	if _, err := AggregatePodStats([]*ContainerStats{{}}, nil); err == nil {
+		t.Error("expected an error for mismatched stats and specs")
+	}
+}
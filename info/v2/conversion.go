@@ -76,6 +76,7 @@ func MachineStatsFromV1(cont *v1.ContainerInfo) []MachineStats {
 			}
 			last = val
 		}
+		stat.CpuTime = val.HostCpu
 		if cont.Spec.HasMemory {
 			stat.Memory = &val.Memory
 		}
@@ -128,13 +129,20 @@ func ContainerStatsFromV1(containerName string, spec *v1.ContainerSpec, stats []
 			stat.Processes = &val.Processes
 		}
 		if spec.HasFilesystem {
-			if len(val.Filesystem) == 1 {
+			// Merged views duplicate the usage of the writable layer they include.
+			var fsStats []v1.FsStats
+			for _, fs := range val.Filesystem {
+				if !fs.Merged {
+					fsStats = append(fsStats, fs)
+				}
+			}
+			if len(fsStats) == 1 {
 				stat.Filesystem = &FilesystemStats{
-					TotalUsageBytes: &val.Filesystem[0].Usage,
-					BaseUsageBytes:  &val.Filesystem[0].BaseUsage,
-					InodeUsage:      &val.Filesystem[0].Inodes,
+					TotalUsageBytes: &fsStats[0].Usage,
+					BaseUsageBytes:  &fsStats[0].BaseUsage,
+					InodeUsage:      &fsStats[0].Inodes,
 				}
-			} else if len(val.Filesystem) > 1 && containerName != "/" {
+			} else if len(fsStats) > 1 && containerName != "/" {
 				// Cannot handle multiple devices per container.
 				klog.V(4).Infof("failed to handle multiple devices for container %s. Skipping Filesystem stats", containerName)
 			}
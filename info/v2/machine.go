@@ -104,6 +104,8 @@ type MachineStats struct {
 	Cpu *v1.CpuStats `json:"cpu,omitempty"`
 	// In nanocores per second (instantaneous)
 	CpuInst *CpuInstStats `json:"cpu_inst,omitempty"`
+	// Time the CPUs spent in each mode, in nanoseconds (aggregated)
+	CpuTime *v1.HostCpuTime `json:"cpu_time,omitempty"`
 	// Memory statistics
 	Memory *v1.MemoryStats `json:"memory,omitempty"`
 	// Network statistics
@@ -0,0 +1,100 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+
+	"k8s.io/klog"
+)
+
+// Directory the NVIDIA driver lists its GPUs in, one directory per GPU.
+const nvidiaGPUsDir = "/proc/driver/nvidia/gpus"
+
+// GPUEnumerator lists the GPUs of the machine, e.g. using NVML.
+type GPUEnumerator interface {
+	GetGPUs() ([]info.AcceleratorInfo, error)
+}
+
+// GetGPUInfo returns the NVIDIA GPUs of the machine as listed by enumerator.
+// When enumerator is nil or fails, for example because NVML is not installed,
+// the GPUs are read from the NVIDIA driver's files in /proc under rootFs,
+// which don't include the GPU memory.
+func GetGPUInfo(enumerator GPUEnumerator, rootFs string) []info.AcceleratorInfo {
+	if enumerator != nil {
+		gpus, err := enumerator.GetGPUs()
+		if err == nil {
+			return gpus
+		}
+		klog.V(4).Infof("Failed to enumerate GPUs, falling back to %s: %v", nvidiaGPUsDir, err)
+	}
+	gpus, err := getProcGPUs(filepath.Join(rootFs, nvidiaGPUsDir))
+	if err != nil {
+		klog.V(4).Infof("Failed to read GPUs from %s: %v", nvidiaGPUsDir, err)
+	}
+	return gpus
+}
+
+// getProcGPUs returns the GPUs listed in gpusDir, ordered by bus location.
+func getProcGPUs(gpusDir string) ([]info.AcceleratorInfo, error) {
+	files, err := filepath.Glob(filepath.Join(gpusDir, "*", "information"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	gpus := []info.AcceleratorInfo{}
+	for _, file := range files {
+		gpu, err := parseGPUInformation(file)
+		if err != nil {
+			klog.V(4).Infof("Failed to read %s: %v", file, err)
+			continue
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus, nil
+}
+
+// parseGPUInformation parses the "key: value" lines of the information file
+// of a GPU.
+func parseGPUInformation(file string) (info.AcceleratorInfo, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return info.AcceleratorInfo{}, err
+	}
+	defer f.Close()
+
+	gpu := info.AcceleratorInfo{Make: "nvidia"}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.SplitN(s.Text(), ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(fields[1])
+		switch strings.TrimSpace(fields[0]) {
+		case "Model":
+			gpu.Model = value
+		case "GPU UUID":
+			gpu.ID = value
+		}
+	}
+	return gpu, s.Err()
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
+
+type fakeGPUEnumerator struct {
+	gpus []info.AcceleratorInfo
+	err  error
+}
+
+func (e *fakeGPUEnumerator) GetGPUs() ([]info.AcceleratorInfo, error) {
+	return e.gpus, e.err
+}
+
+const testGPUInformation = `Model: 		 Tesla V100-SXM2-16GB
+IRQ:   		 33
+GPU UUID: 	 %s
+Video BIOS: 	 88.00.4f.00.09
+Bus Type: 	 PCIe
+DMA Size: 	 47 bits
+DMA Mask: 	 0x7fffffffffff
+Bus Location: 	 %s
+Device Minor: 	 %d
+Blacklisted:	 No
+`
+
+// newTestRootFs returns a root filesystem with the NVIDIA driver files of
+// two GPUs.
+func newTestRootFs(t *testing.T) string {
+	rootFs, err := ioutil.TempDir("", "rootfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for minor, uuid := range []string{"GPU-0f8a1c55-2b7e-4f43-a6b2-0c1d3e5f7a9b", "GPU-9d2e4b61-7c3a-4e58-b1f0-2a4c6e8d0b13"} {
+		busLocation := fmt.Sprintf("0000:00:%02x.0", 0x1e+minor)
+		dir := filepath.Join(rootFs, nvidiaGPUsDir, busLocation)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		content := fmt.Sprintf(testGPUInformation, uuid, busLocation, minor)
+		if err := ioutil.WriteFile(filepath.Join(dir, "information"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rootFs
+}
+
+func TestGetGPUInfoFromEnumerator(t *testing.T) {
+	rootFs := newTestRootFs(t)
+	defer os.RemoveAll(rootFs)
+
+	expected := []info.AcceleratorInfo{{Make: "nvidia", Model: "Tesla V100-SXM2-16GB", ID: "GPU-0f8a1c55-2b7e-4f43-a6b2-0c1d3e5f7a9b", MemoryTotal: 16 << 30}}
+	gpus := GetGPUInfo(&fakeGPUEnumerator{gpus: expected}, rootFs)
+	if !reflect.DeepEqual(gpus, expected) {
+		t.Errorf("expected GPUs %+v, got %+v", expected, gpus)
+	}
+}
+
+func TestGetGPUInfoFallback(t *testing.T) {
+	rootFs := newTestRootFs(t)
+	defer os.RemoveAll(rootFs)
+
+	expected := []info.AcceleratorInfo{
+		{Make: "nvidia", Model: "Tesla V100-SXM2-16GB", ID: "GPU-0f8a1c55-2b7e-4f43-a6b2-0c1d3e5f7a9b"},
+		{Make: "nvidia", Model: "Tesla V100-SXM2-16GB", ID: "GPU-9d2e4b61-7c3a-4e58-b1f0-2a4c6e8d0b13"},
+	}
+	for _, enumerator := range []GPUEnumerator{nil, &fakeGPUEnumerator{err: fmt.Errorf("NVML is not available")}} {
+		gpus := GetGPUInfo(enumerator, rootFs)
+		if !reflect.DeepEqual(gpus, expected) {
+			t.Errorf("expected GPUs %+v, got %+v", expected, gpus)
+		}
+	}
+}
+
+func TestGetGPUInfoNoGPUs(t *testing.T) {
+	gpus := GetGPUInfo(nil, "./testdata/does-not-exist")
+	if len(gpus) != 0 {
+		t.Errorf("expected no GPUs, got %+v", gpus)
+	}
+}
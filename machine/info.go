@@ -19,6 +19,7 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -36,17 +37,30 @@ import (
 )
 
 const hugepagesDirectory = "/sys/kernel/mm/hugepages/"
+const nodeDirectory = "/sys/devices/system/node/"
 
 var machineIdFilePath = flag.String("machine_id_file", "/etc/machine-id,/var/lib/dbus/machine-id", "Comma-separated list of files to check for machine-id. Use the first one that exists.")
 var bootIdFilePath = flag.String("boot_id_file", "/proc/sys/kernel/random/boot_id", "Comma-separated list of files to check for boot-id. Use the first one that exists.")
 
-func getInfoFromFiles(filePaths string) string {
+// maxSymlinks is the maximum number of symbolic links followed when resolving
+// a path under the root filesystem.
+const maxSymlinks = 40
+
+// getInfoFromFiles returns the trimmed contents of the first readable file of
+// the comma-separated filePaths under rootFs.
+func getInfoFromFiles(rootFs, filePaths string) string {
 	if len(filePaths) == 0 {
 		return ""
 	}
 	for _, file := range strings.Split(filePaths, ",") {
-		id, err := ioutil.ReadFile(file)
+		path, err := resolveInRootFs(rootFs, file)
+		if err != nil {
+			klog.V(4).Infof("Failed to resolve %q under %q: %v", file, rootFs, err)
+			continue
+		}
+		id, err := ioutil.ReadFile(path)
 		if err == nil {
+			klog.V(2).Infof("Using %q for the contents of %q", path, file)
 			return strings.TrimSpace(string(id))
 		}
 	}
@@ -54,24 +68,98 @@ func getInfoFromFiles(filePaths string) string {
 	return ""
 }
 
+// resolveInRootFs returns the path of file under rootFs, following the
+// symbolic links of its components as if rootFs was the root directory, so
+// that absolute link targets don't escape it.
+func resolveInRootFs(rootFs, file string) (string, error) {
+	resolved := "/"
+	remaining := strings.Split(file, "/")
+	links := 0
+	for len(remaining) > 0 {
+		component := remaining[0]
+		remaining = remaining[1:]
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, component)
+		target, err := os.Readlink(filepath.Join(rootFs, next))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return "", err
+			}
+			// Not a symbolic link.
+			resolved = next
+			continue
+		}
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many symbolic links in %q", file)
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		remaining = append(strings.Split(target, "/"), remaining...)
+	}
+	return filepath.Join(rootFs, resolved), nil
+}
+
 // GetHugePagesInfo returns information about pre-allocated huge pages
 func GetHugePagesInfo() ([]info.HugePagesInfo, error) {
+	return getHugePagesInfo(hugepagesDirectory)
+}
+
+// GetHugePagesInfoPerNode returns information about the huge pages
+// pre-allocated on each NUMA node, keyed by node id.
+func GetHugePagesInfoPerNode() (map[int][]info.HugePagesInfo, error) {
+	return getHugePagesInfoPerNode(nodeDirectory)
+}
+
+func getHugePagesInfoPerNode(nodeDir string) (map[int][]info.HugePagesInfo, error) {
+	nodes, err := filepath.Glob(filepath.Join(nodeDir, "node*"))
+	if err != nil {
+		return nil, err
+	}
+	hugePagesInfo := make(map[int][]info.HugePagesInfo, len(nodes))
+	for _, node := range nodes {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(node), "node"))
+		if err != nil {
+			// Not a node directory.
+			continue
+		}
+		nodeHugePagesInfo, err := getHugePagesInfo(filepath.Join(node, "hugepages"))
+		if err != nil {
+			return nil, err
+		}
+		hugePagesInfo[id] = nodeHugePagesInfo
+	}
+	return hugePagesInfo, nil
+}
+
+// getHugePagesInfo reads the number of huge pages of each size from the
+// hugepages-<size>kB directories in dir.
+func getHugePagesInfo(dir string) ([]info.HugePagesInfo, error) {
 	var hugePagesInfo []info.HugePagesInfo
-	files, err := ioutil.ReadDir(hugepagesDirectory)
+	files, err := ioutil.ReadDir(dir)
 	if err != nil {
 		// treat as non-fatal since kernels and machine can be
 		// configured to disable hugepage support
 		return hugePagesInfo, nil
 	}
 	for _, st := range files {
-		nameArray := strings.Split(st.Name(), "-")
-		pageSizeArray := strings.Split(nameArray[1], "kB")
-		pageSize, err := strconv.ParseUint(string(pageSizeArray[0]), 10, 64)
+		if !strings.HasPrefix(st.Name(), "hugepages-") || !strings.HasSuffix(st.Name(), "kB") {
+			continue
+		}
+		pageSize, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(st.Name(), "hugepages-"), "kB"), 10, 64)
 		if err != nil {
 			return hugePagesInfo, err
 		}
 
-		numFile := hugepagesDirectory + st.Name() + "/nr_hugepages"
+		numFile := filepath.Join(dir, st.Name(), "nr_hugepages")
 		val, err := ioutil.ReadFile(numFile)
 		if err != nil {
 			return hugePagesInfo, err
@@ -93,7 +181,9 @@ func GetHugePagesInfo() ([]info.HugePagesInfo, error) {
 	return hugePagesInfo, nil
 }
 
-func Info(sysFs sysfs.SysFs, fsInfo fs.FsInfo, inHostNamespace bool) (*info.MachineInfo, error) {
+// Info returns information about the machine. The GPUs are listed by
+// gpuEnumerator, they are not reported at all if it is nil.
+func Info(sysFs sysfs.SysFs, fsInfo fs.FsInfo, gpuEnumerator GPUEnumerator, inHostNamespace bool) (*info.MachineInfo, error) {
 	rootFs := "/"
 	if !inHostNamespace {
 		rootFs = "/rootfs"
@@ -103,24 +193,27 @@ func Info(sysFs sysfs.SysFs, fsInfo fs.FsInfo, inHostNamespace bool) (*info.Mach
 	if err != nil {
 		return nil, err
 	}
-	clockSpeed, err := GetClockSpeed(cpuinfo)
+	clockSpeed, err := GetClockSpeed(cpuinfo, rootFs)
 	if err != nil {
 		return nil, err
 	}
 
+	cpuVendor, cpuModelName, cpuStepping := GetCpuModel(cpuinfo)
+	virtualization := GetVirtualization(rootFs, cpuinfo)
+
 	memoryCapacity, err := GetMachineMemoryCapacity()
 	if err != nil {
 		return nil, err
 	}
 
-	hugePagesInfo, err := GetHugePagesInfo()
+	swapCapacity, err := GetMachineSwapCapacity(rootFs)
 	if err != nil {
 		return nil, err
 	}
 
-	filesystems, err := fsInfo.GetGlobalFsInfo()
+	hugePagesInfo, err := GetHugePagesInfo()
 	if err != nil {
-		klog.Errorf("Failed to get global filesystem information: %v", err)
+		return nil, err
 	}
 
 	diskMap, err := sysinfo.GetBlockDeviceInfo(sysFs)
@@ -137,6 +230,18 @@ func Info(sysFs sysfs.SysFs, fsInfo fs.FsInfo, inHostNamespace bool) (*info.Mach
 	if err != nil {
 		klog.Errorf("Failed to get topology information: %v", err)
 	}
+	numCores = numCoresWithFallback(numCores, err, string(cpuinfo))
+	nodeHugePagesInfo, err := GetHugePagesInfoPerNode()
+	if err != nil {
+		klog.Errorf("Failed to get per node huge pages information: %v", err)
+	}
+	addHugePagesToTopology(topology, nodeHugePagesInfo)
+	addMemoryToTopology(topology, filepath.Join(rootFs, nodeDirectory))
+
+	var accelerators []info.AcceleratorInfo
+	if gpuEnumerator != nil {
+		accelerators = GetGPUInfo(gpuEnumerator, rootFs)
+	}
 
 	systemUUID, err := sysinfo.GetSystemUUID(sysFs)
 	if err != nil {
@@ -150,30 +255,79 @@ func Info(sysFs sysfs.SysFs, fsInfo fs.FsInfo, inHostNamespace bool) (*info.Mach
 
 	machineInfo := &info.MachineInfo{
 		NumCores:       numCores,
+		NumSockets:     GetNumSockets(cpuinfo),
 		CpuFrequency:   clockSpeed,
+		CpuVendor:      cpuVendor,
+		CpuModelName:   cpuModelName,
+		CpuStepping:    cpuStepping,
 		MemoryCapacity: memoryCapacity,
+		SwapCapacity:   swapCapacity,
 		HugePages:      hugePagesInfo,
 		DiskMap:        diskMap,
 		NetworkDevices: netDevices,
 		Topology:       topology,
-		MachineID:      getInfoFromFiles(filepath.Join(rootFs, *machineIdFilePath)),
+		MachineID:      getInfoFromFiles(rootFs, *machineIdFilePath),
 		SystemUUID:     systemUUID,
-		BootID:         getInfoFromFiles(filepath.Join(rootFs, *bootIdFilePath)),
+		BootID:         getInfoFromFiles(rootFs, *bootIdFilePath),
 		CloudProvider:  cloudProvider,
 		InstanceType:   instanceType,
 		InstanceID:     instanceID,
+		Virtualization: virtualization,
+		Accelerators:   accelerators,
+		Filesystems:    getFilesystems(fsInfo),
 	}
 
+	return machineInfo, nil
+}
+
+// getFilesystems returns the filesystems of the machine, nil if they can't
+// be determined.
+func getFilesystems(fsInfo fs.FsInfo) []info.FsInfo {
+	filesystems, err := fsInfo.GetGlobalFsInfo()
+	if err != nil {
+		klog.Errorf("Failed to get global filesystem information: %v", err)
+	}
+
+	var fsInfos []info.FsInfo
 	for i := range filesystems {
 		fs := filesystems[i]
 		inodes := uint64(0)
 		if fs.Inodes != nil {
 			inodes = *fs.Inodes
 		}
-		machineInfo.Filesystems = append(machineInfo.Filesystems, info.FsInfo{Device: fs.Device, DeviceMajor: uint64(fs.Major), DeviceMinor: uint64(fs.Minor), Type: fs.Type.String(), Capacity: fs.Capacity, Inodes: inodes, HasInodes: fs.Inodes != nil})
+		fsInfos = append(fsInfos, info.FsInfo{Device: fs.Device, DeviceMajor: uint64(fs.Major), DeviceMinor: uint64(fs.Minor), Type: fs.Type.String(), Capacity: fs.Capacity, Inodes: inodes, HasInodes: fs.Inodes != nil, ReadOnly: fs.ReadOnly, MountOptions: fs.MountOptions, IsBacked: fs.IsBacked})
 	}
+	return fsInfos
+}
 
-	return machineInfo, nil
+// addMemoryToTopology sets the memory capacity of each node of the topology
+// from the node's meminfo in nodeDir. Nodes whose meminfo can't be read get
+// zero memory.
+func addMemoryToTopology(topology []info.Node, nodeDir string) {
+	for i := range topology {
+		memory, err := getNodeMemory(nodeDir, topology[i].Id)
+		if err != nil {
+			klog.V(4).Infof("Failed to get memory capacity of node %d: %v", topology[i].Id, err)
+		}
+		topology[i].Memory = memory
+	}
+}
+
+// getNodeMemory returns the total memory of a node from
+// <nodeDir>/node<id>/meminfo.
+func getNodeMemory(nodeDir string, id int) (uint64, error) {
+	out, err := ioutil.ReadFile(filepath.Join(nodeDir, fmt.Sprintf("node%d", id), "meminfo"))
+	if err != nil {
+		return 0, err
+	}
+	return parseCapacity(out, memoryCapacityRegexp)
+}
+
+// addHugePagesToTopology sets the huge pages allocated on each node of the topology.
+func addHugePagesToTopology(topology []info.Node, hugePagesInfo map[int][]info.HugePagesInfo) {
+	for i := range topology {
+		topology[i].HugePages = hugePagesInfo[topology[i].Id]
+	}
 }
 
 func ContainerOsVersion() string {
@@ -0,0 +1,130 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"sync"
+
+	"github.com/matthewygf/cadvisor/fs"
+	info "github.com/matthewygf/cadvisor/info/v1"
+	"github.com/matthewygf/cadvisor/utils/sysfs"
+
+	"k8s.io/klog"
+)
+
+// InfoCache caches the machine information returned by Info.
+//
+// Most of the machine information does not change after boot and is computed
+// once: the cpus and topology, disks, network devices, accelerators, machine,
+// system and boot ids, cloud identity and virtualization. The memory and swap
+// capacity, the filesystems and the huge pages, also those of each topology
+// node, are read again on every call of Info. A failed computation is not
+// cached, it is tried again by the next call of Info. Invalidate forces the
+// static parts to be computed again, e.g. after a disk was hotplugged.
+type InfoCache struct {
+	lock   sync.Mutex
+	cached *info.MachineInfo
+
+	// Returns the complete machine information.
+	info func() (*info.MachineInfo, error)
+	// Return the volatile parts of the machine information.
+	memory      func() (memoryCapacity uint64, swapCapacity uint64, err error)
+	filesystems func() []info.FsInfo
+	hugePages   func() ([]info.HugePagesInfo, map[int][]info.HugePagesInfo, error)
+}
+
+// NewInfoCache returns an InfoCache of the machine information returned by
+// Info for the given arguments.
+func NewInfoCache(sysFs sysfs.SysFs, fsInfo fs.FsInfo, gpuEnumerator GPUEnumerator, inHostNamespace bool) *InfoCache {
+	rootFs := "/"
+	if !inHostNamespace {
+		rootFs = "/rootfs"
+	}
+	return &InfoCache{
+		info: func() (*info.MachineInfo, error) {
+			return Info(sysFs, fsInfo, gpuEnumerator, inHostNamespace)
+		},
+		memory: func() (uint64, uint64, error) {
+			memoryCapacity, err := GetMachineMemoryCapacity()
+			if err != nil {
+				return 0, 0, err
+			}
+			swapCapacity, err := GetMachineSwapCapacity(rootFs)
+			if err != nil {
+				return 0, 0, err
+			}
+			return memoryCapacity, swapCapacity, nil
+		},
+		filesystems: func() []info.FsInfo {
+			return getFilesystems(fsInfo)
+		},
+		hugePages: func() ([]info.HugePagesInfo, map[int][]info.HugePagesInfo, error) {
+			hugePagesInfo, err := GetHugePagesInfo()
+			if err != nil {
+				return nil, nil, err
+			}
+			nodeHugePagesInfo, err := GetHugePagesInfoPerNode()
+			if err != nil {
+				klog.Errorf("Failed to get per node huge pages information: %v", err)
+			}
+			return hugePagesInfo, nodeHugePagesInfo, nil
+		},
+	}
+}
+
+// Info returns the machine information, computing its static parts only if
+// they are not cached yet. The slices and maps of the returned information
+// are shared with the cache and must not be modified.
+func (c *InfoCache) Info() (*info.MachineInfo, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.cached == nil {
+		machineInfo, err := c.info()
+		if err != nil {
+			return nil, err
+		}
+		c.cached = machineInfo
+		cached := *c.cached
+		return &cached, nil
+	}
+
+	memoryCapacity, swapCapacity, err := c.memory()
+	if err != nil {
+		return nil, err
+	}
+	hugePagesInfo, nodeHugePagesInfo, err := c.hugePages()
+	if err != nil {
+		return nil, err
+	}
+	machineInfo := *c.cached
+	machineInfo.MemoryCapacity = memoryCapacity
+	machineInfo.SwapCapacity = swapCapacity
+	machineInfo.Filesystems = c.filesystems()
+	machineInfo.HugePages = hugePagesInfo
+	// Copy the nodes so that the cached ones keep their huge pages.
+	machineInfo.Topology = append([]info.Node(nil), c.cached.Topology...)
+	addHugePagesToTopology(machineInfo.Topology, nodeHugePagesInfo)
+	return &machineInfo, nil
+}
+
+// Invalidate drops the cached machine information, the next call of Info
+// computes all of it again.
+func (c *InfoCache) Invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.cached = nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
+
+func newTestInfoCache(computed *int, filesystems []info.FsInfo, hugePages map[int][]info.HugePagesInfo) *InfoCache {
+	return &InfoCache{
+		info: func() (*info.MachineInfo, error) {
+			*computed++
+			return &info.MachineInfo{
+				NumCores: 2,
+				Topology: []info.Node{{Id: 0}, {Id: 1}},
+			}, nil
+		},
+		memory: func() (uint64, uint64, error) {
+			return 4096, 1024, nil
+		},
+		filesystems: func() []info.FsInfo {
+			return filesystems
+		},
+		hugePages: func() ([]info.HugePagesInfo, map[int][]info.HugePagesInfo, error) {
+			return []info.HugePagesInfo{{PageSize: 2048, NumPages: 2}}, hugePages, nil
+		},
+	}
+}
+
+func TestInfoCache(t *testing.T) {
+	computed := 0
+	filesystems := []info.FsInfo{{Device: "/dev/sda1", Capacity: 1024}}
+	nodeHugePages := map[int][]info.HugePagesInfo{
+		1: {{PageSize: 2048, NumPages: 2}},
+	}
+	cache := newTestInfoCache(&computed, filesystems, nodeHugePages)
+
+	if _, err := cache.Info(); err != nil {
+		t.Fatalf("failed to get machine info: %v", err)
+	}
+	machineInfo, err := cache.Info()
+	if err != nil {
+		t.Fatalf("failed to get machine info: %v", err)
+	}
+	if computed != 1 {
+		t.Errorf("expected the machine info to be computed once, it was computed %d times", computed)
+	}
+	if machineInfo.NumCores != 2 {
+		t.Errorf("expected 2 cores, found %d", machineInfo.NumCores)
+	}
+	if machineInfo.MemoryCapacity != 4096 || machineInfo.SwapCapacity != 1024 {
+		t.Errorf("expected memory capacity 4096 and swap capacity 1024, found %d and %d", machineInfo.MemoryCapacity, machineInfo.SwapCapacity)
+	}
+	if !reflect.DeepEqual(machineInfo.Filesystems, filesystems) {
+		t.Errorf("expected filesystems %+v, found %+v", filesystems, machineInfo.Filesystems)
+	}
+	expectedHugePages := []info.HugePagesInfo{{PageSize: 2048, NumPages: 2}}
+	if !reflect.DeepEqual(machineInfo.HugePages, expectedHugePages) {
+		t.Errorf("expected huge pages %+v, found %+v", expectedHugePages, machineInfo.HugePages)
+	}
+	if machineInfo.Topology[0].HugePages != nil {
+		t.Errorf("expected no huge pages on node 0, found %+v", machineInfo.Topology[0].HugePages)
+	}
+	if !reflect.DeepEqual(machineInfo.Topology[1].HugePages, nodeHugePages[1]) {
+		t.Errorf("expected huge pages %+v on node 1, found %+v", nodeHugePages[1], machineInfo.Topology[1].HugePages)
+	}
+	if cache.cached.Topology[1].HugePages != nil {
+		t.Errorf("expected the cached topology to be left unchanged, found %+v", cache.cached.Topology[1])
+	}
+
+	cache.Invalidate()
+	if _, err := cache.Info(); err != nil {
+		t.Fatalf("failed to get machine info: %v", err)
+	}
+	if computed != 2 {
+		t.Errorf("expected the machine info to be computed again after invalidation, it was computed %d times", computed)
+	}
+}
+
+func TestInfoCacheFailure(t *testing.T) {
+	computed := 0
+	cache := newTestInfoCache(&computed, nil, nil)
+	compute := cache.info
+	cache.info = func() (*info.MachineInfo, error) {
+		if computed == 0 {
+			computed++
+			return nil, fmt.Errorf("failed to read cpuinfo")
+		}
+		return compute()
+	}
+
+	if _, err := cache.Info(); err == nil {
+		t.Fatalf("expected an error")
+	}
+	machineInfo, err := cache.Info()
+	if err != nil {
+		t.Fatalf("failed to get machine info: %v", err)
+	}
+	if computed != 2 || machineInfo.NumCores != 2 {
+		t.Errorf("expected the machine info to be computed again after a failure, it was computed %d times", computed)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newMachineIdRootFs(t *testing.T) string {
+	rootFs, err := ioutil.TempDir("", "rootfs")
+	if err != nil {
+		t.Fatalf("failed to create temporary rootfs: %v", err)
+	}
+	for _, dir := range []string{"etc", "usr/lib", "var/lib/dbus"} {
+		if err := os.MkdirAll(filepath.Join(rootFs, dir), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(rootFs, "usr/lib/machine-id"), []byte("0123456789abcdef\n"), 0644); err != nil {
+		t.Fatalf("failed to write machine-id: %v", err)
+	}
+	return rootFs
+}
+
+func TestGetInfoFromFilesAbsoluteSymlink(t *testing.T) {
+	rootFs := newMachineIdRootFs(t)
+	defer os.RemoveAll(rootFs)
+	// The target only exists under the rootfs.
+	if err := os.Symlink("/usr/lib/machine-id", filepath.Join(rootFs, "etc/machine-id")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	id := getInfoFromFiles(rootFs, "/etc/machine-id")
+	if id != "0123456789abcdef" {
+		t.Errorf("expected machine id %q, found %q", "0123456789abcdef", id)
+	}
+}
+
+func TestGetInfoFromFilesRelativeSymlink(t *testing.T) {
+	rootFs := newMachineIdRootFs(t)
+	defer os.RemoveAll(rootFs)
+	if err := os.Symlink("../../../usr/lib/machine-id", filepath.Join(rootFs, "var/lib/dbus/machine-id")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	id := getInfoFromFiles(rootFs, "/var/lib/dbus/machine-id")
+	if id != "0123456789abcdef" {
+		t.Errorf("expected machine id %q, found %q", "0123456789abcdef", id)
+	}
+}
+
+func TestGetInfoFromFilesFallback(t *testing.T) {
+	rootFs := newMachineIdRootFs(t)
+	defer os.RemoveAll(rootFs)
+	if err := os.Symlink("/run/machine-id", filepath.Join(rootFs, "etc/machine-id")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	if err := os.Symlink("/usr/lib/machine-id", filepath.Join(rootFs, "var/lib/dbus/machine-id")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	id := getInfoFromFiles(rootFs, "/etc/machine-id,/var/lib/dbus/machine-id")
+	if id != "0123456789abcdef" {
+		t.Errorf("expected machine id %q, found %q", "0123456789abcdef", id)
+	}
+}
+
+func TestGetInfoFromFilesSymlinkLoop(t *testing.T) {
+	rootFs := newMachineIdRootFs(t)
+	defer os.RemoveAll(rootFs)
+	if err := os.Symlink("/etc/machine-id", filepath.Join(rootFs, "etc/machine-id")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	id := getInfoFromFiles(rootFs, "/etc/machine-id")
+	if id != "" {
+		t.Errorf("expected no machine id, found %q", id)
+	}
+}
@@ -17,6 +17,7 @@ package machine
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -45,21 +46,33 @@ var (
 	cpuClockSpeedMHz     = regexp.MustCompile(`(?:cpu MHz|clock)\s*:\s*([0-9]+\.[0-9]+)(?:MHz)?`)
 	memoryCapacityRegexp = regexp.MustCompile(`MemTotal:\s*([0-9]+) kB`)
 	swapCapacityRegexp   = regexp.MustCompile(`SwapTotal:\s*([0-9]+) kB`)
+	cpuFlagsRegexp       = regexp.MustCompile(`(?m)^flags\s*:(.*)$`)
+	physicalIdRegexp     = regexp.MustCompile(`(?m)^physical id\s*:\s*([0-9]+)$`)
 )
 
 const maxFreqFile = "/sys/devices/system/cpu/cpu0/cpufreq/cpuinfo_max_freq"
+const deviceTreeCpusPath = "/proc/device-tree/cpus"
 const cpuBusPath = "/sys/bus/cpu/devices/"
 
-// GetClockSpeed returns the CPU clock speed, given a []byte formatted as the /proc/cpuinfo file.
-func GetClockSpeed(procInfo []byte) (uint64, error) {
-	// s390/s390x, aarch64 and arm32 changes
-	if isSystemZ() || isAArch64() || isArm32() {
+// GetClockSpeed returns the CPU clock speed, given a []byte formatted as the /proc/cpuinfo file
+// and the root of the host's filesystem.
+func GetClockSpeed(procInfo []byte, rootFs string) (uint64, error) {
+	// s390/s390x changes
+	if isSystemZ() {
 		return 0, nil
 	}
+	return getClockSpeed(procInfo, rootFs)
+}
 
+// getClockSpeed returns the CPU clock speed in kHz, read from the max cpu
+// frequency under rootFs, the cpu MHz of procInfo or the clock frequency of
+// the device tree under rootFs, in this order. It returns 0 if none of them
+// is available.
+func getClockSpeed(procInfo []byte, rootFs string) (uint64, error) {
 	// First look through sys to find a max supported cpu frequency.
-	if utils.FileExists(maxFreqFile) {
-		val, err := ioutil.ReadFile(maxFreqFile)
+	maxFreqPath := filepath.Join(rootFs, maxFreqFile)
+	if utils.FileExists(maxFreqPath) {
+		val, err := ioutil.ReadFile(maxFreqPath)
 		if err != nil {
 			return 0, err
 		}
@@ -70,18 +83,133 @@ func GetClockSpeed(procInfo []byte) (uint64, error) {
 		}
 		return maxFreq, nil
 	}
-	// Fall back to /proc/cpuinfo
+	// Fall back to /proc/cpuinfo, which has no clock speed on ARM.
 	matches := cpuClockSpeedMHz.FindSubmatch(procInfo)
-	if len(matches) != 2 {
-		return 0, fmt.Errorf("could not detect clock speed from output: %q", string(procInfo))
+	if len(matches) == 2 {
+		speed, err := strconv.ParseFloat(string(matches[1]), 64)
+		if err != nil {
+			return 0, err
+		}
+		// Convert to kHz
+		return uint64(speed * 1000), nil
 	}
+	// As a last resort use the clock frequency of the device tree.
+	if speed, ok := getDeviceTreeClockSpeed(filepath.Join(rootFs, deviceTreeCpusPath)); ok {
+		return speed, nil
+	}
+	klog.V(2).Infof("Could not detect the cpu clock speed, reporting 0")
+	return 0, nil
+}
 
-	speed, err := strconv.ParseFloat(string(matches[1]), 64)
+// getDeviceTreeClockSpeed returns the clock frequency in kHz of the first cpu
+// in the device tree directory cpusPath that has one.
+func getDeviceTreeClockSpeed(cpusPath string) (uint64, bool) {
+	files, err := filepath.Glob(filepath.Join(cpusPath, "cpu@*", "clock-frequency"))
 	if err != nil {
-		return 0, err
+		return 0, false
+	}
+	for _, file := range files {
+		val, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		// The frequency is a big-endian cell or pair of cells, in Hz.
+		switch len(val) {
+		case 4:
+			return uint64(binary.BigEndian.Uint32(val)) / 1000, true
+		case 8:
+			return binary.BigEndian.Uint64(val) / 1000, true
+		}
+	}
+	return 0, false
+}
+
+// Names of the ARM CPU implementers, by the "CPU implementer" code in /proc/cpuinfo.
+var armImplementers = map[uint64]string{
+	0x41: "ARM",
+	0x42: "Broadcom",
+	0x43: "Cavium",
+	0x46: "Fujitsu",
+	0x48: "HiSilicon",
+	0x4e: "NVIDIA",
+	0x50: "APM",
+	0x51: "Qualcomm",
+	0x53: "Samsung",
+	0x56: "Marvell",
+	0x61: "Apple",
+	0x69: "Intel",
+	0xc0: "Ampere",
+}
+
+// Names of the CPU parts designed by ARM, by the "CPU part" code in /proc/cpuinfo.
+var armParts = map[uint64]string{
+	0xd03: "Cortex-A53",
+	0xd04: "Cortex-A35",
+	0xd05: "Cortex-A55",
+	0xd07: "Cortex-A57",
+	0xd08: "Cortex-A72",
+	0xd09: "Cortex-A73",
+	0xd0a: "Cortex-A75",
+	0xd0b: "Cortex-A76",
+	0xd0c: "Neoverse-N1",
+	0xd0d: "Cortex-A77",
+	0xd40: "Neoverse-V1",
+	0xd41: "Cortex-A78",
+	0xd49: "Neoverse-N2",
+}
+
+// GetCpuModel returns the vendor, model name and stepping of the first CPU,
+// given a []byte formatted as the /proc/cpuinfo file. ARM CPUs, which do not
+// report a model name, are identified by their implementer and part codes.
+// Fields that cannot be determined are empty.
+func GetCpuModel(procInfo []byte) (vendor, modelName, stepping string) {
+	var implementer, part string
+	for _, line := range strings.Split(string(procInfo), "\n") {
+		// Only look at the first processor.
+		if strings.TrimSpace(line) == "" && (vendor != "" || modelName != "" || implementer != "") {
+			break
+		}
+		fields := strings.SplitN(line, ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(fields[1])
+		switch strings.TrimSpace(fields[0]) {
+		case "vendor_id":
+			vendor = value
+		case "model name":
+			modelName = value
+		case "stepping":
+			stepping = value
+		case "CPU implementer":
+			implementer = value
+		case "CPU part":
+			part = value
+		case "CPU revision":
+			if stepping == "" {
+				stepping = value
+			}
+		}
 	}
-	// Convert to kHz
-	return uint64(speed * 1000), nil
+	if implementer == "" {
+		return vendor, modelName, stepping
+	}
+
+	implementerCode, err := strconv.ParseUint(implementer, 0, 64)
+	if err != nil {
+		return vendor, modelName, stepping
+	}
+	if vendor == "" {
+		vendor = armImplementers[implementerCode]
+	}
+	if modelName == "" && part != "" {
+		if partCode, err := strconv.ParseUint(part, 0, 64); err == nil && implementerCode == 0x41 && armParts[partCode] != "" {
+			modelName = armParts[partCode]
+		} else {
+			modelName = fmt.Sprintf("%s part %s", vendor, part)
+		}
+	}
+	return vendor, modelName, stepping
 }
 
 // GetMachineMemoryCapacity returns the machine's total memory from /proc/meminfo.
@@ -99,19 +227,123 @@ func GetMachineMemoryCapacity() (uint64, error) {
 	return memoryCapacity, err
 }
 
-// GetMachineSwapCapacity returns the machine's total swap from /proc/meminfo.
-// Returns the total swap capacity as an uint64 (number of bytes).
-func GetMachineSwapCapacity() (uint64, error) {
-	out, err := ioutil.ReadFile("/proc/meminfo")
+// GetMachineSwapCapacity returns the machine's total swap from /proc/meminfo
+// under rootFs. Returns the total swap capacity as an uint64 (number of bytes),
+// or 0 if the kernel does not report any swap.
+func GetMachineSwapCapacity(rootFs string) (uint64, error) {
+	out, err := ioutil.ReadFile(filepath.Join(rootFs, "/proc/meminfo"))
 	if err != nil {
 		return 0, err
 	}
+	return parseSwapCapacity(out)
+}
 
-	swapCapacity, err := parseCapacity(out, swapCapacityRegexp)
-	if err != nil {
-		return 0, err
+func parseSwapCapacity(meminfo []byte) (uint64, error) {
+	// Kernels built without swap support omit SwapTotal entirely.
+	if !swapCapacityRegexp.Match(meminfo) {
+		return 0, nil
+	}
+	return parseCapacity(meminfo, swapCapacityRegexp)
+}
+
+// Virtualization types reported by GetVirtualization.
+const (
+	VirtualizationNone       = "none"
+	VirtualizationUnknown    = "unknown"
+	VirtualizationKVM        = "kvm"
+	VirtualizationQEMU       = "qemu"
+	VirtualizationVMware     = "vmware"
+	VirtualizationHyperV     = "hyperv"
+	VirtualizationXen        = "xen"
+	VirtualizationVirtualBox = "virtualbox"
+)
+
+// dmiProductVirtualization maps substrings of the DMI product name set by
+// hypervisors to the virtualization type.
+var dmiProductVirtualization = []struct {
+	product        string
+	virtualization string
+}{
+	{"KVM", VirtualizationKVM},
+	{"Google Compute Engine", VirtualizationKVM},
+	{"VMware", VirtualizationVMware},
+	{"Virtual Machine", VirtualizationHyperV},
+	{"HVM domU", VirtualizationXen},
+	{"VirtualBox", VirtualizationVirtualBox},
+	{"Standard PC", VirtualizationQEMU},
+}
+
+// GetVirtualization returns the type of hypervisor the machine runs on, or
+// VirtualizationNone on bare metal. It looks at the DMI product name and
+// /sys/hypervisor under rootFs and at the hypervisor flag of the cpus in
+// cpuinfo, formatted as /proc/cpuinfo.
+func GetVirtualization(rootFs string, cpuinfo []byte) string {
+	if product, err := ioutil.ReadFile(filepath.Join(rootFs, "/sys/class/dmi/id/product_name")); err == nil {
+		for _, p := range dmiProductVirtualization {
+			if strings.Contains(string(product), p.product) {
+				return p.virtualization
+			}
+		}
+	}
+	// Xen paravirtualized guests have no DMI information.
+	if hypervisor, err := ioutil.ReadFile(filepath.Join(rootFs, "/sys/hypervisor/type")); err == nil {
+		if t := strings.TrimSpace(string(hypervisor)); t != "" {
+			return t
+		}
+	}
+	if hasCpuFlag(cpuinfo, "hypervisor") {
+		return VirtualizationUnknown
+	}
+	return VirtualizationNone
+}
+
+// hasCpuFlag returns whether the first cpu in cpuinfo has the given flag.
+func hasCpuFlag(cpuinfo []byte, flag string) bool {
+	matches := cpuFlagsRegexp.FindSubmatch(cpuinfo)
+	if len(matches) != 2 {
+		return false
 	}
-	return swapCapacity, err
+	for _, f := range strings.Fields(string(matches[1])) {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// userHz is the unit of the times in /proc/stat, in ticks per second. It is
+// 100 on all architectures supported by Linux.
+const userHz = 100
+
+// GetCpuTime returns the time all CPUs of the machine spent in each mode,
+// given a []byte formatted as the /proc/stat file.
+func GetCpuTime(procStat []byte) (*info.HostCpuTime, error) {
+	for _, line := range strings.Split(string(procStat), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "cpu" {
+			continue
+		}
+		// Older kernels do not report all fields, they are zero.
+		var ticks [8]uint64
+		for i := 0; i < len(ticks) && i+1 < len(fields); i++ {
+			val, err := strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse cpu times %q: %v", line, err)
+			}
+			ticks[i] = val * (1e9 / userHz)
+		}
+		return &info.HostCpuTime{
+			User:    ticks[0],
+			Nice:    ticks[1],
+			System:  ticks[2],
+			Idle:    ticks[3],
+			IOWait:  ticks[4],
+			IRQ:     ticks[5],
+			SoftIRQ: ticks[6],
+			Steal:   ticks[7],
+		}, nil
+	}
+	return nil, fmt.Errorf("no cpu times in output: %q", string(procStat))
 }
 
 // parseCapacity matches a Regexp in a []byte, returning the resulting value in bytes.
@@ -216,10 +448,7 @@ func GetTopology(sysFs sysfs.SysFs, cpuinfo string) ([]info.Node, int, error) {
 			numCores++
 			if lastThread != -1 {
 				// New cpu section. Save last one.
-				nodeIdx, err := addNode(&nodes, lastNode)
-				if err != nil {
-					return nil, -1, fmt.Errorf("failed to add node %d: %v", lastNode, err)
-				}
+				nodeIdx := addNode(&nodes, lastNode)
 				nodes[nodeIdx].AddThread(lastThread, lastCore)
 				lastCore = -1
 				lastNode = -1
@@ -274,39 +503,126 @@ func GetTopology(sysFs sysfs.SysFs, cpuinfo string) ([]info.Node, int, error) {
 		}
 	}
 
-	nodeIdx, err := addNode(&nodes, lastNode)
-	if err != nil {
-		return nil, -1, fmt.Errorf("failed to add node %d: %v", lastNode, err)
-	}
+	nodeIdx := addNode(&nodes, lastNode)
 	nodes[nodeIdx].AddThread(lastThread, lastCore)
 	if numCores < 1 {
 		return nil, numCores, fmt.Errorf("could not detect any cores")
 	}
-	for idx, node := range nodes {
-		caches, err := sysinfo.GetCacheInfo(sysFs, node.Cores[0].Threads[0])
-		if err != nil {
-			klog.Errorf("failed to get cache information for node %d: %v", node.Id, err)
-			continue
+	addCachesToTopology(sysFs, nodes)
+	return nodes, numCores, nil
+}
+
+// GetNumSockets returns the number of physical CPU sockets, given a []byte
+// formatted as the /proc/cpuinfo file. Machines whose cpuinfo has no physical
+// ids, such as many single socket VMs, have one socket.
+func GetNumSockets(procInfo []byte) int {
+	sockets := map[string]bool{}
+	for _, match := range physicalIdRegexp.FindAllSubmatch(procInfo, -1) {
+		sockets[string(match[1])] = true
+	}
+	if len(sockets) == 0 {
+		return 1
+	}
+	return len(sockets)
+}
+
+// countProcessors returns the number of processors listed in cpuinfo.
+func countProcessors(cpuinfo string) int {
+	processors := 0
+	for _, line := range strings.Split(cpuinfo, "\n") {
+		if cpuRegExp.MatchString(line) {
+			processors++
+		}
+	}
+	return processors
+}
+
+// numCoresWithFallback returns the number of cores found by GetTopology or, if
+// the topology could not be determined, the number of processors in cpuinfo.
+func numCoresWithFallback(numCores int, topologyErr error, cpuinfo string) int {
+	if topologyErr == nil && numCores > 0 {
+		return numCores
+	}
+	processors := countProcessors(cpuinfo)
+	klog.Warningf("Could not get the number of cores from the topology, using the %d processors in cpuinfo", processors)
+	return processors
+}
+
+// addCachesToTopology adds the caches of the cpus to the topology: caches
+// private to a core to the core and the caches shared by the cores of a node,
+// such as L3, to the node. Caches shared by several cpus are only added once.
+func addCachesToTopology(sysFs sysfs.SysFs, nodes []info.Node) {
+	seen := map[string]bool{}
+	for idx := range nodes {
+		node := &nodes[idx]
+		numThreadsPerNode := 0
+		for _, core := range node.Cores {
+			numThreadsPerNode += len(core.Threads)
 		}
-		numThreadsPerCore := len(node.Cores[0].Threads)
-		numThreadsPerNode := len(node.Cores) * numThreadsPerCore
-		for _, cache := range caches {
-			c := info.Cache{
-				Size:  cache.Size,
-				Level: cache.Level,
-				Type:  cache.Type,
+		var lastErr error
+		for coreIdx := range node.Cores {
+			core := &node.Cores[coreIdx]
+			// All threads of a core see the same caches.
+			caches, err := sysinfo.GetCacheInfo(sysFs, core.Threads[0])
+			if err != nil {
+				lastErr = err
+				continue
 			}
-			if cache.Cpus == numThreadsPerNode && cache.Level > 2 {
-				// Add a node-level cache.
-				nodes[idx].AddNodeCache(c)
-			} else if cache.Cpus == numThreadsPerCore {
-				// Add to each core.
-				nodes[idx].AddPerCoreCache(c)
+			for _, cache := range caches {
+				c := info.Cache{
+					Size:  cache.Size,
+					Level: cache.Level,
+					Type:  cache.Type,
+				}
+				if cache.SharedCpus == nil {
+					// Without shared_cpu_list only the number of cpus
+					// sharing the cache is known.
+					if cache.Cpus == numThreadsPerNode && cache.Level > 2 {
+						key := fmt.Sprintf("node%d/%d/%s", node.Id, cache.Level, cache.Type)
+						if !seen[key] {
+							seen[key] = true
+							node.AddNodeCache(c)
+						}
+					} else if cache.Cpus == len(core.Threads) {
+						core.Caches = append(core.Caches, c)
+					}
+					continue
+				}
+				key := fmt.Sprintf("%d/%s/%v", cache.Level, cache.Type, cache.SharedCpus)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				if containsAll(core.Threads, cache.SharedCpus) {
+					core.Caches = append(core.Caches, c)
+				} else if cache.Level > 2 {
+					// Add a node-level cache.
+					node.AddNodeCache(c)
+				}
+				// Ignore caches shared by some of the cores only.
 			}
-			// Ignore unknown caches.
+		}
+		if lastErr != nil {
+			klog.Warningf("failed to get cache information for node %d: %v", node.Id, lastErr)
 		}
 	}
-	return nodes, numCores, nil
+}
+
+// containsAll returns whether all of values are in set.
+func containsAll(set []int, values []int) bool {
+	for _, v := range values {
+		found := false
+		for _, s := range set {
+			if s == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
 
 func extractValue(s string, r *regexp.Regexp) (bool, int, error) {
@@ -330,7 +646,7 @@ func findNode(nodes []info.Node, id int) (bool, int) {
 	return false, -1
 }
 
-func addNode(nodes *[]info.Node, id int) (int, error) {
+func addNode(nodes *[]info.Node, id int) int {
 	var idx int
 	if id == -1 {
 		// Some VMs don't fill topology data. Export single package.
@@ -341,21 +657,10 @@ func addNode(nodes *[]info.Node, id int) (int, error) {
 	if !ok {
 		// New node
 		node := info.Node{Id: id}
-		// Add per-node memory information.
-		meminfo := fmt.Sprintf("/sys/devices/system/node/node%d/meminfo", id)
-		out, err := ioutil.ReadFile(meminfo)
-		// Ignore if per-node info is not available.
-		if err == nil {
-			m, err := parseCapacity(out, memoryCapacityRegexp)
-			if err != nil {
-				return -1, err
-			}
-			node.Memory = uint64(m)
-		}
 		*nodes = append(*nodes, node)
 		idx = len(*nodes) - 1
 	}
-	return idx, nil
+	return idx
 }
 
 // s390/s390x changes
@@ -369,15 +674,6 @@ func getMachineArch() (string, error) {
 	return string(uname.Machine[:]), nil
 }
 
-// arm32 chanes
-func isArm32() bool {
-	arch, err := getMachineArch()
-	if err == nil {
-		return strings.Contains(arch, "arm")
-	}
-	return false
-}
-
 // aarch64 changes
 func isAArch64() bool {
 	arch, err := getMachineArch()
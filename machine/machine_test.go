@@ -0,0 +1,450 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
+
+func TestGetCpuTime(t *testing.T) {
+	testfile := "./testdata/proc_stat"
+	procStat, err := ioutil.ReadFile(testfile)
+	if err != nil {
+		t.Fatalf("unable to read input test file %s", testfile)
+	}
+	cpuTime, err := GetCpuTime(procStat)
+	if err != nil {
+		t.Fatalf("failed to get cpu time: %v", err)
+	}
+	expected := &info.HostCpuTime{
+		User:    101321530000000,
+		Nice:    2906960000000,
+		System:  30847190000000,
+		Idle:    468284830000000,
+		IOWait:  166830000000,
+		IRQ:     0,
+		SoftIRQ: 251950000000,
+		Steal:   12540000000,
+	}
+	if !reflect.DeepEqual(expected, cpuTime) {
+		t.Errorf("expected cpu time %+v, got %+v", expected, cpuTime)
+	}
+}
+
+func TestGetCpuTimeOldKernel(t *testing.T) {
+	// Kernels before 2.6.11 do not report steal time.
+	cpuTime, err := GetCpuTime([]byte("cpu  100 0 50 1000 10 0 5\n"))
+	if err != nil {
+		t.Fatalf("failed to get cpu time: %v", err)
+	}
+	expected := &info.HostCpuTime{User: 1e9, System: 5e8, Idle: 1e10, IOWait: 1e8, SoftIRQ: 5e7}
+	if !reflect.DeepEqual(expected, cpuTime) {
+		t.Errorf("expected cpu time %+v, got %+v", expected, cpuTime)
+	}
+
+	if _, err := GetCpuTime([]byte("intr 0\n")); err == nil {
+		t.Error("expected an error without cpu times")
+	}
+}
+
+func TestGetHugePagesInfoPerNode(t *testing.T) {
+	hugePagesInfo, err := getHugePagesInfoPerNode("./testdata/node")
+	if err != nil {
+		t.Fatalf("failed to get huge pages info: %v", err)
+	}
+	expected := map[int][]info.HugePagesInfo{
+		0: {{PageSize: 1048576, NumPages: 2}, {PageSize: 2048, NumPages: 512}},
+		1: {{PageSize: 1048576, NumPages: 0}, {PageSize: 2048, NumPages: 256}},
+	}
+	if !reflect.DeepEqual(expected, hugePagesInfo) {
+		t.Errorf("expected huge pages info %+v, got %+v", expected, hugePagesInfo)
+	}
+
+	topology := []info.Node{{Id: 0}, {Id: 1}, {Id: 2}}
+	addHugePagesToTopology(topology, hugePagesInfo)
+	for _, node := range topology {
+		if !reflect.DeepEqual(expected[node.Id], node.HugePages) {
+			t.Errorf("expected huge pages %+v on node %d, got %+v", expected[node.Id], node.Id, node.HugePages)
+		}
+	}
+}
+
+func TestAddMemoryToTopology(t *testing.T) {
+	// Node 2 has no meminfo and node 3 an unparsable one.
+	nodeDir, err := ioutil.TempDir("", "node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(nodeDir)
+	for id, meminfo := range map[int]string{
+		0: "Node 0 MemTotal:       16318812 kB\nNode 0 MemFree:         8712344 kB\n",
+		1: "Node 1 MemTotal:       16510076 kB\nNode 1 MemFree:        12210504 kB\n",
+		3: "Node 3 MemTotal:       unknown\n",
+	} {
+		dir := filepath.Join(nodeDir, fmt.Sprintf("node%d", id))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "meminfo"), []byte(meminfo), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	topology := []info.Node{{Id: 0}, {Id: 1}, {Id: 2}, {Id: 3}}
+	addMemoryToTopology(topology, nodeDir)
+	expected := map[int]uint64{
+		0: 16318812 * 1024,
+		1: 16510076 * 1024,
+		2: 0,
+		3: 0,
+	}
+	for _, node := range topology {
+		if node.Memory != expected[node.Id] {
+			t.Errorf("expected memory %d on node %d, got %d", expected[node.Id], node.Id, node.Memory)
+		}
+	}
+}
+
+func TestGetNodeMemory(t *testing.T) {
+	memory, err := getNodeMemory("./testdata/node", 1)
+	if err != nil {
+		t.Fatalf("failed to get node memory: %v", err)
+	}
+	if memory != 16510076*1024 {
+		t.Errorf("expected memory %d, got %d", 16510076*1024, memory)
+	}
+	if _, err := getNodeMemory("./testdata/node", 2); err == nil {
+		t.Errorf("expected an error for a missing node")
+	}
+}
+
+func TestGetHugePagesInfoNoHugePages(t *testing.T) {
+	hugePagesInfo, err := getHugePagesInfo("./testdata/does-not-exist")
+	if err != nil {
+		t.Fatalf("failed to get huge pages info: %v", err)
+	}
+	if len(hugePagesInfo) != 0 {
+		t.Errorf("expected no huge pages, got %+v", hugePagesInfo)
+	}
+}
+
+func TestGetCpuModel(t *testing.T) {
+	for _, test := range []struct {
+		name              string
+		cpuinfo           string
+		expectedVendor    string
+		expectedModelName string
+		expectedStepping  string
+	}{
+		{
+			name: "x86",
+			cpuinfo: `processor	: 0
+vendor_id	: GenuineIntel
+cpu family	: 6
+model		: 79
+model name	: Intel(R) Xeon(R) CPU E5-2673 v4 @ 2.30GHz
+stepping	: 1
+cpu MHz		: 2294.686
+
+processor	: 1
+vendor_id	: GenuineIntel
+cpu family	: 6
+model		: 85
+model name	: Intel(R) Xeon(R) Platinum 8168 CPU @ 2.70GHz
+stepping	: 4
+`,
+			expectedVendor:    "GenuineIntel",
+			expectedModelName: "Intel(R) Xeon(R) CPU E5-2673 v4 @ 2.30GHz",
+			expectedStepping:  "1",
+		},
+		{
+			name: "arm64",
+			cpuinfo: `processor	: 0
+BogoMIPS	: 243.75
+Features	: fp asimd evtstrm aes pmull sha1 sha2 crc32 atomics fphp asimdhp cpuid asimdrdm lrcpc dcpop asimddp ssbs
+CPU implementer	: 0x41
+CPU architecture: 8
+CPU variant	: 0x3
+CPU part	: 0xd0c
+CPU revision	: 1
+
+processor	: 1
+BogoMIPS	: 243.75
+CPU implementer	: 0x41
+CPU architecture: 8
+CPU variant	: 0x3
+CPU part	: 0xd0c
+CPU revision	: 1
+`,
+			expectedVendor:    "ARM",
+			expectedModelName: "Neoverse-N1",
+			expectedStepping:  "1",
+		},
+		{
+			name: "arm64 unknown part",
+			cpuinfo: `processor	: 0
+CPU implementer	: 0x43
+CPU architecture: 8
+CPU variant	: 0x1
+CPU part	: 0x0af
+CPU revision	: 2
+`,
+			expectedVendor:    "Cavium",
+			expectedModelName: "Cavium part 0x0af",
+			expectedStepping:  "2",
+		},
+		{
+			name: "arm32 with model name",
+			cpuinfo: `processor	: 0
+model name	: ARMv7 Processor rev 4 (v7l)
+BogoMIPS	: 38.40
+CPU implementer	: 0x41
+CPU architecture: 7
+CPU variant	: 0x0
+CPU part	: 0xd03
+CPU revision	: 4
+`,
+			expectedVendor:    "ARM",
+			expectedModelName: "ARMv7 Processor rev 4 (v7l)",
+			expectedStepping:  "4",
+		},
+		{
+			name:    "unknown",
+			cpuinfo: "processor\t: 0\n",
+		},
+	} {
+		vendor, modelName, stepping := GetCpuModel([]byte(test.cpuinfo))
+		if vendor != test.expectedVendor {
+			t.Errorf("%s: expected vendor %q, got %q", test.name, test.expectedVendor, vendor)
+		}
+		if modelName != test.expectedModelName {
+			t.Errorf("%s: expected model name %q, got %q", test.name, test.expectedModelName, modelName)
+		}
+		if stepping != test.expectedStepping {
+			t.Errorf("%s: expected stepping %q, got %q", test.name, test.expectedStepping, stepping)
+		}
+	}
+}
+
+func TestParseSwapCapacity(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		meminfo  string
+		expected uint64
+	}{
+		{
+			name: "with swap",
+			meminfo: `MemTotal:       32879028 kB
+MemFree:        10423640 kB
+MemAvailable:   24329148 kB
+SwapCached:        12340 kB
+SwapTotal:       2097148 kB
+SwapFree:        2084804 kB
+`,
+			expected: 2097148 * 1024,
+		},
+		{
+			name: "swap disabled",
+			meminfo: `MemTotal:       32879028 kB
+SwapCached:            0 kB
+SwapTotal:             0 kB
+SwapFree:              0 kB
+`,
+			expected: 0,
+		},
+		{
+			name: "no swap support",
+			meminfo: `MemTotal:       32879028 kB
+MemFree:        10423640 kB
+`,
+			expected: 0,
+		},
+	} {
+		swapCapacity, err := parseSwapCapacity([]byte(test.meminfo))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if swapCapacity != test.expected {
+			t.Errorf("%s: expected swap capacity %d, got %d", test.name, test.expected, swapCapacity)
+		}
+	}
+}
+
+func TestGetMachineSwapCapacity(t *testing.T) {
+	rootFs, err := ioutil.TempDir("", "swap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootFs)
+	if err := os.MkdirAll(filepath.Join(rootFs, "proc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	meminfo := "MemTotal:       32879028 kB\nSwapTotal:       1048572 kB\n"
+	if err := ioutil.WriteFile(filepath.Join(rootFs, "proc", "meminfo"), []byte(meminfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	swapCapacity, err := GetMachineSwapCapacity(rootFs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if swapCapacity != 1048572*1024 {
+		t.Errorf("expected swap capacity %d, got %d", 1048572*1024, swapCapacity)
+	}
+}
+
+func TestGetVirtualization(t *testing.T) {
+	const (
+		bareMetalCpuinfo = "processor\t: 0\nflags\t\t: fpu vme de pse tsc msr pae\n"
+		guestCpuinfo     = "processor\t: 0\nflags\t\t: fpu vme de pse tsc msr pae hypervisor lahf_lm\n"
+	)
+	for _, test := range []struct {
+		name           string
+		productName    string
+		hypervisorType string
+		cpuinfo        string
+		expected       string
+	}{
+		{"kvm", "KVM\n", "", guestCpuinfo, VirtualizationKVM},
+		{"gce", "Google Compute Engine\n", "", guestCpuinfo, VirtualizationKVM},
+		{"vmware", "VMware Virtual Platform\n", "", guestCpuinfo, VirtualizationVMware},
+		{"hyper-v", "Virtual Machine\n", "", guestCpuinfo, VirtualizationHyperV},
+		{"xen hvm", "HVM domU\n", "xen\n", guestCpuinfo, VirtualizationXen},
+		{"xen pv", "", "xen\n", guestCpuinfo, VirtualizationXen},
+		{"unknown hypervisor", "", "", guestCpuinfo, VirtualizationUnknown},
+		{"bare metal", "PowerEdge R640\n", "", bareMetalCpuinfo, VirtualizationNone},
+		{"bare metal without dmi", "", "", bareMetalCpuinfo, VirtualizationNone},
+	} {
+		rootFs, err := ioutil.TempDir("", "rootfs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := map[string]string{
+			"sys/class/dmi/id/product_name": test.productName,
+			"sys/hypervisor/type":           test.hypervisorType,
+		}
+		for name, content := range files {
+			if content == "" {
+				continue
+			}
+			file := filepath.Join(rootFs, name)
+			if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if virtualization := GetVirtualization(rootFs, []byte(test.cpuinfo)); virtualization != test.expected {
+			t.Errorf("%s: expected virtualization %q, got %q", test.name, test.expected, virtualization)
+		}
+		os.RemoveAll(rootFs)
+	}
+}
+
+func TestGetClockSpeedAArch64(t *testing.T) {
+	cpuinfo, err := ioutil.ReadFile("./testdata/cpuinfo_aarch64")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The max cpu frequency is used when cpuinfo has no clock speed.
+	clockSpeed, err := getClockSpeed(cpuinfo, "./testdata/aarch64")
+	if err != nil {
+		t.Fatalf("failed to get clock speed: %v", err)
+	}
+	if clockSpeed != 2500000 {
+		t.Errorf("expected clock speed 2500000 kHz, got %d", clockSpeed)
+	}
+
+	// Without a clock speed anywhere, 0 is returned.
+	clockSpeed, err = getClockSpeed(cpuinfo, "./testdata/does-not-exist")
+	if err != nil {
+		t.Fatalf("failed to get clock speed: %v", err)
+	}
+	if clockSpeed != 0 {
+		t.Errorf("expected clock speed 0, got %d", clockSpeed)
+	}
+}
+
+func TestGetClockSpeedDeviceTree(t *testing.T) {
+	cpuinfo, err := ioutil.ReadFile("./testdata/cpuinfo_aarch64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootFs, err := ioutil.TempDir("", "rootfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootFs)
+	cpuDir := filepath.Join(rootFs, deviceTreeCpusPath, "cpu@0")
+	if err := os.MkdirAll(cpuDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// 1.8 GHz as a big-endian cell.
+	if err := ioutil.WriteFile(filepath.Join(cpuDir, "clock-frequency"), []byte{0x6b, 0x49, 0xd2, 0x00}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	clockSpeed, err := getClockSpeed(cpuinfo, rootFs)
+	if err != nil {
+		t.Fatalf("failed to get clock speed: %v", err)
+	}
+	if clockSpeed != 1800000 {
+		t.Errorf("expected clock speed 1800000 kHz, got %d", clockSpeed)
+	}
+}
+
+func TestGetClockSpeedCpuinfo(t *testing.T) {
+	cpuinfo, err := ioutil.ReadFile("./testdata/cpuinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clockSpeed, err := getClockSpeed(cpuinfo, "./testdata/does-not-exist")
+	if err != nil {
+		t.Fatalf("failed to get clock speed: %v", err)
+	}
+	if clockSpeed != 1596000 {
+		t.Errorf("expected clock speed 1596000 kHz, got %d", clockSpeed)
+	}
+}
+
+func TestGetNumSockets(t *testing.T) {
+	for _, test := range []struct {
+		file     string
+		expected int
+	}{
+		// Two sockets with three hyperthreaded cores each.
+		{"./testdata/cpuinfo", 2},
+		// No physical id.
+		{"./testdata/cpuinfo_aarch64", 1},
+	} {
+		cpuinfo, err := ioutil.ReadFile(test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sockets := GetNumSockets(cpuinfo); sockets != test.expected {
+			t.Errorf("%s: expected %d sockets, got %d", test.file, test.expected, sockets)
+		}
+	}
+}
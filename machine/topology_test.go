@@ -15,6 +15,7 @@
 package machine
 
 import (
+	"fmt"
 	"io/ioutil"
 	"reflect"
 	"testing"
@@ -57,8 +58,6 @@ func TestTopology(t *testing.T) {
 	}
 	for i := 0; i < numNodes; i++ {
 		node := info.Node{Id: i}
-		// Copy over Memory from result. TODO(rjnagal): Use memory from fake.
-		node.Memory = topology[i].Memory
 		for j := 0; j < numCoresPerNode; j++ {
 			core := info.Core{Id: i*numCoresPerNode + j}
 			core.Caches = append(core.Caches, cache)
@@ -98,8 +97,6 @@ func TestTopologyWithSimpleCpuinfo(t *testing.T) {
 	}
 	core.Caches = append(core.Caches, cache)
 	node.Cores = append(node.Cores, core)
-	// Copy over Memory from result. TODO(rjnagal): Use memory from fake.
-	node.Memory = topology[0].Memory
 	expected := []info.Node{node}
 	if !reflect.DeepEqual(topology, expected) {
 		t.Errorf("Expected topology %+v, got %+v", expected, topology)
@@ -116,6 +113,26 @@ func TestTopologyEmptyCpuinfo(t *testing.T) {
 	}
 }
 
+func TestNumCoresFallback(t *testing.T) {
+	cpuinfo := ""
+	for i := 0; i < 8; i++ {
+		cpuinfo += fmt.Sprintf("processor\t: %d\n", i)
+	}
+	// A core id that can't be parsed fails the topology.
+	cpuinfo += "core id\t\t: 99999999999999999999\n"
+
+	_, numCores, err := GetTopology(&fakesysfs.FakeSysFs{}, cpuinfo)
+	if err == nil {
+		t.Fatalf("expected the topology to fail")
+	}
+	if numCores := numCoresWithFallback(numCores, err, cpuinfo); numCores != 8 {
+		t.Errorf("expected 8 cores from cpuinfo, found %d", numCores)
+	}
+	if numCores := numCoresWithFallback(4, nil, cpuinfo); numCores != 4 {
+		t.Errorf("expected the 4 cores of the topology, found %d", numCores)
+	}
+}
+
 func TestTopologyCoreId(t *testing.T) {
 	val, _ := getCoreIdFromCpuBus("./testdata", 0)
 	if val != 0 {
@@ -139,3 +156,111 @@ func TestTopologyNodeId(t *testing.T) {
 		t.Errorf("Expected core 1234 , found %d", val)
 	}
 }
+
+// Two sockets with two cores of two threads each.
+const testCacheCpuinfo = `processor	: 0
+physical id	: 0
+core id		: 0
+
+processor	: 1
+physical id	: 0
+core id		: 1
+
+processor	: 2
+physical id	: 1
+core id		: 0
+
+processor	: 3
+physical id	: 1
+core id		: 1
+
+processor	: 4
+physical id	: 0
+core id		: 0
+
+processor	: 5
+physical id	: 0
+core id		: 1
+
+processor	: 6
+physical id	: 1
+core id		: 0
+
+processor	: 7
+physical id	: 1
+core id		: 1
+`
+
+func TestTopologyCaches(t *testing.T) {
+	sysFs := &fakesysfs.FakeSysFs{}
+	for cpu := 0; cpu < 8; cpu++ {
+		core := cpu % 4
+		socket := core / 2
+		sysFs.SetCaches(cpu, map[string]sysfs.CacheInfo{
+			"index0": {Size: 32 * 1024, Type: "Data", Level: 1, Cpus: 2, SharedCpus: []int{core, core + 4}},
+			"index1": {Size: 32 * 1024, Type: "Instruction", Level: 1, Cpus: 2, SharedCpus: []int{core, core + 4}},
+			"index2": {Size: 256 * 1024, Type: "Unified", Level: 2, Cpus: 2, SharedCpus: []int{core, core + 4}},
+			"index3": {Size: 8 * 1024 * 1024, Type: "Unified", Level: 3, Cpus: 4, SharedCpus: []int{socket * 2, socket*2 + 1, socket*2 + 4, socket*2 + 5}},
+		})
+	}
+	topology, numCores, err := GetTopology(sysFs, testCacheCpuinfo)
+	if err != nil {
+		t.Fatalf("failed to get topology: %v", err)
+	}
+	if numCores != 8 {
+		t.Errorf("Expected 8 cores, found %d", numCores)
+	}
+
+	coreCaches := []info.Cache{
+		{Size: 32 * 1024, Type: "Data", Level: 1},
+		{Size: 32 * 1024, Type: "Instruction", Level: 1},
+		{Size: 256 * 1024, Type: "Unified", Level: 2},
+	}
+	expected := []info.Node{}
+	for i := 0; i < 2; i++ {
+		node := info.Node{
+			Id:     i,
+			Caches: []info.Cache{{Size: 8 * 1024 * 1024, Type: "Unified", Level: 3}},
+		}
+		for j := 0; j < 2; j++ {
+			node.Cores = append(node.Cores, info.Core{
+				Id:      j,
+				Threads: []int{i*2 + j, i*2 + j + 4},
+				Caches:  coreCaches,
+			})
+		}
+		expected = append(expected, node)
+	}
+	if !reflect.DeepEqual(topology, expected) {
+		t.Errorf("Expected topology %+v, got %+v", expected, topology)
+	}
+}
+
+func TestTopologyMissingCaches(t *testing.T) {
+	sysFs := &fakesysfs.FakeSysFs{}
+	// Only the first core has cache information.
+	sysFs.SetCaches(0, map[string]sysfs.CacheInfo{
+		"index0": {Size: 32 * 1024, Type: "Data", Level: 1, Cpus: 2, SharedCpus: []int{0, 4}},
+	})
+	topology, _, err := GetTopology(sysFs, testCacheCpuinfo)
+	if err != nil {
+		t.Fatalf("failed to get topology: %v", err)
+	}
+	if len(topology) != 2 {
+		t.Fatalf("Expected 2 nodes, found %d", len(topology))
+	}
+	expected := []info.Cache{{Size: 32 * 1024, Type: "Data", Level: 1}}
+	if !reflect.DeepEqual(topology[0].Cores[0].Caches, expected) {
+		t.Errorf("Expected caches %+v on the first core, got %+v", expected, topology[0].Cores[0].Caches)
+	}
+	for _, node := range topology {
+		if len(node.Caches) != 0 {
+			t.Errorf("Expected no caches on node %d, got %+v", node.Id, node.Caches)
+		}
+		for _, core := range node.Cores {
+			if (node.Id != 0 || core.Id != 0) && len(core.Caches) != 0 {
+				t.Errorf("Expected no caches on core %d of node %d, got %+v", core.Id, node.Id, core.Caches)
+			}
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package manager
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -46,6 +47,7 @@ import (
 // Housekeeping interval.
 var enableLoadReader = flag.Bool("enable_load_reader", false, "Whether to enable cpu load reader")
 var HousekeepingInterval = flag.Duration("housekeeping_interval", 1*time.Second, "Interval between container housekeepings")
+var statsCollectionTimeout = flag.Duration("stats_collection_timeout", 0, "how long collecting the stats of a container may take before it is given up on, 0 for no limit")
 
 // cgroup type chosen to fetch the cgroup path of a process.
 // Memory has been chosen, as it is one of the default cgroups that is enabled for most containers.
@@ -572,7 +574,13 @@ func (c *containerData) updateLoad(newLoad uint64) {
 }
 
 func (c *containerData) updateStats() error {
-	stats, statsErr := c.handler.GetStats()
+	ctx := context.Background()
+	if *statsCollectionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *statsCollectionTimeout)
+		defer cancel()
+	}
+	stats, statsErr := container.GetStatsContext(ctx, c.handler)
 	if statsErr != nil {
 		// Ignore errors if the container is dead.
 		if !c.handler.Exists() {
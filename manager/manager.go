@@ -128,12 +128,15 @@ type Manager interface {
 	// Get details about interesting docker images.
 	DockerImages() ([]info.DockerImage, error)
 
+	// Get the disk space used by docker images, containers, volumes and build cache.
+	DockerDiskUsage() (info.DockerDiskUsage, error)
+
 	// Returns debugging information. Map of lines per category.
 	DebugInfo() map[string][]string
 }
 
 // New takes a memory storage and returns a new manager.
-func New(memoryCache *memory.InMemoryCache, sysfs sysfs.SysFs, maxHousekeepingInterval time.Duration, allowDynamicHousekeeping bool, includedMetricsSet container.MetricSet, collectorHttpClient *http.Client, rawContainerCgroupPathPrefixWhiteList []string) (Manager, error) {
+func New(memoryCache *memory.InMemoryCache, sysfs sysfs.SysFs, maxHousekeepingInterval time.Duration, allowDynamicHousekeeping bool, includedMetricsSet container.MetricSet, collectorHttpClient *http.Client, rawContainerCgroupPathPrefixWhiteList []string, rawContainerCgroupPathPrefixBlackList []string) (Manager, error) {
 	if memoryCache == nil {
 		return nil, fmt.Errorf("manager requires memory storage")
 	}
@@ -183,9 +186,16 @@ func New(memoryCache *memory.InMemoryCache, sysfs sysfs.SysFs, maxHousekeepingIn
 		collectorHttpClient:                   collectorHttpClient,
 		nvidiaManager:                         &accelerators.NvidiaManager{},
 		rawContainerCgroupPathPrefixWhiteList: rawContainerCgroupPathPrefixWhiteList,
+		rawContainerCgroupPathPrefixBlackList: rawContainerCgroupPathPrefixBlackList,
 	}
 
-	machineInfo, err := machine.Info(sysfs, fsInfo, inHostNamespace)
+	// Only list the GPUs of the machine if accelerator metrics are collected.
+	var gpuEnumerator machine.GPUEnumerator
+	if includedMetricsSet.Has(container.AcceleratorUsageMetrics) {
+		gpuEnumerator = newManager.nvidiaManager
+	}
+	newManager.machineInfoCache = machine.NewInfoCache(sysfs, fsInfo, gpuEnumerator, inHostNamespace)
+	machineInfo, err := newManager.machineInfoCache.Info()
 	if err != nil {
 		return nil, err
 	}
@@ -217,8 +227,10 @@ type manager struct {
 	memoryCache              *memory.InMemoryCache
 	fsInfo                   fs.FsInfo
 	sysFs                    sysfs.SysFs
-	machineMu                sync.RWMutex // protects machineInfo
+	machineMu                sync.RWMutex // protects machineInfo and cgroupDriver
 	machineInfo              info.MachineInfo
+	machineInfoCache         *machine.InfoCache
+	cgroupDriver             string
 	quitChannels             []chan error
 	cadvisorContainer        string
 	inHostNamespace          bool
@@ -233,13 +245,23 @@ type manager struct {
 	nvidiaManager            accelerators.AcceleratorManager
 	// List of raw container cgroup path prefix whitelist.
 	rawContainerCgroupPathPrefixWhiteList []string
+	// List of raw container cgroup path prefix blacklist.
+	rawContainerCgroupPathPrefixBlackList []string
 }
 
 // Start the container manager.
 func (self *manager) Start() error {
 	self.containerWatchers = container.InitializePlugins(self, self.fsInfo, self.includedMetrics)
 
-	err := raw.Register(self, self.fsInfo, self.includedMetrics, self.rawContainerCgroupPathPrefixWhiteList)
+	// The cgroup driver of the docker daemon is part of the machine info.
+	if status, err := docker.Status(); err == nil {
+		self.machineMu.Lock()
+		self.cgroupDriver = status.CgroupDriver
+		self.machineInfo.CgroupDriver = status.CgroupDriver
+		self.machineMu.Unlock()
+	}
+
+	err := raw.Register(self, self.fsInfo, self.includedMetrics, self.rawContainerCgroupPathPrefixWhiteList, self.rawContainerCgroupPathPrefixBlackList)
 	if err != nil {
 		klog.Errorf("Registration of the raw container factory failed: %v", err)
 	}
@@ -318,12 +340,13 @@ func (self *manager) updateMachineInfo(quit chan error) {
 	for {
 		select {
 		case <-ticker.C:
-			info, err := machine.Info(self.sysFs, self.fsInfo, self.inHostNamespace)
+			info, err := self.machineInfoCache.Info()
 			if err != nil {
 				klog.Errorf("Could not get machine info: %v", err)
 				break
 			}
 			self.machineMu.Lock()
+			info.CgroupDriver = self.cgroupDriver
 			self.machineInfo = *info
 			self.machineMu.Unlock()
 			klog.V(5).Infof("Update machine info: %+v", *info)
@@ -1266,6 +1289,10 @@ func (m *manager) DockerImages() ([]info.DockerImage, error) {
 	return docker.Images()
 }
 
+func (m *manager) DockerDiskUsage() (info.DockerDiskUsage, error) {
+	return docker.DiskUsage()
+}
+
 func (m *manager) DockerInfo() (info.DockerStatus, error) {
 	return docker.Status()
 }
@@ -1299,6 +1326,13 @@ func (m *manager) DebugInfo() map[string][]string {
 				lines = append(lines, fmt.Sprintf("\t\t%s", alias))
 			}
 		}
+
+		if explanations := container.Explain(cont.info.Name); len(explanations) != 0 {
+			lines = append(lines, "\tFactory decisions:")
+			for _, explanation := range explanations {
+				lines = append(lines, fmt.Sprintf("\t\t%s", explanation))
+			}
+		}
 	}
 
 	debugInfo["Managed containers"] = lines
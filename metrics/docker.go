@@ -0,0 +1,110 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+)
+
+// dockerDiskUsageProvider will usually be manager.Manager, but can be swapped
+// out for testing.
+type dockerDiskUsageProvider interface {
+	// DockerDiskUsage provides the disk space used by the docker daemon.
+	DockerDiskUsage() (info.DockerDiskUsage, error)
+}
+
+var (
+	dockerDiskUsageObjectsDesc       = prometheus.NewDesc("machine_docker_disk_usage_objects", "Number of docker objects of the given type.", []string{"type"}, nil)
+	dockerDiskUsageActiveObjectsDesc = prometheus.NewDesc("machine_docker_disk_usage_active_objects", "Number of docker objects of the given type in use by at least one container.", []string{"type"}, nil)
+	dockerDiskUsageBytesDesc         = prometheus.NewDesc("machine_docker_disk_usage_bytes", "Disk space in bytes used by docker objects of the given type.", []string{"type"}, nil)
+	dockerDiskUsageReclaimableDesc   = prometheus.NewDesc("machine_docker_disk_usage_reclaimable_bytes", "Disk space in bytes that would be freed by removing the docker objects of the given type not in use.", []string{"type"}, nil)
+)
+
+// DockerDiskUsageCollector exports the disk space used by the images,
+// containers, volumes and build cache of the docker daemon. Computing it walks
+// all of the layers and volumes, so it is done in the background and scrapes
+// are served the last computed value. It implements prometheus.Collector.
+type DockerDiskUsageCollector struct {
+	provider dockerDiskUsageProvider
+
+	lock  sync.Mutex
+	usage *info.DockerDiskUsage
+}
+
+// NewDockerDiskUsageCollector returns a new DockerDiskUsageCollector that
+// computes the disk usage every interval, for the lifetime of the process.
+func NewDockerDiskUsageCollector(p dockerDiskUsageProvider, interval time.Duration) *DockerDiskUsageCollector {
+	c := &DockerDiskUsageCollector{provider: p}
+	go func() {
+		for {
+			c.update()
+			time.Sleep(interval)
+		}
+	}()
+	return c
+}
+
+// update computes the disk usage. The last value is kept when it fails.
+func (c *DockerDiskUsageCollector) update() {
+	du, err := c.provider.DockerDiskUsage()
+	if err != nil {
+		klog.V(4).Infof("Couldn't get docker disk usage: %s", err)
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.usage = &du
+}
+
+// Describe describes all the metrics exported by the collector. It implements
+// prometheus.Collector.
+func (c *DockerDiskUsageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dockerDiskUsageObjectsDesc
+	ch <- dockerDiskUsageActiveObjectsDesc
+	ch <- dockerDiskUsageBytesDesc
+	ch <- dockerDiskUsageReclaimableDesc
+}
+
+// Collect delivers the last computed disk usage as Prometheus metrics. Nothing
+// is exported until it was computed successfully. It implements
+// prometheus.Collector.
+func (c *DockerDiskUsageCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.Lock()
+	du := c.usage
+	c.lock.Unlock()
+	if du == nil {
+		return
+	}
+	for _, item := range []struct {
+		kind  string
+		usage info.DockerDiskUsageItem
+	}{
+		{"images", du.Images},
+		{"containers", du.Containers},
+		{"volumes", du.Volumes},
+		{"build_cache", du.BuildCache},
+	} {
+		ch <- prometheus.MustNewConstMetric(dockerDiskUsageObjectsDesc, prometheus.GaugeValue, float64(item.usage.Count), item.kind)
+		ch <- prometheus.MustNewConstMetric(dockerDiskUsageActiveObjectsDesc, prometheus.GaugeValue, float64(item.usage.Active), item.kind)
+		ch <- prometheus.MustNewConstMetric(dockerDiskUsageBytesDesc, prometheus.GaugeValue, float64(item.usage.Size), item.kind)
+		ch <- prometheus.MustNewConstMetric(dockerDiskUsageReclaimableDesc, prometheus.GaugeValue, float64(item.usage.Reclaimable), item.kind)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type fakeDockerDiskUsageProvider struct {
+	du    info.DockerDiskUsage
+	err   error
+	calls int
+}
+
+func (p *fakeDockerDiskUsageProvider) DockerDiskUsage() (info.DockerDiskUsage, error) {
+	p.calls++
+	return p.du, p.err
+}
+
+func scrapeDockerDiskUsage(t *testing.T, c *DockerDiskUsageCollector) string {
+	r := prometheus.NewRegistry()
+	r.MustRegister(c)
+	rw := httptest.NewRecorder()
+	promhttp.HandlerFor(r, promhttp.HandlerOpts{}).ServeHTTP(rw, &http.Request{})
+	if rw.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rw.Code, rw.Body.String())
+	}
+	return rw.Body.String()
+}
+
+func TestDockerDiskUsageCollector(t *testing.T) {
+	provider := &fakeDockerDiskUsageProvider{
+		du: info.DockerDiskUsage{
+			Images:     info.DockerDiskUsageItem{Count: 2, Active: 1, Size: 1000, Reclaimable: 500},
+			Containers: info.DockerDiskUsageItem{Count: 2, Active: 1, Size: 30, Reclaimable: 20},
+			Volumes:    info.DockerDiskUsageItem{Count: 3, Active: 1, Size: 500, Reclaimable: 200},
+			BuildCache: info.DockerDiskUsageItem{Size: 50, Reclaimable: 50},
+		},
+	}
+	c := &DockerDiskUsageCollector{provider: provider}
+	c.update()
+	// Scrapes are served the computed usage, even if it can't be computed
+	// again.
+	provider.err = errors.New("docker is not available")
+	c.update()
+	got := scrapeDockerDiskUsage(t, c)
+	if provider.calls != 2 {
+		t.Errorf("expected the disk usage to be computed twice, it was computed %d times", provider.calls)
+	}
+
+	for _, want := range []string{
+		`machine_docker_disk_usage_objects{type="images"} 2`,
+		`machine_docker_disk_usage_active_objects{type="images"} 1`,
+		`machine_docker_disk_usage_bytes{type="images"} 1000`,
+		`machine_docker_disk_usage_reclaimable_bytes{type="images"} 500`,
+		`machine_docker_disk_usage_bytes{type="containers"} 30`,
+		`machine_docker_disk_usage_reclaimable_bytes{type="containers"} 20`,
+		`machine_docker_disk_usage_objects{type="volumes"} 3`,
+		`machine_docker_disk_usage_reclaimable_bytes{type="volumes"} 200`,
+		`machine_docker_disk_usage_bytes{type="build_cache"} 50`,
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("metric %q not found in:\n%s", want, got)
+		}
+	}
+}
+
+func TestDockerDiskUsageCollectorNoDocker(t *testing.T) {
+	c := &DockerDiskUsageCollector{provider: &fakeDockerDiskUsageProvider{err: errors.New("docker is not available")}}
+	c.update()
+	got := scrapeDockerDiskUsage(t, c)
+	if strings.Contains(got, "machine_docker_disk_usage") {
+		t.Errorf("expected no docker disk usage metrics, got:\n%s", got)
+	}
+}
@@ -59,6 +59,10 @@ func asNanosecondsToSeconds(v uint64) float64 {
 func fsValues(fsStats []info.FsStats, valueFn func(*info.FsStats) float64, timestamp time.Time) metricValues {
 	values := make(metricValues, 0, len(fsStats))
 	for _, stat := range fsStats {
+		if stat.Merged {
+			// Reporting the merged view would duplicate the device label.
+			continue
+		}
 		values = append(values, metricValue{
 			value:     valueFn(&stat),
 			labels:    []string{stat.Device},
@@ -81,6 +85,9 @@ func ioValues(ioStats []info.PerDiskStats, ioType string, ioValueFn func(uint64)
 		})
 	}
 	for _, stat := range fsStats {
+		if stat.Merged {
+			continue
+		}
 		values = append(values, metricValue{
 			value:     valueFn(&stat),
 			labels:    []string{stat.Device},
@@ -145,6 +152,22 @@ func NewPrometheusCollector(i infoProvider, f ContainerLabelsFunc, includedMetri
 						timestamp: time.Now(),
 					}}
 				},
+			}, {
+				name:        "container_collection_latency_seconds",
+				help:        "Time spent collecting each metric group of the container in the last collection, if enabled",
+				valueType:   prometheus.GaugeValue,
+				extraLabels: []string{"group"},
+				getValues: func(s *info.ContainerStats) metricValues {
+					values := make(metricValues, 0, len(s.CollectionLatencies))
+					for group, latency := range s.CollectionLatencies {
+						values = append(values, metricValue{
+							value:     latency.Seconds(),
+							labels:    []string{group},
+							timestamp: s.Timestamp,
+						})
+					}
+					return values
+				},
 			},
 		},
 		includedMetrics: includedMetrics,
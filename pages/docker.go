@@ -46,6 +46,7 @@ func toStatusKV(status info.DockerStatus) ([]keyVal, []keyVal) {
 		{Key: "Host Name", Value: status.Hostname},
 		{Key: "Docker Root Directory", Value: status.RootDir},
 		{Key: "Execution  Driver", Value: status.ExecDriver},
+		{Key: "Cgroup Driver", Value: status.CgroupDriver},
 		{Key: "Number of Images", Value: strconv.Itoa(status.NumImages)},
 		{Key: "Number of Containers", Value: strconv.Itoa(status.NumContainers)},
 	}, ds
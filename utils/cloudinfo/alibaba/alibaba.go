@@ -0,0 +1,83 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alibaba
+
+import (
+	"context"
+	"net/http"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+	"github.com/matthewygf/cadvisor/utils/cloudinfo"
+
+	"k8s.io/klog"
+)
+
+// Alibaba Cloud ECS serves its metadata on its own address rather than on
+// 169.254.169.254.
+const metadataURL = "http://100.100.100.200/latest/meta-data/"
+
+func init() {
+	cloudinfo.RegisterCloudProvider(info.Alibaba, newProvider(metadataURL, nil))
+}
+
+type provider struct {
+	client *cloudinfo.MetadataClient
+}
+
+var _ cloudinfo.CloudProvider = &provider{}
+var _ cloudinfo.CloudProviderWithContext = &provider{}
+
+// newProvider returns a provider querying the given metadata URL. A nil
+// transport selects a transport that does not use proxies.
+func newProvider(metadataURL string, transport http.RoundTripper) *provider {
+	return &provider{client: cloudinfo.NewMetadataClient(metadataURL, transport)}
+}
+
+func (self *provider) IsActiveProvider() bool {
+	return self.IsActiveProviderWithContext(context.Background())
+}
+
+func (self *provider) IsActiveProviderWithContext(ctx context.Context) bool {
+	id, err := self.client.GetString(ctx, "instance-id")
+	if err != nil {
+		klog.V(2).Infof("Failed to query Alibaba Cloud metadata service: %v", err)
+		return false
+	}
+	return id != ""
+}
+
+func (self *provider) GetInstanceType() info.InstanceType {
+	return self.GetInstanceTypeWithContext(context.Background())
+}
+
+func (self *provider) GetInstanceTypeWithContext(ctx context.Context) info.InstanceType {
+	instanceType, err := self.client.GetString(ctx, "instance/instance-type")
+	if err != nil || instanceType == "" {
+		return info.UnknownInstance
+	}
+	return info.InstanceType(instanceType)
+}
+
+func (self *provider) GetInstanceID() info.InstanceID {
+	return self.GetInstanceIDWithContext(context.Background())
+}
+
+func (self *provider) GetInstanceIDWithContext(ctx context.Context) info.InstanceID {
+	id, err := self.client.GetString(ctx, "instance-id")
+	if err != nil || id == "" {
+		return info.UnNamedInstance
+	}
+	return info.InstanceID(id)
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alibaba
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
+
+// fakeTransport answers requests for the metadata in metadata, keyed by URL,
+// and fails requests for anything else as an unreachable address would.
+type fakeTransport struct {
+	metadata map[string]string
+}
+
+func (t *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	value, ok := t.metadata[req.URL.String()]
+	if !ok {
+		return nil, fmt.Errorf("dial tcp %s: i/o timeout", req.URL.Host)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Body:       ioutil.NopCloser(strings.NewReader(value)),
+		Request:    req,
+	}, nil
+}
+
+func TestAlibabaProvider(t *testing.T) {
+	p := newProvider(metadataURL, &fakeTransport{metadata: map[string]string{
+		"http://100.100.100.200/latest/meta-data/instance-id":            "i-bp1hygp5b04o2k2xj0yq",
+		"http://100.100.100.200/latest/meta-data/instance/instance-type": "ecs.g6.large\n",
+	}})
+	if !p.IsActiveProvider() {
+		t.Fatalf("expected Alibaba Cloud to be the active provider")
+	}
+	if instanceType := p.GetInstanceType(); instanceType != "ecs.g6.large" {
+		t.Errorf("expected instance type %q, got %q", "ecs.g6.large", instanceType)
+	}
+	if instanceID := p.GetInstanceID(); instanceID != "i-bp1hygp5b04o2k2xj0yq" {
+		t.Errorf("expected instance ID %q, got %q", "i-bp1hygp5b04o2k2xj0yq", instanceID)
+	}
+}
+
+func TestAlibabaProviderInactive(t *testing.T) {
+	// Metadata served on 169.254.169.254 by other clouds is not used.
+	p := newProvider(metadataURL, &fakeTransport{metadata: map[string]string{
+		"http://169.254.169.254/latest/meta-data/instance-id": "i-1234567890abcdef0",
+	}})
+	if p.IsActiveProvider() {
+		t.Errorf("expected Alibaba Cloud not to be the active provider")
+	}
+	if instanceType := p.GetInstanceType(); instanceType != info.UnknownInstance {
+		t.Errorf("expected instance type %q, got %q", info.UnknownInstance, instanceType)
+	}
+	if instanceID := p.GetInstanceID(); instanceID != info.UnNamedInstance {
+		t.Errorf("expected instance ID %q, got %q", info.UnNamedInstance, instanceID)
+	}
+}
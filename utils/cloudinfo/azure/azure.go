@@ -15,42 +15,120 @@
 package cloudinfo
 
 import (
+	"context"
 	"io/ioutil"
 	"strings"
 
 	info "github.com/matthewygf/cadvisor/info/v1"
 	"github.com/matthewygf/cadvisor/utils/cloudinfo"
+
+	"k8s.io/klog"
 )
 
 const (
 	sysVendorFileName    = "/sys/class/dmi/id/sys_vendor"
 	biosUUIDFileName     = "/sys/class/dmi/id/product_uuid"
 	microsoftCorporation = "Microsoft Corporation"
+
+	// Azure Instance Metadata Service endpoint. It is only reachable from
+	// inside an Azure VM.
+	metadataURL = "http://169.254.169.254/metadata/instance?api-version=2019-03-11"
 )
 
 func init() {
-	cloudinfo.RegisterCloudProvider(info.Azure, &provider{})
+	cloudinfo.RegisterCloudProvider(info.Azure, newProvider(sysVendorFileName, biosUUIDFileName, metadataURL))
+}
+
+// instanceMetadata is the subset of the IMDS instance document we use.
+type instanceMetadata struct {
+	Compute struct {
+		VMID   string `json:"vmId"`
+		VMSize string `json:"vmSize"`
+	} `json:"compute"`
+}
+
+type provider struct {
+	sysVendorFileName string
+	biosUUIDFileName  string
+	client            *cloudinfo.MetadataClient
+
+	metadata cloudinfo.MetadataCache
 }
 
-type provider struct{}
+var _ cloudinfo.CloudProvider = &provider{}
+var _ cloudinfo.CloudProviderWithContext = &provider{}
 
-var _ cloudinfo.CloudProvider = provider{}
+func newProvider(sysVendorFileName, biosUUIDFileName, metadataURL string) *provider {
+	return &provider{
+		sysVendorFileName: sysVendorFileName,
+		biosUUIDFileName:  biosUUIDFileName,
+		client:            cloudinfo.NewMetadataClient(metadataURL, nil),
+	}
+}
 
-func (provider) IsActiveProvider() bool {
-	data, err := ioutil.ReadFile(sysVendorFileName)
+func (self *provider) getMetadata(ctx context.Context) *instanceMetadata {
+	metadata, err := self.metadata.Get(func() (interface{}, error) {
+		return self.fetchMetadata(ctx)
+	})
+	if err != nil {
+		klog.V(2).Infof("Failed to query Azure instance metadata: %v", err)
+		return nil
+	}
+	return metadata.(*instanceMetadata)
+}
+
+func (self *provider) fetchMetadata(ctx context.Context) (*instanceMetadata, error) {
+	req, err := self.client.NewRequest(ctx, "GET", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+
+	var metadata instanceMetadata
+	if err := self.client.DoJSON(req, &metadata); err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+func (self *provider) IsActiveProvider() bool {
+	return self.IsActiveProviderWithContext(context.Background())
+}
+
+// IsActiveProviderWithContext checks the system vendor reported by DMI, the
+// metadata service is only queried for the instance type and ID.
+func (self *provider) IsActiveProviderWithContext(ctx context.Context) bool {
+	data, err := ioutil.ReadFile(self.sysVendorFileName)
 	if err != nil {
 		return false
 	}
 	return strings.Contains(string(data), microsoftCorporation)
 }
 
-// TODO: Implement method.
-func (provider) GetInstanceType() info.InstanceType {
-	return info.UnknownInstance
+func (self *provider) GetInstanceType() info.InstanceType {
+	return self.GetInstanceTypeWithContext(context.Background())
 }
 
-func (provider) GetInstanceID() info.InstanceID {
-	data, err := ioutil.ReadFile(biosUUIDFileName)
+func (self *provider) GetInstanceTypeWithContext(ctx context.Context) info.InstanceType {
+	metadata := self.getMetadata(ctx)
+	if metadata == nil || metadata.Compute.VMSize == "" {
+		return info.UnknownInstance
+	}
+	return info.InstanceType(metadata.Compute.VMSize)
+}
+
+func (self *provider) GetInstanceID() info.InstanceID {
+	return self.GetInstanceIDWithContext(context.Background())
+}
+
+// GetInstanceIDWithContext returns the VM ID reported by the metadata service,
+// or the BIOS UUID if the metadata service can't be queried.
+func (self *provider) GetInstanceIDWithContext(ctx context.Context) info.InstanceID {
+	metadata := self.getMetadata(ctx)
+	if metadata != nil && metadata.Compute.VMID != "" {
+		return info.InstanceID(metadata.Compute.VMID)
+	}
+	data, err := ioutil.ReadFile(self.biosUUIDFileName)
 	if err != nil {
 		return info.UnNamedInstance
 	}
@@ -0,0 +1,115 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudinfo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
+
+// writeDMIFiles writes the system vendor and BIOS UUID files to a temporary
+// directory and returns their paths and a function removing them.
+func writeDMIFiles(t *testing.T, sysVendor string) (string, string, func()) {
+	dir, err := ioutil.TempDir("", "azure")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	sysVendorFile := filepath.Join(dir, "sys_vendor")
+	biosUUIDFile := filepath.Join(dir, "product_uuid")
+	if err := ioutil.WriteFile(sysVendorFile, []byte(sysVendor+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", sysVendorFile, err)
+	}
+	if err := ioutil.WriteFile(biosUUIDFile, []byte("bios-uuid\n"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", biosUUIDFile, err)
+	}
+	return sysVendorFile, biosUUIDFile, func() { os.RemoveAll(dir) }
+}
+
+func TestAzureProvider(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Metadata") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"compute": {"vmId": "02aab8a4-74ef-476e-8182-f6d2ba4166a6", "vmSize": "Standard_D2s_v3"}}`)
+	}))
+	defer server.Close()
+	sysVendorFile, biosUUIDFile, cleanup := writeDMIFiles(t, "Microsoft Corporation")
+	defer cleanup()
+
+	p := newProvider(sysVendorFile, biosUUIDFile, server.URL)
+	if !p.IsActiveProvider() {
+		t.Fatalf("expected Azure to be the active provider")
+	}
+	if requests != 0 {
+		t.Errorf("expected no metadata request to detect Azure, got %d", requests)
+	}
+	if instanceType := p.GetInstanceType(); instanceType != "Standard_D2s_v3" {
+		t.Errorf("expected instance type %q, got %q", "Standard_D2s_v3", instanceType)
+	}
+	if instanceID := p.GetInstanceID(); instanceID != "02aab8a4-74ef-476e-8182-f6d2ba4166a6" {
+		t.Errorf("expected instance ID %q, got %q", "02aab8a4-74ef-476e-8182-f6d2ba4166a6", instanceID)
+	}
+	if requests != 1 {
+		t.Errorf("expected the metadata to be fetched once, it was fetched %d times", requests)
+	}
+}
+
+func TestAzureProviderInactive(t *testing.T) {
+	sysVendorFile, biosUUIDFile, cleanup := writeDMIFiles(t, "QEMU")
+	defer cleanup()
+
+	p := newProvider(sysVendorFile, biosUUIDFile, "http://127.0.0.1:0")
+	if p.IsActiveProvider() {
+		t.Errorf("expected Azure not to be the active provider")
+	}
+}
+
+func TestAzureProviderMetadataFailure(t *testing.T) {
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"compute": {"vmId": "02aab8a4-74ef-476e-8182-f6d2ba4166a6", "vmSize": "Standard_D2s_v3"}}`)
+	}))
+	defer server.Close()
+	sysVendorFile, biosUUIDFile, cleanup := writeDMIFiles(t, "Microsoft Corporation")
+	defer cleanup()
+
+	p := newProvider(sysVendorFile, biosUUIDFile, server.URL)
+	if instanceType := p.GetInstanceType(); instanceType != info.UnknownInstance {
+		t.Errorf("expected instance type %q, got %q", info.UnknownInstance, instanceType)
+	}
+	if instanceID := p.GetInstanceID(); instanceID != "bios-uuid" {
+		t.Errorf("expected instance ID %q, got %q", "bios-uuid", instanceID)
+	}
+
+	// The failure is not cached.
+	fail = false
+	if instanceType := p.GetInstanceType(); instanceType != "Standard_D2s_v3" {
+		t.Errorf("expected instance type %q, got %q", "Standard_D2s_v3", instanceType)
+	}
+}
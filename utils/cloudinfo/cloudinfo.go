@@ -17,6 +17,11 @@
 package cloudinfo
 
 import (
+	"context"
+	"flag"
+	"sync"
+	"time"
+
 	info "github.com/matthewygf/cadvisor/info/v1"
 	"k8s.io/klog"
 )
@@ -40,6 +45,21 @@ type CloudProvider interface {
 	GetInstanceID() info.InstanceID
 }
 
+// CloudProviderWithContext is implemented by providers whose lookups can be
+// cancelled, e.g. those querying a metadata server. Providers only
+// implementing CloudProvider are run in the background and abandoned once the
+// context is done.
+type CloudProviderWithContext interface {
+	IsActiveProviderWithContext(ctx context.Context) bool
+	GetInstanceTypeWithContext(ctx context.Context) info.InstanceType
+	GetInstanceIDWithContext(ctx context.Context) info.InstanceID
+}
+
+// detectionTimeout bounds the time spent probing each cloud provider.
+var detectionTimeout = 10 * time.Second
+
+var disableCloudInfo = flag.Bool("disable_cloud_info", false, "Skip cloud provider detection and report the provider, instance type and instance ID as unknown. Avoids metadata server requests on bare-metal or air-gapped nodes.")
+
 var providers = map[info.CloudProvider]CloudProvider{}
 
 // RegisterCloudProvider registers the given cloud provider
@@ -56,18 +76,42 @@ type realCloudInfo struct {
 	instanceID    info.InstanceID
 }
 
+// NewRealCloudInfo returns the information reported by a registered provider
+// that is active. If none is, or if cloud detection is disabled by
+// --disable_cloud_info, the provider, instance type and instance ID are
+// UnknownProvider, UnknownInstance and UnNamedInstance.
 func NewRealCloudInfo() CloudInfo {
+	if *disableCloudInfo {
+		return unknownCloudInfo()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	return newRealCloudInfo(ctx)
+}
+
+// newRealCloudInfo probes the registered providers in parallel, each for at
+// most detectionTimeout, and returns the information of the first one found
+// active. The probes still running are cancelled once ctx is done.
+func newRealCloudInfo(ctx context.Context) *realCloudInfo {
+	results := make(chan *realCloudInfo, len(providers))
 	for name, provider := range providers {
-		if provider.IsActiveProvider() {
-			return &realCloudInfo{
-				cloudProvider: name,
-				instanceType:  provider.GetInstanceType(),
-				instanceID:    provider.GetInstanceID(),
-			}
+		go func(name info.CloudProvider, provider CloudProvider) {
+			ctx, cancel := context.WithTimeout(ctx, detectionTimeout)
+			defer cancel()
+			results <- probe(ctx, name, provider)
+		}(name, provider)
+	}
+	for range providers {
+		if cloudInfo := <-results; cloudInfo != nil {
+			return cloudInfo
 		}
 	}
 
 	// No registered active provider.
+	return unknownCloudInfo()
+}
+
+func unknownCloudInfo() *realCloudInfo {
 	return &realCloudInfo{
 		cloudProvider: info.UnknownProvider,
 		instanceType:  info.UnknownInstance,
@@ -75,6 +119,63 @@ func NewRealCloudInfo() CloudInfo {
 	}
 }
 
+// probe returns the information reported by the provider, or nil if it is not
+// active or does not answer before ctx is done.
+func probe(ctx context.Context, name info.CloudProvider, provider CloudProvider) *realCloudInfo {
+	p, ok := provider.(CloudProviderWithContext)
+	if !ok {
+		return probeLegacy(ctx, name, provider)
+	}
+	if !p.IsActiveProviderWithContext(ctx) {
+		return nil
+	}
+	return &realCloudInfo{
+		cloudProvider: name,
+		instanceType:  p.GetInstanceTypeWithContext(ctx),
+		instanceID:    p.GetInstanceIDWithContext(ctx),
+	}
+}
+
+// legacyProbe is a probe of a provider without context support. It can't be
+// cancelled, so at most one runs per provider and later probes wait for it.
+type legacyProbe struct {
+	done      chan struct{}
+	cloudInfo *realCloudInfo
+}
+
+var legacyProbesLock sync.Mutex
+var legacyProbes = map[CloudProvider]*legacyProbe{}
+
+func probeLegacy(ctx context.Context, name info.CloudProvider, provider CloudProvider) *realCloudInfo {
+	legacyProbesLock.Lock()
+	p, ok := legacyProbes[provider]
+	if !ok {
+		p = &legacyProbe{done: make(chan struct{})}
+		legacyProbes[provider] = p
+		go func() {
+			if provider.IsActiveProvider() {
+				p.cloudInfo = &realCloudInfo{
+					cloudProvider: name,
+					instanceType:  provider.GetInstanceType(),
+					instanceID:    provider.GetInstanceID(),
+				}
+			}
+			legacyProbesLock.Lock()
+			delete(legacyProbes, provider)
+			legacyProbesLock.Unlock()
+			close(p.done)
+		}()
+	}
+	legacyProbesLock.Unlock()
+
+	select {
+	case <-p.done:
+		return p.cloudInfo
+	case <-ctx.Done():
+		return nil
+	}
+}
+
 func (self *realCloudInfo) GetCloudProvider() info.CloudProvider {
 	return self.cloudProvider
 }
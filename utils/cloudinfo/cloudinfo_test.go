@@ -0,0 +1,145 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudinfo
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
+
+type fakeProvider struct {
+	active  bool
+	queried bool
+}
+
+func (self *fakeProvider) IsActiveProvider() bool {
+	self.queried = true
+	return self.active
+}
+
+func (self *fakeProvider) GetInstanceType() info.InstanceType {
+	return "fake-type"
+}
+
+func (self *fakeProvider) GetInstanceID() info.InstanceID {
+	return "fake-id"
+}
+
+// setProviders replaces the registered providers and returns a function that
+// restores them.
+func setProviders(p map[info.CloudProvider]CloudProvider) func() {
+	old := providers
+	providers = p
+	return func() { providers = old }
+}
+
+func TestNewRealCloudInfo(t *testing.T) {
+	fake := &fakeProvider{active: true}
+	defer setProviders(map[info.CloudProvider]CloudProvider{info.GCE: fake})()
+
+	cloudInfo := NewRealCloudInfo()
+	if cloudInfo.GetCloudProvider() != info.GCE {
+		t.Errorf("expected provider %q, got %q", info.GCE, cloudInfo.GetCloudProvider())
+	}
+	if cloudInfo.GetInstanceType() != "fake-type" {
+		t.Errorf("expected instance type %q, got %q", "fake-type", cloudInfo.GetInstanceType())
+	}
+	if cloudInfo.GetInstanceID() != "fake-id" {
+		t.Errorf("expected instance ID %q, got %q", "fake-id", cloudInfo.GetInstanceID())
+	}
+}
+
+func TestNewRealCloudInfoDisabled(t *testing.T) {
+	fake := &fakeProvider{active: true}
+	defer setProviders(map[info.CloudProvider]CloudProvider{info.GCE: fake})()
+	*disableCloudInfo = true
+	defer func() { *disableCloudInfo = false }()
+
+	cloudInfo := NewRealCloudInfo()
+	if fake.queried {
+		t.Errorf("expected providers not to be queried when cloud info is disabled")
+	}
+	if cloudInfo.GetCloudProvider() != info.UnknownProvider {
+		t.Errorf("expected provider %q, got %q", info.UnknownProvider, cloudInfo.GetCloudProvider())
+	}
+	if cloudInfo.GetInstanceType() != info.UnknownInstance {
+		t.Errorf("expected instance type %q, got %q", info.UnknownInstance, cloudInfo.GetInstanceType())
+	}
+	if cloudInfo.GetInstanceID() != info.UnNamedInstance {
+		t.Errorf("expected instance ID %q, got %q", info.UnNamedInstance, cloudInfo.GetInstanceID())
+	}
+}
+
+// blockingProvider never answers, like a provider stuck on a metadata server.
+type blockingProvider struct {
+	unblock chan struct{}
+	probes  int32
+}
+
+func (self *blockingProvider) IsActiveProvider() bool {
+	atomic.AddInt32(&self.probes, 1)
+	<-self.unblock
+	return true
+}
+
+func (self *blockingProvider) GetInstanceType() info.InstanceType {
+	<-self.unblock
+	return "blocked-type"
+}
+
+func (self *blockingProvider) GetInstanceID() info.InstanceID {
+	<-self.unblock
+	return "blocked-id"
+}
+
+func TestNewRealCloudInfoTimeout(t *testing.T) {
+	blocking := &blockingProvider{unblock: make(chan struct{})}
+	defer close(blocking.unblock)
+	defer setProviders(map[info.CloudProvider]CloudProvider{info.AWS: blocking})()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	cloudInfo := newRealCloudInfo(ctx)
+	if cloudInfo.GetCloudProvider() != info.UnknownProvider {
+		t.Errorf("expected provider %q, got %q", info.UnknownProvider, cloudInfo.GetCloudProvider())
+	}
+	if cloudInfo.GetInstanceType() != info.UnknownInstance {
+		t.Errorf("expected instance type %q, got %q", info.UnknownInstance, cloudInfo.GetInstanceType())
+	}
+}
+
+func TestNewRealCloudInfoBlockedProvider(t *testing.T) {
+	blocking := &blockingProvider{unblock: make(chan struct{})}
+	defer close(blocking.unblock)
+	fake := &fakeProvider{active: true}
+	defer setProviders(map[info.CloudProvider]CloudProvider{info.AWS: blocking, info.GCE: fake})()
+
+	for i := 0; i < 2; i++ {
+		cloudInfo := NewRealCloudInfo()
+		if cloudInfo.GetCloudProvider() != info.GCE {
+			t.Errorf("expected provider %q, got %q", info.GCE, cloudInfo.GetCloudProvider())
+		}
+	}
+	for i := 0; i < 100 && atomic.LoadInt32(&blocking.probes) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if probes := atomic.LoadInt32(&blocking.probes); probes != 1 {
+		t.Errorf("expected the blocked provider to be probed once, it was probed %d times", probes)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package digitalocean
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+	"github.com/matthewygf/cadvisor/utils/cloudinfo"
+
+	"k8s.io/klog"
+)
+
+const metadataURL = "http://169.254.169.254/metadata/v1/"
+
+func init() {
+	cloudinfo.RegisterCloudProvider(info.DigitalOcean, newProvider(metadataURL))
+}
+
+type provider struct {
+	client *cloudinfo.MetadataClient
+}
+
+var _ cloudinfo.CloudProvider = &provider{}
+var _ cloudinfo.CloudProviderWithContext = &provider{}
+
+func newProvider(metadataURL string) *provider {
+	return &provider{client: cloudinfo.NewMetadataClient(metadataURL, nil)}
+}
+
+// getDropletID returns the id of the droplet. Other clouds serve their own
+// metadata on the same address, so the id has to be numeric.
+func (self *provider) getDropletID(ctx context.Context) (string, error) {
+	id, err := self.client.GetString(ctx, "id")
+	if err != nil {
+		return "", err
+	}
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return "", fmt.Errorf("invalid droplet id %q", id)
+	}
+	return id, nil
+}
+
+func (self *provider) IsActiveProvider() bool {
+	return self.IsActiveProviderWithContext(context.Background())
+}
+
+func (self *provider) IsActiveProviderWithContext(ctx context.Context) bool {
+	if _, err := self.getDropletID(ctx); err != nil {
+		klog.V(2).Infof("Failed to query DigitalOcean metadata service: %v", err)
+		return false
+	}
+	return true
+}
+
+func (self *provider) GetInstanceType() info.InstanceType {
+	return self.GetInstanceTypeWithContext(context.Background())
+}
+
+// GetInstanceTypeWithContext returns the size slug of the droplet, e.g.
+// "s-1vcpu-1gb".
+func (self *provider) GetInstanceTypeWithContext(ctx context.Context) info.InstanceType {
+	size, err := self.client.GetString(ctx, "size")
+	if err != nil || size == "" {
+		return info.UnknownInstance
+	}
+	return info.InstanceType(size)
+}
+
+func (self *provider) GetInstanceID() info.InstanceID {
+	return self.GetInstanceIDWithContext(context.Background())
+}
+
+func (self *provider) GetInstanceIDWithContext(ctx context.Context) info.InstanceID {
+	id, err := self.getDropletID(ctx)
+	if err != nil {
+		return info.UnNamedInstance
+	}
+	return info.InstanceID(id)
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package digitalocean
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
+
+func newFakeMetadataServer(metadata map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, ok := metadata[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, value)
+	}))
+}
+
+func TestDigitalOceanProvider(t *testing.T) {
+	server := newFakeMetadataServer(map[string]string{
+		"/metadata/v1/id":     "2756294",
+		"/metadata/v1/region": "nyc3",
+		"/metadata/v1/size":   "s-1vcpu-1gb\n",
+	})
+	defer server.Close()
+
+	p := newProvider(server.URL + "/metadata/v1/")
+	if !p.IsActiveProvider() {
+		t.Fatalf("expected DigitalOcean to be the active provider")
+	}
+	if instanceType := p.GetInstanceType(); instanceType != "s-1vcpu-1gb" {
+		t.Errorf("expected instance type %q, got %q", "s-1vcpu-1gb", instanceType)
+	}
+	if instanceID := p.GetInstanceID(); instanceID != "2756294" {
+		t.Errorf("expected instance ID %q, got %q", "2756294", instanceID)
+	}
+}
+
+func TestDigitalOceanProviderInactive(t *testing.T) {
+	// Another cloud answering on the metadata address.
+	server := newFakeMetadataServer(map[string]string{
+		"/metadata/v1/id": "<html>not found</html>",
+	})
+	defer server.Close()
+
+	p := newProvider(server.URL + "/metadata/v1/")
+	if p.IsActiveProvider() {
+		t.Errorf("expected DigitalOcean not to be the active provider")
+	}
+	if instanceType := p.GetInstanceType(); instanceType != info.UnknownInstance {
+		t.Errorf("expected instance type %q, got %q", info.UnknownInstance, instanceType)
+	}
+	if instanceID := p.GetInstanceID(); instanceID != info.UnNamedInstance {
+		t.Errorf("expected instance ID %q, got %q", info.UnNamedInstance, instanceID)
+	}
+}
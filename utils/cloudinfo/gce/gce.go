@@ -15,31 +15,72 @@
 package gce
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"strings"
 
 	info "github.com/matthewygf/cadvisor/info/v1"
 	"github.com/matthewygf/cadvisor/utils/cloudinfo"
 
-	"cloud.google.com/go/compute/metadata"
 	"k8s.io/klog"
 )
 
 const (
 	gceProductName = "/sys/class/dmi/id/product_name"
 	google         = "Google"
+
+	metadataURL    = "http://metadata.google.internal/computeMetadata/v1/"
+	metadataFlavor = "Google"
 )
 
 func init() {
-	cloudinfo.RegisterCloudProvider(info.GCE, &provider{})
+	cloudinfo.RegisterCloudProvider(info.GCE, newProvider(gceProductName, metadataURL))
+}
+
+type provider struct {
+	productNameFile string
+	client          *cloudinfo.MetadataClient
 }
 
-type provider struct{}
+var _ cloudinfo.CloudProvider = &provider{}
+var _ cloudinfo.CloudProviderWithContext = &provider{}
+
+func newProvider(productNameFile, metadataURL string) *provider {
+	return &provider{
+		productNameFile: productNameFile,
+		client:          cloudinfo.NewMetadataClient(metadataURL, nil),
+	}
+}
+
+// getMetadata returns the value of the given metadata path, relative to
+// computeMetadata/v1/.
+func (self *provider) getMetadata(ctx context.Context, path string) (string, error) {
+	req, err := self.client.NewRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", metadataFlavor)
+
+	header, data, err := self.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	// Anything else answering on this address is not the GCE metadata server.
+	if header.Get("Metadata-Flavor") != metadataFlavor {
+		return "", fmt.Errorf("unexpected Metadata-Flavor %q for metadata %q", header.Get("Metadata-Flavor"), path)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
 
-var _ cloudinfo.CloudProvider = provider{}
+func (self *provider) IsActiveProvider() bool {
+	return self.IsActiveProviderWithContext(context.Background())
+}
 
-func (provider) IsActiveProvider() bool {
-	data, err := ioutil.ReadFile(gceProductName)
+// IsActiveProviderWithContext checks the product name reported by DMI, the
+// metadata server is only queried for the instance type and ID.
+func (self *provider) IsActiveProviderWithContext(ctx context.Context) bool {
+	data, err := ioutil.ReadFile(self.productNameFile)
 	if err != nil {
 		klog.V(2).Infof("Error while reading product_name: %v", err)
 		return false
@@ -47,20 +88,29 @@ func (provider) IsActiveProvider() bool {
 	return strings.Contains(string(data), google)
 }
 
-func (provider) GetInstanceType() info.InstanceType {
-	machineType, err := metadata.Get("instance/machine-type")
-	if err != nil {
+func (self *provider) GetInstanceType() info.InstanceType {
+	return self.GetInstanceTypeWithContext(context.Background())
+}
+
+func (self *provider) GetInstanceTypeWithContext(ctx context.Context) info.InstanceType {
+	machineType, err := self.getMetadata(ctx, "instance/machine-type")
+	if err != nil || machineType == "" {
 		return info.UnknownInstance
 	}
 
-	responseParts := strings.Split(machineType, "/") // Extract the instance name from the machine type.
+	// The machine type is of the form projects/<project>/machineTypes/<name>.
+	responseParts := strings.Split(machineType, "/")
 	return info.InstanceType(responseParts[len(responseParts)-1])
 }
 
-func (provider) GetInstanceID() info.InstanceID {
-	instanceID, err := metadata.Get("instance/id")
-	if err != nil {
-		return info.UnknownInstance
+func (self *provider) GetInstanceID() info.InstanceID {
+	return self.GetInstanceIDWithContext(context.Background())
+}
+
+func (self *provider) GetInstanceIDWithContext(ctx context.Context) info.InstanceID {
+	instanceID, err := self.getMetadata(ctx, "instance/id")
+	if err != nil || instanceID == "" {
+		return info.UnNamedInstance
 	}
-	return info.InstanceID(info.InstanceType(instanceID))
+	return info.InstanceID(instanceID)
 }
@@ -0,0 +1,92 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
+
+func newFakeMetadataServer(flavor string) *httptest.Server {
+	metadata := map[string]string{
+		"/instance/machine-type": "projects/123456789/machineTypes/n1-standard-4",
+		"/instance/id":           "4520031799277581759",
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, ok := metadata[r.URL.Path]
+		if !ok || r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Metadata-Flavor", flavor)
+		fmt.Fprint(w, value)
+	}))
+}
+
+// writeProductName writes the DMI product name to a temporary file and returns
+// its path.
+func writeProductName(t *testing.T, productName string) string {
+	f, err := ioutil.TempFile("", "product_name")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(productName + "\n"); err != nil {
+		t.Fatalf("failed to write %s: %v", f.Name(), err)
+	}
+	return f.Name()
+}
+
+func TestGceProvider(t *testing.T) {
+	server := newFakeMetadataServer("Google")
+	defer server.Close()
+	productNameFile := writeProductName(t, "Google Compute Engine")
+	defer os.Remove(productNameFile)
+
+	p := newProvider(productNameFile, server.URL+"/")
+	if !p.IsActiveProvider() {
+		t.Fatalf("expected GCE to be the active provider")
+	}
+	if instanceType := p.GetInstanceType(); instanceType != "n1-standard-4" {
+		t.Errorf("expected instance type %q, got %q", "n1-standard-4", instanceType)
+	}
+	if instanceID := p.GetInstanceID(); instanceID != "4520031799277581759" {
+		t.Errorf("expected instance ID %q, got %q", "4520031799277581759", instanceID)
+	}
+}
+
+func TestGceProviderInactive(t *testing.T) {
+	server := newFakeMetadataServer("")
+	defer server.Close()
+	productNameFile := writeProductName(t, "Standard PC (Q35 + ICH9, 2009)")
+	defer os.Remove(productNameFile)
+
+	p := newProvider(productNameFile, server.URL+"/")
+	if p.IsActiveProvider() {
+		t.Errorf("expected GCE not to be the active provider")
+	}
+	if instanceType := p.GetInstanceType(); instanceType != info.UnknownInstance {
+		t.Errorf("expected instance type %q, got %q", info.UnknownInstance, instanceType)
+	}
+	if instanceID := p.GetInstanceID(); instanceID != info.UnNamedInstance {
+		t.Errorf("expected instance ID %q, got %q", info.UnNamedInstance, instanceID)
+	}
+}
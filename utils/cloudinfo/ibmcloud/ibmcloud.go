@@ -0,0 +1,153 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ibmcloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+	"github.com/matthewygf/cadvisor/utils/cloudinfo"
+
+	"k8s.io/klog"
+)
+
+const (
+	// IBM Cloud VPC instance metadata service endpoint. It is only reachable
+	// from inside a VPC instance with the metadata service enabled.
+	metadataURL = "http://169.254.169.254/"
+	// Version of the metadata service API requested.
+	metadataVersion = "2022-03-01"
+	// Lifetime requested for the token, in seconds.
+	tokenExpiry = 300
+)
+
+func init() {
+	cloudinfo.RegisterCloudProvider(info.IBMCloud, newProvider(metadataURL))
+}
+
+// instanceMetadata is the subset of the instance metadata document we use.
+type instanceMetadata struct {
+	ID      string `json:"id"`
+	Profile struct {
+		Name string `json:"name"`
+	} `json:"profile"`
+}
+
+type provider struct {
+	client *cloudinfo.MetadataClient
+
+	metadata cloudinfo.MetadataCache
+}
+
+var _ cloudinfo.CloudProvider = &provider{}
+var _ cloudinfo.CloudProviderWithContext = &provider{}
+
+func newProvider(metadataURL string) *provider {
+	return &provider{client: cloudinfo.NewMetadataClient(metadataURL, nil)}
+}
+
+func (self *provider) getMetadata(ctx context.Context) *instanceMetadata {
+	metadata, err := self.metadata.Get(func() (interface{}, error) {
+		return self.fetchMetadata(ctx)
+	})
+	if err != nil {
+		klog.V(2).Infof("Failed to query IBM Cloud instance metadata: %v", err)
+		return nil
+	}
+	return metadata.(*instanceMetadata)
+}
+
+// fetchMetadata obtains a token for the metadata service, then fetches the
+// instance metadata with it.
+func (self *provider) fetchMetadata(ctx context.Context) (*instanceMetadata, error) {
+	token, err := self.fetchToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata token: %v", err)
+	}
+
+	req, err := self.client.NewRequest(ctx, "GET", "metadata/v1/instance?version="+metadataVersion, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	var metadata instanceMetadata
+	if err := self.client.DoJSON(req, &metadata); err != nil {
+		return nil, err
+	}
+	if metadata.ID == "" {
+		return nil, fmt.Errorf("no instance id in instance metadata from %s", req.URL)
+	}
+	return &metadata, nil
+}
+
+func (self *provider) fetchToken(ctx context.Context) (string, error) {
+	body, err := json.Marshal(map[string]int{"expires_in": tokenExpiry})
+	if err != nil {
+		return "", err
+	}
+	req, err := self.client.NewRequest(ctx, "PUT", "instance_identity/v1/token?version="+metadataVersion, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "ibm")
+	req.Header.Set("Content-Type", "application/json")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := self.client.DoJSON(req, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("no access token in response from %s", req.URL)
+	}
+	return token.AccessToken, nil
+}
+
+func (self *provider) IsActiveProvider() bool {
+	return self.IsActiveProviderWithContext(context.Background())
+}
+
+func (self *provider) IsActiveProviderWithContext(ctx context.Context) bool {
+	return self.getMetadata(ctx) != nil
+}
+
+func (self *provider) GetInstanceType() info.InstanceType {
+	return self.GetInstanceTypeWithContext(context.Background())
+}
+
+func (self *provider) GetInstanceTypeWithContext(ctx context.Context) info.InstanceType {
+	metadata := self.getMetadata(ctx)
+	if metadata == nil || metadata.Profile.Name == "" {
+		return info.UnknownInstance
+	}
+	return info.InstanceType(metadata.Profile.Name)
+}
+
+func (self *provider) GetInstanceID() info.InstanceID {
+	return self.GetInstanceIDWithContext(context.Background())
+}
+
+func (self *provider) GetInstanceIDWithContext(ctx context.Context) info.InstanceID {
+	metadata := self.getMetadata(ctx)
+	if metadata == nil {
+		return info.UnNamedInstance
+	}
+	return info.InstanceID(metadata.ID)
+}
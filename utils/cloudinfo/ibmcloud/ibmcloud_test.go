@@ -0,0 +1,129 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ibmcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
+
+const (
+	testToken      = "eyJhbGciOiJSUzI1NiJ9"
+	testInstance   = `{"id": "0717_6c5dcf5a-2d8b-4f4f-b0c9-8e9b1f0a2c3d", "name": "worker-1", "profile": {"name": "bx2-2x8"}, "zone": {"name": "us-south-1"}}`
+	testInstanceID = "0717_6c5dcf5a-2d8b-4f4f-b0c9-8e9b1f0a2c3d"
+)
+
+// fakeMetadataHandler serves a token for PUT requests to the token endpoint,
+// and the instance metadata for GET requests authorized with it.
+func fakeMetadataHandler(tokenStatus int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "PUT" && r.URL.Path == "/instance_identity/v1/token":
+			var body struct {
+				ExpiresIn int `json:"expires_in"`
+			}
+			if r.Header.Get("Metadata-Flavor") != "ibm" || json.NewDecoder(r.Body).Decode(&body) != nil || body.ExpiresIn == 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(tokenStatus)
+			fmt.Fprintf(w, `{"access_token": %q, "expires_in": %d}`, testToken, body.ExpiresIn)
+		case r.Method == "GET" && r.URL.Path == "/metadata/v1/instance":
+			if r.Header.Get("Authorization") != "Bearer "+testToken {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, testInstance)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestIBMCloudProvider(t *testing.T) {
+	server := httptest.NewServer(fakeMetadataHandler(http.StatusOK))
+	defer server.Close()
+
+	p := newProvider(server.URL + "/")
+	if !p.IsActiveProvider() {
+		t.Fatalf("expected IBM Cloud to be the active provider")
+	}
+	if instanceType := p.GetInstanceType(); instanceType != "bx2-2x8" {
+		t.Errorf("expected instance type %q, got %q", "bx2-2x8", instanceType)
+	}
+	if instanceID := p.GetInstanceID(); instanceID != testInstanceID {
+		t.Errorf("expected instance ID %q, got %q", testInstanceID, instanceID)
+	}
+}
+
+func TestIBMCloudProviderInactive(t *testing.T) {
+	for _, handler := range []http.Handler{
+		// Token endpoint failing.
+		fakeMetadataHandler(http.StatusForbidden),
+		// Other clouds serving their own metadata on the same address.
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}),
+		// Token served, but no instance metadata.
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "PUT" {
+				fmt.Fprintf(w, `{"access_token": %q}`, testToken)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}),
+	} {
+		server := httptest.NewServer(handler)
+
+		p := newProvider(server.URL + "/")
+		if p.IsActiveProvider() {
+			t.Errorf("expected IBM Cloud not to be the active provider")
+		}
+		if instanceType := p.GetInstanceType(); instanceType != info.UnknownInstance {
+			t.Errorf("expected instance type %q, got %q", info.UnknownInstance, instanceType)
+		}
+		if instanceID := p.GetInstanceID(); instanceID != info.UnNamedInstance {
+			t.Errorf("expected instance ID %q, got %q", info.UnNamedInstance, instanceID)
+		}
+		server.Close()
+	}
+}
+
+func TestIBMCloudProviderRetriesAfterFailure(t *testing.T) {
+	fail := true
+	handler := fakeMetadataHandler(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	p := newProvider(server.URL + "/")
+	if p.IsActiveProvider() {
+		t.Errorf("expected IBM Cloud not to be the active provider while the metadata server fails")
+	}
+	fail = false
+	if !p.IsActiveProvider() {
+		t.Errorf("expected IBM Cloud to be the active provider once the metadata server answers")
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metadataTimeout bounds each request to a metadata server, so that detection
+// fails fast when not running on the cloud of the server.
+const metadataTimeout = 2 * time.Second
+
+// MetadataClient queries the metadata server of a cloud provider.
+type MetadataClient struct {
+	url    string
+	client *http.Client
+}
+
+// NewMetadataClient returns a client querying the metadata server at url,
+// which the paths of requests are relative to. A nil transport selects a
+// transport that does not use proxies, metadata servers are only reachable
+// directly.
+func NewMetadataClient(url string, transport http.RoundTripper) *MetadataClient {
+	if transport == nil {
+		transport = &http.Transport{Proxy: nil}
+	}
+	return &MetadataClient{
+		url: url,
+		client: &http.Client{
+			Timeout:   metadataTimeout,
+			Transport: transport,
+		},
+	}
+}
+
+// MetadataStatusError is returned for a response from the metadata server
+// with a status other than 2xx.
+type MetadataStatusError struct {
+	URL        string
+	Status     string
+	StatusCode int
+}
+
+func (self *MetadataStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %q from %s", self.Status, self.URL)
+}
+
+// NewRequest returns a request of the given path, relative to the URL of the
+// metadata server, bound to ctx.
+func (self *MetadataClient) NewRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, self.url+path, body)
+	if err != nil {
+		return nil, err
+	}
+	return req.WithContext(ctx), nil
+}
+
+// Do sends req and returns the header and the body of the response.
+func (self *MetadataClient) Do(req *http.Request) (http.Header, []byte, error) {
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, &MetadataStatusError{URL: req.URL.String(), Status: resp.Status, StatusCode: resp.StatusCode}
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Header, data, nil
+}
+
+// DoJSON sends req and decodes the JSON body of the response into v.
+func (self *MetadataClient) DoJSON(req *http.Request, v interface{}) error {
+	_, data, err := self.Do(req)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %v", req.URL, err)
+	}
+	return nil
+}
+
+// GetString returns the value of the given metadata path, with surrounding
+// white space removed.
+func (self *MetadataClient) GetString(ctx context.Context, path string) (string, error) {
+	req, err := self.NewRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return "", err
+	}
+	_, data, err := self.Do(req)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// MetadataCache keeps the metadata of a cloud provider once it was fetched
+// successfully, so that it is shared by all methods of the provider. A failed
+// fetch is retried by the next call.
+type MetadataCache struct {
+	lock     sync.Mutex
+	metadata interface{}
+}
+
+// Get returns the cached metadata, calling fetch to obtain it if there is
+// none yet.
+func (self *MetadataCache) Get(fetch func() (interface{}, error)) (interface{}, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.metadata == nil {
+		metadata, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		self.metadata = metadata
+	}
+	return self.metadata, nil
+}
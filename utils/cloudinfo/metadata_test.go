@@ -0,0 +1,84 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudinfo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetadataClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metadata/instance-id":
+			fmt.Fprint(w, "i-1234\n")
+		case "/metadata/private":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewMetadataClient(server.URL+"/metadata/", nil)
+	id, err := client.GetString(context.Background(), "instance-id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != "i-1234" {
+		t.Errorf("expected instance id %q, got %q", "i-1234", id)
+	}
+
+	_, err = client.GetString(context.Background(), "private")
+	statusErr, ok := err.(*MetadataStatusError)
+	if !ok {
+		t.Fatalf("expected a MetadataStatusError, got %v", err)
+	}
+	if statusErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, statusErr.StatusCode)
+	}
+}
+
+func TestMetadataCache(t *testing.T) {
+	var cache MetadataCache
+	fetches := 0
+	fetch := func() (interface{}, error) {
+		fetches++
+		if fetches == 1 {
+			return nil, fmt.Errorf("metadata server unreachable")
+		}
+		return "i-1234", nil
+	}
+
+	if _, err := cache.Get(fetch); err == nil {
+		t.Fatal("expected the failed fetch to return an error")
+	}
+	// The failed fetch is retried, the successful one is kept.
+	for i := 0; i < 2; i++ {
+		metadata, err := cache.Get(fetch)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if metadata != "i-1234" {
+			t.Errorf("expected metadata %q, got %v", "i-1234", metadata)
+		}
+	}
+	if fetches != 2 {
+		t.Errorf("expected 2 fetches, got %d", fetches)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+	"github.com/matthewygf/cadvisor/utils/cloudinfo"
+
+	"k8s.io/klog"
+)
+
+// OCI instance metadata service endpoint. It is only reachable from inside an
+// OCI instance.
+const metadataURL = "http://169.254.169.254/opc/"
+
+func init() {
+	cloudinfo.RegisterCloudProvider(info.OCI, newProvider(metadataURL))
+}
+
+// instanceMetadata is the subset of the instance metadata document we use.
+type instanceMetadata struct {
+	ID    string `json:"id"`
+	Shape string `json:"shape"`
+}
+
+type provider struct {
+	client *cloudinfo.MetadataClient
+
+	metadata cloudinfo.MetadataCache
+}
+
+var _ cloudinfo.CloudProvider = &provider{}
+var _ cloudinfo.CloudProviderWithContext = &provider{}
+
+func newProvider(metadataURL string) *provider {
+	return &provider{client: cloudinfo.NewMetadataClient(metadataURL, nil)}
+}
+
+func (self *provider) getMetadata(ctx context.Context) *instanceMetadata {
+	metadata, err := self.metadata.Get(func() (interface{}, error) {
+		return self.fetchMetadata(ctx)
+	})
+	if err != nil {
+		klog.V(2).Infof("Failed to query OCI instance metadata: %v", err)
+		return nil
+	}
+	return metadata.(*instanceMetadata)
+}
+
+// fetchMetadata fetches the instance metadata from the v2 endpoint, falling
+// back to the v1 endpoint on instances where v2 is not enabled.
+func (self *provider) fetchMetadata(ctx context.Context) (*instanceMetadata, error) {
+	metadata, err := self.fetchInstance(ctx, "v2")
+	if statusErr, ok := err.(*cloudinfo.MetadataStatusError); ok && statusErr.StatusCode == http.StatusUnauthorized {
+		klog.V(4).Infof("OCI instance metadata v2 endpoint is not authorized, falling back to v1")
+		metadata, err = self.fetchInstance(ctx, "v1")
+	}
+	return metadata, err
+}
+
+func (self *provider) fetchInstance(ctx context.Context, version string) (*instanceMetadata, error) {
+	req, err := self.client.NewRequest(ctx, "GET", version+"/instance/", nil)
+	if err != nil {
+		return nil, err
+	}
+	if version == "v2" {
+		req.Header.Set("Authorization", "Bearer Oracle")
+	}
+
+	var metadata instanceMetadata
+	if err := self.client.DoJSON(req, &metadata); err != nil {
+		return nil, err
+	}
+	// Other clouds serve their own metadata on the same address.
+	if metadata.ID == "" {
+		return nil, fmt.Errorf("no instance id in instance metadata from %s", req.URL)
+	}
+	return &metadata, nil
+}
+
+func (self *provider) IsActiveProvider() bool {
+	return self.IsActiveProviderWithContext(context.Background())
+}
+
+func (self *provider) IsActiveProviderWithContext(ctx context.Context) bool {
+	return self.getMetadata(ctx) != nil
+}
+
+func (self *provider) GetInstanceType() info.InstanceType {
+	return self.GetInstanceTypeWithContext(context.Background())
+}
+
+func (self *provider) GetInstanceTypeWithContext(ctx context.Context) info.InstanceType {
+	metadata := self.getMetadata(ctx)
+	if metadata == nil || metadata.Shape == "" {
+		return info.UnknownInstance
+	}
+	return info.InstanceType(metadata.Shape)
+}
+
+func (self *provider) GetInstanceID() info.InstanceID {
+	return self.GetInstanceIDWithContext(context.Background())
+}
+
+func (self *provider) GetInstanceIDWithContext(ctx context.Context) info.InstanceID {
+	metadata := self.getMetadata(ctx)
+	if metadata == nil {
+		return info.UnNamedInstance
+	}
+	return info.InstanceID(metadata.ID)
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
+
+const (
+	testInstance = `{"id": "ocid1.instance.oc1.phx.abyhqljt", "shape": "VM.Standard2.1", "region": "phx"}`
+	testOCID     = "ocid1.instance.oc1.phx.abyhqljt"
+)
+
+func newFakeMetadataServer(v2Enabled bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/opc/v2/instance/":
+			if !v2Enabled || r.Header.Get("Authorization") != "Bearer Oracle" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		case "/opc/v1/instance/":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, testInstance)
+	}))
+}
+
+func TestOciProvider(t *testing.T) {
+	for _, v2Enabled := range []bool{true, false} {
+		server := newFakeMetadataServer(v2Enabled)
+
+		p := newProvider(server.URL + "/opc/")
+		if !p.IsActiveProvider() {
+			t.Errorf("expected OCI to be the active provider with v2 enabled: %v", v2Enabled)
+		}
+		if instanceType := p.GetInstanceType(); instanceType != "VM.Standard2.1" {
+			t.Errorf("expected instance type %q, got %q", "VM.Standard2.1", instanceType)
+		}
+		if instanceID := p.GetInstanceID(); instanceID != testOCID {
+			t.Errorf("expected instance ID %q, got %q", testOCID, instanceID)
+		}
+		server.Close()
+	}
+}
+
+func TestOciProviderInactive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	p := newProvider(server.URL + "/opc/")
+	if p.IsActiveProvider() {
+		t.Errorf("expected OCI not to be the active provider")
+	}
+	if instanceType := p.GetInstanceType(); instanceType != info.UnknownInstance {
+		t.Errorf("expected instance type %q, got %q", info.UnknownInstance, instanceType)
+	}
+	if instanceID := p.GetInstanceID(); instanceID != info.UnNamedInstance {
+		t.Errorf("expected instance ID %q, got %q", info.UnNamedInstance, instanceID)
+	}
+}
+
+func TestOciProviderRetriesAfterFailure(t *testing.T) {
+	metadata := newFakeMetadataServer(true)
+	defer metadata.Close()
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		metadata.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	p := newProvider(server.URL + "/opc/")
+	if p.IsActiveProvider() {
+		t.Errorf("expected OCI not to be the active provider while the metadata server fails")
+	}
+	fail = false
+	if !p.IsActiveProvider() {
+		t.Errorf("expected OCI to be the active provider once the metadata server answers")
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tencent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
+
+// fakeResponse is the body and content type served for a metadata URL.
+type fakeResponse struct {
+	contentType string
+	body        string
+}
+
+// fakeTransport answers requests for the metadata in metadata, keyed by URL,
+// and fails requests for anything else as an unreachable address would.
+type fakeTransport struct {
+	metadata map[string]fakeResponse
+}
+
+func (t *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	value, ok := t.metadata[req.URL.String()]
+	if !ok {
+		return nil, fmt.Errorf("dial tcp %s: i/o timeout", req.URL.Host)
+	}
+	header := http.Header{}
+	if value.contentType != "" {
+		header.Set("Content-Type", value.contentType)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(value.body)),
+		Request:    req,
+	}, nil
+}
+
+func TestTencentProvider(t *testing.T) {
+	p := newProvider(metadataURL, &fakeTransport{metadata: map[string]fakeResponse{
+		"http://metadata.tencentyun.com/latest/meta-data/instance-id":            {contentType: "text/plain", body: "ins-0ok1kuts"},
+		"http://metadata.tencentyun.com/latest/meta-data/instance/instance-type": {body: "S5.MEDIUM4\n"},
+	}})
+	if !p.IsActiveProvider() {
+		t.Fatalf("expected Tencent Cloud to be the active provider")
+	}
+	if instanceType := p.GetInstanceType(); instanceType != "S5.MEDIUM4" {
+		t.Errorf("expected instance type %q, got %q", "S5.MEDIUM4", instanceType)
+	}
+	if instanceID := p.GetInstanceID(); instanceID != "ins-0ok1kuts" {
+		t.Errorf("expected instance ID %q, got %q", "ins-0ok1kuts", instanceID)
+	}
+}
+
+func TestTencentProviderInactive(t *testing.T) {
+	for _, metadata := range []map[string]fakeResponse{
+		// Unreachable metadata service.
+		{},
+		// HTML error pages served with a 200 status, e.g. by a proxy.
+		{
+			"http://metadata.tencentyun.com/latest/meta-data/instance-id":            {contentType: "text/html; charset=utf-8", body: "Not Found"},
+			"http://metadata.tencentyun.com/latest/meta-data/instance/instance-type": {contentType: "text/html", body: "Not Found"},
+		},
+		{
+			"http://metadata.tencentyun.com/latest/meta-data/instance-id":            {body: "<html><body>Not Found</body></html>"},
+			"http://metadata.tencentyun.com/latest/meta-data/instance/instance-type": {body: "<!DOCTYPE html>"},
+		},
+	} {
+		p := newProvider(metadataURL, &fakeTransport{metadata: metadata})
+		if p.IsActiveProvider() {
+			t.Errorf("expected Tencent Cloud not to be the active provider for %v", metadata)
+		}
+		if instanceType := p.GetInstanceType(); instanceType != info.UnknownInstance {
+			t.Errorf("expected instance type %q, got %q", info.UnknownInstance, instanceType)
+		}
+		if instanceID := p.GetInstanceID(); instanceID != info.UnNamedInstance {
+			t.Errorf("expected instance ID %q, got %q", info.UnNamedInstance, instanceID)
+		}
+	}
+}
@@ -34,6 +34,11 @@ type CpuLoadReader interface {
 	// name is the full hierarchical name of the container.
 	// Path is an absolute filesystem path for a container under CPU cgroup hierarchy.
 	GetCpuLoad(name string, path string) (info.LoadStats, error)
+
+	// Retrieve Cpu load for several groups at once, by name. paths holds the
+	// path of each group, as for GetCpuLoad. When the load of some groups
+	// can't be read, the load of the others is returned with an error.
+	GetCpuLoadBatch(paths map[string]string) (map[string]info.LoadStats, error)
 }
 
 func New() (CpuLoadReader, error) {
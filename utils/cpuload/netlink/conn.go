@@ -18,10 +18,18 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/binary"
+	"io"
 	"os"
 	"syscall"
 )
 
+// netlinkConnection is the subset of Connection used to query task stats.
+type netlinkConnection interface {
+	WriteMessage(msg syscall.NetlinkMessage) (uint32, error)
+	ReadMessage() (syscall.NetlinkMessage, error)
+	Close() error
+}
+
 type Connection struct {
 	// netlink socket
 	fd int
@@ -69,7 +77,9 @@ func (self *Connection) Close() error {
 	return syscall.Close(self.fd)
 }
 
-func (self *Connection) WriteMessage(msg syscall.NetlinkMessage) error {
+// WriteMessage sends msg and returns the sequence number it was sent with,
+// which the kernel copies into the response.
+func (self *Connection) WriteMessage(msg syscall.NetlinkMessage) (uint32, error) {
 	w := bytes.NewBuffer(nil)
 	msg.Header.Len = uint32(syscall.NLMSG_HDRLEN + len(msg.Data))
 	msg.Header.Seq = self.seq
@@ -78,10 +88,10 @@ func (self *Connection) WriteMessage(msg syscall.NetlinkMessage) error {
 	binary.Write(w, binary.LittleEndian, msg.Header)
 	_, err := w.Write(msg.Data)
 	if err != nil {
-		return err
+		return msg.Header.Seq, err
 	}
 	_, err = self.Write(w.Bytes())
-	return err
+	return msg.Header.Seq, err
 }
 
 func (self *Connection) ReadMessage() (msg syscall.NetlinkMessage, err error) {
@@ -90,6 +100,7 @@ func (self *Connection) ReadMessage() (msg syscall.NetlinkMessage, err error) {
 		return msg, err
 	}
 	msg.Data = make([]byte, msg.Header.Len-syscall.NLMSG_HDRLEN)
-	_, err = self.rbuf.Read(msg.Data)
+	// Read the whole message, so the next one is read from its start.
+	_, err = io.ReadFull(self.rbuf, msg.Data)
 	return msg, err
 }
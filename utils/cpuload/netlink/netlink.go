@@ -18,11 +18,13 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
 	"syscall"
 
 	info "github.com/matthewygf/cadvisor/info/v1"
 	"golang.org/x/sys/unix"
+	"k8s.io/klog"
 )
 
 var (
@@ -64,7 +66,7 @@ func padding(size int, alignment int) int {
 }
 
 // Get family id for taskstats subsystem.
-func getFamilyId(conn *Connection) (uint16, error) {
+func getFamilyId(conn netlinkConnection) (uint16, error) {
 	msg := prepareFamilyMessage()
 	conn.WriteMessage(msg.toRawMsg())
 
@@ -181,18 +183,50 @@ func parseLoadStatsResp(msg syscall.NetlinkMessage) (*loadStatsResp, error) {
 	if err != nil {
 		return m, err
 	}
-	// cgroup stats response should have just one attribute.
-	// Read it directly into the stats structure.
-	var attr syscall.RtAttr
-	err = binary.Read(buf, Endian, &attr)
-	if err != nil {
-		return m, err
+	// Scan the attributes for the cgroup stats. The kernel currently sends
+	// just that one, but skip any others rather than misreading them.
+	for buf.Len() >= syscall.SizeofRtAttr {
+		var attr syscall.RtAttr
+		err = binary.Read(buf, Endian, &attr)
+		if err != nil {
+			return m, err
+		}
+		payload := int(attr.Len) - syscall.SizeofRtAttr
+		if payload < 0 || payload > buf.Len() {
+			return m, fmt.Errorf("invalid attribute length %d with %d bytes left", attr.Len, buf.Len())
+		}
+		if attr.Type == unix.CGROUPSTATS_TYPE_CGROUP_STATS {
+			var stats unix.CGroupStats
+			if payload < binary.Size(stats) {
+				return m, fmt.Errorf("cgroup stats attribute too short: %d bytes", payload)
+			}
+			err = binary.Read(buf, Endian, &stats)
+			if err != nil {
+				return m, err
+			}
+			m.Stats = info.LoadStats{
+				NrSleeping:        stats.Sleeping,
+				NrRunning:         stats.Running,
+				NrStopped:         stats.Stopped,
+				NrUninterruptible: stats.Uninterruptible,
+				NrIoWait:          stats.Io_wait,
+			}
+			return m, nil
+		}
+		// The padding of the last attribute may be missing.
+		buf.Next(payload + padding(payload, syscall.NLMSG_ALIGNTO))
 	}
-	err = binary.Read(buf, Endian, &m.Stats)
-	if err != nil {
-		return m, err
+	return m, fmt.Errorf("cgroup stats not found in the response")
+}
+
+// isConnectionError returns whether err means the connection is no longer
+// usable, as opposed to the request itself failing.
+func isConnectionError(err error) bool {
+	switch err {
+	case syscall.EBADF, syscall.ENOBUFS, syscall.ENOTCONN, syscall.EPIPE, syscall.ECONNRESET, io.EOF, io.ErrUnexpectedEOF:
+		return true
 	}
-	return m, err
+	return false
 }
 
 // Verify and return any error reported by kernel.
@@ -203,7 +237,7 @@ func verifyHeader(msg syscall.NetlinkMessage) error {
 	case syscall.NLMSG_ERROR:
 		buf := bytes.NewBuffer(msg.Data)
 		var errno int32
-		binary.Read(buf, Endian, errno)
+		binary.Read(buf, Endian, &errno)
 		return fmt.Errorf("netlink request failed with error %s", syscall.Errno(-errno))
 	}
 	return nil
@@ -213,16 +247,25 @@ func verifyHeader(msg syscall.NetlinkMessage) error {
 // id: family id for taskstats.
 // cfd: open file to path to the cgroup directory under cpu hierarchy.
 // conn: open netlink connection used to communicate with kernel.
-func getLoadStats(id uint16, cfd *os.File, conn *Connection) (info.LoadStats, error) {
+func getLoadStats(id uint16, cfd *os.File, conn netlinkConnection) (info.LoadStats, error) {
 	msg := prepareCmdMessage(id, cfd.Fd())
-	err := conn.WriteMessage(msg.toRawMsg())
+	seq, err := conn.WriteMessage(msg.toRawMsg())
 	if err != nil {
 		return info.LoadStats{}, err
 	}
 
-	resp, err := conn.ReadMessage()
-	if err != nil {
-		return info.LoadStats{}, err
+	// Skip responses to other requests, e.g. late ones to a request that
+	// failed before its response was read.
+	var resp syscall.NetlinkMessage
+	for {
+		resp, err = conn.ReadMessage()
+		if err != nil {
+			return info.LoadStats{}, err
+		}
+		if resp.Header.Seq == seq {
+			break
+		}
+		klog.V(4).Infof("Skipping netlink response with sequence number %d, expected %d", resp.Header.Seq, seq)
 	}
 
 	parsedmsg, err := parseLoadStatsResp(resp)
@@ -231,3 +274,71 @@ func getLoadStats(id uint16, cfd *os.File, conn *Connection) (info.LoadStats, er
 	}
 	return parsedmsg.Stats, nil
 }
+
+// Maximum number of taskstats requests in flight on a connection, so the
+// responses can't overflow the socket's receive buffer.
+const maxPipelinedRequests = 64
+
+// Get load stats for several task groups, pipelining the requests.
+// id: family id for taskstats.
+// cfds: open files to the cgroup directories, by name.
+// conn: open netlink connection used to communicate with kernel.
+// Stats and errors for individual task groups are added to stats and errs.
+// The returned error is a connection error, after which the task groups in
+// neither map have not been queried.
+// The kernel may answer requests in any order, responses are matched to them
+// by sequence number.
+func getLoadStatsBatch(id uint16, cfds map[string]*os.File, conn netlinkConnection, stats map[string]info.LoadStats, errs map[string]error) error {
+	names := make([]string, 0, len(cfds))
+	for name := range cfds {
+		names = append(names, name)
+	}
+	for len(names) > 0 {
+		n := len(names)
+		if n > maxPipelinedRequests {
+			n = maxPipelinedRequests
+		}
+		err := getLoadStatsPipelined(id, names[:n], cfds, conn, stats, errs)
+		if err != nil {
+			return err
+		}
+		names = names[n:]
+	}
+	return nil
+}
+
+func getLoadStatsPipelined(id uint16, names []string, cfds map[string]*os.File, conn netlinkConnection, stats map[string]info.LoadStats, errs map[string]error) error {
+	pending := make(map[uint32]string, len(names))
+	for _, name := range names {
+		msg := prepareCmdMessage(id, cfds[name].Fd())
+		seq, err := conn.WriteMessage(msg.toRawMsg())
+		if err != nil {
+			if isConnectionError(err) {
+				return err
+			}
+			errs[name] = err
+			continue
+		}
+		pending[seq] = name
+	}
+
+	for len(pending) > 0 {
+		resp, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		name, ok := pending[resp.Header.Seq]
+		if !ok {
+			klog.V(4).Infof("Skipping netlink response with unexpected sequence number %d", resp.Header.Seq)
+			continue
+		}
+		delete(pending, resp.Header.Seq)
+		parsedmsg, err := parseLoadStatsResp(resp)
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+		stats[name] = parsedmsg.Stats
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netlink
+
+import (
+	"syscall"
+	"testing"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
+
+// Payload of a CGROUPSTATS_CMD_NEW response, as returned by the kernel for a
+// cgroup with 12 sleeping, 3 running, 1 stopped, 7 uninterruptible and 2 IO
+// waiting tasks.
+var cgroupStatsPayload = []byte{
+	// Generic netlink header: cmd, version, reserved.
+	0x05, 0x01, 0x00, 0x00,
+	// Attribute header: len 44, type CGROUPSTATS_TYPE_CGROUP_STATS.
+	0x2c, 0x00, 0x01, 0x00,
+	// struct cgroupstats.
+	0x0c, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestParseLoadStatsResp(t *testing.T) {
+	msg := syscall.NetlinkMessage{
+		Header: syscall.NlMsghdr{
+			Len:  uint32(syscall.NLMSG_HDRLEN + len(cgroupStatsPayload)),
+			Type: 0x17,
+		},
+		Data: cgroupStatsPayload,
+	}
+	resp, err := parseLoadStatsResp(msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := info.LoadStats{
+		NrSleeping:        12,
+		NrRunning:         3,
+		NrStopped:         1,
+		NrUninterruptible: 7,
+		NrIoWait:          2,
+	}
+	if resp.Stats != expected {
+		t.Errorf("expected %+v, got %+v", expected, resp.Stats)
+	}
+}
+
+func TestParseLoadStatsRespError(t *testing.T) {
+	msg := syscall.NetlinkMessage{
+		Header: syscall.NlMsghdr{Type: syscall.NLMSG_ERROR},
+		// -EBADF followed by the original request header.
+		Data: []byte{0xf7, 0xff, 0xff, 0xff},
+	}
+	_, err := parseLoadStatsResp(msg)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if expected := "netlink request failed with error " + syscall.EBADF.Error(); err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err)
+	}
+}
+
+func TestParseLoadStatsRespInvalidAttribute(t *testing.T) {
+	for _, attr := range [][]byte{
+		// Length shorter than the attribute header.
+		{0x02, 0x00, 0x02, 0x00},
+		// Length beyond the end of the message.
+		{0x40, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00},
+		// Cgroup stats shorter than struct cgroupstats.
+		{0x08, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00},
+	} {
+		data := append([]byte{0x05, 0x01, 0x00, 0x00}, attr...)
+		msg := syscall.NetlinkMessage{
+			Header: syscall.NlMsghdr{
+				Len:  uint32(syscall.NLMSG_HDRLEN + len(data)),
+				Type: 0x17,
+			},
+			Data: data,
+		}
+		if _, err := parseLoadStatsResp(msg); err == nil {
+			t.Errorf("expected an error for attribute %v", attr)
+		}
+	}
+}
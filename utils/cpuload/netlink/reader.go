@@ -17,15 +17,47 @@ package netlink
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	info "github.com/matthewygf/cadvisor/info/v1"
 
+	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"k8s.io/klog"
+	"k8s.io/utils/clock"
+)
+
+const (
+	// Mount point of the cgroup v2 unified hierarchy.
+	unifiedMountpoint = "/sys/fs/cgroup"
+	// Minimum time between two reconnects, so a persistently failing socket
+	// doesn't turn every query into a reconnect.
+	minReconnectInterval = 5 * time.Second
 )
 
 type NetlinkReader struct {
 	familyId uint16
-	conn     *Connection
+
+	// Serializes requests on conn, and guards its replacement on reconnect.
+	connLock      sync.Mutex
+	conn          netlinkConnection
+	dial          func() (netlinkConnection, error)
+	clock         clock.Clock
+	lastReconnect time.Time
+
+	// Root of the unified hierarchy, empty unless running on cgroup v2.
+	unifiedRoot string
+}
+
+func dialConnection() (netlinkConnection, error) {
+	conn, err := newConnection()
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
 }
 
 func New() (*NetlinkReader, error) {
@@ -39,13 +71,21 @@ func New() (*NetlinkReader, error) {
 		return nil, fmt.Errorf("failed to get netlink family id for task stats: %s", err)
 	}
 	klog.V(4).Infof("Family id for taskstats: %d", id)
-	return &NetlinkReader{
+	reader := &NetlinkReader{
 		familyId: id,
 		conn:     conn,
-	}, nil
+		dial:     dialConnection,
+		clock:    clock.RealClock{},
+	}
+	if cgroups.IsCgroup2UnifiedMode() {
+		reader.unifiedRoot = unifiedMountpoint
+	}
+	return reader, nil
 }
 
 func (self *NetlinkReader) Stop() {
+	self.connLock.Lock()
+	defer self.connLock.Unlock()
 	if self.conn != nil {
 		self.conn.Close()
 	}
@@ -59,22 +99,142 @@ func (self *NetlinkReader) Start() error {
 // Returns instantaneous number of running tasks in a group.
 // Caller can use historical data to calculate cpu load.
 // path is an absolute filesystem path for a container under the CPU cgroup hierarchy.
+// On the cgroup v2 unified hierarchy, which has no separate CPU hierarchy, the
+// container's cgroup directory is found from its name instead.
 // NOTE: non-hierarchical load is returned. It does not include load for subcontainers.
 func (self *NetlinkReader) GetCpuLoad(name string, path string) (info.LoadStats, error) {
-	if len(path) == 0 {
-		return info.LoadStats{}, fmt.Errorf("cgroup path can not be empty!")
-	}
-
-	cfd, err := os.Open(path)
+	cfd, err := self.openCgroup(name, path)
 	if err != nil {
-		return info.LoadStats{}, fmt.Errorf("failed to open cgroup path %s: %q", path, err)
+		return info.LoadStats{}, err
 	}
 	defer cfd.Close()
 
+	self.connLock.Lock()
+	defer self.connLock.Unlock()
 	stats, err := getLoadStats(self.familyId, cfd, self.conn)
+	if err != nil && isConnectionError(err) {
+		// Retry once on a fresh connection.
+		if reconnectErr := self.reconnect(); reconnectErr != nil {
+			klog.V(4).Infof("Failed to reconnect netlink connection: %v", reconnectErr)
+			return info.LoadStats{}, err
+		}
+		stats, err = getLoadStats(self.familyId, cfd, self.conn)
+	}
 	if err != nil {
 		return info.LoadStats{}, err
 	}
-	klog.V(4).Infof("Task stats for %q: %+v", path, stats)
+	klog.V(4).Infof("Task stats for %q: %+v", cfd.Name(), stats)
 	return stats, nil
 }
+
+// BatchError holds the errors for the cgroups GetCpuLoadBatch failed to get the
+// load of, by name.
+type BatchError map[string]error
+
+func (self BatchError) Error() string {
+	names := make([]string, 0, len(self))
+	for name := range self {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errs := make([]string, len(names))
+	for i, name := range names {
+		errs[i] = fmt.Sprintf("%q: %v", name, self[name])
+	}
+	return fmt.Sprintf("failed to get load for %d cgroups: %s", len(self), strings.Join(errs, ", "))
+}
+
+// Returns instantaneous number of running tasks for several groups, like
+// GetCpuLoad, by name. paths holds the cgroup path of each group by name.
+// The requests are pipelined on the connection rather than made one by one.
+// The kernel does not necessarily answer them in order, responses are matched
+// to requests by netlink sequence number.
+// If the load of some groups could not be read, the load of the others is
+// returned along with a BatchError.
+func (self *NetlinkReader) GetCpuLoadBatch(paths map[string]string) (map[string]info.LoadStats, error) {
+	stats := make(map[string]info.LoadStats, len(paths))
+	errs := BatchError{}
+	cfds := make(map[string]*os.File, len(paths))
+	defer func() {
+		for _, cfd := range cfds {
+			cfd.Close()
+		}
+	}()
+	for name, path := range paths {
+		cfd, err := self.openCgroup(name, path)
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+		cfds[name] = cfd
+	}
+
+	self.connLock.Lock()
+	defer self.connLock.Unlock()
+	err := getLoadStatsBatch(self.familyId, cfds, self.conn, stats, errs)
+	if err != nil && isConnectionError(err) {
+		// Retry the remaining groups once on a fresh connection.
+		if reconnectErr := self.reconnect(); reconnectErr != nil {
+			klog.V(4).Infof("Failed to reconnect netlink connection: %v", reconnectErr)
+		} else {
+			err = getLoadStatsBatch(self.familyId, unqueried(cfds, stats, errs), self.conn, stats, errs)
+		}
+	}
+	if err != nil {
+		for name := range unqueried(cfds, stats, errs) {
+			errs[name] = err
+		}
+	}
+
+	if len(errs) > 0 {
+		return stats, errs
+	}
+	return stats, nil
+}
+
+// unqueried returns the files of the groups that have neither stats nor an
+// error.
+func unqueried(cfds map[string]*os.File, stats map[string]info.LoadStats, errs BatchError) map[string]*os.File {
+	remaining := make(map[string]*os.File)
+	for name, cfd := range cfds {
+		_, hasStats := stats[name]
+		_, hasErr := errs[name]
+		if !hasStats && !hasErr {
+			remaining[name] = cfd
+		}
+	}
+	return remaining
+}
+
+// reconnect replaces the connection. Must be called with connLock held.
+func (self *NetlinkReader) reconnect() error {
+	now := self.clock.Now()
+	if !self.lastReconnect.IsZero() && now.Sub(self.lastReconnect) < minReconnectInterval {
+		return fmt.Errorf("last reconnect was less than %v ago", minReconnectInterval)
+	}
+	self.lastReconnect = now
+
+	conn, err := self.dial()
+	if err != nil {
+		return fmt.Errorf("failed to create a new connection: %s", err)
+	}
+	self.conn.Close()
+	self.conn = conn
+	klog.V(2).Infof("Reconnected netlink connection for task stats")
+	return nil
+}
+
+func (self *NetlinkReader) openCgroup(name string, path string) (*os.File, error) {
+	if self.unifiedRoot != "" {
+		path = filepath.Join(self.unifiedRoot, name)
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cgroup path can not be empty!")
+	}
+
+	cfd, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cgroup path %s: %q", path, err)
+	}
+	return cfd, nil
+}
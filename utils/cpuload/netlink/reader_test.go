@@ -0,0 +1,365 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netlink
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+
+	clock "k8s.io/utils/clock/testing"
+)
+
+func TestOpenCgroup(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	v1Path := filepath.Join(root, "cpu", "docker", "abc")
+	if err := os.MkdirAll(v1Path, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := &NetlinkReader{}
+	cfd, err := reader.openCgroup("/docker/abc", v1Path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfd.Close()
+	if cfd.Name() != v1Path {
+		t.Errorf("expected %q to be opened, got %q", v1Path, cfd.Name())
+	}
+}
+
+func TestOpenCgroupUnified(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	unifiedPath := filepath.Join(root, "docker", "abc")
+	if err := os.MkdirAll(unifiedPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cpu hierarchy path does not exist on cgroup v2.
+	reader := &NetlinkReader{unifiedRoot: root}
+	cfd, err := reader.openCgroup("/docker/abc", filepath.Join(root, "cpu", "docker", "abc"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfd.Close()
+	if cfd.Name() != unifiedPath {
+		t.Errorf("expected %q to be opened, got %q", unifiedPath, cfd.Name())
+	}
+
+	if _, err := reader.openCgroup("/docker/missing", ""); err == nil {
+		t.Errorf("expected an error for a missing cgroup")
+	}
+}
+
+// fakeConnection answers every request with a canned response, or fails with
+// err.
+type fakeConnection struct {
+	err      error
+	response syscall.NetlinkMessage
+	closed   bool
+	// If set, answers requests instead of response.
+	respond func(req syscall.NetlinkMessage) syscall.NetlinkMessage
+	// Answer requests last in, first out.
+	reverse bool
+	seq     uint32
+	// Requests not answered yet, oldest first.
+	pending []syscall.NetlinkMessage
+}
+
+func (self *fakeConnection) WriteMessage(msg syscall.NetlinkMessage) (uint32, error) {
+	if self.err != nil {
+		return 0, self.err
+	}
+	self.seq++
+	msg.Header.Seq = self.seq
+	self.pending = append(self.pending, msg)
+	return self.seq, nil
+}
+
+func (self *fakeConnection) ReadMessage() (syscall.NetlinkMessage, error) {
+	if self.err != nil {
+		return syscall.NetlinkMessage{}, self.err
+	}
+	if len(self.pending) == 0 {
+		return self.response, nil
+	}
+	var req syscall.NetlinkMessage
+	if self.reverse {
+		req = self.pending[len(self.pending)-1]
+		self.pending = self.pending[:len(self.pending)-1]
+	} else {
+		req = self.pending[0]
+		self.pending = self.pending[1:]
+	}
+	resp := self.response
+	if self.respond != nil {
+		resp = self.respond(req)
+	}
+	resp.Header.Seq = req.Header.Seq
+	return resp, nil
+}
+
+func (self *fakeConnection) Close() error {
+	self.closed = true
+	return nil
+}
+
+func newFakeConnection() *fakeConnection {
+	return &fakeConnection{
+		response: syscall.NetlinkMessage{
+			Header: syscall.NlMsghdr{Type: 0x17},
+			Data:   cgroupStatsPayload,
+		},
+	}
+}
+
+func TestGetCpuLoadReconnects(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	broken := &fakeConnection{err: syscall.EBADF}
+	healthy := newFakeConnection()
+	dials := 0
+	fakeClock := clock.NewFakeClock(time.Now())
+	reader := &NetlinkReader{
+		conn: broken,
+		dial: func() (netlinkConnection, error) {
+			dials++
+			return healthy, nil
+		},
+		clock: fakeClock,
+	}
+
+	stats, err := reader.GetCpuLoad("/", root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.NrRunning != 3 || stats.NrUninterruptible != 7 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if dials != 1 {
+		t.Errorf("expected 1 reconnect, got %d", dials)
+	}
+	if !broken.closed {
+		t.Errorf("expected the broken connection to be closed")
+	}
+
+	// A connection breaking again right away is not reconnected.
+	healthy.err = syscall.ENOBUFS
+	if _, err := reader.GetCpuLoad("/", root); err != syscall.ENOBUFS {
+		t.Errorf("expected error %v, got %v", syscall.ENOBUFS, err)
+	}
+	if dials != 1 {
+		t.Errorf("expected 1 reconnect, got %d", dials)
+	}
+
+	// It is once the reconnect interval has passed.
+	fakeClock.Step(minReconnectInterval)
+	healthy = newFakeConnection()
+	stats, err = reader.GetCpuLoad("/", root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats == (info.LoadStats{}) {
+		t.Errorf("expected non-empty stats")
+	}
+	if dials != 2 {
+		t.Errorf("expected 2 reconnects, got %d", dials)
+	}
+}
+
+func TestGetCpuLoadRequestErrorNoReconnect(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	conn := newFakeConnection()
+	conn.response = syscall.NetlinkMessage{
+		Header: syscall.NlMsghdr{Type: syscall.NLMSG_ERROR},
+		Data:   []byte{0xea, 0xff, 0xff, 0xff},
+	}
+	reader := &NetlinkReader{
+		conn: conn,
+		dial: func() (netlinkConnection, error) {
+			t.Fatalf("unexpected reconnect")
+			return nil, nil
+		},
+		clock: clock.NewFakeClock(time.Now()),
+	}
+	if _, err := reader.GetCpuLoad("/", root); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestGetCpuLoadSkipsOtherResponses(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	conn := newFakeConnection()
+	// A request whose response was never read.
+	conn.pending = []syscall.NetlinkMessage{{Header: syscall.NlMsghdr{Seq: 100}}}
+	conn.respond = func(req syscall.NetlinkMessage) syscall.NetlinkMessage {
+		if req.Header.Seq == 100 {
+			return syscall.NetlinkMessage{
+				Header: syscall.NlMsghdr{Type: syscall.NLMSG_ERROR},
+				Data:   []byte{0xea, 0xff, 0xff, 0xff},
+			}
+		}
+		return conn.response
+	}
+	reader := &NetlinkReader{
+		conn:  conn,
+		clock: clock.NewFakeClock(time.Now()),
+	}
+
+	stats, err := reader.GetCpuLoad("/", root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.NrRunning != 3 || stats.NrUninterruptible != 7 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if len(conn.pending) != 0 {
+		t.Errorf("expected all responses to be read, %d are left", len(conn.pending))
+	}
+}
+
+// cgroupStatsResponse returns a taskstats response with the given stats.
+func cgroupStatsResponse(stats info.LoadStats) syscall.NetlinkMessage {
+	data := make([]byte, 8, 48)
+	copy(data, cgroupStatsPayload[:8])
+	for _, v := range []uint64{stats.NrSleeping, stats.NrRunning, stats.NrStopped, stats.NrUninterruptible, stats.NrIoWait} {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, v)
+		data = append(data, b...)
+	}
+	return syscall.NetlinkMessage{
+		Header: syscall.NlMsghdr{Type: 0x17},
+		Data:   data,
+	}
+}
+
+func TestGetCpuLoadBatch(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// Each cgroup has as many running tasks as its index, and the kernel
+	// fails the request for the last one.
+	paths := map[string]string{}
+	running := map[string]uint64{}
+	const numCgroups = maxPipelinedRequests + 3
+	for i := 0; i < numCgroups; i++ {
+		name := fmt.Sprintf("/cgroup%d", i)
+		path := filepath.Join(root, name)
+		if err := os.Mkdir(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		paths[name] = path
+		running[path] = uint64(i)
+	}
+	failing := fmt.Sprintf("/cgroup%d", numCgroups-1)
+	paths["/missing"] = filepath.Join(root, "missing")
+
+	conn := &fakeConnection{
+		reverse: true,
+		respond: func(req syscall.NetlinkMessage) syscall.NetlinkMessage {
+			// The request carries the cgroup fd after the generic netlink
+			// and attribute headers.
+			fd := binary.LittleEndian.Uint32(req.Data[8:12])
+			path, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd))
+			if err != nil {
+				t.Fatalf("failed to resolve fd %d: %v", fd, err)
+			}
+			if path == paths[failing] {
+				return syscall.NetlinkMessage{
+					Header: syscall.NlMsghdr{Type: syscall.NLMSG_ERROR},
+					Data:   []byte{0xea, 0xff, 0xff, 0xff},
+				}
+			}
+			return cgroupStatsResponse(info.LoadStats{NrRunning: running[path]})
+		},
+	}
+	reader := &NetlinkReader{
+		conn:  conn,
+		clock: clock.NewFakeClock(time.Now()),
+	}
+
+	stats, err := reader.GetCpuLoadBatch(paths)
+	batchErr, ok := err.(BatchError)
+	if !ok {
+		t.Fatalf("expected a BatchError, got %v", err)
+	}
+	if len(batchErr) != 2 || batchErr[failing] == nil || batchErr["/missing"] == nil {
+		t.Errorf("expected errors for %q and %q, got %v", failing, "/missing", batchErr)
+	}
+	if len(stats) != numCgroups-1 {
+		t.Errorf("expected stats for %d cgroups, got %d", numCgroups-1, len(stats))
+	}
+	for name, s := range stats {
+		if expected := running[paths[name]]; s.NrRunning != expected {
+			t.Errorf("expected %d running tasks for %q, got %d", expected, name, s.NrRunning)
+		}
+	}
+}
+
+func TestGetCpuLoadBatchReconnects(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	healthy := newFakeConnection()
+	reader := &NetlinkReader{
+		conn: &fakeConnection{err: syscall.EBADF},
+		dial: func() (netlinkConnection, error) {
+			return healthy, nil
+		},
+		clock: clock.NewFakeClock(time.Now()),
+	}
+	stats, err := reader.GetCpuLoadBatch(map[string]string{"/a": root, "/b": root})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stats) != 2 || stats["/a"].NrRunning != 3 || stats["/b"].NrRunning != 3 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package statsbatch encodes batches of container stats into a compact
+// stream. The stream is gzip-compressed and holds one JSON-encoded
+// info.ContainerStats per record, each prefixed by its length as a 4 byte
+// big-endian integer.
+package statsbatch
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
+
+// maxRecordSize bounds the size of a single record when decoding, to avoid
+// allocating arbitrarily large buffers for corrupt streams.
+const maxRecordSize = 64 << 20
+
+// Encode writes the stats to w as a single compressed batch.
+func Encode(w io.Writer, stats []*info.ContainerStats) error {
+	gz := gzip.NewWriter(w)
+	if err := writeRecords(gz, stats); err != nil {
+		gz.Close()
+		return err
+	}
+	return gz.Close()
+}
+
+// writeRecords writes the length-prefixed JSON records of the stats to gz.
+func writeRecords(gz *gzip.Writer, stats []*info.ContainerStats) error {
+	var length [4]byte
+	for i, s := range stats {
+		record, err := json.Marshal(s)
+		if err != nil {
+			return fmt.Errorf("failed to encode stats %d: %v", i, err)
+		}
+		binary.BigEndian.PutUint32(length[:], uint32(len(record)))
+		if _, err := gz.Write(length[:]); err != nil {
+			return err
+		}
+		if _, err := gz.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode reads a batch written by Encode from r.
+func Decode(r io.Reader) ([]*info.ContainerStats, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	br := bufio.NewReader(gz)
+
+	stats := []*info.ContainerStats{}
+	var length [4]byte
+	for {
+		if _, err := io.ReadFull(br, length[:]); err != nil {
+			if err == io.EOF {
+				return stats, nil
+			}
+			return nil, fmt.Errorf("failed to read length of record %d: %v", len(stats), err)
+		}
+		size := binary.BigEndian.Uint32(length[:])
+		if size > maxRecordSize {
+			return nil, fmt.Errorf("record %d is too large: %d bytes", len(stats), size)
+		}
+		record := make([]byte, size)
+		if _, err := io.ReadFull(br, record); err != nil {
+			return nil, fmt.Errorf("failed to read record %d: %v", len(stats), err)
+		}
+		s := &info.ContainerStats{}
+		if err := json.Unmarshal(record, s); err != nil {
+			return nil, fmt.Errorf("failed to decode record %d: %v", len(stats), err)
+		}
+		stats = append(stats, s)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsbatch
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	info "github.com/matthewygf/cadvisor/info/v1"
+)
+
+func TestRoundTrip(t *testing.T) {
+	now := time.Unix(1500000000, 0).UTC()
+	stats := []*info.ContainerStats{
+		{
+			Timestamp: now,
+			Cpu: info.CpuStats{
+				Usage: info.CpuUsage{Total: 1000, User: 600, System: 400, PerCpu: []uint64{300, 700}},
+			},
+			Memory: info.MemoryStats{Usage: 4096, WorkingSet: 2048},
+		},
+		{
+			Timestamp: now.Add(time.Second),
+			Network: info.NetworkStats{
+				Interfaces: []info.InterfaceStats{{Name: "eth0", RxBytes: 100, TxBytes: 200}},
+			},
+			Filesystem: []info.FsStats{{Device: "/dev/sda1", Limit: 1000, Usage: 10}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, stats); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(stats) {
+		t.Fatalf("expected %d stats, got %d", len(stats), len(decoded))
+	}
+	for i := range stats {
+		if !stats[i].Eq(decoded[i]) {
+			t.Errorf("expected stats %+v, got %+v", stats[i], decoded[i])
+		}
+	}
+}
+
+func TestRoundTripEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 0 {
+		t.Errorf("expected no stats, got %+v", decoded)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, []*info.ContainerStats{{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(bytes.NewReader(buf.Bytes()[:buf.Len()-10])); err == nil {
+		t.Error("expected an error for a truncated batch")
+	}
+}
@@ -15,7 +15,9 @@
 package fakesysfs
 
 import (
+	"fmt"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/matthewygf/cadvisor/utils/sysfs"
@@ -53,6 +55,13 @@ func (self *FileInfo) Sys() interface{} {
 type FakeSysFs struct {
 	info  FileInfo
 	cache sysfs.CacheInfo
+	// Caches of each cpu by name, if set with SetCaches.
+	caches map[int]map[string]sysfs.CacheInfo
+	// Link of the network devices, if set with SetNetworkLink.
+	networkLinkSet bool
+	speed          string
+	duplex         string
+	linkErr        error
 }
 
 func (self *FakeSysFs) GetBlockDevices() ([]os.FileInfo, error) {
@@ -85,22 +94,69 @@ func (self *FakeSysFs) GetNetworkMtu(name string) (string, error) {
 }
 
 func (self *FakeSysFs) GetNetworkSpeed(name string) (string, error) {
+	if self.networkLinkSet {
+		return self.speed, self.linkErr
+	}
 	return "1000\n", nil
 }
 
+func (self *FakeSysFs) GetNetworkDuplex(name string) (string, error) {
+	if self.networkLinkSet {
+		return self.duplex, self.linkErr
+	}
+	return "full\n", nil
+}
+
+// SetNetworkLink sets the contents of the speed and duplex files of the
+// network devices, or the error reading them returns.
+func (self *FakeSysFs) SetNetworkLink(speed, duplex string, err error) {
+	self.networkLinkSet = true
+	self.speed = speed
+	self.duplex = duplex
+	self.linkErr = err
+}
+
 func (self *FakeSysFs) GetNetworkStatValue(name string, stat string) (uint64, error) {
 	return 1024, nil
 }
 
 func (self *FakeSysFs) GetCaches(id int) ([]os.FileInfo, error) {
+	if self.caches != nil {
+		caches, ok := self.caches[id]
+		if !ok {
+			return nil, &os.PathError{Op: "open", Path: fmt.Sprintf("cpu%d/cache", id), Err: os.ErrNotExist}
+		}
+		names := make([]string, 0, len(caches))
+		for name := range caches {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		infos := make([]os.FileInfo, 0, len(names))
+		for _, name := range names {
+			infos = append(infos, &FileInfo{EntryName: name})
+		}
+		return infos, nil
+	}
 	self.info.EntryName = "index0"
 	return []os.FileInfo{&self.info}, nil
 }
 
 func (self *FakeSysFs) GetCacheInfo(cpu int, cache string) (sysfs.CacheInfo, error) {
+	if self.caches != nil {
+		return self.caches[cpu][cache], nil
+	}
 	return self.cache, nil
 }
 
+// SetCaches sets the caches of the given cpu by name. Once set, only the
+// cpus with caches set have caches.
+func (self *FakeSysFs) SetCaches(cpu int, caches map[string]sysfs.CacheInfo) {
+	if self.caches == nil {
+		self.caches = make(map[int]map[string]sysfs.CacheInfo)
+	}
+	self.caches[cpu] = caches
+}
+
 func (self *FakeSysFs) SetCacheInfo(cache sysfs.CacheInfo) {
 	self.cache = cache
 }
@@ -41,6 +41,9 @@ type CacheInfo struct {
 	Level int
 	// number of cpus that can access this cache.
 	Cpus int
+	// ids of the cpus that can access this cache, from shared_cpu_list.
+	// Nil if the kernel does not report it.
+	SharedCpus []int
 }
 
 // Abstracts the lowest level calls to sysfs.
@@ -58,6 +61,7 @@ type SysFs interface {
 	GetNetworkAddress(string) (string, error)
 	GetNetworkMtu(string) (string, error)
 	GetNetworkSpeed(string) (string, error)
+	GetNetworkDuplex(string) (string, error)
 	GetNetworkStatValue(dev string, stat string) (uint64, error)
 
 	// Get directory information for available caches accessible to given cpu.
@@ -148,6 +152,14 @@ func (self *realSysFs) GetNetworkSpeed(name string) (string, error) {
 	return string(speed), nil
 }
 
+func (self *realSysFs) GetNetworkDuplex(name string) (string, error) {
+	duplex, err := ioutil.ReadFile(path.Join(netDir, name, "/duplex"))
+	if err != nil {
+		return "", err
+	}
+	return string(duplex), nil
+}
+
 func (self *realSysFs) GetNetworkStatValue(dev string, stat string) (uint64, error) {
 	statPath := path.Join(netDir, dev, "/statistics", stat)
 	out, err := ioutil.ReadFile(statPath)
@@ -194,8 +206,50 @@ func getCpuCount(cache string) (count int, err error) {
 	return
 }
 
+// parseCpuList parses a cpu list such as "0-3,8,10-11" into the ids of the
+// cpus it contains.
+func parseCpuList(list string) ([]int, error) {
+	cpus := []int{}
+	list = strings.TrimSpace(list)
+	if list == "" {
+		return cpus, nil
+	}
+	for _, r := range strings.Split(list, ",") {
+		bounds := strings.SplitN(r, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse cpu list %q: %v", list, err)
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse cpu list %q: %v", list, err)
+			}
+		}
+		if last < first {
+			return nil, fmt.Errorf("invalid range %q in cpu list %q", r, list)
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+func getSharedCpus(cache string) ([]int, error) {
+	out, err := ioutil.ReadFile(path.Join(cache, "/shared_cpu_list"))
+	if err != nil {
+		return nil, err
+	}
+	return parseCpuList(string(out))
+}
+
 func (self *realSysFs) GetCacheInfo(id int, name string) (CacheInfo, error) {
-	cachePath := fmt.Sprintf("%s%d/cache/%s", cacheDir, id, name)
+	return getCacheInfo(fmt.Sprintf("%s%d/cache/%s", cacheDir, id, name))
+}
+
+func getCacheInfo(cachePath string) (CacheInfo, error) {
 	out, err := ioutil.ReadFile(path.Join(cachePath, "/size"))
 	if err != nil {
 		return CacheInfo{}, err
@@ -226,11 +280,17 @@ func (self *realSysFs) GetCacheInfo(id int, name string) (CacheInfo, error) {
 	if err != nil {
 		return CacheInfo{}, err
 	}
+	// Older kernels only report shared_cpu_map.
+	sharedCpus, err := getSharedCpus(cachePath)
+	if err != nil && !os.IsNotExist(err) {
+		return CacheInfo{}, err
+	}
 	return CacheInfo{
-		Size:  size,
-		Level: level,
-		Type:  cacheType,
-		Cpus:  cpuCount,
+		Size:       size,
+		Level:      level,
+		Type:       cacheType,
+		Cpus:       cpuCount,
+		SharedCpus: sharedCpus,
 	}, nil
 }
 
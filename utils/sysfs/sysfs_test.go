@@ -0,0 +1,89 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseCpuList(t *testing.T) {
+	for list, expected := range map[string][]int{
+		"0\n":          {0},
+		"0-3":          {0, 1, 2, 3},
+		"0,12":         {0, 12},
+		"0-1,8-9,16\n": {0, 1, 8, 9, 16},
+		"":             {},
+	} {
+		cpus, err := parseCpuList(list)
+		if err != nil {
+			t.Errorf("failed to parse cpu list %q: %v", list, err)
+			continue
+		}
+		if !reflect.DeepEqual(cpus, expected) {
+			t.Errorf("expected cpus %v for %q, got %v", expected, list, cpus)
+		}
+	}
+	for _, list := range []string{"a", "3-1", "0-b"} {
+		if _, err := parseCpuList(list); err == nil {
+			t.Errorf("expected an error for cpu list %q", list)
+		}
+	}
+}
+
+func writeCacheFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetCacheInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "index3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeCacheFiles(t, dir, map[string]string{
+		"size":           "8192K\n",
+		"level":          "3\n",
+		"type":           "Unified\n",
+		"shared_cpu_map": "00000000,00000033\n",
+	})
+
+	// Older kernels don't have shared_cpu_list.
+	cache, err := getCacheInfo(dir)
+	if err != nil {
+		t.Fatalf("failed to get cache info: %v", err)
+	}
+	expected := CacheInfo{Size: 8192 * 1024, Level: 3, Type: "Unified", Cpus: 4}
+	if !reflect.DeepEqual(cache, expected) {
+		t.Errorf("expected cache %+v, got %+v", expected, cache)
+	}
+
+	writeCacheFiles(t, dir, map[string]string{"shared_cpu_list": "0-1,4-5\n"})
+	cache, err = getCacheInfo(dir)
+	if err != nil {
+		t.Fatalf("failed to get cache info: %v", err)
+	}
+	expected.SharedCpus = []int{0, 1, 4, 5}
+	if !reflect.DeepEqual(cache, expected) {
+		t.Errorf("expected cache %+v, got %+v", expected, cache)
+	}
+}
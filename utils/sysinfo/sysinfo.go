@@ -22,6 +22,8 @@ import (
 
 	info "github.com/matthewygf/cadvisor/info/v1"
 	"github.com/matthewygf/cadvisor/utils/sysfs"
+
+	"k8s.io/klog"
 )
 
 var schedulerRegExp = regexp.MustCompile(`.*\[(.*)\].*`)
@@ -118,21 +120,47 @@ func GetNetworkDevices(sysfs sysfs.SysFs) ([]info.NetInfo, error) {
 			MacAddress: strings.TrimSpace(address),
 			Mtu:        mtu,
 		}
-		speed, err := sysfs.GetNetworkSpeed(name)
-		// Some devices don't set speed.
-		if err == nil {
-			var s int64
-			n, err := fmt.Sscanf(speed, "%d", &s)
-			if err != nil || n != 1 {
-				return nil, fmt.Errorf("could not parse speed from %s for device %s", speed, name)
-			}
-			netInfo.Speed = s
-		}
+		netInfo.Speed = getNetworkSpeed(sysfs, name)
+		netInfo.Duplex = getNetworkDuplex(sysfs, name)
 		netDevices = append(netDevices, netInfo)
 	}
 	return netDevices, nil
 }
 
+// getNetworkSpeed returns the link speed of the device in Mbits/s. Virtual
+// devices and devices whose link is down have no speed, the kernel either
+// fails the read or reports -1. Zero is returned for those.
+func getNetworkSpeed(sysfs sysfs.SysFs, name string) int64 {
+	speed, err := sysfs.GetNetworkSpeed(name)
+	if err != nil {
+		return 0
+	}
+	var s int64
+	n, err := fmt.Sscanf(speed, "%d", &s)
+	if err != nil || n != 1 {
+		klog.V(4).Infof("could not parse speed from %q for device %s", speed, name)
+		return 0
+	}
+	if s < 0 {
+		return 0
+	}
+	return s
+}
+
+// getNetworkDuplex returns the duplex mode of the device, "full" or "half",
+// or an empty string if unknown.
+func getNetworkDuplex(sysfs sysfs.SysFs, name string) string {
+	duplex, err := sysfs.GetNetworkDuplex(name)
+	if err != nil {
+		return ""
+	}
+	switch duplex = strings.TrimSpace(duplex); duplex {
+	case "full", "half":
+		return duplex
+	}
+	return ""
+}
+
 func GetCacheInfo(sysFs sysfs.SysFs, id int) ([]sysfs.CacheInfo, error) {
 	caches, err := sysFs.GetCaches(id)
 	if err != nil {
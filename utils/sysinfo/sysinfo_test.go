@@ -15,6 +15,8 @@
 package sysinfo
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
 
 	info "github.com/matthewygf/cadvisor/info/v1"
@@ -68,11 +70,52 @@ func TestGetNetworkDevices(t *testing.T) {
 	if eth.Speed != 1000 {
 		t.Errorf("expected device speed to be set to 1000. Found %d", eth.Speed)
 	}
+	if eth.Duplex != "full" {
+		t.Errorf("expected device duplex to be full. Found %q", eth.Duplex)
+	}
 	if eth.MacAddress != "42:01:02:03:04:f4" {
 		t.Errorf("expected mac address to be '42:01:02:03:04:f4'. Found %q", eth.MacAddress)
 	}
 }
 
+func TestGetNetworkDevicesLink(t *testing.T) {
+	for _, test := range []struct {
+		name           string
+		speed          string
+		duplex         string
+		err            error
+		expectedSpeed  int64
+		expectedDuplex string
+	}{
+		{"negotiated", "100\n", "half\n", nil, 100, "half"},
+		{"link down", "-1\n", "unknown\n", nil, 0, ""},
+		{"virtual device", "", "", fmt.Errorf("invalid argument"), 0, ""},
+		{"unparsable speed", "fast\n", "full\n", nil, 0, "full"},
+	} {
+		fakeSys := fakesysfs.FakeSysFs{}
+		fakeSys.SetEntryName("eth0")
+		fakeSys.SetNetworkLink(test.speed, test.duplex, test.err)
+		devs, err := GetNetworkDevices(&fakeSys)
+		if err != nil {
+			t.Errorf("%s: expected call to GetNetworkDevices() to succeed. Failed with %s", test.name, err)
+			continue
+		}
+		if len(devs) != 1 {
+			t.Errorf("%s: expected to get one network device. Got %d", test.name, len(devs))
+			continue
+		}
+		if devs[0].Speed != test.expectedSpeed {
+			t.Errorf("%s: expected speed %d, got %d", test.name, test.expectedSpeed, devs[0].Speed)
+		}
+		if devs[0].Duplex != test.expectedDuplex {
+			t.Errorf("%s: expected duplex %q, got %q", test.name, test.expectedDuplex, devs[0].Duplex)
+		}
+		if devs[0].Mtu != 1024 {
+			t.Errorf("%s: expected mtu to be set to 1024. Found %d", test.name, devs[0].Mtu)
+		}
+	}
+}
+
 func TestIgnoredNetworkDevices(t *testing.T) {
 	fakeSys := fakesysfs.FakeSysFs{}
 	ignoredDevices := []string{"veth1234", "lo", "docker0"}
@@ -104,7 +147,7 @@ func TestGetCacheInfo(t *testing.T) {
 	if len(caches) != 1 {
 		t.Errorf("expected to get one cache. Got %d", len(caches))
 	}
-	if caches[0] != cacheInfo {
+	if !reflect.DeepEqual(caches[0], cacheInfo) {
 		t.Errorf("expected to find cacheinfo %+v. Got %+v", cacheInfo, caches[0])
 	}
 }
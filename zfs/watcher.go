@@ -15,6 +15,9 @@ package zfs
 
 import (
 	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,8 +31,11 @@ type ZfsWatcher struct {
 	filesystem string
 	lock       *sync.RWMutex
 	cache      map[string]uint64
+	quotas     map[string]uint64
 	period     time.Duration
 	stopChan   chan struct{}
+	// getRefquotas returns the refquota of the filesystem and its children.
+	getRefquotas func(filesystem string) (map[string]uint64, error)
 }
 
 // NewThinPoolWatcher returns a new ThinPoolWatcher for the given devicemapper
@@ -37,11 +43,13 @@ type ZfsWatcher struct {
 func NewZfsWatcher(filesystem string) (*ZfsWatcher, error) {
 
 	return &ZfsWatcher{
-		filesystem: filesystem,
-		lock:       &sync.RWMutex{},
-		cache:      make(map[string]uint64),
-		period:     15 * time.Second,
-		stopChan:   make(chan struct{}),
+		filesystem:   filesystem,
+		lock:         &sync.RWMutex{},
+		cache:        make(map[string]uint64),
+		quotas:       make(map[string]uint64),
+		period:       15 * time.Second,
+		stopChan:     make(chan struct{}),
+		getRefquotas: getRefquotas,
 	}, nil
 }
 
@@ -88,11 +96,26 @@ func (w *ZfsWatcher) GetUsage(filesystem string) (uint64, error) {
 	return v, nil
 }
 
+// GetQuota gets the cached quota of the given filesystem, the smaller of its
+// quota and refquota properties, or 0 if neither is set.
+func (w *ZfsWatcher) GetQuota(filesystem string) (uint64, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	v, ok := w.quotas[filesystem]
+	if !ok {
+		return 0, fmt.Errorf("no cached value for quota of filesystem %v", filesystem)
+	}
+
+	return v, nil
+}
+
 // Refresh performs a zfs get
 func (w *ZfsWatcher) Refresh() error {
 	w.lock.Lock()
 	defer w.lock.Unlock()
 	newCache := make(map[string]uint64)
+	newQuotas := make(map[string]uint64)
 	parent, err := zfs.GetDataset(w.filesystem)
 	if err != nil {
 		klog.Errorf("encountered error getting zfs filesystem: %s: %v", w.filesystem, err)
@@ -103,11 +126,58 @@ func (w *ZfsWatcher) Refresh() error {
 		klog.Errorf("encountered error getting children of zfs filesystem: %s: %v", w.filesystem, err)
 		return err
 	}
+	refquotas, err := w.getRefquotas(w.filesystem)
+	if err != nil {
+		klog.V(4).Infof("encountered error getting refquota of children of zfs filesystem: %s: %v", w.filesystem, err)
+	}
 
 	for _, ds := range children {
 		newCache[ds.Name] = ds.Used
+		newQuotas[ds.Name] = effectiveQuota(ds.Quota, refquotas[ds.Name])
 	}
 
 	w.cache = newCache
+	w.quotas = newQuotas
 	return nil
 }
+
+// effectiveQuota returns the smaller of the set quota and refquota, 0 if
+// neither is set.
+func effectiveQuota(quota, refquota uint64) uint64 {
+	if quota == 0 || (refquota != 0 && refquota < quota) {
+		return refquota
+	}
+	return quota
+}
+
+// getRefquotas returns the refquota of the filesystem and its children, by
+// name. Filesystems without a refquota are left out.
+func getRefquotas(filesystem string) (map[string]uint64, error) {
+	out, err := exec.Command("zfs", "get", "-Hpr", "-t", "filesystem", "-o", "name,value", "refquota", filesystem).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseRefquotas(string(out))
+}
+
+// parseRefquotas parses the output of zfs get -Hp -o name,value refquota.
+func parseRefquotas(out string) (map[string]uint64, error) {
+	refquotas := make(map[string]uint64)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 2 {
+			continue
+		}
+		if fields[1] == "-" || fields[1] == "none" {
+			continue
+		}
+		refquota, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse refquota %q of %s: %v", fields[1], fields[0], err)
+		}
+		if refquota != 0 {
+			refquotas[fields[0]] = refquota
+		}
+	}
+	return refquotas, nil
+}